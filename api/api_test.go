@@ -43,6 +43,14 @@ func (m *MockRuleStore) GetRule(ctx context.Context, id string) (*database.Rule,
 	return args.Get(0).(*database.Rule), args.Error(1)
 }
 
+func (m *MockRuleStore) GetRuleByName(ctx context.Context, tenant, templateName, name string) (*database.Rule, error) {
+	args := m.Called(ctx, tenant, templateName, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.Rule), args.Error(1)
+}
+
 func (m *MockRuleStore) ListRules(ctx context.Context, offset, limit int) ([]*database.Rule, error) {
 	args := m.Called(ctx, offset, limit)
 	return args.Get(0).([]*database.Rule), args.Error(1)
@@ -63,6 +71,16 @@ func (m *MockRuleStore) SearchRules(ctx context.Context, filter database.RuleFil
 	return args.Get(0).([]*database.Rule), args.Error(1)
 }
 
+func (m *MockRuleStore) SearchRulesPage(ctx context.Context, filter database.RuleFilter, page database.PageRequest) (*database.RulePage, error) {
+	args := m.Called(ctx, filter, page)
+	return args.Get(0).(*database.RulePage), args.Error(1)
+}
+
+func (m *MockRuleStore) FacetRules(ctx context.Context, filter database.RuleFilter, fields []string, limit int) (*database.RuleFacets, error) {
+	args := m.Called(ctx, filter, fields, limit)
+	return args.Get(0).(*database.RuleFacets), args.Error(1)
+}
+
 // We also need the TemplateProvider for the rules service.
 type MockTemplateProvider struct {
 	mock.Mock
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"rulemanager/internal/database"
+	"rulemanager/internal/rules"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// DatasourceHandlers handles datasource registry API requests.
+type DatasourceHandlers struct {
+	registry    *rules.DatasourceRegistry
+	ruleService *rules.Service
+}
+
+// NewDatasourceHandlers registers datasource handlers with the API.
+func NewDatasourceHandlers(api huma.API, registry *rules.DatasourceRegistry, svc *rules.Service) {
+	h := &DatasourceHandlers{
+		registry:    registry,
+		ruleService: svc,
+	}
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-datasources",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/datasources",
+		Summary:     "List datasources",
+		Description: "Lists the datasources from the configuration and the registry. Credentials are redacted.",
+		Tags:        []string{"Datasources"},
+	}, h.ListDatasources)
+
+	huma.Register(api, huma.Operation{
+		OperationID:   "create-datasource",
+		Method:        http.MethodPost,
+		Path:          "/api/v1/datasources",
+		Summary:       "Create a datasource",
+		Description:   "Registers a datasource that template schemas can reference by name. Requires an admin when access control is enabled.",
+		Tags:          []string{"Datasources"},
+		DefaultStatus: http.StatusCreated,
+	}, h.CreateDatasource)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-datasource",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/datasources/{name}",
+		Summary:     "Get a datasource",
+		Description: "Retrieves a datasource and the schemas referencing it. Credentials are redacted.",
+		Tags:        []string{"Datasources"},
+	}, h.GetDatasource)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-datasource",
+		Method:      http.MethodPut,
+		Path:        "/api/v1/datasources/{name}",
+		Summary:     "Update a datasource",
+		Description: "Replaces a datasource. Redacted credentials (\"" + rules.RedactedSecret + "\") keep their stored value. Every template referencing the datasource uses the new settings. Requires an admin when access control is enabled.",
+		Tags:        []string{"Datasources"},
+	}, h.UpdateDatasource)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-datasource",
+		Method:      http.MethodDelete,
+		Path:        "/api/v1/datasources/{name}",
+		Summary:     "Delete a datasource",
+		Description: "Deletes a datasource. Fails with 409 while schemas still reference it, unless force=true. Requires an admin when access control is enabled.",
+		Tags:        []string{"Datasources"},
+	}, h.DeleteDatasource)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "datasource-health",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/datasources/{name}/health",
+		Summary:     "Check datasource health",
+		Description: "Probes the build information and labels endpoints of a datasource, optionally with the overrides of an environment. Requires an admin when access control is enabled.",
+		Tags:        []string{"Datasources"},
+	}, h.DatasourceHealth)
+}
+
+// Inputs/Outputs
+
+type DatasourceNameInput struct {
+	Name string `path:"name"`
+}
+
+type DatasourceBodyInput struct {
+	Body database.Datasource
+}
+
+type UpdateDatasourceInput struct {
+	Name string `path:"name"`
+	Body database.Datasource
+}
+
+type DeleteDatasourceInput struct {
+	Name  string `path:"name"`
+	Force bool   `query:"force" doc:"Delete even if schemas still reference the datasource"`
+}
+
+type DatasourceHealthInput struct {
+	Name        string `path:"name"`
+	Environment string `query:"environment" doc:"Apply the overrides of this environment"`
+}
+
+type ListDatasourcesOutput struct {
+	Body []*database.Datasource
+}
+
+type DatasourceOutput struct {
+	Body *database.Datasource
+}
+
+type GetDatasourceOutput struct {
+	Body struct {
+		*database.Datasource
+		ReferencedBy []string `json:"referenced_by" doc:"Schemas whose datasource refers to this datasource"`
+	}
+}
+
+type DatasourceHealthOutput struct {
+	Body *rules.DatasourceHealth
+}
+
+// Handlers
+
+// ListDatasources lists all datasources.
+func (h *DatasourceHandlers) ListDatasources(ctx context.Context, input *struct{}) (*ListDatasourcesOutput, error) {
+	datasources, err := h.registry.List(ctx)
+	if err != nil {
+		slog.Error("ListDatasources: Failed to list datasources", "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &ListDatasourcesOutput{Body: datasources}, nil
+}
+
+// CreateDatasource registers a new datasource.
+func (h *DatasourceHandlers) CreateDatasource(ctx context.Context, input *DatasourceBodyInput) (*DatasourceOutput, error) {
+	if err := rules.RequireAdmin(ctx); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+	ds := input.Body
+	if err := h.registry.Create(ctx, &ds); err != nil {
+		slog.Warn("CreateDatasource: Failed to create datasource", "name", ds.Name, "error", err)
+		return nil, datasourceError(err)
+	}
+	slog.Info("CreateDatasource: Successfully created datasource", "name", ds.Name)
+	return h.redacted(ctx, ds.Name)
+}
+
+// GetDatasource retrieves a datasource and the schemas referencing it.
+func (h *DatasourceHandlers) GetDatasource(ctx context.Context, input *DatasourceNameInput) (*GetDatasourceOutput, error) {
+	ds, err := h.registry.Get(ctx, input.Name)
+	if err != nil {
+		return nil, datasourceError(err)
+	}
+	references, err := h.ruleService.DatasourceReferences(ctx, input.Name)
+	if err != nil {
+		slog.Error("GetDatasource: Failed to find references", "name", input.Name, "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	resp := &GetDatasourceOutput{}
+	resp.Body.Datasource = ds
+	resp.Body.ReferencedBy = references
+	return resp, nil
+}
+
+// UpdateDatasource replaces a datasource.
+func (h *DatasourceHandlers) UpdateDatasource(ctx context.Context, input *UpdateDatasourceInput) (*DatasourceOutput, error) {
+	if err := rules.RequireAdmin(ctx); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+	if input.Body.Name != "" && input.Body.Name != input.Name {
+		return nil, huma.Error400BadRequest("datasource name cannot be changed")
+	}
+	ds := input.Body
+	if err := h.registry.Update(ctx, input.Name, &ds); err != nil {
+		slog.Warn("UpdateDatasource: Failed to update datasource", "name", input.Name, "error", err)
+		return nil, datasourceError(err)
+	}
+	slog.Info("UpdateDatasource: Successfully updated datasource", "name", input.Name)
+	return h.redacted(ctx, input.Name)
+}
+
+// DeleteDatasource deletes a datasource unless schemas still reference it.
+func (h *DatasourceHandlers) DeleteDatasource(ctx context.Context, input *DeleteDatasourceInput) (*struct{}, error) {
+	if err := rules.RequireAdmin(ctx); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+	if !input.Force {
+		references, err := h.ruleService.DatasourceReferences(ctx, input.Name)
+		if err != nil {
+			slog.Error("DeleteDatasource: Failed to find references", "name", input.Name, "error", err)
+			return nil, huma.Error500InternalServerError(err.Error())
+		}
+		if len(references) > 0 {
+			return nil, huma.Error409Conflict("datasource is referenced by schemas: " + strings.Join(references, ", "))
+		}
+	}
+
+	if err := h.registry.Delete(ctx, input.Name); err != nil {
+		slog.Warn("DeleteDatasource: Failed to delete datasource", "name", input.Name, "error", err)
+		return nil, datasourceError(err)
+	}
+	slog.Info("DeleteDatasource: Successfully deleted datasource", "name", input.Name)
+	return nil, nil
+}
+
+// DatasourceHealth probes a datasource.
+func (h *DatasourceHandlers) DatasourceHealth(ctx context.Context, input *DatasourceHealthInput) (*DatasourceHealthOutput, error) {
+	if err := rules.RequireAdmin(ctx); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+	health, err := h.registry.Health(ctx, input.Name, input.Environment)
+	if err != nil {
+		return nil, datasourceError(err)
+	}
+	return &DatasourceHealthOutput{Body: health}, nil
+}
+
+// redacted returns the stored datasource with its credentials redacted.
+func (h *DatasourceHandlers) redacted(ctx context.Context, name string) (*DatasourceOutput, error) {
+	ds, err := h.registry.Get(ctx, name)
+	if err != nil {
+		return nil, datasourceError(err)
+	}
+	return &DatasourceOutput{Body: ds}, nil
+}
+
+// datasourceError maps registry errors to HTTP errors.
+func datasourceError(err error) error {
+	switch {
+	case errors.Is(err, database.ErrDatasourceNotFound):
+		return huma.Error404NotFound(err.Error())
+	case errors.Is(err, database.ErrDatasourceExists), errors.Is(err, rules.ErrDatasourceReadOnly):
+		return huma.Error409Conflict(err.Error())
+	default:
+		return huma.Error400BadRequest(err.Error())
+	}
+}
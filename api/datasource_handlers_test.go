@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rulemanager/config"
+	"rulemanager/internal/database"
+	"rulemanager/internal/rules"
+	"rulemanager/internal/validation"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDatasourceHandlers(t *testing.T) {
+	promServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/status/buildinfo":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"version":"2.53.0"}}`))
+		case "/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer promServer.Close()
+
+	// Setup
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	registry, err := rules.NewDatasourceRegistry([]config.DatasourceConfig{{Name: "static", Type: "prometheus", URL: promServer.URL}}, store)
+	assert.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{
+		{Name: "k8s", Schema: json.RawMessage(`{"datasource": {"name": "vm"}}`)},
+		{Name: "custom", Schema: json.RawMessage(`{"datasource": {"type": "prometheus", "url": "http://localhost:9090"}}`)},
+	}, nil)
+	service := rules.NewService(mockTP, new(MockRuleStore), validation.NewJSONSchemaValidator(), rules.WithDatasources(registry))
+
+	NewDatasourceHandlers(humaAPI, registry, service)
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var reader *bytes.Reader
+		if body != nil {
+			raw, _ := json.Marshal(body)
+			reader = bytes.NewReader(raw)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/datasources", map[string]any{
+			"name": "vm",
+			"type": "victoriametrics",
+			"url":  promServer.URL,
+			"auth": map[string]any{"bearer_token": "secret"},
+			"environments": map[string]any{
+				"staging": map[string]any{"url": promServer.URL, "auth": map[string]any{"username": "vm", "password": "staging-secret"}},
+			},
+		})
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var ds database.Datasource
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ds))
+		assert.Equal(t, rules.RedactedSecret, ds.Auth.BearerToken)
+		assert.Equal(t, rules.RedactedSecret, ds.Environments["staging"].Auth.Password)
+
+		w = do(http.MethodPost, "/api/v1/datasources", map[string]any{"name": "vm", "url": promServer.URL})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = do(http.MethodPost, "/api/v1/datasources", map[string]any{"name": "broken", "url": "not a url"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(http.MethodPost, "/api/v1/datasources", map[string]any{"name": "exfil", "url": promServer.URL, "auth": map[string]any{"bearer_token_file": "/etc/passwd"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "bearer_token_file")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/datasources", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var list []database.Datasource
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Len(t, list, 2)
+		assert.Equal(t, "static", list[0].Name)
+		assert.True(t, list[0].ReadOnly)
+		assert.Equal(t, "vm", list[1].Name)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/datasources/vm", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]any
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "vm", resp["name"])
+		assert.Equal(t, "victoriametrics", resp["type"])
+		assert.Equal(t, []any{"k8s"}, resp["referenced_by"])
+
+		w = do(http.MethodGet, "/api/v1/datasources/missing", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/datasources/vm", nil)
+		var ds database.Datasource
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &ds))
+		ds.Headers = map[string]string{"X-Scope-OrgID": "42"}
+
+		w = do(http.MethodPut, "/api/v1/datasources/vm", ds)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		stored, err := store.GetDatasource(t.Context(), "vm")
+		assert.NoError(t, err)
+		assert.Equal(t, "42", stored.Headers["X-Scope-OrgID"])
+		assert.Equal(t, "secret", stored.Auth.BearerToken, "redacted secrets keep their stored value")
+		assert.Equal(t, "staging-secret", stored.Environments["staging"].Auth.Password)
+
+		ds.Name = "renamed"
+		w = do(http.MethodPut, "/api/v1/datasources/vm", ds)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do(http.MethodPut, "/api/v1/datasources/static", map[string]any{"name": "static", "url": promServer.URL})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = do(http.MethodPut, "/api/v1/datasources/missing", map[string]any{"name": "missing", "url": promServer.URL})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/datasources/vm/health?environment=staging", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var health rules.DatasourceHealth
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+		assert.Equal(t, rules.DatasourceHealthy, health.Status)
+		assert.Equal(t, "staging", health.Environment)
+		assert.Equal(t, "2.53.0", health.Version)
+
+		w = do(http.MethodGet, "/api/v1/datasources/missing/health", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := do(http.MethodDelete, "/api/v1/datasources/vm", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "k8s")
+
+		w = do(http.MethodDelete, "/api/v1/datasources/vm?force=true", nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = do(http.MethodDelete, "/api/v1/datasources/vm?force=true", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = do(http.MethodDelete, "/api/v1/datasources/static", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestDatasourceHandlers_AdminOnly(t *testing.T) {
+	router := chi.NewMux()
+	router.Use(IdentityMiddleware(config.AuthConfig{Enabled: true, AdminTeams: []string{"sre"}}))
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, store.CreateDatasource(t.Context(), &database.Datasource{Name: "vm", DatasourceSettings: database.DatasourceSettings{URL: "http://vm:8428"}}))
+	registry, err := rules.NewDatasourceRegistry(nil, store)
+	assert.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{}, nil)
+	NewDatasourceHandlers(humaAPI, registry, rules.NewService(mockTP, new(MockRuleStore), validation.NewJSONSchemaValidator(), rules.WithDatasources(registry)))
+
+	do := func(method, path, teams, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-User", "alice")
+		req.Header.Set("X-Forwarded-Groups", teams)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("NonAdmin", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1/datasources", "payments", `{"name": "evil", "url": "http://attacker"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/api/v1/datasources/vm", "payments", `{"name": "vm", "url": "http://attacker"}`).Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/api/v1/datasources/vm", "payments", "").Code)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/datasources/vm/health", "payments", "").Code)
+
+		stored, err := store.GetDatasource(t.Context(), "vm")
+		assert.NoError(t, err)
+		assert.Equal(t, "http://vm:8428", stored.URL)
+
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/datasources/vm", "payments", "").Code, "reading is allowed")
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/datasources/vm", "sre", `{"name": "vm", "url": "http://vm-v2:8428"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do(http.MethodDelete, "/api/v1/datasources/vm?force=true", "sre", "")
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	})
+}
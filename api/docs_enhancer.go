@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"rulemanager/internal/database"
+	"slices"
 	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -58,7 +59,47 @@ func registerSchemas(api huma.API, provider database.TemplateProvider) error {
 		keepSchemas[schema.Name] = true
 	}
 
-	// Helper to resolve and inline schemas
+	// Request bodies are validated against the schemas captured at registration, which reference
+	// the registry; those schemas must stay registered even when inlined in the docs.
+	validated := make(map[string]bool)
+	var collect func(s *huma.Schema)
+	collect = func(s *huma.Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			refName := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+			if validated[refName] {
+				return
+			}
+			validated[refName] = true
+			collect(registry.Map()[refName])
+			return
+		}
+		for _, prop := range s.Properties {
+			collect(prop)
+		}
+		collect(s.Items)
+		if schema, ok := s.AdditionalProperties.(*huma.Schema); ok {
+			collect(schema)
+		}
+		for _, sub := range slices.Concat(s.OneOf, s.AnyOf, s.AllOf) {
+			collect(sub)
+		}
+	}
+	for _, pathItem := range api.OpenAPI().Paths {
+		for _, op := range []*huma.Operation{pathItem.Put, pathItem.Post, pathItem.Patch, pathItem.Delete} {
+			if op != nil && op.RequestBody != nil {
+				for _, content := range op.RequestBody.Content {
+					collect(content.Schema)
+				}
+			}
+		}
+	}
+
+	// Helper to resolve and inline schemas. Recursive types (e.g. nested form fields) cannot be
+	// inlined, so schemas referenced from within themselves are kept and stay referenced.
+	inlining := make(map[string]bool)
 	var resolve func(s *huma.Schema) *huma.Schema
 	resolve = func(s *huma.Schema) *huma.Schema {
 		if s == nil {
@@ -67,6 +108,10 @@ func registerSchemas(api huma.API, provider database.TemplateProvider) error {
 
 		if s.Ref != "" {
 			refName := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+			if inlining[refName] {
+				keepSchemas[refName] = true
+				return s
+			}
 			if !keepSchemas[refName] {
 				// It's an internal schema, inline it
 				if target, ok := registry.Map()[refName]; ok {
@@ -74,6 +119,8 @@ func registerSchemas(api huma.API, provider database.TemplateProvider) error {
 					// We need to deep copy if we want to be safe, but for now shallow copy + recursive resolve
 					inlined := *target
 					inlined.Ref = "" // Clear ref
+					inlining[refName] = true
+					defer delete(inlining, refName)
 					return resolve(&inlined)
 				}
 			}
@@ -147,9 +194,26 @@ func registerSchemas(api huma.API, provider database.TemplateProvider) error {
 		}
 	}
 
+	// Inline the internal schemas used by kept recursive schemas
+	resolved := make(map[string]bool)
+	for done := false; !done; {
+		done = true
+		for name := range keepSchemas {
+			target, ok := registry.Map()[name]
+			if !ok || loadedSchemas[name] || resolved[name] {
+				continue
+			}
+			resolved[name] = true
+			done = false
+			inlining[name] = true
+			resolve(target)
+			delete(inlining, name)
+		}
+	}
+
 	// Remove internal schemas from registry
 	for name := range registry.Map() {
-		if !keepSchemas[name] {
+		if !keepSchemas[name] && !validated[name] {
 			delete(registry.Map(), name)
 		}
 	}
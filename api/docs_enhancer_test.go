@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rulemanager/internal/database"
+	"strings"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEnhanceDocumentation_RecursiveSchemas(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+	mockStore := new(MockTemplateProvider)
+	mockStore.On("ListSchemas", mock.Anything).Return([]*database.Schema{
+		{Name: "k8s", Schema: json.RawMessage(`{"type": "object"}`)},
+	}, nil)
+	NewTemplateHandlers(humaAPI, mockStore, nil, nil)
+
+	// The form model nests fields in fields; inlining it must terminate
+	assert.NoError(t, EnhanceDocumentation(humaAPI, mockStore, t.TempDir()))
+
+	schemas := humaAPI.OpenAPI().Components.Schemas.Map()
+	assert.Contains(t, schemas, "k8s")
+	assert.Contains(t, schemas, "FormField", "recursive schemas stay referenced")
+	assert.NotContains(t, schemas, "FormModel", "other internal schemas are inlined")
+
+	form := humaAPI.OpenAPI().Paths["/api/v1/templates/{name}/form"].Get.Responses["200"].Content["application/json"].Schema
+	assert.Empty(t, form.Ref)
+	assert.Equal(t, "#/components/schemas/FormField", form.Properties["fields"].Items.Properties["fields"].Items.Ref)
+
+	field := schemas["FormField"]
+	assert.Equal(t, "#/components/schemas/FormField", field.Properties["fields"].Items.Ref)
+	assert.Empty(t, field.Properties["choices"].Items.Ref, "schemas used by kept schemas are inlined")
+
+	// Request bodies are still validated against the registered schemas
+	mockStore.On("CreateSchema", mock.Anything, "new", mock.Anything).Return(nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/templates/schemas", strings.NewReader(`{"name": "new", "content": {"type": "object"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/templates/schemas", strings.NewReader(`{"name": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
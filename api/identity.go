@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"rulemanager/config"
+	"rulemanager/internal/rules"
+)
+
+// IdentityMiddleware attaches the caller identity, read from the headers configured in cfg, to the
+// request context. Requests without a user header get an anonymous identity without teams, which
+// may only change rules without an owning team. When access control is disabled the middleware
+// does nothing, and every caller may change every rule.
+func IdentityMiddleware(cfg config.AuthConfig) func(http.Handler) http.Handler {
+	userHeader := cfg.UserHeader
+	if userHeader == "" {
+		userHeader = "X-Forwarded-User"
+	}
+	teamsHeader := cfg.TeamsHeader
+	if teamsHeader == "" {
+		teamsHeader = "X-Forwarded-Groups"
+	}
+	tenantHeader := cfg.TenantHeader
+	if tenantHeader == "" {
+		tenantHeader = "X-Forwarded-Tenant"
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity := &rules.Identity{User: r.Header.Get(userHeader), Tenant: strings.TrimSpace(r.Header.Get(tenantHeader))}
+			for _, value := range r.Header.Values(teamsHeader) {
+				for _, team := range strings.Split(value, ",") {
+					if team = strings.TrimSpace(team); team != "" {
+						identity.Teams = append(identity.Teams, team)
+					}
+				}
+			}
+			for _, team := range identity.Teams {
+				if slices.Contains(cfg.AdminTeams, team) {
+					identity.Admin = true
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(rules.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rulemanager/config"
+	"rulemanager/internal/database"
+	"rulemanager/internal/rules"
+	"rulemanager/internal/validation"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdentityMiddleware(t *testing.T) {
+	identityOf := func(cfg config.AuthConfig, header http.Header) *rules.Identity {
+		var identity *rules.Identity
+		handler := IdentityMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity = rules.IdentityFromContext(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header = header
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return identity
+	}
+
+	tests := []struct {
+		name     string
+		cfg      config.AuthConfig
+		header   http.Header
+		expected *rules.Identity
+	}{
+		{
+			name:     "Disabled",
+			cfg:      config.AuthConfig{},
+			header:   http.Header{"X-Forwarded-User": {"alice"}},
+			expected: nil,
+		},
+		{
+			name:     "DefaultHeaders",
+			cfg:      config.AuthConfig{Enabled: true, AdminTeams: []string{"sre"}},
+			header:   http.Header{"X-Forwarded-User": {"alice"}, "X-Forwarded-Groups": {"payments, checkout", "search"}},
+			expected: &rules.Identity{User: "alice", Teams: []string{"payments", "checkout", "search"}},
+		},
+		{
+			name:     "Admin",
+			cfg:      config.AuthConfig{Enabled: true, AdminTeams: []string{"sre"}},
+			header:   http.Header{"X-Forwarded-User": {"bob"}, "X-Forwarded-Groups": {"sre"}},
+			expected: &rules.Identity{User: "bob", Teams: []string{"sre"}, Admin: true},
+		},
+		{
+			name:     "CustomHeaders",
+			cfg:      config.AuthConfig{Enabled: true, UserHeader: "X-User", TeamsHeader: "X-Teams"},
+			header:   http.Header{"X-User": {"carol"}, "X-Teams": {"platform"}, "X-Forwarded-Groups": {"payments"}},
+			expected: &rules.Identity{User: "carol", Teams: []string{"platform"}},
+		},
+		{
+			name:     "Tenant",
+			cfg:      config.AuthConfig{Enabled: true, TenantHeader: "X-Tenant"},
+			header:   http.Header{"X-Forwarded-User": {"dave"}, "X-Tenant": {"acme"}, "X-Forwarded-Tenant": {"other"}},
+			expected: &rules.Identity{User: "dave", Tenant: "acme"},
+		},
+		{
+			name:     "Anonymous",
+			cfg:      config.AuthConfig{Enabled: true},
+			header:   http.Header{},
+			expected: &rules.Identity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, identityOf(tt.cfg, tt.header))
+		})
+	}
+}
+
+func TestRuleHandlers_Ownership(t *testing.T) {
+	router := chi.NewMux()
+	router.Use(IdentityMiddleware(config.AuthConfig{Enabled: true, AdminTeams: []string{"sre"}}))
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	owned := json.RawMessage(`{"target": {"namespace": "team-a"}, "rules": [{"rule_type": "cpu"}]}`)
+	for _, rule := range []*database.Rule{
+		{ID: "r1", TemplateName: "k8s", Parameters: owned, Metadata: &database.RuleMetadata{Team: "payments"}},
+		{ID: "r2", TemplateName: "k8s", Parameters: json.RawMessage(`{"target": {"namespace": "team-b"}, "rules": [{"rule_type": "cpu"}]}`)},
+		{ID: "r3", TemplateName: "k8s", Parameters: owned, Metadata: &database.RuleMetadata{Team: "search"}},
+	} {
+		assert.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object", "uniqueness_keys": ["target.namespace"]}`, nil)
+	mockTP.On("GetTemplate", mock.Anything, "k8s").Return(`alert: test`, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	do := func(method, target, teams, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-User", "alice")
+		req.Header.Set("X-Forwarded-Groups", teams)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("UpdateOtherTeam", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r1", "platform", `{"templateName": "k8s", "parameters": {"common": {"severity": "critical"}}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "payments")
+	})
+
+	t.Run("PlanOverwriteOtherTeam", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/rules/plan", "platform", `{"templateName": "k8s", "parameters": {"target": {"namespace": "team-a"}, "rules": [{"rule_type": "cpu"}]}}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Plans []rules.RulePlan `json:"plans"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Plans, 1) {
+			assert.Equal(t, rules.PlanActionForbidden, body.Plans[0].Action)
+			assert.Contains(t, body.Plans[0].Reason, "owned by another team")
+		}
+	})
+
+	t.Run("CreateOverwritingOtherTeam", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/rules", "platform", `{"templateName": "k8s", "parameters": {"target": {"namespace": "team-a"}, "rules": [{"rule_type": "cpu"}]}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	})
+
+	t.Run("CreateForOtherTeam", func(t *testing.T) {
+		w := do(http.MethodPost, "/api/v1/rules", "platform", `{"templateName": "k8s", "parameters": {"target": {"namespace": "team-c"}, "rules": [{"rule_type": "cpu"}]}, "metadata": {"team": "payments"}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	})
+
+	t.Run("ClaimUnownedRule", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r2/metadata", "platform", `{"team": "payments"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		w = do(http.MethodPut, "/api/v1/rules/r2/metadata", "platform", `{"team": "platform"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("DeleteOtherTeam", func(t *testing.T) {
+		w := do(http.MethodDelete, "/api/v1/rules/r1", "platform", "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		_, err := store.GetRule(context.Background(), "r1")
+		assert.NoError(t, err, "rule is kept")
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r1/metadata", "payments", `{"team": "payments", "owner": "alice"}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do(http.MethodDelete, "/api/v1/rules/r1", "payments", "")
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	})
+
+	t.Run("AdminOverride", func(t *testing.T) {
+		w := do(http.MethodDelete, "/api/v1/rules/r3", "sre", "")
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"rulemanager/internal/database"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// pageRequest builds the store page request of a listing from its query parameters.
+func pageRequest(limit, offset int, cursor, sort, fields string) (database.PageRequest, error) {
+	ruleSort, err := database.ParseRuleSort(sort)
+	if err != nil {
+		return database.PageRequest{}, huma.Error400BadRequest(err.Error())
+	}
+	ruleFields, err := database.ParseRuleFields(fields)
+	if err != nil {
+		return database.PageRequest{}, huma.Error400BadRequest(err.Error())
+	}
+	return database.PageRequest{Limit: limit, Offset: offset, Cursor: cursor, Sort: ruleSort, Fields: ruleFields}, nil
+}
+
+// pageError maps store errors of a paged listing to API errors.
+func pageError(err error) error {
+	if errors.Is(err, database.ErrInvalidPage) || errors.Is(err, database.ErrInvalidFilter) {
+		return huma.Error400BadRequest(err.Error())
+	}
+	return huma.Error500InternalServerError(err.Error())
+}
+
+// linkHeader returns the Link header of a page: the first page, and the next one unless this is
+// the last. Links keep the other query parameters of the request.
+func linkHeader(u *url.URL, page *database.RulePage) string {
+	link := func(rel, cursor string) string {
+		query := u.Query()
+		query.Del("offset")
+		query.Del("cursor")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		target := url.URL{Path: u.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+
+	links := []string{link("first", "")}
+	if page.NextCursor != "" {
+		links = append(links, link("next", page.NextCursor))
+	}
+	return strings.Join(links, ", ")
+}
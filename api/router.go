@@ -14,9 +14,11 @@ type API struct {
 	Huma   huma.API
 }
 
-// NewAPI creates a new API instance.
-func NewAPI() *API {
+// NewAPI creates a new API instance. Middlewares apply to every route; chi requires them before
+// any route is registered, which Huma does on creation.
+func NewAPI(middlewares ...func(http.Handler) http.Handler) *API {
 	router := chi.NewMux()
+	router.Use(middlewares...)
 	config := huma.DefaultConfig("Rule Manager API", "1.0.0")
 	humaAPI := humachi.New(router, config)
 
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"rulemanager/internal/database"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type FacetRulesInput struct {
+	Fields      string            `query:"fields" required:"true" doc:"Comma-separated fields to count values of: templateName or parameter paths (e.g. templateName,parameters.target.namespace)"`
+	Limit       int               `query:"limit" doc:"Most frequent values returned per field; 0 returns all" default:"0" minimum:"0" maximum:"1000"`
+	QueryParams map[string]string // Populated by Resolve with the filter parameters, as for search
+}
+
+// facetParams are the query parameters of FacetRulesInput that are not filters.
+var facetParams = map[string]bool{"fields": true, "limit": true}
+
+// Resolve implements huma.Resolver to capture the filter parameters.
+func (i *FacetRulesInput) Resolve(ctx huma.Context) []error {
+	i.QueryParams = make(map[string]string)
+	u := ctx.URL()
+	for key, values := range u.Query() {
+		if len(values) > 0 && !facetParams[key] {
+			i.QueryParams[key] = values[0]
+		}
+	}
+	return nil
+}
+
+type FacetRulesOutput struct {
+	Body *database.RuleFacets
+}
+
+// FacetRules counts the rules per value of the requested fields, over the rules matching the
+// search filters (templateName, q and parameter paths; see SearchRules).
+// Example:
+//
+//	?fields=templateName,parameters.target.namespace&q=rules.threshold>0.8
+//	→ {"total": 12, "facets": {"templateName": [{"value": "k8s", "count": 9}, ...], ...}}
+func (h *RuleHandlers) FacetRules(ctx context.Context, input *FacetRulesInput) (*FacetRulesOutput, error) {
+	fields, err := database.ParseFacetFields(input.Fields)
+	if err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	filter, err := searchFilter(input.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	facets, err := h.ruleService.FacetRules(ctx, filter, fields, input.Limit)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidFilter) || errors.Is(err, database.ErrInvalidFacet) {
+			return nil, huma.Error400BadRequest(err.Error())
+		}
+		slog.Error("FacetRules: Failed to count rules", "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &FacetRulesOutput{Body: facets}, nil
+}
@@ -3,11 +3,14 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"rulemanager/internal/database"
 	"rulemanager/internal/rules"
+	"strings"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -32,7 +35,7 @@ func NewRuleHandlers(api huma.API, rs database.RuleStore, svc *rules.Service) {
 		Method:      http.MethodPost,
 		Path:        "/api/v1/rules",
 		Summary:     "Create a new rule",
-		Description: "Creates a new rule based on a template and parameters.",
+		Description: "Creates a new rule based on a template and parameters. With access control enabled, existing rules owned by another team are not overwritten (403).",
 		Tags:        []string{"Rules"},
 	}, h.CreateRule)
 
@@ -45,12 +48,30 @@ func NewRuleHandlers(api huma.API, rs database.RuleStore, svc *rules.Service) {
 		Tags:        []string{"Rules"},
 	}, h.GetRule)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "get-rule-by-name",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/rules/by-name/{template}/{name}",
+		Summary:     "Get a rule by name",
+		Description: "Retrieves the rule of a template by its client-supplied name or external ID, in the tenant of the caller (see auth.tenant_header).",
+		Tags:        []string{"Rules"},
+	}, h.GetRuleByName)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "put-rule-by-name",
+		Method:      http.MethodPut,
+		Path:        "/api/v1/rules/by-name/{template}/{name}",
+		Summary:     "Create or replace a rule by name",
+		Description: "Idempotently creates the rule of a template with a client-supplied name or external ID (201), or replaces its parameters (200). Names are unique per template and tenant; the tenant is the caller's (see auth.tenant_header). An unnamed rule with the same uniqueness keys is updated and takes the name; a rule with another name is a conflict (409).",
+		Tags:        []string{"Rules"},
+	}, h.PutRuleByName)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "list-rules",
 		Method:      http.MethodGet,
 		Path:        "/api/v1/rules",
 		Summary:     "List rules",
-		Description: "Lists all rules with pagination.",
+		Description: "Lists all rules, one page at a time. X-Total-Count holds the number of rules and the Link header the first and next pages; follow the next link (cursor) to page through stable results.",
 		Tags:        []string{"Rules"},
 	}, h.ListRules)
 
@@ -59,16 +80,25 @@ func NewRuleHandlers(api huma.API, rs database.RuleStore, svc *rules.Service) {
 		Method:      http.MethodPut,
 		Path:        "/api/v1/rules/{id}",
 		Summary:     "Update a rule",
-		Description: "Updates an existing rule.",
+		Description: "Updates an existing rule. With access control enabled, only members of the owning team and admins may update it (403).",
 		Tags:        []string{"Rules"},
 	}, h.UpdateRule)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "update-rule-metadata",
+		Method:      http.MethodPut,
+		Path:        "/api/v1/rules/{id}/metadata",
+		Summary:     "Update rule metadata",
+		Description: "Replaces the owner, team, description, labels and links of a rule without changing its parameters. Templates can add owner, team and labels to alerts with {{ ruleLabels }}.",
+		Tags:        []string{"Rules"},
+	}, h.UpdateRuleMetadata)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "delete-rule",
 		Method:      http.MethodDelete,
 		Path:        "/api/v1/rules/{id}",
 		Summary:     "Delete a rule",
-		Description: "Deletes a rule by its ID.",
+		Description: "Deletes a rule by its ID. With access control enabled, only members of the owning team and admins may delete it (403).",
 		Tags:        []string{"Rules"},
 	}, h.DeleteRule)
 
@@ -77,10 +107,19 @@ func NewRuleHandlers(api huma.API, rs database.RuleStore, svc *rules.Service) {
 		Method:      http.MethodGet,
 		Path:        "/api/v1/rules/search",
 		Summary:     "Search rules",
-		Description: "Search rules by template and parameters (e.g., ?template=demo&target.service=api&target.environment=prod).",
+		Description: "Search rules by template and parameter paths declared by the template schema (e.g., ?templateName=demo&target.service=api), or with a query in q (e.g., ?q=target.namespace=~\"team-.*\" AND rules.threshold>0.8). Queries support =, !=, =~, !~, >, >=, <, <=, in (...), not in (...), exists(field), field[...] to match one array element, and AND, OR, NOT and parentheses. Unknown fields and values not matching the field type are rejected with 400.",
 		Tags:        []string{"Rules"},
 	}, h.SearchRules)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "facet-rules",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/rules/facets",
+		Summary:     "Count rules per field value",
+		Description: "Counts the rules per value of each field in fields (templateName or parameter paths, e.g. ?fields=templateName,parameters.target.namespace), over the rules matching the same filters as search. Values are ordered by count; array fields count each element.",
+		Tags:        []string{"Rules"},
+	}, h.FacetRules)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "plan-rule",
 		Method:      http.MethodPost,
@@ -120,15 +159,18 @@ type RuleCreationParams struct {
 
 type CreateRuleInput struct {
 	Body struct {
-		TemplateName string          `json:"templateName" doc:"The name of the template to use"`
-		Parameters   json.RawMessage `json:"parameters" doc:"The parameters for the rule template"`
+		TemplateName string                 `json:"templateName" doc:"The name of the template to use"`
+		Parameters   json.RawMessage        `json:"parameters" doc:"The parameters for the rule template"`
+		Metadata     *database.RuleMetadata `json:"metadata,omitempty" doc:"Ownership and labels of the rule, independent of the template parameters"`
 	}
 }
 
 type CreateRuleOutput struct {
 	Body struct {
-		IDs   []string `json:"ids" doc:"The IDs of the created or updated rules"`
-		Count int      `json:"count" doc:"The number of rules processed"`
+		IDs       []string                `json:"ids" doc:"The IDs of the created or updated rules"`
+		Count     int                     `json:"count" doc:"The number of rules processed"`
+		Pipelines []*rules.PipelineReport `json:"pipelines" doc:"Per-step pipeline results for each processed rule, in the same order as ids"`
+		Warnings  []string                `json:"warnings,omitempty" doc:"Non-blocking pipeline warnings (steps with on_failure=warn and warnings reported by passing steps)"`
 	}
 }
 
@@ -138,6 +180,10 @@ type PlanRuleOutput struct {
 	}
 }
 
+type PlanUpdateRuleOutput struct {
+	Body *rules.RulePlan
+}
+
 type GetRuleInput struct {
 	ID string `path:"id" doc:"The ID of the rule to retrieve"`
 }
@@ -147,28 +193,52 @@ type GetRuleOutput struct {
 }
 
 type ListRulesInput struct {
-	Offset int `query:"offset" doc:"The offset for pagination" default:"0"`
-	Limit  int `query:"limit" doc:"The limit for pagination" default:"10"`
+	Offset int    `query:"offset" doc:"The offset for pagination; ignored when a cursor is given" default:"0" minimum:"0"`
+	Limit  int    `query:"limit" doc:"The limit for pagination" default:"10" minimum:"1" maximum:"1000"`
+	Cursor string `query:"cursor" doc:"Continue after the previous page; taken from its next Link"`
+	Sort   string `query:"sort" doc:"Sort order: id, templateName, createdAt or updatedAt, with an optional :asc or :desc (e.g. updatedAt:desc)" default:"id:asc"`
+	Fields string `query:"fields" doc:"Comma-separated fields to return, e.g. id,templateName,parameters.target; the id is always returned"`
+	url    url.URL
+}
+
+// Resolve keeps the request URL for the Link header.
+func (i *ListRulesInput) Resolve(ctx huma.Context) []error {
+	i.url = ctx.URL()
+	return nil
 }
 
 type ListRulesOutput struct {
-	Body []*database.Rule
+	Link  string `header:"Link" doc:"Links to the first and next pages"`
+	Total int64  `header:"X-Total-Count" doc:"The number of rules"`
+	Body  []*database.Rule
 }
 
 type UpdateRuleInput struct {
 	ID   string `path:"id" doc:"The ID of the rule to update"`
 	Body struct {
-		TemplateName string          `json:"templateName" doc:"The name of the template to use"`
-		Parameters   json.RawMessage `json:"parameters" doc:"The parameters for the rule template"`
+		TemplateName string                 `json:"templateName" doc:"The name of the template to use"`
+		Parameters   json.RawMessage        `json:"parameters" doc:"The parameters for the rule template"`
+		Metadata     *database.RuleMetadata `json:"metadata,omitempty" doc:"Replaces the metadata of the rule when given"`
 	}
 }
 
 type UpdateRuleOutput struct {
 	Body struct {
-		ID string `json:"id"`
+		ID       string                `json:"id"`
+		Pipeline *rules.PipelineReport `json:"pipeline,omitempty" doc:"Per-step pipeline results for the updated rule"`
+		Warnings []string              `json:"warnings,omitempty" doc:"Non-blocking pipeline warnings (steps with on_failure=warn and warnings reported by passing steps)"`
 	}
 }
 
+type UpdateRuleMetadataInput struct {
+	ID   string `path:"id" doc:"The ID of the rule"`
+	Body database.RuleMetadata
+}
+
+type UpdateRuleMetadataOutput struct {
+	Body *database.Rule
+}
+
 type DeleteRuleInput struct {
 	ID string `path:"id" doc:"The ID of the rule to delete"`
 }
@@ -181,8 +251,10 @@ type DeleteRuleOutput struct {
 type OptionsRequest struct {
 	Body struct {
 		TemplateName  string                 `json:"template_name" doc:"Name of the template"`
-		FieldPath     string                 `json:"field_path" doc:"Path to the field in the schema (e.g. target.namespace)"`
+		FieldPath     string                 `json:"field_path" doc:"Path to the field in the schema (e.g. target.namespace, rules[0].service_name or rules[rule_type=service_up].service_name)"`
 		CurrentValues map[string]interface{} `json:"current_values" doc:"Current values of the form to resolve dependencies"`
+		CurrentItem   map[string]interface{} `json:"current_item,omitempty" doc:"Values of the array item the field belongs to, merged over current_values for dependencies. Taken from current_values when field_path addresses the item by index"`
+		Search        string                 `json:"search,omitempty" doc:"Only return options matching this term (case-insensitive substring or prefix, depending on the field)"`
 	}
 }
 
@@ -193,7 +265,9 @@ type OptionsResponse struct {
 	}
 }
 
-// CreateRule creates one or more rules from a template using a 'rules' array parameter.
+// CreateRule creates one or more rules from a template using a 'rules' array parameter. Every
+// rule is planned, run through its pipelines and rendered before any is saved, so a batch is
+// rejected as a whole.
 func (h *RuleHandlers) CreateRule(ctx context.Context, input *CreateRuleInput) (*CreateRuleOutput, error) {
 	// Parse parameters into the expected structure
 	var params RuleCreationParams
@@ -215,9 +289,19 @@ func (h *RuleHandlers) CreateRule(ctx context.Context, input *CreateRuleInput) (
 		return nil, huma.Error400BadRequest("'rules' array cannot be empty")
 	}
 
-	var createdIDs []string
+	if err := input.Body.Metadata.Validate(); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if err := h.ruleService.AuthorizeRuleChange(ctx, nil, &database.Rule{Metadata: input.Body.Metadata}); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+
+	// Plan and check every item before saving any, so that a batch failing at one item leaves the
+	// store unchanged
+	plans := make([]*rules.RulePlan, 0, len(params.Rules))
+	var warnings []string
 
-	// Create a separate rule for each item in the rules array
+	// Each item in the rules array becomes a separate rule
 	for i, ruleItem := range params.Rules {
 		// Construct parameters for this single rule: {target, common, rules: [rule]}
 		singleRuleParams := struct {
@@ -243,36 +327,64 @@ func (h *RuleHandlers) CreateRule(ctx context.Context, input *CreateRuleInput) (
 			return nil, huma.Error400BadRequest(fmt.Sprintf("Validation/Planning failed for rule %d: %s", i, err.Error()))
 		}
 
-		// Validate template syntax by attempting generation (PlanRuleCreation only validates schema)
-		if _, err := h.ruleService.GenerateRule(ctx, input.Body.TemplateName, singleRuleJSON); err != nil {
+		if plan.Action == rules.PlanActionForbidden {
+			slog.Warn("CreateRule: Forbidden", "rule_index", i, "id", plan.ExistingRule.ID, "reason", plan.Reason)
+			return nil, huma.Error403Forbidden(fmt.Sprintf("Rule %d: %s", i, plan.Reason))
+		}
+
+		if !plan.Pipeline.Passed() {
+			slog.Warn("CreateRule: Pipeline failed", "rule_index", i, "template", input.Body.TemplateName, "error", plan.Pipeline.Err())
+			return nil, pipelineFailure(fmt.Sprintf("Pipeline failed for rule %d", i), plan.Pipeline)
+		}
+		warnings = append(warnings, pipelineWarnings(fmt.Sprintf("rule %d: ", i), plan.Pipeline)...)
+
+		// Validate template syntax by attempting generation (PlanRuleCreation only validates schema).
+		// The plan holds the parameters as enriched by mutating pipeline steps; the rule keeps its
+		// metadata unless new metadata is given.
+		metadata := input.Body.Metadata
+		if metadata == nil && plan.ExistingRule != nil {
+			metadata = plan.ExistingRule.Metadata
+		}
+		if err := h.ruleService.CheckRule(ctx, input.Body.TemplateName, plan.NewRule.Parameters, metadata); err != nil {
 			slog.Warn("CreateRule: Generation failed", "rule_index", i, "template", input.Body.TemplateName, "error", err)
 			return nil, huma.Error400BadRequest(fmt.Sprintf("Generation failed for rule %d: %s", i, err.Error()))
 		}
+		plans = append(plans, plan)
+	}
 
+	createdIDs := make([]string, 0, len(plans))
+	reports := make([]*rules.PipelineReport, 0, len(plans))
+	for i, plan := range plans {
 		if plan.Action == "update" {
 			// Update existing rule
 			rule := plan.ExistingRule
-			rule.Parameters = singleRuleJSON
+			rule.Parameters = plan.NewRule.Parameters
 			rule.TemplateName = input.Body.TemplateName // Ensure template name is updated if changed (though plan checks template name)
+			if input.Body.Metadata != nil {
+				rule.Metadata = input.Body.Metadata
+			}
 
 			if err := h.ruleStore.UpdateRule(ctx, rule.ID, rule); err != nil {
-				slog.Error("CreateRule: Failed to update rule", "id", rule.ID, "error", err)
-				return nil, huma.Error500InternalServerError(fmt.Sprintf("Failed to update rule %d: %s", i, err.Error()))
+				slog.Error("CreateRule: Failed to update rule", "id", rule.ID, "saved", createdIDs, "error", err)
+				return nil, huma.Error500InternalServerError(fmt.Sprintf("Failed to update rule %d: %s%s", i, err.Error(), savedRules(createdIDs)))
 			}
 			createdIDs = append(createdIDs, rule.ID)
+			reports = append(reports, plan.Pipeline)
 			slog.Info("CreateRule: Updated existing rule", "id", rule.ID)
 		} else {
 			// Create new rule
 			rule := plan.NewRule
 			rule.ID = primitive.NewObjectID().Hex()
+			rule.Metadata = input.Body.Metadata
 			rule.CreatedAt = time.Now()
 			rule.UpdatedAt = time.Now()
 
 			if err := h.ruleStore.CreateRule(ctx, rule); err != nil {
-				slog.Error("CreateRule: Failed to persist rule", "rule_index", i, "error", err)
-				return nil, huma.Error500InternalServerError(fmt.Sprintf("Failed to create rule %d: %s", i, err.Error()))
+				slog.Error("CreateRule: Failed to persist rule", "rule_index", i, "saved", createdIDs, "error", err)
+				return nil, huma.Error500InternalServerError(fmt.Sprintf("Failed to create rule %d: %s%s", i, err.Error(), savedRules(createdIDs)))
 			}
 			createdIDs = append(createdIDs, rule.ID)
+			reports = append(reports, plan.Pipeline)
 			slog.Info("CreateRule: Created new rule", "id", rule.ID)
 		}
 	}
@@ -280,10 +392,21 @@ func (h *RuleHandlers) CreateRule(ctx context.Context, input *CreateRuleInput) (
 	resp := &CreateRuleOutput{}
 	resp.Body.IDs = createdIDs
 	resp.Body.Count = len(createdIDs)
+	resp.Body.Pipelines = reports
+	resp.Body.Warnings = warnings
 	slog.Info("CreateRule: Successfully processed rules", "count", len(createdIDs), "template", input.Body.TemplateName)
 	return resp, nil
 }
 
+// savedRules describes the rules of a batch saved before a store error, which the store cannot
+// roll back.
+func savedRules(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return " (rules saved before the error: " + strings.Join(ids, ", ") + ")"
+}
+
 // PlanRule simulates rule creation and returns the plan.
 func (h *RuleHandlers) PlanRule(ctx context.Context, input *CreateRuleInput) (*PlanRuleOutput, error) {
 	// Parse parameters into the expected structure
@@ -339,15 +462,23 @@ func (h *RuleHandlers) GetRule(ctx context.Context, input *GetRuleInput) (*GetRu
 	return &GetRuleOutput{Body: rule}, nil
 }
 
-// ListRules lists all rules with pagination.
+// ListRules lists all rules with pagination. Projected parameter paths must be declared by a
+// template schema.
 func (h *RuleHandlers) ListRules(ctx context.Context, input *ListRulesInput) (*ListRulesOutput, error) {
-	rules, err := h.ruleStore.ListRules(ctx, input.Offset, input.Limit)
+	page, err := pageRequest(input.Limit, input.Offset, input.Cursor, input.Sort, input.Fields)
 	if err != nil {
-		slog.Error("ListRules: Failed to list rules", "error", err)
-		return nil, huma.Error500InternalServerError(err.Error())
+		return nil, err
 	}
 
-	return &ListRulesOutput{Body: rules}, nil
+	result, err := h.ruleService.SearchRules(ctx, database.RuleFilter{}, page)
+	if err != nil {
+		if !errors.Is(err, database.ErrInvalidPage) {
+			slog.Error("ListRules: Failed to list rules", "error", err)
+		}
+		return nil, pageError(err)
+	}
+
+	return &ListRulesOutput{Link: linkHeader(&input.url, result), Total: result.Total, Body: result.Rules}, nil
 }
 
 // UpdateRule updates an existing rule.
@@ -365,6 +496,10 @@ func (h *RuleHandlers) UpdateRule(ctx context.Context, input *UpdateRuleInput) (
 		templateName = existingRule.TemplateName
 	}
 
+	if err := input.Body.Metadata.Validate(); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
 	// 2. Plan the update (checks for conflicts)
 	plan, err := h.ruleService.PlanRuleUpdate(ctx, input.ID, templateName, input.Body.Parameters)
 	if err != nil {
@@ -372,31 +507,79 @@ func (h *RuleHandlers) UpdateRule(ctx context.Context, input *UpdateRuleInput) (
 		return nil, huma.Error400BadRequest(err.Error())
 	}
 
-	// 3. Check for conflict
+	// 3. Check ownership and conflicts
+	if plan.Action == rules.PlanActionForbidden {
+		return nil, huma.Error403Forbidden(plan.Reason)
+	}
 	if plan.Action == "conflict" {
 		return nil, huma.Error409Conflict(plan.Reason)
 	}
 
-	// 4. Validate template syntax (PlanRuleUpdate only validates schema)
-	// We use the NewRule from the plan which has the merged parameters
-	if _, err := h.ruleService.GenerateRule(ctx, templateName, plan.NewRule.Parameters); err != nil {
+	if !plan.Pipeline.Passed() {
+		slog.Warn("UpdateRule: Pipeline failed", "id", input.ID, "error", plan.Pipeline.Err())
+		return nil, pipelineFailure("Pipeline failed", plan.Pipeline)
+	}
+
+	// 4. Apply the new metadata
+	// We use the NewRule from the plan which has the merged parameters and the existing metadata
+	if input.Body.Metadata != nil {
+		plan.NewRule.Metadata = input.Body.Metadata
+		if err := h.ruleService.AuthorizeRuleChange(ctx, nil, plan.NewRule); err != nil {
+			return nil, huma.Error403Forbidden(err.Error())
+		}
+	}
+
+	// 5. Validate template syntax (PlanRuleUpdate only validates schema)
+	if err := h.ruleService.CheckRule(ctx, templateName, plan.NewRule.Parameters, plan.NewRule.Metadata); err != nil {
 		return nil, huma.Error400BadRequest(err.Error())
 	}
 
-	// 5. Update the rule
-	// We use the NewRule from the plan which has the merged parameters
+	// 6. Update the rule
 	if err := h.ruleStore.UpdateRule(ctx, input.ID, plan.NewRule); err != nil {
-		slog.Error("UpdateRule: Failed to update rule", "id", input.ID, "error", err)
-		return nil, huma.Error500InternalServerError(err.Error())
+		return nil, ruleNameError("UpdateRule: Failed to update rule", err, "id", input.ID)
 	}
 
 	resp := &UpdateRuleOutput{}
 	resp.Body.ID = input.ID
+	resp.Body.Pipeline = plan.Pipeline
+	resp.Body.Warnings = pipelineWarnings("", plan.Pipeline)
 	return resp, nil
 }
 
+// UpdateRuleMetadata replaces the metadata of a rule, leaving its parameters untouched. Since the
+// parameters do not change, the rule is not re-planned, but it is rendered with the new metadata.
+// Empty metadata removes it.
+func (h *RuleHandlers) UpdateRuleMetadata(ctx context.Context, input *UpdateRuleMetadataInput) (*UpdateRuleMetadataOutput, error) {
+	rule, err := h.ruleStore.GetRule(ctx, input.ID)
+	if err != nil {
+		return nil, huma.Error404NotFound("Rule not found: " + err.Error())
+	}
+
+	metadata := &input.Body
+	if err := metadata.Validate(); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if err := h.ruleService.AuthorizeRuleChange(ctx, rule, &database.Rule{Metadata: metadata}); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+	rule.Metadata = metadata
+	if metadata.IsZero() {
+		rule.Metadata = nil
+	}
+	// Metadata labels are rendered into the alerts, so the rule must still generate
+	if err := h.ruleService.CheckRule(ctx, rule.TemplateName, rule.Parameters, rule.Metadata); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	if err := h.ruleStore.UpdateRule(ctx, input.ID, rule); err != nil {
+		slog.Error("UpdateRuleMetadata: Failed to update rule", "id", input.ID, "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &UpdateRuleMetadataOutput{Body: rule}, nil
+}
+
 // PlanUpdateRule simulates rule update and returns the plan.
-func (h *RuleHandlers) PlanUpdateRule(ctx context.Context, input *UpdateRuleInput) (*rules.RulePlan, error) {
+func (h *RuleHandlers) PlanUpdateRule(ctx context.Context, input *UpdateRuleInput) (*PlanUpdateRuleOutput, error) {
 	// 1. Fetch existing rule to get template name if not provided
 	templateName := input.Body.TemplateName
 	if templateName == "" {
@@ -413,11 +596,18 @@ func (h *RuleHandlers) PlanUpdateRule(ctx context.Context, input *UpdateRuleInpu
 		return nil, huma.Error400BadRequest(err.Error())
 	}
 
-	return plan, nil
+	return &PlanUpdateRuleOutput{Body: plan}, nil
 }
 
 // DeleteRule deletes a rule by ID.
 func (h *RuleHandlers) DeleteRule(ctx context.Context, input *DeleteRuleInput) (*DeleteRuleOutput, error) {
+	if err := h.ruleService.AuthorizeRuleDeletion(ctx, input.ID); err != nil {
+		if errors.Is(err, rules.ErrForbidden) {
+			return nil, huma.Error403Forbidden(err.Error())
+		}
+		slog.Error("DeleteRule: Failed to check ownership", "id", input.ID, "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
 	if err := h.ruleStore.DeleteRule(ctx, input.ID); err != nil {
 		slog.Error("DeleteRule: Failed to delete rule", "id", input.ID, "error", err)
 		return nil, huma.Error500InternalServerError(err.Error())
@@ -428,7 +618,13 @@ func (h *RuleHandlers) DeleteRule(ctx context.Context, input *DeleteRuleInput) (
 
 // GetOptions resolves dynamic options for a field.
 func (h *RuleHandlers) GetOptions(ctx context.Context, input *OptionsRequest) (*OptionsResponse, error) {
-	options, err := h.ruleService.GetOptions(ctx, input.Body.TemplateName, input.Body.FieldPath, input.Body.CurrentValues)
+	options, err := h.ruleService.GetOptions(ctx, rules.OptionsQuery{
+		TemplateName: input.Body.TemplateName,
+		FieldPath:    input.Body.FieldPath,
+		Values:       input.Body.CurrentValues,
+		Item:         input.Body.CurrentItem,
+		Search:       input.Body.Search,
+	})
 	if err != nil {
 		slog.Error("GetOptions: Failed to get options", "template", input.Body.TemplateName, "field", input.Body.FieldPath, "error", err)
 		return nil, huma.Error500InternalServerError(err.Error())
@@ -438,3 +634,34 @@ func (h *RuleHandlers) GetOptions(ctx context.Context, input *OptionsRequest) (*
 	resp.Body.Options = options
 	return resp, nil
 }
+
+// pipelineFailure builds a 400 response that carries one error detail per failed pipeline step.
+func pipelineFailure(msg string, report *rules.PipelineReport) error {
+	failed := report.Failed()
+	details := make([]error, 0, len(failed))
+	for _, step := range failed {
+		details = append(details, &huma.ErrorDetail{
+			Message:  step.Message,
+			Location: fmt.Sprintf("pipelines.%s.%s", step.Scope, step.Name),
+			Value:    step,
+		})
+	}
+	return huma.Error400BadRequest(msg, details...)
+}
+
+// pipelineWarnings renders the non-blocking warnings of a pipeline report as messages.
+func pipelineWarnings(prefix string, report *rules.PipelineReport) []string {
+	if report == nil {
+		return nil
+	}
+	var warnings []string
+	for _, step := range report.Steps {
+		if step.Status == rules.StepStatusWarning {
+			warnings = append(warnings, fmt.Sprintf("%s%s/%s: %s", prefix, step.Scope, step.Name, step.Message))
+		}
+		for _, w := range step.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s%s/%s: %s", prefix, step.Scope, step.Name, w))
+		}
+	}
+	return warnings
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"rulemanager/internal/database"
 	"rulemanager/internal/rules"
 	"rulemanager/internal/validation"
+	"strings"
 	"testing"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -130,6 +136,89 @@ func TestRuleHandlers_CreateRule(t *testing.T) {
 		mockStore.AssertExpectations(t)
 	})
 
+	t.Run("PipelineFailure", func(t *testing.T) {
+		input := &CreateRuleInput{}
+		input.Body.TemplateName = "k8s"
+		input.Body.Parameters = json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}]}`)
+
+		// validate_metric_exists without a datasource always fails
+		pipelineSchema := `{
+			"type": "object",
+			"pipelines": [
+				{"name": "check_metric", "type": "validate_metric_exists", "parameters": {"metric_name": "up"}}
+			]
+		}`
+		mockTP.On("GetSchema", ctx, "k8s").Return(pipelineSchema, nil).Once()
+		mockStore.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+
+		output, err := handlers.CreateRule(ctx, input)
+
+		assert.Error(t, err)
+		assert.Nil(t, output)
+		var statusErr *huma.ErrorModel
+		if assert.ErrorAs(t, err, &statusErr) {
+			assert.Equal(t, http.StatusBadRequest, statusErr.Status)
+			assert.Len(t, statusErr.Errors, 1)
+			assert.Equal(t, "pipelines.global.check_metric", statusErr.Errors[0].Location)
+		}
+		mockTP.AssertExpectations(t)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("PipelineWarning", func(t *testing.T) {
+		input := &CreateRuleInput{}
+		input.Body.TemplateName = "k8s"
+		input.Body.Parameters = json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}]}`)
+
+		warnSchema := `{
+			"type": "object",
+			"pipelines": [
+				{"name": "check_metric", "type": "validate_metric_exists", "on_failure": "warn", "parameters": {"metric_name": "up"}}
+			]
+		}`
+		mockTP.On("GetSchema", ctx, "k8s").Return(warnSchema, nil).Twice()
+		mockTP.On("GetTemplate", ctx, "k8s").Return(tmpl, nil).Once()
+		mockStore.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+		mockStore.On("CreateRule", ctx, mock.AnythingOfType("*database.Rule")).Return(nil).Once()
+
+		output, err := handlers.CreateRule(ctx, input)
+
+		assert.NoError(t, err)
+		assert.Len(t, output.Body.IDs, 1)
+		assert.Len(t, output.Body.Warnings, 1)
+		assert.Contains(t, output.Body.Warnings[0], "rule 0: global/check_metric")
+		assert.Equal(t, rules.StepStatusWarning, output.Body.Pipelines[0].Steps[0].Status)
+		mockTP.AssertExpectations(t)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("PipelinePatchPersisted", func(t *testing.T) {
+		input := &CreateRuleInput{}
+		input.Body.TemplateName = "k8s"
+		input.Body.Parameters = json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}]}`)
+
+		patchSchema := `{
+			"type": "object",
+			"pipelines": [
+				{"name": "default_team", "type": "patch_parameters", "parameters": {"patch": [{"op": "add", "path": "/common", "value": {"labels": {"team": "{{ .target.namespace }}"}}}]}}
+			]
+		}`
+		mockTP.On("GetSchema", ctx, "k8s").Return(patchSchema, nil).Twice()
+		mockTP.On("GetTemplate", ctx, "k8s").Return(tmpl, nil).Once()
+		mockStore.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+		var persisted *database.Rule
+		mockStore.On("CreateRule", ctx, mock.AnythingOfType("*database.Rule")).Run(func(args mock.Arguments) {
+			persisted = args.Get(1).(*database.Rule)
+		}).Return(nil).Once()
+
+		_, err := handlers.CreateRule(ctx, input)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"target": {"namespace": "test"}, "common": {"labels": {"team": "test"}}, "rules": [{"rule_type": "cpu"}]}`, string(persisted.Parameters))
+		mockTP.AssertExpectations(t)
+		mockStore.AssertExpectations(t)
+	})
+
 	t.Run("MissingRulesArray", func(t *testing.T) {
 		input := &CreateRuleInput{}
 		input.Body.TemplateName = "k8s"
@@ -167,6 +256,39 @@ func TestRuleHandlers_CreateRule(t *testing.T) {
 	})
 }
 
+func TestRuleHandlers_CreateRuleBatchAtomic(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+
+	// validate_metric_exists without a datasource always fails; only the ram rule runs it
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{
+		"type": "object",
+		"pipelines": [
+			{"name": "check_metric", "type": "validate_metric_exists", "condition": {"path": "rules[0].rule_type", "value": "ram"}, "parameters": {"metric_name": "up"}}
+		]
+	}`, nil)
+	mockTP.On("GetTemplate", mock.Anything, "k8s").Return(`alert: test`, nil)
+	handlers := &RuleHandlers{ruleStore: store, ruleService: rules.NewService(mockTP, store, validation.NewJSONSchemaValidator())}
+
+	input := &CreateRuleInput{}
+	input.Body.TemplateName = "k8s"
+	input.Body.Parameters = json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}, {"rule_type": "ram"}]}`)
+
+	output, err := handlers.CreateRule(ctx, input)
+
+	assert.Nil(t, output)
+	var statusErr *huma.ErrorModel
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusBadRequest, statusErr.Status)
+		assert.Contains(t, statusErr.Detail, "Pipeline failed for rule 1")
+	}
+	stored, err := store.ListRules(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, stored, "rule 0 is not saved when rule 1 fails")
+}
+
 func TestRuleHandlers_GetRule(t *testing.T) {
 	mockStore := new(MockRuleStore)
 	mockTP := new(MockTemplateProvider)
@@ -227,18 +349,21 @@ func TestRuleHandlers_ListRules(t *testing.T) {
 			{ID: "2", TemplateName: "k8s"},
 		}
 
-		mockStore.On("ListRules", ctx, 0, 10).Return(expectedRules, nil).Once()
+		page := database.PageRequest{Limit: 10, Sort: database.DefaultRuleSort}
+		mockStore.On("SearchRulesPage", ctx, database.RuleFilter{}, page).Return(&database.RulePage{Rules: expectedRules, Total: 2}, nil).Once()
 
 		output, err := handlers.ListRules(ctx, &ListRulesInput{Offset: 0, Limit: 10})
 
 		assert.NoError(t, err)
 		assert.NotNil(t, output)
 		assert.Equal(t, expectedRules, output.Body)
+		assert.Equal(t, int64(2), output.Total)
 		mockStore.AssertExpectations(t)
 	})
 
 	t.Run("StoreError", func(t *testing.T) {
-		mockStore.On("ListRules", ctx, 0, 10).Return(([]*database.Rule)(nil), errors.New("database error")).Once()
+		page := database.PageRequest{Limit: 10, Sort: database.DefaultRuleSort}
+		mockStore.On("SearchRulesPage", ctx, database.RuleFilter{}, page).Return((*database.RulePage)(nil), errors.New("database error")).Once()
 
 		output, err := handlers.ListRules(ctx, &ListRulesInput{Offset: 0, Limit: 10})
 
@@ -248,6 +373,241 @@ func TestRuleHandlers_ListRules(t *testing.T) {
 	})
 }
 
+func TestRuleHandlers_Pagination(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	for _, id := range []string{"r1", "r2", "r3"} {
+		assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{
+			ID:           id,
+			TemplateName: "k8s",
+			Parameters:   json.RawMessage(`{"target": {"namespace": "team-` + id + `"}}`),
+		}))
+	}
+
+	schema := `{"type": "object", "properties": {"target": {"type": "object", "properties": {"namespace": {"type": "string"}}}}}`
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(schema, nil)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{{Name: "k8s", Schema: json.RawMessage(schema)}}, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	get := func(target string) (*httptest.ResponseRecorder, []string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var body []database.Rule
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		var ids []string
+		for _, r := range body {
+			ids = append(ids, r.ID)
+		}
+		return w, ids
+	}
+	nextLink := func(w *httptest.ResponseRecorder) string {
+		for _, link := range strings.Split(w.Header().Get("Link"), ", ") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				return strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+		return ""
+	}
+
+	t.Run("List", func(t *testing.T) {
+		w, ids := get("/api/v1/rules?limit=2&sort=id:desc")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"r3", "r2"}, ids)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+		assert.Contains(t, w.Header().Get("Link"), `</api/v1/rules?limit=2&sort=id%3Adesc>; rel="first"`)
+
+		next := nextLink(w)
+		assert.Contains(t, next, "cursor=")
+		w, ids = get(next)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"r1"}, ids)
+		assert.Empty(t, nextLink(w), "last page")
+	})
+
+	t.Run("ListOffset", func(t *testing.T) {
+		w, ids := get("/api/v1/rules?offset=0&limit=21")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"r1", "r2", "r3"}, ids)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		w, ids := get("/api/v1/rules/search?templateName=k8s&q=target.namespace%3D~%22team-.*%22&limit=1")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"r1"}, ids)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+
+		next := nextLink(w)
+		assert.Contains(t, next, "templateName=k8s")
+		w, ids = get(next)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"r2"}, ids)
+	})
+
+	t.Run("InvalidSort", func(t *testing.T) {
+		w, _ := get("/api/v1/rules?sort=parameters.target")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = get("/api/v1/rules/search?sort=id:sideways")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		w, _ := get("/api/v1/rules?cursor=garbage")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		w, _ := get("/api/v1/rules?limit=1&fields=target.namespace")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[{"id": "r1", "parameters": {"target": {"namespace": "team-r1"}}}]`, w.Body.String())
+		assert.Contains(t, nextLink(w), "fields=target.namespace")
+
+		w, _ = get("/api/v1/rules/search?templateName=k8s&fields=templateName&limit=1")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[{"id": "r1", "templateName": "k8s"}]`, w.Body.String())
+	})
+
+	t.Run("InvalidFields", func(t *testing.T) {
+		w, _ := get("/api/v1/rules?fields=target.cluster")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = get("/api/v1/rules/search?templateName=k8s&fields=target..namespace")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRuleHandlers_FacetRules(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	for id, namespace := range map[string]string{"r1": "team-a", "r2": "team-a", "r3": "team-b"} {
+		assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{
+			ID:           id,
+			TemplateName: "k8s",
+			Parameters:   json.RawMessage(`{"target": {"namespace": "` + namespace + `"}}`),
+		}))
+	}
+
+	schema := `{"type": "object", "properties": {"target": {"type": "object", "properties": {"namespace": {"type": "string"}}}}}`
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(schema, nil)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{{Name: "k8s", Schema: json.RawMessage(schema)}}, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	get := func(target string) (*httptest.ResponseRecorder, database.RuleFacets) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var body database.RuleFacets
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	t.Run("Counts", func(t *testing.T) {
+		w, body := get("/api/v1/rules/facets?fields=templateName,target.namespace")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, int64(3), body.Total)
+		assert.Equal(t, map[string][]database.FacetValue{
+			"templateName":                {{Value: "k8s", Count: 3}},
+			"parameters.target.namespace": {{Value: "team-a", Count: 2}, {Value: "team-b", Count: 1}},
+		}, body.Facets)
+	})
+
+	t.Run("SearchFilters", func(t *testing.T) {
+		w, body := get("/api/v1/rules/facets?fields=target.namespace&templateName=k8s&q=id!%3Dr1&limit=1")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, int64(2), body.Total)
+		assert.Equal(t, []database.FacetValue{{Value: "team-a", Count: 1}}, body.Facets["parameters.target.namespace"])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		w, _ := get("/api/v1/rules/facets")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "fields is required")
+		for _, target := range []string{
+			"/api/v1/rules/facets?fields=createdAt",
+			"/api/v1/rules/facets?fields=target",
+			"/api/v1/rules/facets?fields=target.cluster",
+			"/api/v1/rules/facets?fields=templateName&target.cluster=a",
+		} {
+			w, _ := get(target)
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		}
+	})
+}
+
+func TestRuleHandlers_UpdateRuleMetadata(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	params := json.RawMessage(`{"target":{"namespace":"team-a"}}`)
+	assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{ID: "r1", TemplateName: "k8s", Parameters: params}))
+	assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{ID: "r2", TemplateName: "k8s", Parameters: params}))
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object"}`, nil)
+	mockTP.On("GetTemplate", mock.Anything, "k8s").Return("alert: test\nlabels:\n  severity: critical\n{{- range $k, $v := ruleLabels }}\n  {{ $k }}: {{ $v }}\n{{- end }}\n", nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r1/metadata", `{"owner": "alice", "team": "payments", "labels": {"tier": "1"}, "links": [{"name": "runbook", "url": "https://runbooks.example.com/cpu"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		rule, err := store.GetRule(context.Background(), "r1")
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(params), string(rule.Parameters), "parameters are untouched")
+		assert.Equal(t, &database.RuleMetadata{
+			Owner:  "alice",
+			Team:   "payments",
+			Labels: map[string]string{"tier": "1"},
+			Links:  []database.RuleLink{{Name: "runbook", URL: "https://runbooks.example.com/cpu"}},
+		}, rule.Metadata)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/v1/rules/search?metadata.team=payments", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body []database.Rule
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body, 1) {
+			assert.Equal(t, "r1", body[0].ID)
+		}
+
+		w = do(http.MethodGet, "/api/v1/rules/search?metadata.ticket=OPS-1", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r1/metadata", `{}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		rule, err := store.GetRule(context.Background(), "r1")
+		assert.NoError(t, err)
+		assert.Nil(t, rule.Metadata)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/r2/metadata", `{"labels": {"cost-center": "42"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do(http.MethodPut, "/api/v1/rules/r2/metadata", `{"labels": {"severity": "warning"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "labels colliding with template labels are rejected")
+		assert.Contains(t, w.Body.String(), "severity")
+		w = do(http.MethodPut, "/api/v1/rules/missing/metadata", `{"team": "payments"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
 func TestRuleHandlers_UpdateRule(t *testing.T) {
 	mockStore := new(MockRuleStore)
 	mockTP := new(MockTemplateProvider)
@@ -379,6 +739,36 @@ func TestRuleHandlers_UpdateRule(t *testing.T) {
 	})
 }
 
+func TestRuleHandlers_PlanUpdateRule(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{
+		ID:           "123",
+		TemplateName: "k8s",
+		Parameters:   json.RawMessage(`{"target": {"namespace": "old"}}`),
+	}))
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object", "properties": {"target": {"type": "object"}}}`, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rules/123/plan", strings.NewReader(`{"templateName": "k8s", "parameters": {"target": {"namespace": "new"}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// The plan is the response body
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var plan rules.RulePlan
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, "update", plan.Action)
+	assert.Equal(t, "123", plan.NewRule.ID)
+	assert.JSONEq(t, `{"target": {"namespace": "new"}}`, string(plan.NewRule.Parameters))
+}
+
 func TestRuleHandlers_DeleteRule(t *testing.T) {
 	mockStore := new(MockRuleStore)
 	mockTP := new(MockTemplateProvider)
@@ -417,11 +807,22 @@ func TestRuleHandlers_DeleteRule(t *testing.T) {
 
 func TestRuleHandlers_SearchRules(t *testing.T) {
 	mockStore := new(MockRuleStore)
+	mockTP := new(MockTemplateProvider)
 	handlers := &RuleHandlers{
-		ruleStore: mockStore,
+		ruleStore:   mockStore,
+		ruleService: rules.NewService(mockTP, mockStore, validation.NewJSONSchemaValidator()),
 	}
 	ctx := context.Background()
 
+	schema := `{"type": "object", "properties": {
+		"target": {"type": "object", "properties": {"service": {"type": "string"}, "environment": {"type": "string"}, "namespace": {"type": "string"}}},
+		"rules": {"type": "array", "items": {"type": "object", "properties": {"threshold": {"type": "number"}, "enabled": {"type": "boolean"}}}}}}`
+	mockTP.On("GetSchema", ctx, "demo").Return(schema, nil)
+	mockTP.On("GetSchema", ctx, "missing").Return("", errors.New("schema not found"))
+	mockTP.On("ListSchemas", ctx).Return([]*database.Schema{{Name: "demo", Schema: json.RawMessage(schema)}}, nil)
+	// Inputs built without Huma have no limit, so every rule is returned
+	allRulesPage := database.PageRequest{Sort: database.DefaultRuleSort}
+
 	t.Run("SearchByTemplateName", func(t *testing.T) {
 		expectedRules := []*database.Rule{
 			{ID: "1", TemplateName: "demo"},
@@ -432,7 +833,7 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 			TemplateName: "demo",
 			Parameters:   map[string]string{},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return(expectedRules, nil).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return(&database.RulePage{Rules: expectedRules}, nil).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{"templateName": "demo"},
@@ -456,7 +857,7 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 				"parameters.target.service": "payment-service",
 			},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return(expectedRules, nil).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return(&database.RulePage{Rules: expectedRules}, nil).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{
@@ -483,7 +884,7 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 				"parameters.target.environment": "production",
 			},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return(expectedRules, nil).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return(&database.RulePage{Rules: expectedRules}, nil).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{
@@ -507,7 +908,7 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 				"parameters.target.service": "non-existent",
 			},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return([]*database.Rule{}, nil).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return(&database.RulePage{Rules: []*database.Rule{}}, nil).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{
@@ -531,7 +932,7 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 			{ID: "1", TemplateName: "demo"},
 			{ID: "2", TemplateName: "k8s"},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return(allRules, nil).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return(&database.RulePage{Rules: allRules}, nil).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{},
@@ -544,12 +945,104 @@ func TestRuleHandlers_SearchRules(t *testing.T) {
 		mockStore.AssertExpectations(t)
 	})
 
+	t.Run("SearchByQuery", func(t *testing.T) {
+		expectedRules := []*database.Rule{
+			{ID: "1", TemplateName: "demo"},
+		}
+
+		mockStore.On("SearchRulesPage", ctx, mock.MatchedBy(func(filter database.RuleFilter) bool {
+			return filter.TemplateName == "demo" && len(filter.Parameters) == 0 &&
+				filter.Query != nil && filter.Query.String() == `target.namespace=~"team-.*" AND rules.threshold>0.8`
+		}), allRulesPage).Return(&database.RulePage{Rules: expectedRules}, nil).Once()
+
+		input := &SearchRulesInput{
+			QueryParams: map[string]string{
+				"templateName": "demo",
+				"q":            `target.namespace=~"team-.*" AND rules.threshold>0.8`,
+			},
+		}
+		output, err := handlers.SearchRules(ctx, input)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedRules, output.Body)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		input := &SearchRulesInput{
+			QueryParams: map[string]string{"q": `target.namespace=~"team-(" AND`},
+		}
+		output, err := handlers.SearchRules(ctx, input)
+
+		assert.Error(t, err)
+		assert.Nil(t, output)
+		var statusErr huma.StatusError
+		if assert.ErrorAs(t, err, &statusErr) {
+			assert.Equal(t, http.StatusBadRequest, statusErr.GetStatus())
+		}
+	})
+
+	t.Run("RejectsInvalidFilters", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			params map[string]string
+			err    string
+		}{
+			{"UnknownParameter", map[string]string{"parameters.target.cluster": "a"}, "unknown field 'target.cluster'"},
+			{"RuleFieldAsParameter", map[string]string{"_id": "1"}, "unknown field '_id'"},
+			{"OperatorKey", map[string]string{"$where": "1"}, "invalid field '$where'"},
+			{"OperatorSegment", map[string]string{"target.$ne": "a"}, "invalid field 'target.$ne'"},
+			{"NumberMismatch", map[string]string{"templateName": "demo", "rules.threshold": "high"}, "field 'rules.threshold' is a number, got 'high'"},
+			{"UnknownTemplate", map[string]string{"templateName": "missing", "target.service": "a"}, "unknown template 'missing'"},
+			{"UnknownQueryField", map[string]string{"q": `target.cluster=a`}, "unknown field 'target.cluster'"},
+			{"UnknownElemMatchField", map[string]string{"q": `rules[severity=critical]`}, "unknown field 'rules.severity'"},
+			{"QueryTypeMismatch", map[string]string{"q": `rules.threshold>"high"`}, "field 'rules.threshold' is a number, got 'high'"},
+			{"RegexOnNumber", map[string]string{"q": `rules.threshold=~"0.*"`}, "regular expressions need a string field"},
+			{"OrderedBool", map[string]string{"q": `rules.enabled>true`}, "needs a number, string or time"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				output, err := handlers.SearchRules(ctx, &SearchRulesInput{QueryParams: tc.params})
+
+				assert.Nil(t, output)
+				var statusErr huma.StatusError
+				if assert.ErrorAs(t, err, &statusErr) {
+					assert.Equal(t, http.StatusBadRequest, statusErr.GetStatus())
+				}
+				assert.ErrorContains(t, err, tc.err)
+			})
+		}
+	})
+
+	t.Run("AcceptsTypedFilters", func(t *testing.T) {
+		expectedFilter := database.RuleFilter{
+			TemplateName: "demo",
+			Parameters:   map[string]string{"rules.threshold": "0.8", "rules.enabled": "true"},
+		}
+		mockStore.On("SearchRulesPage", ctx, mock.MatchedBy(func(filter database.RuleFilter) bool {
+			return assert.ObjectsAreEqual(expectedFilter.Parameters, filter.Parameters) && filter.Query != nil
+		}), allRulesPage).Return(&database.RulePage{Rules: []*database.Rule{}}, nil).Once()
+
+		input := &SearchRulesInput{
+			QueryParams: map[string]string{
+				"templateName":    "demo",
+				"rules.threshold": "0.8",
+				"rules.enabled":   "true",
+				"q":               `rules[threshold>=0.9 AND enabled=true] OR rules.0.threshold<0.1 OR exists(target.namespace) OR updatedAt>2025-01-01`,
+			},
+		}
+		_, err := handlers.SearchRules(ctx, input)
+
+		assert.NoError(t, err)
+		mockStore.AssertExpectations(t)
+	})
+
 	t.Run("StoreError", func(t *testing.T) {
 		expectedFilter := database.RuleFilter{
 			TemplateName: "demo",
 			Parameters:   map[string]string{},
 		}
-		mockStore.On("SearchRules", ctx, expectedFilter).Return(([]*database.Rule)(nil), errors.New("database error")).Once()
+		mockStore.On("SearchRulesPage", ctx, expectedFilter, allRulesPage).Return((*database.RulePage)(nil), errors.New("database error")).Once()
 
 		input := &SearchRulesInput{
 			QueryParams: map[string]string{"templateName": "demo"},
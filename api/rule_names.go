@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"rulemanager/internal/database"
+	"rulemanager/internal/rules"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GetRuleByNameInput struct {
+	Template string `path:"template" doc:"The template of the rule"`
+	Name     string `path:"name" doc:"The name or external ID of the rule"`
+}
+
+type GetRuleByNameOutput struct {
+	Body *database.Rule
+}
+
+type PutRuleByNameInput struct {
+	Template string `path:"template" doc:"The template of the rule"`
+	Name     string `path:"name" doc:"The name or external ID of the rule: letters, digits, '_', '.', ':' and '-', starting with a letter or digit"`
+	Body     struct {
+		Parameters json.RawMessage        `json:"parameters" doc:"The parameters for the rule template; they replace those of an existing rule"`
+		Metadata   *database.RuleMetadata `json:"metadata,omitempty" doc:"Replaces the metadata of the rule when given"`
+	}
+}
+
+type PutRuleByNameOutput struct {
+	Status int
+	Body   struct {
+		ID       string                `json:"id"`
+		Action   string                `json:"action" doc:"create, update or no_change"`
+		Pipeline *rules.PipelineReport `json:"pipeline,omitempty" doc:"Per-step pipeline results for the rule"`
+		Warnings []string              `json:"warnings,omitempty" doc:"Non-blocking pipeline warnings (steps with on_failure=warn and warnings reported by passing steps)"`
+	}
+}
+
+// GetRuleByName retrieves the rule of a template by its client-supplied name, in the tenant of the
+// caller.
+func (h *RuleHandlers) GetRuleByName(ctx context.Context, input *GetRuleByNameInput) (*GetRuleByNameOutput, error) {
+	rule, err := h.ruleStore.GetRuleByName(ctx, rules.TenantFromContext(ctx), input.Template, input.Name)
+	if err != nil {
+		if errors.Is(err, database.ErrRuleNotFound) {
+			return nil, huma.Error404NotFound(err.Error())
+		}
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+	return &GetRuleByNameOutput{Body: rule}, nil
+}
+
+// PutRuleByName creates or replaces the rule of a template with a client-supplied name, so that
+// applying the same request again changes nothing (see Service.PlanRuleUpsert).
+// Responds 201 when the rule is created and 200 otherwise.
+func (h *RuleHandlers) PutRuleByName(ctx context.Context, input *PutRuleByNameInput) (*PutRuleByNameOutput, error) {
+	if err := database.ValidateRuleName(input.Name); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if len(input.Body.Parameters) == 0 {
+		return nil, huma.Error400BadRequest("'parameters' is required")
+	}
+	if err := input.Body.Metadata.Validate(); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+	if err := h.ruleService.AuthorizeRuleChange(ctx, nil, &database.Rule{Metadata: input.Body.Metadata}); err != nil {
+		return nil, huma.Error403Forbidden(err.Error())
+	}
+
+	plan, err := h.ruleService.PlanRuleUpsert(ctx, input.Template, input.Name, input.Body.Parameters)
+	if err != nil {
+		slog.Warn("PutRuleByName: Planning failed", "template", input.Template, "name", input.Name, "error", err)
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	switch plan.Action {
+	case rules.PlanActionForbidden:
+		return nil, huma.Error403Forbidden(plan.Reason)
+	case "conflict":
+		return nil, huma.Error409Conflict(plan.Reason)
+	}
+
+	if !plan.Pipeline.Passed() {
+		slog.Warn("PutRuleByName: Pipeline failed", "template", input.Template, "name", input.Name, "error", plan.Pipeline.Err())
+		return nil, pipelineFailure("Pipeline failed", plan.Pipeline)
+	}
+
+	// Validate template syntax (planning only validates the schema), with the metadata the rule keeps
+	metadata := input.Body.Metadata
+	if metadata == nil && plan.ExistingRule != nil {
+		metadata = plan.ExistingRule.Metadata
+	}
+	if err := h.ruleService.CheckRule(ctx, input.Template, plan.NewRule.Parameters, metadata); err != nil {
+		return nil, huma.Error400BadRequest(err.Error())
+	}
+
+	resp := &PutRuleByNameOutput{Status: http.StatusOK}
+	resp.Body.Action = plan.Action
+	resp.Body.Pipeline = plan.Pipeline
+	resp.Body.Warnings = pipelineWarnings("", plan.Pipeline)
+
+	switch {
+	case plan.Action == "create":
+		rule := plan.NewRule
+		rule.ID = primitive.NewObjectID().Hex()
+		rule.Metadata = input.Body.Metadata
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = time.Now()
+		if err := h.ruleStore.CreateRule(ctx, rule); err != nil {
+			return nil, ruleNameError("PutRuleByName: Failed to create rule", err, "template", input.Template, "name", input.Name)
+		}
+		resp.Status = http.StatusCreated
+		resp.Body.ID = rule.ID
+		slog.Info("PutRuleByName: Created rule", "id", rule.ID, "template", input.Template, "name", input.Name)
+
+	case plan.Action == "no_change" && (input.Body.Metadata == nil || reflect.DeepEqual(input.Body.Metadata, plan.ExistingRule.Metadata)):
+		resp.Body.ID = plan.ExistingRule.ID
+
+	default:
+		// Update the rule with the name, or adopt the unnamed rule with the same uniqueness keys
+		rule := *plan.ExistingRule
+		rule.Name = input.Name
+		rule.TemplateName = input.Template
+		rule.Parameters = plan.NewRule.Parameters
+		if input.Body.Metadata != nil {
+			rule.Metadata = input.Body.Metadata
+		}
+		if err := h.ruleStore.UpdateRule(ctx, rule.ID, &rule); err != nil {
+			return nil, ruleNameError("PutRuleByName: Failed to update rule", err, "id", rule.ID)
+		}
+		resp.Body.Action = "update"
+		resp.Body.ID = rule.ID
+		slog.Info("PutRuleByName: Updated rule", "id", rule.ID, "template", input.Template, "name", input.Name)
+	}
+	return resp, nil
+}
+
+// ruleNameError maps store errors of rule writes to API errors: names taken concurrently are
+// conflicts. Other errors are logged with msg and args.
+func ruleNameError(msg string, err error, args ...any) error {
+	if errors.Is(err, database.ErrDuplicateRuleName) {
+		return huma.Error409Conflict(err.Error())
+	}
+	slog.Error(msg, append(args, "error", err)...)
+	return huma.Error500InternalServerError(err.Error())
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rulemanager/config"
+	"rulemanager/internal/database"
+	"rulemanager/internal/rules"
+	"rulemanager/internal/validation"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRuleHandlers_RuleNames(t *testing.T) {
+	router := chi.NewMux()
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, store.CreateRule(context.Background(), &database.Rule{
+		ID:           "unnamed",
+		TemplateName: "k8s",
+		Parameters:   json.RawMessage(`{"target": {"namespace": "team-b"}, "rules": [{"rule_type": "cpu"}]}`),
+	}))
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object", "uniqueness_keys": ["target.namespace"]}`, nil)
+	mockTP.On("GetTemplate", mock.Anything, "k8s").Return(`alert: test`, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	type putResponse struct {
+		ID     string `json:"id"`
+		Action string `json:"action"`
+	}
+	put := func(target, body string) (*httptest.ResponseRecorder, putResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var resp putResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+	get := func(target string) (*httptest.ResponseRecorder, *database.Rule) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var rule database.Rule
+		_ = json.Unmarshal(w.Body.Bytes(), &rule)
+		return w, &rule
+	}
+
+	body := `{"parameters": {"target": {"namespace": "team-a"}, "rules": [{"rule_type": "cpu", "threshold": 80}]}, "metadata": {"team": "payments"}}`
+	var id string
+
+	t.Run("Create", func(t *testing.T) {
+		w, resp := put("/api/v1/rules/by-name/k8s/jira:OPS-1", body)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.Equal(t, "create", resp.Action)
+		id = resp.ID
+
+		w, rule := get("/api/v1/rules/by-name/k8s/jira:OPS-1")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, id, rule.ID)
+		assert.Equal(t, "jira:OPS-1", rule.Name)
+		assert.Equal(t, "payments", rule.Metadata.Team)
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		w, resp := put("/api/v1/rules/by-name/k8s/jira:OPS-1", body)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "no_change", resp.Action)
+		assert.Equal(t, id, resp.ID)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		w, resp := put("/api/v1/rules/by-name/k8s/jira:OPS-1", `{"parameters": {"target": {"namespace": "team-a"}, "rules": [{"rule_type": "memory"}]}}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "update", resp.Action)
+		assert.Equal(t, id, resp.ID)
+
+		_, rule := get("/api/v1/rules/by-name/k8s/jira:OPS-1")
+		assert.JSONEq(t, `{"target": {"namespace": "team-a"}, "rules": [{"rule_type": "memory"}]}`, string(rule.Parameters))
+		assert.Equal(t, "payments", rule.Metadata.Team, "metadata is kept when not given")
+	})
+
+	t.Run("KeptOnUpdateByID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/rules/"+id, strings.NewReader(`{"templateName": "k8s", "parameters": {"common": {"severity": "critical"}}}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		_, rule := get("/api/v1/rules/" + id)
+		assert.Equal(t, "jira:OPS-1", rule.Name)
+	})
+
+	t.Run("AdoptUnnamed", func(t *testing.T) {
+		w, resp := put("/api/v1/rules/by-name/k8s/team-b-cpu", `{"parameters": {"target": {"namespace": "team-b"}, "rules": [{"rule_type": "cpu"}]}}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "update", resp.Action)
+		assert.Equal(t, "unnamed", resp.ID)
+	})
+
+	t.Run("ConflictWithOtherName", func(t *testing.T) {
+		w, _ := put("/api/v1/rules/by-name/k8s/OPS-2", body)
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		w, _ := put("/api/v1/rules/by-name/k8s/-bad", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		w, _ := get("/api/v1/rules/by-name/k8s/missing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = get("/api/v1/rules/by-name/other/jira:OPS-1")
+		assert.Equal(t, http.StatusNotFound, w.Code, "names are per template")
+	})
+}
+
+func TestRuleHandlers_RuleNamesPerTenant(t *testing.T) {
+	router := chi.NewMux()
+	router.Use(IdentityMiddleware(config.AuthConfig{Enabled: true}))
+	humaAPI := humachi.New(router, huma.DefaultConfig("Test API", "1.0.0"))
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object", "uniqueness_keys": ["target.namespace"]}`, nil)
+	mockTP.On("GetTemplate", mock.Anything, "k8s").Return(`alert: test`, nil)
+	NewRuleHandlers(humaAPI, store, rules.NewService(mockTP, store, validation.NewJSONSchemaValidator()))
+
+	do := func(method, target, tenant, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-User", "alice")
+		req.Header.Set("X-Forwarded-Tenant", tenant)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	idOf := func(w *httptest.ResponseRecorder) string {
+		var resp struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.ID
+	}
+
+	w := do(http.MethodPut, "/api/v1/rules/by-name/k8s/high-cpu", "acme", `{"parameters": {"target": {"namespace": "acme"}, "rules": [{"rule_type": "cpu"}]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	acmeID := idOf(w)
+	w = do(http.MethodPut, "/api/v1/rules/by-name/k8s/high-cpu", "globex", `{"parameters": {"target": {"namespace": "globex"}, "rules": [{"rule_type": "cpu"}]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, "the same name is free in another tenant: %s", w.Body.String())
+	globexID := idOf(w)
+	assert.NotEqual(t, acmeID, globexID)
+
+	w = do(http.MethodGet, "/api/v1/rules/by-name/k8s/high-cpu", "acme", "")
+	assert.Equal(t, acmeID, idOf(w))
+	w = do(http.MethodGet, "/api/v1/rules/by-name/k8s/high-cpu", "globex", "")
+	assert.Equal(t, globexID, idOf(w))
+	w = do(http.MethodGet, "/api/v1/rules/by-name/k8s/high-cpu", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	t.Run("OtherTenantCannotOverwrite", func(t *testing.T) {
+		w := do(http.MethodPut, "/api/v1/rules/"+acmeID, "globex", `{"templateName": "k8s", "parameters": {"rules": [{"rule_type": "memory"}]}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		// Matching the uniqueness keys of another tenant's rule does not adopt it
+		w = do(http.MethodPut, "/api/v1/rules/by-name/k8s/acme-cpu", "globex", `{"parameters": {"target": {"namespace": "acme"}, "rules": [{"rule_type": "cpu"}]}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		w = do(http.MethodDelete, "/api/v1/rules/"+acmeID, "globex", "")
+		assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+		rule, err := store.GetRule(context.Background(), acmeID)
+		assert.NoError(t, err)
+		assert.Equal(t, "acme", rule.Tenant)
+		assert.JSONEq(t, `{"target": {"namespace": "acme"}, "rules": [{"rule_type": "cpu"}]}`, string(rule.Parameters))
+	})
+}
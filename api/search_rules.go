@@ -2,24 +2,35 @@ package api
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net/url"
 	"rulemanager/internal/database"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
 type SearchRulesInput struct {
-	QueryParams map[string]string // Populated by Resolve method with all query parameters
+	Limit       int               `query:"limit" doc:"Maximum number of rules returned" default:"100" minimum:"1" maximum:"1000"`
+	Offset      int               `query:"offset" doc:"The offset for pagination; ignored when a cursor is given" default:"0" minimum:"0"`
+	Cursor      string            `query:"cursor" doc:"Continue after the previous page; taken from its next Link"`
+	Sort        string            `query:"sort" doc:"Sort order: id, templateName, createdAt or updatedAt, with an optional :asc or :desc (e.g. updatedAt:desc)" default:"id:asc"`
+	Fields      string            `query:"fields" doc:"Comma-separated fields to return, e.g. id,templateName,parameters.target; the id is always returned"`
+	QueryParams map[string]string // Populated by Resolve method with all other query parameters
+	url         url.URL
 }
 
+// pageParams are the query parameters of SearchRulesInput that select the page rather than filter.
+var pageParams = map[string]bool{"limit": true, "offset": true, "cursor": true, "sort": true, "fields": true}
+
 // Resolve implements huma.Resolver to capture all query parameters dynamically
 func (i *SearchRulesInput) Resolve(ctx huma.Context) []error {
 	i.QueryParams = make(map[string]string)
 
 	// Get the URL from the context and extract all query parameters
-	url := ctx.URL()
-	for key, values := range url.Query() {
-		if len(values) > 0 {
+	i.url = ctx.URL()
+	for key, values := range i.url.Query() {
+		if len(values) > 0 && !pageParams[key] {
 			i.QueryParams[key] = values[0]
 		}
 	}
@@ -28,37 +39,73 @@ func (i *SearchRulesInput) Resolve(ctx huma.Context) []error {
 }
 
 type SearchRulesOutput struct {
-	Body []*database.Rule
+	Link  string `header:"Link" doc:"Links to the first and next pages"`
+	Total int64  `header:"X-Total-Count" doc:"The number of matching rules"`
+	Body  []*database.Rule
 }
 
-// SearchRules searches for rules using explicit MongoDB field names.
-// Query parameters map directly to MongoDB document fields (no magic conversions).
+// SearchRules searches for rules by template, parameter paths and a rule query (q, see
+// database.ParseQuery). Parameter paths and query fields must be declared by the template's
+// schema (or any schema without templateName), and values must fit their types; other
+// parameters are rejected with 400 instead of reaching the store.
 // Examples:
 //
 //	?templateName=demo                              → Search by template name
 //	?parameters.target.service=api                  → Search by nested parameter
 //	?templateName=demo&parameters.target.env=prod   → Combine multiple filters
+//	?q=target.namespace=~"team-.*" AND rules.threshold>0.8 → Search with a query
+//	?metadata.team=payments                         → Search by metadata
+//
+// limit, offset, cursor, sort and fields select the page (see ListRules), so parameters with these
+// names need the parameters. prefix.
 func (h *RuleHandlers) SearchRules(ctx context.Context, input *SearchRulesInput) (*SearchRulesOutput, error) {
-	filter := database.RuleFilter{
-		Parameters: make(map[string]string),
+	filter, err := searchFilter(input.QueryParams)
+	if err != nil {
+		return nil, err
 	}
 
-	// Pass all query parameters directly to MongoDB without conversion
-	// Special handling for templateName to populate the dedicated filter field
-	for key, value := range input.QueryParams {
-		if key == "templateName" {
-			filter.TemplateName = value
-		} else {
-			// All other params (including parameters.* fields) are passed as-is
-			filter.Parameters[key] = value
-		}
+	page, err := pageRequest(input.Limit, input.Offset, input.Cursor, input.Sort, input.Fields)
+	if err != nil {
+		return nil, err
 	}
 
-	rules, err := h.ruleStore.SearchRules(ctx, filter)
+	result, err := h.ruleService.SearchRules(ctx, filter, page)
 	if err != nil {
-		slog.Error("SearchRules: Failed to search rules", "error", err)
-		return nil, huma.Error500InternalServerError(err.Error())
+		if !errors.Is(err, database.ErrInvalidFilter) && !errors.Is(err, database.ErrInvalidPage) {
+			slog.Error("SearchRules: Failed to search rules", "error", err)
+		}
+		return nil, pageError(err)
 	}
 
-	return &SearchRulesOutput{Body: rules}, nil
+	return &SearchRulesOutput{Link: linkHeader(&input.url, result), Total: result.Total, Body: result.Rules}, nil
+}
+
+// searchFilter builds the rule filter of search query parameters: templateName, q and metadata.*
+// populate the dedicated filter fields, and everything else is a parameter path.
+func searchFilter(params map[string]string) (database.RuleFilter, error) {
+	filter := database.RuleFilter{
+		Parameters: make(map[string]string),
+	}
+	for key, value := range params {
+		if database.IsMetadataField(key) {
+			if filter.Metadata == nil {
+				filter.Metadata = make(map[string]string)
+			}
+			filter.Metadata[key] = value
+			continue
+		}
+		switch key {
+		case "templateName":
+			filter.TemplateName = value
+		case "q":
+			query, err := database.ParseQuery(value)
+			if err != nil {
+				return database.RuleFilter{}, huma.Error400BadRequest(err.Error())
+			}
+			filter.Query = query
+		default:
+			filter.Parameters[key] = value
+		}
+	}
+	return filter, nil
 }
@@ -8,6 +8,7 @@ import (
 	"rulemanager/internal/database"
 	"rulemanager/internal/rules"
 	"rulemanager/internal/validation"
+	"sort"
 	"text/template"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -37,6 +38,15 @@ func NewTemplateHandlers(api huma.API, store database.TemplateProvider, validato
 		Tags:        []string{"Templates"},
 	}, h.CreateSchema)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "list-schemas",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/templates/schemas",
+		Summary:     "List schemas",
+		Description: "Lists the available templates with the title and description of their schema.",
+		Tags:        []string{"Templates"},
+	}, h.ListSchemas)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "get-schema",
 		Method:      http.MethodGet,
@@ -53,6 +63,15 @@ func NewTemplateHandlers(api huma.API, store database.TemplateProvider, validato
 		Tags:        []string{"Templates"},
 	}, h.DeleteSchema)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "get-template-form",
+		Method:      http.MethodGet,
+		Path:        "/api/v1/templates/{name}/form",
+		Summary:     "Get the form model of a template",
+		Description: "Resolves the template schema into fields, widgets, rule type choices and dynamic options dependencies for rendering a rule form.",
+		Tags:        []string{"Templates"},
+	}, h.GetForm)
+
 	// Template Endpoints
 	huma.Register(api, huma.Operation{
 		OperationID: "create-template",
@@ -108,12 +127,27 @@ type GetTemplateInput struct {
 	Name string `path:"name"`
 }
 
+// SchemaSummary describes an available template.
+type SchemaSummary struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type ListSchemasOutput struct {
+	Body []SchemaSummary
+}
+
 type GetSchemaOutput struct {
 	Body struct {
 		Content json.RawMessage `json:"content"`
 	}
 }
 
+type GetFormOutput struct {
+	Body *rules.FormModel
+}
+
 type GetTemplateOutput struct {
 	Body struct {
 		Content string `json:"content"`
@@ -179,6 +213,26 @@ func (h *TemplateHandlers) CreateSchema(ctx context.Context, input *CreateSchema
 	return nil, nil
 }
 
+// ListSchemas lists the available schemas by name.
+func (h *TemplateHandlers) ListSchemas(ctx context.Context, input *struct{}) (*ListSchemasOutput, error) {
+	schemas, err := h.store.ListSchemas(ctx)
+	if err != nil {
+		slog.Error("ListSchemas: Failed to list schemas", "error", err)
+		return nil, huma.Error500InternalServerError(err.Error())
+	}
+
+	summaries := make([]SchemaSummary, 0, len(schemas))
+	for _, schema := range schemas {
+		summary := SchemaSummary{Name: schema.Name}
+		// Title and description are informational; a schema that fails to parse is still listed
+		_ = json.Unmarshal(schema.Schema, &summary)
+		summary.Name = schema.Name
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return &ListSchemasOutput{Body: summaries}, nil
+}
+
 // GetSchema retrieves a schema by name.
 func (h *TemplateHandlers) GetSchema(ctx context.Context, input *GetTemplateInput) (*GetSchemaOutput, error) {
 	content, err := h.store.GetSchema(ctx, input.Name)
@@ -191,6 +245,21 @@ func (h *TemplateHandlers) GetSchema(ctx context.Context, input *GetTemplateInpu
 	}{Content: json.RawMessage(content)}}, nil
 }
 
+// GetForm returns the form model of a template.
+func (h *TemplateHandlers) GetForm(ctx context.Context, input *GetTemplateInput) (*GetFormOutput, error) {
+	content, err := h.store.GetSchema(ctx, input.Name)
+	if err != nil {
+		slog.Warn("GetForm: Schema not found", "name", input.Name, "error", err)
+		return nil, huma.Error404NotFound(err.Error())
+	}
+	model, err := rules.BuildFormModel(input.Name, content)
+	if err != nil {
+		slog.Error("GetForm: Failed to build form model", "name", input.Name, "error", err)
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+	return &GetFormOutput{Body: model}, nil
+}
+
 // DeleteSchema deletes a schema by name.
 func (h *TemplateHandlers) DeleteSchema(ctx context.Context, input *GetTemplateInput) (*struct{}, error) {
 	if err := h.store.DeleteSchema(ctx, input.Name); err != nil {
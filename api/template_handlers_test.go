@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"rulemanager/internal/database"
 	"rulemanager/internal/rules"
 	"rulemanager/internal/validation"
 	"testing"
@@ -113,4 +114,45 @@ annotations:
 		assert.Contains(t, resp.Result, "alert: HighCPU")
 		assert.Contains(t, resp.Result, "expr: cpu_usage > 80")
 	})
+
+	t.Run("GetForm", func(t *testing.T) {
+		mockStore.On("GetSchema", mock.Anything, "form-schema").Return(`{"title": "Form", "properties": {"rules": {"type": "array", "items": {"properties": {"rule_type": {"type": "string", "enum": ["cpu"]}}}}}}`, nil)
+		mockStore.On("GetSchema", mock.Anything, "broken-schema").Return(`{"properties": []}`, nil)
+		mockStore.On("GetSchema", mock.Anything, "missing-schema").Return("", assert.AnError)
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/templates/form-schema/form", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var model rules.FormModel
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &model))
+		assert.Equal(t, "Form", model.Title)
+		assert.Equal(t, rules.WidgetList, model.Fields[0].Widget)
+		assert.Equal(t, "rules[].rule_type", model.Fields[0].Fields[0].Path)
+
+		req, _ = http.NewRequest(http.MethodGet, "/api/v1/templates/broken-schema/form", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		req, _ = http.NewRequest(http.MethodGet, "/api/v1/templates/missing-schema/form", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("ListSchemas", func(t *testing.T) {
+		mockStore.On("ListSchemas", mock.Anything).Return([]*database.Schema{
+			{Name: "k8s", Schema: json.RawMessage(`{"title": "K8s", "description": "Kubernetes workloads"}`)},
+			{Name: "broken", Schema: json.RawMessage(`not json`)},
+		}, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/templates/schemas", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[{"name": "broken"}, {"name": "k8s", "title": "K8s", "description": "Kubernetes workloads"}]`, w.Body.String())
+	})
 }
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UIPath is where the embedded web UI is served.
+const UIPath = "/ui"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// RegisterUI serves the embedded single-page UI under UIPath and redirects the root to it.
+// Paths that are not assets fall back to index.html so client-side routes can be reloaded.
+func RegisterUI(router chi.Router) {
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		// The directory is embedded at build time
+		panic(err)
+	}
+	fileServer := http.StripPrefix(UIPath+"/", http.FileServer(http.FS(assets)))
+
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, UIPath+"/", http.StatusFound)
+	})
+	router.Get(UIPath, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, UIPath+"/", http.StatusMovedPermanently)
+	})
+	router.Get(UIPath+"/*", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(path.Clean(r.URL.Path), UIPath), "/")
+		if info, err := fs.Stat(assets, name); err != nil || info.IsDir() || name == "index.html" {
+			serveIndex(w, assets)
+			return
+		}
+		// Assets are not fingerprinted, so browsers revalidate them on every load
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+func serveIndex(w http.ResponseWriter, assets fs.FS) {
+	index, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(index)
+}
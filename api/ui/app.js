@@ -0,0 +1,715 @@
+// Rule Manager web UI. A dependency-free single-page app on top of the JSON API;
+// views are selected by the location hash (#/rules, #/rules/new, #/rules/{id}, #/templates).
+'use strict';
+
+const API = '/api/v1';
+const PAGE_SIZE = 20;
+
+// ---------------------------------------------------------------------------
+// Helpers
+
+async function request(method, path, body) {
+  return (await send(method, path, body)).data;
+}
+
+// requestPage fetches a page of a listing along with the total from X-Total-Count.
+async function requestPage(path) {
+  const { data, resp } = await send('GET', path);
+  return { items: data || [], total: Number(resp.headers.get('X-Total-Count') || 0) };
+}
+
+async function send(method, path, body) {
+  const opts = { method, headers: {} };
+  if (body !== undefined) {
+    opts.headers['Content-Type'] = 'application/json';
+    opts.body = JSON.stringify(body);
+  }
+  const resp = await fetch(API + path, opts);
+  const text = await resp.text();
+  const data = text ? JSON.parse(text) : null;
+  if (!resp.ok) {
+    // Huma problem details: detail plus optional per-location errors
+    let msg = (data && (data.detail || data.title)) || resp.statusText;
+    for (const e of (data && data.errors) || []) {
+      msg += '\n' + (e.location ? e.location + ': ' : '') + e.message;
+    }
+    throw new Error(msg);
+  }
+  return { data, resp };
+}
+
+// el builds a DOM element: el('a', {href: '#'}, 'text', child, ...). Attributes starting with
+// "on" are event listeners; null and false children are skipped.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key.startsWith('on')) {
+      node.addEventListener(key.slice(2), value);
+    } else if (key === 'class') {
+      node.className = value;
+    } else if (value === true) {
+      node.setAttribute(key, '');
+    } else if (value !== false && value != null) {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children.flat()) {
+    if (child == null || child === false) continue;
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+function errorBox(err) {
+  return el('p', { class: 'error' }, err.message || String(err));
+}
+
+function render(...nodes) {
+  const view = document.getElementById('view');
+  view.replaceChildren(...nodes);
+}
+
+let schemasPromise;
+function listSchemas() {
+  schemasPromise = schemasPromise || request('GET', '/templates/schemas').catch((err) => {
+    schemasPromise = null;
+    throw err;
+  });
+  return schemasPromise;
+}
+
+function templateSelect(selected, onchange, includeAll) {
+  const select = el('select', { onchange: () => onchange(select.value) });
+  if (includeAll) select.append(el('option', { value: '' }, 'All templates'));
+  listSchemas().then((schemas) => {
+    for (const s of schemas) {
+      select.append(el('option', { value: s.name, selected: s.name === selected }, s.title ? `${s.name} — ${s.title}` : s.name));
+    }
+  }).catch((err) => select.after(errorBox(err)));
+  return select;
+}
+
+// normalizePath drops item selectors, so rules[2].tier, rules[rule_type=cpu].tier and rules[].tier compare equal.
+function normalizePath(path) {
+  return path.replace(/\[[^\]]*\]/g, '[]');
+}
+
+// flatten maps every leaf of a JSON value to its dotted path, for diffs.
+function flatten(value, prefix, out) {
+  out = out || {};
+  if (value !== null && typeof value === 'object' && Object.keys(value).length > 0) {
+    for (const [key, child] of Object.entries(value)) {
+      flatten(child, Array.isArray(value) ? `${prefix}[${key}]` : (prefix ? `${prefix}.${key}` : key), out);
+    }
+  } else if (prefix) {
+    out[prefix] = JSON.stringify(value);
+  }
+  return out;
+}
+
+function diffLines(before, after) {
+  const a = flatten(before || {}, '');
+  const b = flatten(after || {}, '');
+  const keys = [...new Set([...Object.keys(a), ...Object.keys(b)])].sort();
+  const lines = [];
+  for (const key of keys) {
+    if (a[key] === b[key]) continue;
+    if (key in a) lines.push(el('div', { class: 'del' }, `- ${key}: ${a[key]}`));
+    if (key in b) lines.push(el('div', { class: 'add' }, `+ ${key}: ${b[key]}`));
+  }
+  return lines.length ? el('pre', { class: 'diff' }, lines) : el('p', { class: 'muted' }, 'No parameter changes.');
+}
+
+// ---------------------------------------------------------------------------
+// Rules list
+
+function rulesView(query) {
+  const state = {
+    template: query.get('template') || '',
+    q: query.get('q') || '',
+    offset: Number(query.get('offset') || 0),
+  };
+
+  const filterInput = el('input', { type: 'text', size: 60, value: state.q, placeholder: 'target.namespace=~"team-.*" AND rules.threshold>0.8' });
+  const results = el('div');
+
+  const navigate = () => {
+    const params = new URLSearchParams();
+    if (state.template) params.set('template', state.template);
+    if (state.q) params.set('q', state.q);
+    if (state.offset) params.set('offset', state.offset);
+    location.hash = '#/rules?' + params.toString();
+  };
+
+  const search = (e) => {
+    e.preventDefault();
+    state.q = filterInput.value.trim();
+    state.offset = 0;
+    navigate();
+  };
+
+  render(
+    el('h2', {}, 'Rules'),
+    el('form', { class: 'toolbar', onsubmit: search },
+      templateSelect(state.template, (value) => { state.template = value; state.offset = 0; navigate(); }, true),
+      filterInput,
+      el('button', { type: 'submit' }, 'Search'),
+      el('a', { href: '#/rules/new' + (state.template ? '?template=' + encodeURIComponent(state.template) : '') }, el('button', { type: 'button', class: 'primary' }, 'New rule'))),
+    results,
+  );
+
+  const params = new URLSearchParams({ offset: state.offset, limit: PAGE_SIZE, sort: 'updatedAt:desc' });
+  if (state.template) params.set('templateName', state.template);
+  if (state.q) params.set('q', state.q);
+  const load = requestPage((state.template || state.q ? '/rules/search?' : '/rules?') + params.toString());
+
+  load.then(({ items: rules, total }) => {
+    const hasMore = state.offset + rules.length < total;
+    if (rules.length === 0) {
+      results.replaceChildren(el('p', { class: 'muted' }, 'No rules found.'));
+      return;
+    }
+    const rows = rules.map((rule) => {
+      const params = rule.parameters || {};
+      const types = (params.rules || []).map((r) => r.rule_type).filter(Boolean).join(', ');
+      return el('tr', {},
+        el('td', {}, el('a', { href: '#/rules/' + rule.id, title: rule.id }, el('code', {}, rule.name || rule.id))),
+        el('td', {}, rule.templateName),
+        el('td', {}, types),
+        el('td', {}, el('code', {}, JSON.stringify(params.target || {}))),
+        el('td', {}, (rule.metadata && rule.metadata.team) || ''),
+        el('td', {}, rule.updatedAt && !rule.updatedAt.startsWith('0001') ? new Date(rule.updatedAt).toLocaleString() : ''),
+        el('td', {}, el('button', {
+          class: 'danger',
+          onclick: async () => {
+            if (!confirm(`Delete rule ${rule.id}?`)) return;
+            try {
+              await request('DELETE', '/rules/' + rule.id);
+              route();
+            } catch (err) {
+              results.prepend(errorBox(err));
+            }
+          },
+        }, 'Delete')));
+    });
+    results.replaceChildren(
+      el('table', {},
+        el('thead', {}, el('tr', {}, ['Name / ID', 'Template', 'Rule types', 'Target', 'Team', 'Updated', ''].map((h) => el('th', {}, h)))),
+        el('tbody', {}, rows)),
+      el('div', { class: 'toolbar' },
+        el('button', { disabled: state.offset === 0, onclick: () => { state.offset = Math.max(0, state.offset - PAGE_SIZE); navigate(); } }, 'Previous'),
+        el('span', { class: 'muted' }, `${state.offset + 1}–${state.offset + rules.length} of ${total}`),
+        el('button', { disabled: !hasMore, onclick: () => { state.offset += PAGE_SIZE; navigate(); } }, 'Next')),
+    );
+  }).catch((err) => results.replaceChildren(errorBox(err)));
+}
+
+// ---------------------------------------------------------------------------
+// Rule form
+
+// RuleForm renders a form model into inputs bound to a parameters object. Dynamic fields load
+// their options from the options endpoint and reload them when a field they depend on changes.
+class RuleForm {
+  constructor(model, values, onchange) {
+    this.model = model;
+    this.values = values;
+    this.onchange = onchange;
+    this.dynamic = []; // {field, container, datalist}
+  }
+
+  render() {
+    const root = el('div');
+    this.dynamic = [];
+    for (const field of this.model.fields) {
+      const node = this.renderField(field, this.values, null);
+      if (node) root.append(node);
+    }
+    this.refreshOptions();
+    return root;
+  }
+
+  changed(path) {
+    const changed = normalizePath(path);
+    this.refreshOptions((f) => (f.depends_on || []).some((dep) => normalizePath(dep) === changed));
+    this.onchange();
+  }
+
+  refreshOptions(filter) {
+    for (const d of this.dynamic) {
+      if (filter && !filter(d.field)) continue;
+      const body = {
+        template_name: this.model.template,
+        field_path: d.field.path,
+        current_values: this.values,
+      };
+      if (d.item) body.current_item = d.item;
+      request('POST', '/rules/options', body).then((resp) => {
+        d.datalist.replaceChildren(...resp.options.map((o) => el('option', { value: o })));
+      }).catch((err) => console.warn('options', d.field.path, err));
+    }
+  }
+
+  renderField(field, container, item) {
+    if (field.widget === 'hidden') {
+      if (field.const !== undefined) container[field.name] = field.const;
+      return null;
+    }
+    if (container[field.name] === undefined && field.default !== undefined) {
+      container[field.name] = structuredClone(field.default);
+    }
+
+    if (field.widget === 'group') {
+      container[field.name] = container[field.name] || {};
+      return el('fieldset', {},
+        el('legend', {}, field.label),
+        field.description && el('p', { class: 'muted' }, field.description),
+        field.fields.map((f) => this.renderField(f, container[field.name], item)));
+    }
+    if (field.widget === 'list') {
+      return this.renderList(field, container);
+    }
+
+    const input = this.renderInput(field, container, item);
+    const row = el('div', { class: 'field' },
+      el('label', { class: field.required ? 'required' : '' }, field.label),
+      input,
+      field.description && el('div', { class: 'help' }, field.description));
+    if (field.visible_when && item) {
+      const discriminator = field.visible_when.field.split('.').pop();
+      const update = () => {
+        row.hidden = !field.visible_when.in.includes(item[discriminator]);
+        if (row.hidden && field.name in item) {
+          // Values of other rule types would fail validation
+          delete item[field.name];
+          row.querySelectorAll('input, select').forEach((input) => { input.value = ''; input.checked = false; });
+        }
+      };
+      update();
+      row.updateVisibility = update;
+    }
+    return row;
+  }
+
+  renderInput(field, container, item) {
+    const set = (value) => {
+      if (value === '' || value === undefined) {
+        delete container[field.name];
+      } else {
+        container[field.name] = value;
+      }
+      this.changed(field.path);
+    };
+    const current = container[field.name];
+
+    switch (field.widget) {
+      case 'checkbox': {
+        const box = el('input', { type: 'checkbox', checked: current === true, onchange: () => set(box.checked) });
+        return box;
+      }
+      case 'number': {
+        const num = el('input', { type: 'number', step: 'any', min: field.minimum, max: field.maximum, value: current ?? '' });
+        num.addEventListener('change', () => set(num.value === '' ? undefined : Number(num.value)));
+        return num;
+      }
+      case 'select': {
+        const sel = el('select', {},
+          el('option', { value: '' }, ''),
+          field.choices.map((c, i) => el('option', { value: i, selected: c.value === current }, c.label)));
+        sel.addEventListener('change', () => set(sel.value === '' ? undefined : field.choices[Number(sel.value)].value));
+        return sel;
+      }
+      case 'multiselect': {
+        const sel = el('select', { multiple: true, size: Math.min(field.choices.length, 6) },
+          field.choices.map((c, i) => el('option', { value: i, selected: (current || []).includes(c.value) }, c.label)));
+        sel.addEventListener('change', () => set([...sel.selectedOptions].map((o) => field.choices[Number(o.value)].value)));
+        return sel;
+      }
+      case 'key_value':
+        return this.renderKeyValue(field, container);
+      case 'dynamic_select': {
+        const id = 'opts-' + Math.random().toString(36).slice(2);
+        const datalist = el('datalist', { id });
+        const text = el('input', { type: 'text', list: id, value: current ?? '', placeholder: (field.depends_on || []).length ? 'depends on ' + field.depends_on.join(', ') : '' });
+        text.addEventListener('change', () => set(text.value));
+        this.dynamic.push({ field, datalist, item });
+        return el('span', {}, text, datalist);
+      }
+      default: {
+        const text = el('input', { type: 'text', pattern: field.pattern, value: current ?? '' });
+        text.addEventListener('change', () => set(text.value));
+        return text;
+      }
+    }
+  }
+
+  renderKeyValue(field, container) {
+    const box = el('div');
+    const rows = Object.entries(container[field.name] || {});
+    const sync = () => {
+      const obj = {};
+      for (const [k, v] of rows) if (k) obj[k] = v;
+      container[field.name] = obj;
+      this.changed(field.path);
+    };
+    const draw = () => {
+      box.replaceChildren(
+        ...rows.map((pair, i) => {
+          const key = el('input', { type: 'text', value: pair[0], placeholder: 'key' });
+          const value = el('input', { type: 'text', value: pair[1], placeholder: 'value' });
+          key.addEventListener('change', () => { pair[0] = key.value; sync(); });
+          value.addEventListener('change', () => { pair[1] = value.value; sync(); });
+          return el('div', { class: 'kv-row' }, key, value,
+            el('button', { type: 'button', onclick: () => { rows.splice(i, 1); sync(); draw(); } }, '×'));
+        }),
+        el('button', { type: 'button', onclick: () => { rows.push(['', '']); draw(); } }, 'Add'));
+    };
+    draw();
+    return box;
+  }
+
+  renderList(field, container) {
+    container[field.name] = container[field.name] || [];
+    const items = container[field.name];
+    const scalar = field.fields.length === 1 && field.fields[0].path === field.path + '[]';
+    const box = el('div');
+
+    const draw = (initial) => {
+      box.replaceChildren(
+        ...items.map((_, i) => this.renderItem(field, items, i, scalar, draw)),
+        el('button', { type: 'button', onclick: () => { items.push(scalar ? '' : {}); draw(); this.onchange(); } }, 'Add ' + field.label.replace(/s$/, '').toLowerCase()));
+      if (!initial) {
+        // Forget the inputs of redrawn items and load the options of the new ones
+        this.dynamic = this.dynamic.filter((d) => d.datalist.isConnected);
+        this.refreshOptions((f) => normalizePath(f.path).startsWith(normalizePath(field.path) + '[]'));
+      }
+    };
+    draw(true);
+    return el('fieldset', {},
+      el('legend', { class: field.required ? 'required' : '' }, field.label),
+      field.description && el('p', { class: 'muted' }, field.description),
+      box);
+  }
+
+  renderItem(field, items, index, scalar, redraw) {
+    const remove = el('button', { type: 'button', class: 'danger', onclick: () => { items.splice(index, 1); redraw(); this.onchange(); } }, 'Remove');
+    if (scalar) {
+      const wrapper = { item: items[index] };
+      const itemField = { ...field.fields[0], name: 'item', label: `#${index + 1}` };
+      const input = this.renderInput(itemField, wrapper, null);
+      input.addEventListener('change', () => {
+        items[index] = wrapper.item ?? '';
+        this.onchange();
+      });
+      return el('div', { class: 'kv-row' }, input, remove);
+    }
+
+    const item = items[index];
+    const rows = [];
+    const node = el('div', { class: 'item' });
+    for (const f of field.fields) {
+      const row = this.renderField(f, item, item);
+      if (!row) continue;
+      rows.push(row);
+      if (field.fields.some((o) => o.visible_when && o.visible_when.field === f.path)) {
+        // Discriminator: switching the rule type shows the fields of the branch
+        row.querySelector('select').addEventListener('change', () => rows.forEach((r) => r.updateVisibility && r.updateVisibility()));
+      }
+    }
+    node.append(...rows, remove);
+    return node;
+  }
+}
+
+// ruleEditorView creates a rule (id empty) or edits an existing one: form, plan with diffs and
+// rendered YAML, then save.
+async function ruleEditorView(id, query) {
+  let rule = null;
+  let template = query.get('template') || '';
+  const heading = el('h2', {}, id ? 'Edit rule' : 'New rule');
+  const formBox = el('div', { class: 'panel' });
+  const planBox = el('div');
+  const status = el('div');
+  const metadataBox = el('div');
+  render(heading, status, formBox, planBox, metadataBox);
+
+  try {
+    if (id) {
+      rule = await request('GET', '/rules/' + id);
+      template = rule.templateName;
+      heading.append(' ', el('code', {}, id));
+      metadataBox.replaceChildren(metadataPanel(rule));
+    } else if (!template) {
+      const schemas = await listSchemas();
+      template = schemas.length ? schemas[0].name : '';
+    }
+  } catch (err) {
+    status.replaceChildren(errorBox(err));
+    return;
+  }
+
+  let values = rule ? structuredClone(rule.parameters || {}) : {};
+  let planned = null;
+  const saveButton = el('button', { class: 'primary', disabled: true }, 'Save');
+  const rawJSON = el('pre');
+
+  const invalidate = () => {
+    planned = null;
+    saveButton.disabled = true;
+    rawJSON.textContent = JSON.stringify(values, null, 2);
+  };
+
+  const loadForm = async () => {
+    planBox.replaceChildren();
+    try {
+      const model = await request('GET', `/templates/${encodeURIComponent(template)}/form`);
+      const form = new RuleForm(model, values, invalidate);
+      formBox.replaceChildren(
+        el('div', { class: 'toolbar' },
+          el('label', {}, 'Template '),
+          id ? el('strong', {}, template) : templateSelect(template, (value) => {
+            template = value;
+            values = {};
+            location.hash = '#/rules/new?template=' + encodeURIComponent(value);
+          }, false)),
+        model.description ? el('p', { class: 'muted' }, model.description) : '',
+        form.render(),
+        el('details', {}, el('summary', {}, 'Parameters JSON'), rawJSON),
+        el('div', { class: 'toolbar' },
+          el('button', { onclick: plan }, 'Plan'),
+          saveButton));
+      invalidate();
+    } catch (err) {
+      formBox.replaceChildren(errorBox(err));
+    }
+  };
+
+  const plan = async () => {
+    status.replaceChildren();
+    planBox.replaceChildren(el('p', { class: 'muted' }, 'Planning…'));
+    try {
+      const body = { templateName: template, parameters: values };
+      const plans = id ? [await request('POST', `/rules/${id}/plan`, body)] : (await request('POST', '/rules/plan', body)).plans;
+      planBox.replaceChildren(el('h3', {}, 'Plan'), ...plans.map((p, i) => renderPlan(p, i, template, rule)));
+      const blocked = plans.some((p) => p.action === 'conflict' || p.action === 'forbidden' || (p.pipeline && p.pipeline.steps.some((s) => s.status === 'failed')));
+      planned = blocked ? null : plans;
+      saveButton.disabled = blocked;
+    } catch (err) {
+      planBox.replaceChildren(errorBox(err));
+    }
+  };
+
+  saveButton.addEventListener('click', async () => {
+    if (!planned) return;
+    saveButton.disabled = true;
+    try {
+      const body = { templateName: template, parameters: values };
+      if (id) {
+        const resp = await request('PUT', '/rules/' + id, body);
+        status.replaceChildren(el('p', {}, 'Saved.'), ...(resp.warnings || []).map((w) => el('p', { class: 'muted' }, w)));
+      } else {
+        const resp = await request('POST', '/rules', body);
+        status.replaceChildren(
+          el('p', {}, `Saved ${resp.count} rule(s): `, resp.ids.map((rid) => el('a', { href: '#/rules/' + rid }, el('code', {}, rid), ' '))),
+          ...(resp.warnings || []).map((w) => el('p', { class: 'muted' }, w)));
+      }
+      planBox.replaceChildren();
+    } catch (err) {
+      status.replaceChildren(errorBox(err));
+      saveButton.disabled = false;
+    }
+  });
+
+  if (!template) {
+    formBox.replaceChildren(el('p', { class: 'muted' }, 'No templates available.'));
+    return;
+  }
+  loadForm();
+}
+
+// metadataPanel edits the owner, team, description, labels and links of a rule. Metadata is saved
+// on its own, without planning, since the parameters do not change.
+function metadataPanel(rule) {
+  const m = rule.metadata || {};
+  const owner = el('input', { type: 'text', value: m.owner || '' });
+  const team = el('input', { type: 'text', value: m.team || '' });
+  const description = el('input', { type: 'text', size: 60, value: m.description || '' });
+  const labels = el('textarea', { rows: 3, cols: 60, placeholder: 'name=value, one per line' },
+    Object.entries(m.labels || {}).map(([k, v]) => `${k}=${v}`).join('\n'));
+  const links = el('textarea', { rows: 3, cols: 60, placeholder: 'name https://..., one per line' },
+    (m.links || []).map((l) => `${l.name} ${l.url}`).join('\n'));
+  const status = el('div');
+
+  const lines = (text) => text.split('\n').map((l) => l.trim()).filter(Boolean);
+  const save = async () => {
+    const body = { owner: owner.value.trim(), team: team.value.trim(), description: description.value.trim(), labels: {}, links: [] };
+    for (const line of lines(labels.value)) {
+      const at = line.indexOf('=');
+      if (at > 0) body.labels[line.slice(0, at).trim()] = line.slice(at + 1).trim();
+    }
+    for (const line of lines(links.value)) {
+      const [name, url] = line.split(/\s+/, 2);
+      body.links.push(url ? { name, url } : { name: '', url: name });
+    }
+    try {
+      await request('PUT', `/rules/${rule.id}/metadata`, body);
+      status.replaceChildren(el('p', {}, 'Metadata saved.'));
+    } catch (err) {
+      status.replaceChildren(errorBox(err));
+    }
+  };
+
+  return el('div', { class: 'panel' },
+    el('h3', {}, 'Metadata'),
+    el('p', { class: 'muted' }, 'Ownership and labels, independent of the template parameters. Templates add owner, team and labels to alerts with ruleLabels.'),
+    el('div', { class: 'toolbar' }, el('label', {}, 'Owner ', owner), el('label', {}, 'Team ', team)),
+    el('div', { class: 'toolbar' }, el('label', {}, 'Description ', description)),
+    el('div', {}, el('label', {}, 'Labels', el('br'), labels)),
+    el('div', {}, el('label', {}, 'Links', el('br'), links)),
+    el('div', { class: 'toolbar' }, el('button', { onclick: save }, 'Save metadata')),
+    status);
+}
+
+// renderPlan shows a plan: its action, pipeline findings, the parameter diff against the rule it
+// overrides (or the edited rule) and the rendered rule.
+function renderPlan(plan, index, template, editedRule) {
+  const yaml = el('pre', {}, 'Rendering…');
+  const previous = plan.existing_rule || editedRule;
+  const existing = previous && previous.parameters;
+  const steps = (plan.pipeline && plan.pipeline.steps) || [];
+  const findings = steps.filter((s) => (s.status !== 'passed' && s.status !== 'skipped') || (s.warnings || []).length > 0);
+
+  renderYAML(template, plan.new_rule.parameters)
+    .then((text) => { yaml.textContent = text; })
+    .catch((err) => { yaml.replaceWith(errorBox(err)); });
+
+  return el('div', { class: 'panel' },
+    el('div', {},
+      el('strong', {}, `Rule ${index + 1} `),
+      el('span', { class: 'badge ' + plan.action }, plan.action),
+      plan.existing_rule && el('span', {}, ' ', el('a', { href: '#/rules/' + plan.existing_rule.id }, el('code', {}, plan.existing_rule.id)))),
+    plan.reason && el('p', { class: 'muted' }, plan.reason),
+    findings.length > 0 && el('ul', {}, findings.map((s) => el('li', { class: s.status === 'failed' ? 'error' : '' },
+      `${s.scope ? s.scope + '/' : ''}${s.name}: ${s.status}${s.message ? ' — ' + s.message : ''}`,
+      (s.warnings || []).map((w) => el('div', { class: 'muted' }, w))))),
+    el('div', { class: 'split' },
+      el('div', {}, el('h4', {}, 'Parameter changes'), diffLines(existing, plan.new_rule.parameters)),
+      el('div', {}, el('h4', {}, 'Rendered rule'), yaml)));
+}
+
+// renderYAML renders the template's Go template with the planned parameters through the validate dry-run.
+const goTemplates = {};
+async function renderYAML(template, parameters) {
+  if (!(template in goTemplates)) {
+    goTemplates[template] = request('GET', `/templates/go-templates/${encodeURIComponent(template)}`).then((r) => r.content);
+  }
+  const content = await goTemplates[template];
+  const resp = await request('POST', '/templates/validate', { templateContent: content, parameters });
+  return resp.result;
+}
+
+// ---------------------------------------------------------------------------
+// Template editor
+
+function templatesView(query) {
+  let name = query.get('name') || '';
+  const editor = el('textarea', { rows: 24, spellcheck: 'false' });
+  const params = el('textarea', { rows: 24, spellcheck: 'false' });
+  const output = el('div');
+  const nameInput = el('input', { type: 'text', value: name, placeholder: 'template name' });
+
+  const load = async (value) => {
+    name = value;
+    nameInput.value = name;
+    output.replaceChildren();
+    delete goTemplates[name];
+    try {
+      editor.value = (await request('GET', `/templates/go-templates/${encodeURIComponent(name)}`)).content;
+    } catch (err) {
+      editor.value = '';
+      output.replaceChildren(errorBox(err));
+    }
+    // Start the sample parameters from an existing rule of the template, if any
+    try {
+      const rules = await request('GET', '/rules/search?limit=1&templateName=' + encodeURIComponent(name));
+      if (rules && rules.length && !params.value.trim()) params.value = JSON.stringify(rules[0].parameters, null, 2);
+    } catch (err) {
+      console.warn('sample parameters', err);
+    }
+  };
+
+  const validate = async () => {
+    let parameters;
+    try {
+      parameters = JSON.parse(params.value || '{}');
+    } catch (err) {
+      output.replaceChildren(errorBox(new Error('Parameters are not valid JSON: ' + err.message)));
+      return false;
+    }
+    try {
+      const resp = await request('POST', '/templates/validate', { templateContent: editor.value, parameters });
+      output.replaceChildren(el('p', {}, 'Valid.'), el('pre', {}, resp.result));
+      return true;
+    } catch (err) {
+      output.replaceChildren(errorBox(err));
+      return false;
+    }
+  };
+
+  const save = async () => {
+    name = nameInput.value.trim();
+    if (!name) {
+      output.replaceChildren(errorBox(new Error('A template name is required.')));
+      return;
+    }
+    if (params.value.trim() && !(await validate())) return;
+    try {
+      await request('POST', '/templates/go-templates', { name, content: editor.value });
+      delete goTemplates[name];
+      output.prepend(el('p', {}, `Saved ${name}.`));
+    } catch (err) {
+      output.replaceChildren(errorBox(err));
+    }
+  };
+
+  render(
+    el('h2', {}, 'Template editor'),
+    el('div', { class: 'toolbar' },
+      templateSelect(name, (value) => load(value), false),
+      nameInput,
+      el('button', { onclick: validate }, 'Validate'),
+      el('button', { class: 'primary', onclick: save }, 'Save')),
+    el('div', { class: 'split' },
+      el('div', {}, el('h4', {}, 'Go template'), editor),
+      el('div', {}, el('h4', {}, 'Parameters (JSON)'), params)),
+    output,
+  );
+
+  if (name) {
+    load(name);
+  } else {
+    listSchemas().then((schemas) => { if (schemas.length) load(schemas[0].name); }).catch((err) => output.replaceChildren(errorBox(err)));
+  }
+}
+
+// ---------------------------------------------------------------------------
+// Routing
+
+function route() {
+  const hash = location.hash.replace(/^#/, '') || '/rules';
+  const [path, search] = hash.split('?');
+  const query = new URLSearchParams(search || '');
+  const parts = path.split('/').filter(Boolean);
+
+  if (parts[0] === 'templates') {
+    templatesView(query);
+  } else if (parts[0] === 'rules' && parts[1] === 'new') {
+    ruleEditorView('', query);
+  } else if (parts[0] === 'rules' && parts[1]) {
+    ruleEditorView(decodeURIComponent(parts[1]), query);
+  } else {
+    rulesView(query);
+  }
+}
+
+window.addEventListener('hashchange', route);
+route();
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterUI(t *testing.T) {
+	router := chi.NewMux()
+	RegisterUI(router)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		location    string
+		contentType string
+		contains    string
+	}{
+		{name: "RootRedirects", path: "/", status: http.StatusFound, location: "/ui/"},
+		{name: "BarePathRedirects", path: "/ui", status: http.StatusMovedPermanently, location: "/ui/"},
+		{name: "Index", path: "/ui/", status: http.StatusOK, contentType: "text/html; charset=utf-8", contains: "<title>Rule Manager</title>"},
+		{name: "Script", path: "/ui/app.js", status: http.StatusOK, contentType: "text/javascript; charset=utf-8", contains: "/api/v1"},
+		{name: "Stylesheet", path: "/ui/style.css", status: http.StatusOK, contentType: "text/css; charset=utf-8"},
+		{name: "ClientRouteFallsBackToIndex", path: "/ui/rules/123", status: http.StatusOK, contentType: "text/html; charset=utf-8", contains: "app.js"},
+		{name: "IndexIsNotRedirected", path: "/ui/index.html", status: http.StatusOK, contains: "app.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.path)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.location != "" {
+				assert.Equal(t, tt.location, w.Header().Get("Location"))
+			}
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+			if tt.contains != "" {
+				assert.Contains(t, w.Body.String(), tt.contains)
+			}
+		})
+	}
+}
@@ -29,6 +29,7 @@ func main() {
 	ctx := context.Background()
 	var ruleStore database.RuleStore
 	var templateProvider database.TemplateProvider
+	var datasourceStore database.DatasourceStore
 
 	if cfg.TemplateStorage.Type == "file" {
 		slog.Info("Using File Store (Local Mode)")
@@ -42,6 +43,7 @@ func main() {
 			os.Exit(1)
 		}
 		ruleStore = fileStore
+		datasourceStore = fileStore
 		// Wrap with caching
 		templateProvider = database.NewCachingTemplateProvider(fileStore)
 	} else {
@@ -55,6 +57,7 @@ func main() {
 		}
 		defer ruleMongoStore.Close(ctx)
 		ruleStore = ruleMongoStore
+		datasourceStore = ruleMongoStore
 
 		// Initialize Template Provider
 		tmplConnStr := cfg.TemplateStorage.MongoDB.ConnectionString
@@ -83,8 +86,29 @@ func main() {
 
 	// 4. Initialize Services
 	validator := validation.NewJSONSchemaValidator()
+	policies, err := rules.NewPolicySet(cfg.Policies)
+	if err != nil {
+		slog.Error("Failed to load policies", "error", err)
+		os.Exit(1)
+	}
+	datasources, err := rules.NewDatasourceRegistry(cfg.Datasources, datasourceStore)
+	if err != nil {
+		slog.Error("Failed to load datasources", "error", err)
+		os.Exit(1)
+	}
+	runners, err := rules.NewSubprocessRunners(cfg.Pipelines.Runners)
+	if err != nil {
+		slog.Error("Failed to load pipeline runners", "error", err)
+		os.Exit(1)
+	}
 	// Use the initialized store and provider
-	ruleService := rules.NewService(templateProvider, ruleStore, validator)
+	ruleService := rules.NewService(templateProvider, ruleStore, validator,
+		rules.WithPolicies(policies),
+		rules.WithPipelineConfig(cfg.Pipelines),
+		rules.WithOptionsConfig(cfg.Options),
+		rules.WithStepRunners(runners),
+		rules.WithDatasources(datasources),
+	)
 
 	// Seed default templates
 	if err := rules.SeedTemplates(ctx, templateProvider, "./templates"); err != nil {
@@ -92,9 +116,13 @@ func main() {
 	}
 
 	// 5. Initialize API
-	apiInstance := api.NewAPI()
+	apiInstance := api.NewAPI(api.IdentityMiddleware(cfg.Auth))
 	api.NewRuleHandlers(apiInstance.Huma, ruleStore, ruleService)
 	api.NewTemplateHandlers(apiInstance.Huma, templateProvider, validator, ruleService)
+	api.NewDatasourceHandlers(apiInstance.Huma, datasources, ruleService)
+	if !cfg.Server.DisableUI {
+		api.RegisterUI(apiInstance.Router)
+	}
 
 	// Enhance Documentation
 	docsDir := "./docs"
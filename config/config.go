@@ -2,21 +2,39 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	Server          ServerConfig   `mapstructure:"server"`
-	Database        DatabaseConfig `mapstructure:"database"`
-	TemplateStorage StorageConfig  `mapstructure:"template_storage"`
-	Logging         LoggingConfig  `mapstructure:"logging"`
+	Server          ServerConfig    `mapstructure:"server"`
+	Database        DatabaseConfig  `mapstructure:"database"`
+	TemplateStorage StorageConfig   `mapstructure:"template_storage"`
+	Logging         LoggingConfig   `mapstructure:"logging"`
+	Policies        PoliciesConfig  `mapstructure:"policies"`
+	Pipelines       PipelinesConfig `mapstructure:"pipelines"`
+	Options         OptionsConfig   `mapstructure:"options"`
+	Auth            AuthConfig      `mapstructure:"auth"`
+	// Datasources are the named datasources template schemas reference by name.
+	Datasources []DatasourceConfig `mapstructure:"datasources"`
 }
 
 // ServerConfig holds the HTTP server configuration.
 type ServerConfig struct {
-	Port int `mapstructure:"port"`
+	Port      int  `mapstructure:"port"`
+	DisableUI bool `mapstructure:"disable_ui"` // Do not serve the embedded web UI under /ui
+}
+
+// AuthConfig enables ownership-based access control. The caller identity is read from headers
+// set by an authenticating proxy in front of the service, which must strip them from client requests.
+type AuthConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	UserHeader   string   `mapstructure:"user_header"`   // Header holding the user name (default X-Forwarded-User)
+	TeamsHeader  string   `mapstructure:"teams_header"`  // Header holding the comma-separated teams of the user (default X-Forwarded-Groups)
+	TenantHeader string   `mapstructure:"tenant_header"` // Header holding the tenant of the user, which scopes rule names (default X-Forwarded-Tenant)
+	AdminTeams   []string `mapstructure:"admin_teams"`   // Members of these teams may change rules of any team and tenant
 }
 
 // DatabaseConfig holds the database connection configuration.
@@ -49,6 +67,78 @@ type LoggingConfig struct {
 	Compress   bool   `mapstructure:"compress"`    // Compress backups
 }
 
+// PipelinesConfig tunes pipeline execution. Zero values use the defaults.
+type PipelinesConfig struct {
+	Concurrency   int           `mapstructure:"concurrency"`     // Max steps running at once per request (default 8)
+	Timeout       time.Duration `mapstructure:"timeout"`         // Deadline for all pipelines of a request (default 30s)
+	QueryCacheTTL time.Duration `mapstructure:"query_cache_ttl"` // How long datasource query results are shared across requests (default 15s, negative disables)
+	// Runners registers custom step types backed by external executables.
+	Runners []RunnerConfig `mapstructure:"runners"`
+}
+
+// OptionsConfig tunes dynamic options lookups. Zero values use the defaults.
+type OptionsConfig struct {
+	CacheTTL time.Duration `mapstructure:"cache_ttl"` // How long resolved options are reused (default 1m, negative disables); fields may override it
+}
+
+// RunnerConfig registers a pipeline step type implemented by an executable speaking the
+// JSON stdin/stdout runner protocol (see docs/technical_spec.md).
+type RunnerConfig struct {
+	Name             string        `mapstructure:"name"`              // Step type referenced by template schemas
+	Command          string        `mapstructure:"command"`           // Path of the executable
+	Args             []string      `mapstructure:"args"`              // Arguments passed to the executable
+	Env              []string      `mapstructure:"env"`               // Additional KEY=VALUE environment variables
+	Timeout          time.Duration `mapstructure:"timeout"`           // Per-run timeout (default 10s)
+	ParametersSchema string        `mapstructure:"parameters_schema"` // JSON Schema the step parameters must satisfy
+}
+
+// DatasourceConfig defines a named, optionally authenticated datasource. Credentials live only in
+// the configuration; schemas reference the datasource by name.
+type DatasourceConfig struct {
+	Name            string            `mapstructure:"name"`
+	Type            string            `mapstructure:"type"`              // prometheus, victoriametrics or thanos
+	URL             string            `mapstructure:"url"`               // Base URL; the query API path is appended
+	Tenant          string            `mapstructure:"tenant"`            // VictoriaMetrics cluster tenant (accountID[:projectID]), queried under /select/<tenant>/prometheus
+	Headers         map[string]string `mapstructure:"headers"`           // Extra request headers, e.g. AccountID (header names are case-insensitive)
+	BearerToken     string            `mapstructure:"bearer_token"`      // Sent as "Authorization: Bearer <token>"
+	BearerTokenFile string            `mapstructure:"bearer_token_file"` // Read on every request, so rotated tokens are picked up
+	BasicAuth       *BasicAuthConfig  `mapstructure:"basic_auth"`
+	TLS             TLSConfig         `mapstructure:"tls"`
+	Timeout         time.Duration     `mapstructure:"timeout"` // Per-request timeout (default 10s)
+}
+
+// BasicAuthConfig holds HTTP basic auth credentials.
+type BasicAuthConfig struct {
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	PasswordFile string `mapstructure:"password_file"` // Read on every request
+}
+
+// TLSConfig holds client TLS settings. Paths refer to files on the server.
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// PoliciesConfig holds the CEL policies evaluated by the rule pipelines.
+type PoliciesConfig struct {
+	// Global policies are evaluated for every rule of every template.
+	Global []PolicyConfig `mapstructure:"global"`
+	// Libraries are named policy sets that template schemas reference from a cel pipeline step.
+	// Library names are case-insensitive (viper lowercases map keys).
+	Libraries map[string][]PolicyConfig `mapstructure:"libraries"`
+}
+
+// PolicyConfig defines a single CEL policy.
+type PolicyConfig struct {
+	Name       string `mapstructure:"name"`
+	Expression string `mapstructure:"expression"` // CEL, must evaluate to true for the rule to pass
+	Message    string `mapstructure:"message"`    // Reported when the policy is violated
+	OnFailure  string `mapstructure:"on_failure"` // error (default), warn or ignore; global policies only
+}
+
 // LoadConfig reads the configuration from config files and environment variables.
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -46,3 +47,116 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "mongodb://test:27017", cfg.Database.ConnectionString)
 	assert.Equal(t, "testdb", cfg.Database.DatabaseName)
 }
+
+func TestLoadConfig_Policies(t *testing.T) {
+	f, err := os.Create("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`policies:
+  global:
+    - name: critical_requires_runbook
+      expression: 'rendered.all(r, r.labels.severity != "critical" || "runbook_url" in r.annotations)'
+      message: critical alerts need a runbook_url
+      on_failure: warn
+  libraries:
+    k8s-guardrails:
+      - name: cpu_threshold
+        expression: 'params.rules.all(r, r.rule_type != "cpu" || r.threshold < 1.0)'
+`)
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove("config.yaml")
+
+	viper.Reset()
+
+	cfg, err := LoadConfig()
+
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Policies.Global, 1)
+	assert.Equal(t, "critical_requires_runbook", cfg.Policies.Global[0].Name)
+	assert.Equal(t, "warn", cfg.Policies.Global[0].OnFailure)
+	assert.Len(t, cfg.Policies.Libraries["k8s-guardrails"], 1)
+	assert.Equal(t, "cpu_threshold", cfg.Policies.Libraries["k8s-guardrails"][0].Name)
+}
+
+func TestLoadConfig_Pipelines(t *testing.T) {
+	f, err := os.Create("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`pipelines:
+  concurrency: 4
+  timeout: 10s
+  query_cache_ttl: 1m
+  runners:
+    - name: kafka_topic_exists
+      command: /usr/local/bin/check-topic
+      args: ["--brokers", "kafka:9092"]
+      timeout: 5s
+      parameters_schema: '{"type": "object", "required": ["topic"]}'
+options:
+  cache_ttl: 5m
+`)
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove("config.yaml")
+
+	viper.Reset()
+
+	cfg, err := LoadConfig()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cfg.Pipelines.Concurrency)
+	assert.Equal(t, 10*time.Second, cfg.Pipelines.Timeout)
+	assert.Equal(t, time.Minute, cfg.Pipelines.QueryCacheTTL)
+	assert.Len(t, cfg.Pipelines.Runners, 1)
+	assert.Equal(t, "kafka_topic_exists", cfg.Pipelines.Runners[0].Name)
+	assert.Equal(t, []string{"--brokers", "kafka:9092"}, cfg.Pipelines.Runners[0].Args)
+	assert.Equal(t, 5*time.Second, cfg.Pipelines.Runners[0].Timeout)
+	assert.JSONEq(t, `{"type": "object", "required": ["topic"]}`, cfg.Pipelines.Runners[0].ParametersSchema)
+	assert.Equal(t, 5*time.Minute, cfg.Options.CacheTTL)
+}
+
+func TestLoadConfig_Datasources(t *testing.T) {
+	f, err := os.Create("config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`datasources:
+  - name: vm-prod
+    type: victoriametrics
+    url: http://vmselect:8481
+    tenant: "42"
+    bearer_token_file: /etc/rulemanager/vm-token
+    headers:
+      AccountID: "42"
+    tls:
+      ca_file: /etc/rulemanager/ca.pem
+    timeout: 5s
+  - name: prometheus-staging
+    type: prometheus
+    url: https://prometheus.staging:9090
+    basic_auth:
+      username: rulemanager
+      password: secret
+`)
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove("config.yaml")
+
+	viper.Reset()
+
+	cfg, err := LoadConfig()
+
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Datasources, 2)
+	vm := cfg.Datasources[0]
+	assert.Equal(t, "vm-prod", vm.Name)
+	assert.Equal(t, "42", vm.Tenant)
+	assert.Equal(t, "/etc/rulemanager/vm-token", vm.BearerTokenFile)
+	assert.Equal(t, "42", vm.Headers["accountid"], "viper lowercases map keys; header names are case-insensitive")
+	assert.Equal(t, "/etc/rulemanager/ca.pem", vm.TLS.CAFile)
+	assert.Equal(t, 5*time.Second, vm.Timeout)
+	assert.Equal(t, "rulemanager", cfg.Datasources[1].BasicAuth.Username)
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInvalidFacet is returned for fields that cannot be faceted.
+var ErrInvalidFacet = errors.New("invalid facet")
+
+// FacetValue is a value of a faceted field and the number of rules holding it.
+type FacetValue struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// RuleFacets holds the value counts of faceted fields over the rules matching a filter. Facets
+// is keyed by field (see ParseFacetFields), and values are ordered by count, most frequent first.
+type RuleFacets struct {
+	Total  int64                   `json:"total"`
+	Facets map[string][]FacetValue `json:"facets"`
+}
+
+// ParseFacetFields parses a comma-separated list of fields to facet, such as
+// "templateName,metadata.team,parameters.target.namespace". Fields are templateName, metadata
+// fields or parameter paths (with or without the "parameters." prefix), which are returned
+// prefixed. Paths cannot index arrays; a path through an array counts the values of every
+// element.
+func ParseFacetFields(s string) ([]string, error) {
+	var fields []string
+	seen := map[string]bool{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		switch {
+		case field == queryFieldTemplateName:
+		case IsMetadataField(field):
+			if err := ValidateMetadataField(field); err != nil || field == metadataPrefix+"links" {
+				return nil, fmt.Errorf("%w: cannot facet '%s' (use metadata.owner, metadata.team, metadata.labels.<name> or metadata.links.name)", ErrInvalidFacet, field)
+			}
+		default:
+			if IsRuleField(field) || field == projectionParameters || field == projectionMetadata {
+				return nil, fmt.Errorf("%w: cannot facet '%s' (use templateName, metadata fields or parameter paths)", ErrInvalidFacet, field)
+			}
+			path := ParameterPath(field)
+			if err := ValidateFieldPath(path); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFacet, err)
+			}
+			for _, segment := range strings.Split(path, ".") {
+				if _, err := strconv.Atoi(segment); err == nil {
+					return nil, fmt.Errorf("%w: field '%s' indexes an array", ErrInvalidFacet, path)
+				}
+			}
+			field = projectionParameters + "." + path
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no fields given", ErrInvalidFacet)
+	}
+	return fields, nil
+}
+
+// facetRules counts facet values over rules in memory, for stores without aggregations. A rule
+// counts once per distinct value; arrays count each element, and objects and nulls are skipped.
+// A limit above 0 keeps the most frequent values of each field.
+func facetRules(rules []*Rule, fields []string, limit int) *RuleFacets {
+	result := &RuleFacets{Total: int64(len(rules)), Facets: map[string][]FacetValue{}}
+	for _, field := range fields {
+		counts := map[any]int64{}
+		for _, rule := range rules {
+			seen := map[any]bool{}
+			for _, v := range ruleFieldValues(rule, field) {
+				switch v.(type) {
+				case string, float64, bool:
+					if !seen[v] {
+						seen[v] = true
+						counts[v]++
+					}
+				}
+			}
+		}
+
+		values := make([]FacetValue, 0, len(counts))
+		for v, n := range counts {
+			values = append(values, FacetValue{Value: v, Count: n})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return compareFacetValues(values[i].Value, values[j].Value) < 0
+		})
+		if limit > 0 && len(values) > limit {
+			values = values[:limit]
+		}
+		result.Facets[field] = values
+	}
+	return result
+}
+
+// ruleFieldValues returns the values of a facet field of a rule, with arrays expanded at every
+// level of the path.
+func ruleFieldValues(rule *Rule, field string) []any {
+	if field == queryFieldTemplateName {
+		return []any{rule.TemplateName}
+	}
+	var doc any
+	path, ok := metadataPath(field)
+	if ok {
+		doc = metadataValues(rule.Metadata)
+	} else if err := json.Unmarshal(rule.Parameters, &doc); err != nil {
+		return nil
+	} else {
+		path = ParameterPath(field)
+	}
+	keys := strings.Split(path, ".")
+	values := lookupPath(doc, keys)
+	for range keys {
+		values = expandArrays(values)
+	}
+	return values
+}
+
+// compareFacetValues orders values like MongoDB: numbers before strings before booleans.
+func compareFacetValues(a, b any) int {
+	rank := func(v any) int {
+		switch v.(type) {
+		case float64:
+			return 0
+		case string:
+			return 1
+		}
+		return 2
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case bool:
+		bv := b.(bool)
+		switch {
+		case av == bv:
+			return 0
+		case !av:
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// mongoFacetPipeline returns the aggregation computing facets over the rules matching query. Each
+// field is a sub-pipeline of $facet, named by its position since field paths are not valid names:
+// the field values are unwound once per path segment (unwinding a scalar keeps it), grouped per
+// rule so that a rule counts once per value, then counted.
+func mongoFacetPipeline(query bson.M, fields []string, limit int) bson.A {
+	facets := bson.M{"total": bson.A{bson.M{"$count": "n"}}}
+	for i, field := range fields {
+		stages := bson.A{bson.M{"$project": bson.M{"v": "$" + field}}}
+		for range strings.Split(field, ".") {
+			stages = append(stages, bson.M{"$unwind": "$v"})
+		}
+		stages = append(stages,
+			bson.M{"$match": bson.M{"v": bson.M{"$type": bson.A{"string", "number", "bool"}}}},
+			bson.M{"$group": bson.M{"_id": bson.M{"r": "$_id", "v": "$v"}}},
+			bson.M{"$group": bson.M{"_id": "$_id.v", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		)
+		if limit > 0 {
+			stages = append(stages, bson.M{"$limit": limit})
+		}
+		facets["f"+strconv.Itoa(i)] = stages
+	}
+	return bson.A{bson.M{"$match": query}, bson.M{"$facet": facets}}
+}
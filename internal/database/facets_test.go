@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// testRuleFacets counts facets over queryTestRules in a store.
+func testRuleFacets(t *testing.T, store RuleStore) {
+	ctx := context.Background()
+
+	t.Run("AllRules", func(t *testing.T) {
+		fields, err := ParseFacetFields("templateName,target.namespace,parameters.rules.rule_type,target.port,target.tags,rules.enabled")
+		require.NoError(t, err)
+		facets, err := store.FacetRules(ctx, RuleFilter{}, fields, 0)
+		require.NoError(t, err)
+
+		data, err := json.Marshal(facets)
+		require.NoError(t, err)
+		// Numbers and their text are distinct values; a rule counts once per value
+		assert.JSONEq(t, `{"total": 3, "facets": {
+			"templateName": [{"value": "k8s", "count": 2}, {"value": "openstack", "count": 1}],
+			"parameters.target.namespace": [{"value": "infra", "count": 1}, {"value": "team-a", "count": 1}, {"value": "team-b", "count": 1}],
+			"parameters.rules.rule_type": [{"value": "cpu", "count": 2}, {"value": "memory", "count": 2}],
+			"parameters.target.port": [{"value": 8080, "count": 1}, {"value": "8080", "count": 1}],
+			"parameters.target.tags": [{"value": "eu", "count": 1}, {"value": "gold", "count": 1}],
+			"parameters.rules.enabled": [{"value": true, "count": 1}]
+		}}`, string(data))
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		fields, err := ParseFacetFields("metadata.team,metadata.labels.tier,metadata.links.name")
+		require.NoError(t, err)
+		facets, err := store.FacetRules(ctx, RuleFilter{}, fields, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []FacetValue{{Value: "payments", Count: 1}, {Value: "platform", Count: 1}}, facets.Facets["metadata.team"])
+		assert.Equal(t, []FacetValue{{Value: "1", Count: 1}, {Value: "2", Count: 1}}, facets.Facets["metadata.labels.tier"])
+		assert.Equal(t, []FacetValue{{Value: "runbook", Count: 1}}, facets.Facets["metadata.links.name"])
+	})
+
+	t.Run("FilterAndLimit", func(t *testing.T) {
+		q, err := ParseQuery(`rules.threshold>0.8`)
+		require.NoError(t, err)
+		facets, err := store.FacetRules(ctx, RuleFilter{Query: q}, []string{"templateName", "parameters.target.namespace"}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), facets.Total)
+		assert.Equal(t, []FacetValue{{Value: "k8s", Count: 2}}, facets.Facets["templateName"])
+		assert.Equal(t, []FacetValue{{Value: "team-a", Count: 1}}, facets.Facets["parameters.target.namespace"])
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		facets, err := store.FacetRules(ctx, RuleFilter{TemplateName: "none"}, []string{"templateName"}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), facets.Total)
+		assert.Empty(t, facets.Facets["templateName"])
+	})
+}
+
+func TestFileStore_FacetRules(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRuleFacets(t, store)
+}
+
+func TestParseFacetFields(t *testing.T) {
+	fields, err := ParseFacetFields("templateName, target.namespace,parameters.target.namespace")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"templateName", "parameters.target.namespace"}, fields)
+
+	for _, s := range []string{"", "id", "createdAt", "parameters", "metadata", "metadata.links", "metadata.ticket", "rules.0.threshold", "target.$ne"} {
+		_, err := ParseFacetFields(s)
+		assert.ErrorIs(t, err, ErrInvalidFacet, s)
+	}
+}
+
+func TestMongoFacetPipeline(t *testing.T) {
+	pipeline := mongoFacetPipeline(bson.M{"templateName": "k8s"}, []string{"parameters.target.tags"}, 5)
+	require.Len(t, pipeline, 2)
+	assert.Equal(t, bson.M{"$match": bson.M{"templateName": "k8s"}}, pipeline[0])
+
+	facets := pipeline[1].(bson.M)["$facet"].(bson.M)
+	assert.Equal(t, bson.A{bson.M{"$count": "n"}}, facets["total"])
+	stages := facets["f0"].(bson.A)
+	assert.Equal(t, bson.M{"$project": bson.M{"v": "$parameters.target.tags"}}, stages[0])
+	// One unwind per path segment expands arrays nested in arrays
+	assert.Equal(t, bson.M{"$unwind": "$v"}, stages[3])
+	assert.Equal(t, bson.M{"$limit": 5}, stages[len(stages)-1])
+}
@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
-// FileStore implements RuleStore and TemplateProvider using the local filesystem.
+// FileStore implements RuleStore, TemplateProvider and DatasourceStore using the local filesystem.
 type FileStore struct {
 	basePath string
 	mu       sync.RWMutex
@@ -26,6 +28,9 @@ func NewFileStore(basePath string) (*FileStore, error) {
 	if err := os.MkdirAll(filepath.Join(basePath, "templates"), 0o755); err != nil {
 		return nil, fmt.Errorf("failed to create templates directory: %w", err)
 	}
+	if err := os.MkdirAll(filepath.Join(basePath, "datasources"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create datasources directory: %w", err)
+	}
 
 	return &FileStore{
 		basePath: basePath,
@@ -54,6 +59,15 @@ func (s *FileStore) CreateRule(ctx context.Context, rule *Rule) error {
 	if _, err := os.Stat(path); err == nil {
 		return errors.New("rule already exists")
 	}
+	if err := s.checkRuleName(rule); err != nil {
+		return err
+	}
+
+	// Timestamps are set like in the MongoStore, so listings sort the same way
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	rule.UpdatedAt = time.Now()
 
 	data, err := json.MarshalIndent(rule, "", "  ")
 	if err != nil {
@@ -72,7 +86,7 @@ func (s *FileStore) GetRule(ctx context.Context, id string) (*Rule, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, errors.New("rule not found")
+			return nil, ErrRuleNotFound
 		}
 		return nil, err
 	}
@@ -84,6 +98,67 @@ func (s *FileStore) GetRule(ctx context.Context, id string) (*Rule, error) {
 	return &rule, nil
 }
 
+// GetRuleByName retrieves the rule of a tenant and template with a name from the file store.
+func (s *FileStore) GetRuleByName(ctx context.Context, tenant, templateName, name string) (*Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, err := s.findRuleByName(tenant, templateName, name)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// findRuleByName scans the rules for a tenant, template and name; it returns nil when none has
+// them. Callers hold the lock.
+func (s *FileStore) findRuleByName(tenant, templateName, name string) (*Rule, error) {
+	dir := filepath.Join(s.basePath, "rules")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		var rule Rule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			continue
+		}
+		if rule.Name == name && rule.TemplateName == templateName && rule.Tenant == tenant {
+			return &rule, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkRuleName checks that no other rule of the tenant and template has the name of a rule.
+// Callers hold the lock.
+func (s *FileStore) checkRuleName(rule *Rule) error {
+	if rule.Name == "" {
+		return nil
+	}
+	other, err := s.findRuleByName(rule.Tenant, rule.TemplateName, rule.Name)
+	if err != nil {
+		return err
+	}
+	if other != nil && other.ID != rule.ID {
+		return fmt.Errorf("%w: '%s' is used by rule %s of template '%s'%s", ErrDuplicateRuleName, rule.Name, other.ID, rule.TemplateName, tenantSuffix(rule.Tenant))
+	}
+	return nil
+}
+
 // UpdateRule updates an existing rule in the file store.
 func (s *FileStore) UpdateRule(ctx context.Context, id string, rule *Rule) error {
 	s.mu.Lock()
@@ -92,12 +167,26 @@ func (s *FileStore) UpdateRule(ctx context.Context, id string, rule *Rule) error
 	path := filepath.Join(s.basePath, "rules", id+".json")
 
 	// Check if exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return errors.New("rule not found")
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ErrRuleNotFound
+	}
+	if err != nil {
+		return err
 	}
 
-	// Ensure ID in rule matches
+	// Ensure ID in rule matches and keep the creation time and tenant, which the MongoStore does
+	// not update either
 	rule.ID = id
+	var stored Rule
+	if err := json.Unmarshal(existing, &stored); err == nil {
+		rule.CreatedAt = stored.CreatedAt
+		rule.Tenant = stored.Tenant
+	}
+	if err := s.checkRuleName(rule); err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now()
 
 	data, err := json.MarshalIndent(rule, "", "  ")
 	if err != nil {
@@ -115,7 +204,7 @@ func (s *FileStore) DeleteRule(ctx context.Context, id string) error {
 	path := filepath.Join(s.basePath, "rules", id+".json")
 	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
-			return errors.New("rule not found")
+			return ErrRuleNotFound
 		}
 		return err
 	}
@@ -123,7 +212,7 @@ func (s *FileStore) DeleteRule(ctx context.Context, id string) error {
 }
 
 // ListRules retrieves a paginated list of rules from the file store.
-func (s *FileStore) ListRules(ctx context.Context, limit, offset int) ([]*Rule, error) {
+func (s *FileStore) ListRules(ctx context.Context, offset, limit int) ([]*Rule, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -168,8 +257,33 @@ func (s *FileStore) ListRules(ctx context.Context, limit, offset int) ([]*Rule,
 	return rules[offset:end], nil
 }
 
+// SearchRulesPage searches for rules matching the given filter and returns the requested page.
+// Rules are sorted in memory.
+func (s *FileStore) SearchRulesPage(ctx context.Context, filter RuleFilter, page PageRequest) (*RulePage, error) {
+	rules, err := s.SearchRules(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return pageRules(rules, page)
+}
+
+// FacetRules counts field values over the rules matching the given filter, in memory.
+func (s *FileStore) FacetRules(ctx context.Context, filter RuleFilter, fields []string, limit int) (*RuleFacets, error) {
+	rules, err := s.SearchRules(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return facetRules(rules, fields, limit), nil
+}
+
 // SearchRules searches for rules matching the given filter.
 func (s *FileStore) SearchRules(ctx context.Context, filter RuleFilter) ([]*Rule, error) {
+	expr, err := filterExpr(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := &Query{Expr: expr}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -196,7 +310,7 @@ func (s *FileStore) SearchRules(ctx context.Context, filter RuleFilter) ([]*Rule
 			continue
 		}
 
-		if s.matchesFilter(&rule, filter) {
+		if query.Matches(&rule) {
 			rules = append(rules, &rule)
 		}
 	}
@@ -204,67 +318,6 @@ func (s *FileStore) SearchRules(ctx context.Context, filter RuleFilter) ([]*Rule
 	return rules, nil
 }
 
-func (s *FileStore) matchesFilter(rule *Rule, filter RuleFilter) bool {
-	if filter.TemplateName != "" && rule.TemplateName != filter.TemplateName {
-		return false
-	}
-
-	for key, value := range filter.Parameters {
-		// Flatten parameters to check for nested keys
-		// This is a simple implementation, might need optimization for deep nesting if frequent
-		// For now, we'll just unmarshal to map and traverse
-		var params map[string]interface{}
-		if err := json.Unmarshal(rule.Parameters, &params); err != nil {
-			return false
-		}
-
-		if !s.checkNestedValue(params, key, value) {
-			return false
-		}
-	}
-	return true
-}
-
-func (s *FileStore) checkNestedValue(data map[string]interface{}, keyPath string, expectedValue string) bool {
-	keys := splitKeyPath(keyPath)
-	current := data
-
-	for i, key := range keys {
-		val, ok := current[key]
-		if !ok {
-			return false
-		}
-
-		if i == len(keys)-1 {
-			// Last key, check value
-			return fmt.Sprintf("%v", val) == expectedValue
-		}
-
-		// Navigate deeper
-		if nextMap, ok := val.(map[string]interface{}); ok {
-			current = nextMap
-		} else {
-			return false
-		}
-	}
-	return false
-}
-
-func splitKeyPath(path string) []string {
-	// Simple split by dot, assuming no dots in keys
-	// In a real scenario, might need more robust parsing
-	var keys []string
-	start := 0
-	for i := range path {
-		if path[i] == '.' {
-			keys = append(keys, path[start:i])
-			start = i + 1
-		}
-	}
-	keys = append(keys, path[start:])
-	return keys
-}
-
 // --- TemplateProvider Implementation ---
 
 // Templates are stored as JSON files: templates/{name}_{type}.json
@@ -413,3 +466,128 @@ func (s *FileStore) deleteTemplateFile(name, typeStr string) error {
 	}
 	return nil
 }
+
+// --- DatasourceStore Implementation ---
+
+// Datasources are stored as JSON files: datasources/{name}.json
+
+// CreateDatasource saves a new datasource to the file store.
+func (s *FileStore) CreateDatasource(ctx context.Context, ds *Datasource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.datasourcePath(ds.Name)
+	if _, err := os.Stat(path); err == nil {
+		return ErrDatasourceExists
+	}
+
+	now := time.Now()
+	ds.CreatedAt, ds.UpdatedAt = now, now
+	return writeJSONFile(path, ds)
+}
+
+// GetDatasource retrieves a datasource by name from the file store.
+func (s *FileStore) GetDatasource(ctx context.Context, name string) (*Datasource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.datasourcePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrDatasourceNotFound
+		}
+		return nil, err
+	}
+
+	var ds Datasource
+	if err := json.Unmarshal(data, &ds); err != nil {
+		return nil, err
+	}
+	return &ds, nil
+}
+
+// ListDatasources retrieves all datasources from the file store, sorted by name.
+func (s *FileStore) ListDatasources(ctx context.Context) ([]*Datasource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dir := filepath.Join(s.basePath, "datasources")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Datasource{}, nil
+		}
+		return nil, err
+	}
+
+	datasources := make([]*Datasource, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // Skip unreadable files
+		}
+
+		var ds Datasource
+		if err := json.Unmarshal(data, &ds); err != nil {
+			continue // Skip invalid JSON
+		}
+		datasources = append(datasources, &ds)
+	}
+
+	sort.Slice(datasources, func(i, j int) bool { return datasources[i].Name < datasources[j].Name })
+	return datasources, nil
+}
+
+// UpdateDatasource replaces an existing datasource in the file store.
+func (s *FileStore) UpdateDatasource(ctx context.Context, name string, ds *Datasource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.datasourcePath(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrDatasourceNotFound
+		}
+		return err
+	}
+	var existing Datasource
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return err
+	}
+
+	ds.Name = name
+	ds.CreatedAt = existing.CreatedAt
+	ds.UpdatedAt = time.Now()
+	return writeJSONFile(path, ds)
+}
+
+// DeleteDatasource removes a datasource from the file store.
+func (s *FileStore) DeleteDatasource(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.datasourcePath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrDatasourceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) datasourcePath(name string) string {
+	return filepath.Join(s.basePath, "datasources", name+".json")
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
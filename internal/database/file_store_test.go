@@ -90,4 +90,76 @@ func TestFileStore_SearchRules(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Len(t, rules, 0)
 	})
+
+	t.Run("FilterByPrefixedParameter", func(t *testing.T) {
+		filter := RuleFilter{
+			Parameters: map[string]string{"parameters.target.namespace": "ns2"},
+		}
+		rules, err := store.SearchRules(ctx, filter)
+		assert.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "2", rules[0].ID)
+	})
+
+	t.Run("InvalidParameterPath", func(t *testing.T) {
+		filter := RuleFilter{
+			Parameters: map[string]string{"$where": "1"},
+		}
+		_, err := store.SearchRules(ctx, filter)
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+	})
+}
+
+func TestFileStore_Datasources(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	testDatasourceStore(t, store)
+}
+
+// testDatasourceStore runs the DatasourceStore contract against a store implementation.
+func testDatasourceStore(t *testing.T, store DatasourceStore) {
+	ctx := context.Background()
+
+	ds := &Datasource{
+		Name: "vm-prod",
+		DatasourceSettings: DatasourceSettings{
+			Type: "victoriametrics",
+			URL:  "http://vmselect:8481",
+			Auth: &DatasourceAuth{BearerToken: "s3cr3t"},
+		},
+		Environments: map[string]DatasourceSettings{"staging": {URL: "http://vmselect-staging:8481"}},
+	}
+
+	require.NoError(t, store.CreateDatasource(ctx, ds))
+	assert.ErrorIs(t, store.CreateDatasource(ctx, &Datasource{Name: "vm-prod"}), ErrDatasourceExists)
+
+	fetched, err := store.GetDatasource(ctx, "vm-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "http://vmselect:8481", fetched.URL)
+	assert.Equal(t, "s3cr3t", fetched.Auth.BearerToken)
+	assert.Equal(t, "http://vmselect-staging:8481", fetched.Environments["staging"].URL)
+	assert.False(t, fetched.CreatedAt.IsZero())
+
+	require.NoError(t, store.CreateDatasource(ctx, &Datasource{Name: "prometheus", DatasourceSettings: DatasourceSettings{Type: "prometheus", URL: "http://prom:9090"}}))
+	list, err := store.ListDatasources(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "prometheus", list[0].Name)
+	assert.Equal(t, "vm-prod", list[1].Name)
+
+	update := &Datasource{DatasourceSettings: DatasourceSettings{Type: "victoriametrics", URL: "http://vmselect-new:8481"}}
+	require.NoError(t, store.UpdateDatasource(ctx, "vm-prod", update))
+	fetched, err = store.GetDatasource(ctx, "vm-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "http://vmselect-new:8481", fetched.URL)
+	assert.Nil(t, fetched.Auth)
+	assert.Empty(t, fetched.Environments)
+	assert.WithinDuration(t, ds.CreatedAt, fetched.CreatedAt, time.Millisecond, "update keeps the creation time")
+	assert.ErrorIs(t, store.UpdateDatasource(ctx, "missing", update), ErrDatasourceNotFound)
+
+	require.NoError(t, store.DeleteDatasource(ctx, "vm-prod"))
+	_, err = store.GetDatasource(ctx, "vm-prod")
+	assert.ErrorIs(t, err, ErrDatasourceNotFound)
+	assert.ErrorIs(t, store.DeleteDatasource(ctx, "vm-prod"), ErrDatasourceNotFound)
 }
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidMetadata is returned for rule metadata that cannot be stored.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// RuleMetadata describes who owns a rule and why it exists, independently of the template
+// parameters. It is not validated against template schemas.
+type RuleMetadata struct {
+	Owner       string            `json:"owner,omitempty" bson:"owner,omitempty"`
+	Team        string            `json:"team,omitempty" bson:"team,omitempty"`
+	Description string            `json:"description,omitempty" bson:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	Links       []RuleLink        `json:"links,omitempty" bson:"links,omitempty"`
+}
+
+// RuleLink is a named link of a rule, such as a ticket or a runbook.
+type RuleLink struct {
+	Name string `json:"name" bson:"name"`
+	URL  string `json:"url" bson:"url"`
+}
+
+// metadataPrefix prefixes metadata fields in queries, filters, projections and facets.
+const metadataPrefix = "metadata."
+
+// labelName is the form of a label name, as Prometheus requires since labels can end up on alerts.
+var labelName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks label names and links, which must be http or https URLs.
+func (m *RuleMetadata) Validate() error {
+	if m == nil {
+		return nil
+	}
+	for name := range m.Labels {
+		if !labelName.MatchString(name) {
+			return fmt.Errorf("%w: label name '%s' must match %s", ErrInvalidMetadata, name, labelName)
+		}
+	}
+	for i, link := range m.Links {
+		u, err := url.Parse(link.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: link %d needs an absolute URL, got '%s'", ErrInvalidMetadata, i, link.URL)
+		}
+		// Links are rendered in UIs, so schemes such as javascript: must not get through.
+		if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+			return fmt.Errorf("%w: link %d must be an http or https URL, got '%s'", ErrInvalidMetadata, i, link.URL)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether the metadata holds nothing.
+func (m *RuleMetadata) IsZero() bool {
+	return m == nil || (m.Owner == "" && m.Team == "" && m.Description == "" && len(m.Labels) == 0 && len(m.Links) == 0)
+}
+
+// AlertLabels returns the labels the metadata adds to alerts: the metadata labels, plus owner and
+// team when set.
+func (m *RuleMetadata) AlertLabels() map[string]string {
+	labels := map[string]string{}
+	if m == nil {
+		return labels
+	}
+	for name, value := range m.Labels {
+		labels[name] = value
+	}
+	if m.Owner != "" {
+		labels["owner"] = m.Owner
+	}
+	if m.Team != "" {
+		labels["team"] = m.Team
+	}
+	return labels
+}
+
+// metadataPath returns the path inside the metadata of a field such as "metadata.team", or false
+// for other fields.
+func metadataPath(field string) (string, bool) {
+	return strings.CutPrefix(field, metadataPrefix)
+}
+
+// IsMetadataField reports whether a field refers to rule metadata.
+func IsMetadataField(field string) bool {
+	_, ok := metadataPath(field)
+	return ok
+}
+
+// ValidateMetadataField checks that a metadata field exists: metadata.owner, metadata.team,
+// metadata.description, metadata.labels.<name>, metadata.links, metadata.links.name or
+// metadata.links.url.
+func ValidateMetadataField(field string) error {
+	path, _ := metadataPath(field)
+	switch path {
+	case "owner", "team", "description", "links", "links.name", "links.url":
+		return nil
+	}
+	if name, ok := strings.CutPrefix(path, "labels."); ok && labelName.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("unknown metadata field '%s' (use owner, team, description, labels.<name>, links.name or links.url)", field)
+}
+
+// metadataValues returns the metadata as a JSON document, for in-memory path lookups.
+func metadataValues(m *RuleMetadata) map[string]any {
+	if m == nil {
+		return nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	var values map[string]any
+	_ = json.Unmarshal(data, &values)
+	return values
+}
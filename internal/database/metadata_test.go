@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleMetadata_Validate(t *testing.T) {
+	var none *RuleMetadata
+	assert.NoError(t, none.Validate())
+	assert.True(t, none.IsZero())
+	assert.True(t, (&RuleMetadata{}).IsZero())
+
+	valid := &RuleMetadata{
+		Owner:  "alice",
+		Labels: map[string]string{"cost_center": "42"},
+		Links:  []RuleLink{{Name: "ticket", URL: "https://tickets.example.com/OPS-1"}},
+	}
+	assert.NoError(t, valid.Validate())
+	assert.False(t, valid.IsZero())
+
+	err := (&RuleMetadata{Labels: map[string]string{"cost-center": "42"}}).Validate()
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+	assert.ErrorContains(t, err, "label name 'cost-center'")
+
+	err = (&RuleMetadata{Links: []RuleLink{{Name: "ticket", URL: "OPS-1"}}}).Validate()
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+
+	for _, link := range []string{"javascript://x/%0aalert(1)", "JavaScript://x/%0aalert(1)", "ftp://files.example.com/runbook", "data://x/text/html"} {
+		err = (&RuleMetadata{Links: []RuleLink{{Name: "runbook", URL: link}}}).Validate()
+		assert.ErrorIs(t, err, ErrInvalidMetadata, link)
+	}
+	assert.NoError(t, (&RuleMetadata{Links: []RuleLink{{Name: "runbook", URL: "HTTP://runbooks.example.com/cpu"}}}).Validate())
+}
+
+func TestRuleMetadata_AlertLabels(t *testing.T) {
+	var none *RuleMetadata
+	assert.Empty(t, none.AlertLabels())
+
+	m := &RuleMetadata{Owner: "alice", Team: "payments", Description: "not a label", Labels: map[string]string{"tier": "1", "team": "ignored"}}
+	assert.Equal(t, map[string]string{"owner": "alice", "team": "payments", "tier": "1"}, m.AlertLabels())
+}
+
+func TestValidateMetadataField(t *testing.T) {
+	for _, field := range []string{"metadata.owner", "metadata.team", "metadata.description", "metadata.labels.tier", "metadata.links", "metadata.links.url"} {
+		assert.NoError(t, ValidateMetadataField(field), field)
+	}
+	for _, field := range []string{"metadata.ticket", "metadata.labels", "metadata.labels.a-b", "metadata.links.title"} {
+		assert.Error(t, ValidateMetadataField(field), field)
+	}
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mongoQuery translates a parsed query into a MongoDB filter on the rules collection.
+func mongoQuery(q *Query) bson.M {
+	if q == nil || q.Expr == nil {
+		return bson.M{}
+	}
+	return mongoExpr(q.Expr, false)
+}
+
+// mongoField maps a query field to its document path. Fields inside element matches are
+// relative to the array element and kept as they are.
+func mongoField(field string, relative bool) string {
+	if relative {
+		return field
+	}
+	if path, ok := parameterPath(field); ok {
+		return "parameters." + path
+	}
+	if field == queryFieldID {
+		return "_id"
+	}
+	return field
+}
+
+func mongoExpr(expr QueryExpr, relative bool) bson.M {
+	switch e := expr.(type) {
+	case *AndExpr:
+		return bson.M{"$and": mongoExprs(e.Exprs, relative)}
+	case *OrExpr:
+		return bson.M{"$or": mongoExprs(e.Exprs, relative)}
+	case *NotExpr:
+		return bson.M{"$nor": bson.A{mongoExpr(e.Expr, relative)}}
+	case *ExistsExpr:
+		return bson.M{mongoField(e.Field, relative): bson.M{"$exists": true}}
+	case *ElemMatchExpr:
+		return bson.M{mongoField(e.Field, relative): bson.M{"$elemMatch": mongoExpr(e.Expr, true)}}
+	case *CompareExpr:
+		return bson.M{mongoField(e.Field, relative): mongoCompare(e, !relative && isTimeField(e.Field))}
+	}
+	return bson.M{}
+}
+
+func mongoExprs(exprs []QueryExpr, relative bool) bson.A {
+	out := make(bson.A, 0, len(exprs))
+	for _, e := range exprs {
+		out = append(out, mongoExpr(e, relative))
+	}
+	return out
+}
+
+func mongoCompare(e *CompareExpr, timeField bool) bson.M {
+	switch e.Op {
+	case OpEq:
+		if e.Values[0].Kind == ValueString && !timeField {
+			return bson.M{"$eq": e.Values[0].Text}
+		}
+		return bson.M{"$in": mongoValues(e.Values, timeField)}
+	case OpIn:
+		return bson.M{"$in": mongoValues(e.Values, timeField)}
+	case OpNe, OpNotIn:
+		return bson.M{"$nin": mongoValues(e.Values, timeField)}
+	case OpRegex:
+		return bson.M{"$regex": primitive.Regex{Pattern: e.re.String()}}
+	case OpNotRegex:
+		return bson.M{"$not": primitive.Regex{Pattern: e.re.String()}}
+	}
+
+	var value any
+	switch {
+	case timeField:
+		value = mongoTime(e.Values[0])
+	case e.Values[0].Kind == ValueNumber:
+		value = e.Values[0].Number
+	default:
+		value = e.Values[0].Text
+	}
+	ops := map[string]string{OpGt: "$gt", OpGte: "$gte", OpLt: "$lt", OpLte: "$lte"}
+	return bson.M{ops[e.Op]: value}
+}
+
+// mongoValues expands literals for $in/$nin: unquoted numbers and booleans match their typed
+// value and their text.
+func mongoValues(lits []QueryValue, timeField bool) bson.A {
+	values := bson.A{}
+	for _, lit := range lits {
+		switch {
+		case timeField:
+			values = append(values, mongoTime(lit))
+		case lit.Kind == ValueNumber:
+			values = append(values, lit.Number, lit.Text)
+		case lit.Kind == ValueBool:
+			values = append(values, lit.Bool, lit.Text)
+		default:
+			values = append(values, lit.Text)
+		}
+	}
+	return values
+}
+
+// mongoSort returns the sort document of a rule sort, with _id as the tie-breaker.
+func mongoSort(s RuleSort) bson.D {
+	direction := 1
+	if s.Desc {
+		direction = -1
+	}
+	if s.Field == queryFieldID {
+		return bson.D{{Key: "_id", Value: direction}}
+	}
+	return bson.D{{Key: s.Field, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// mongoAfter matches the rules after a cursor position in the sort order.
+func mongoAfter(s RuleSort, after *pageCursor) bson.M {
+	op := "$gt"
+	if s.Desc {
+		op = "$lt"
+	}
+	if s.Field == queryFieldID {
+		return bson.M{"_id": bson.M{op: after.ID}}
+	}
+	var value any = after.Value
+	if isTimeField(s.Field) {
+		// Cursors are validated when decoded
+		value, _ = time.Parse(time.RFC3339Nano, after.Value)
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{s.Field: bson.M{op: value}},
+		bson.M{s.Field: value, "_id": bson.M{op: after.ID}},
+	}}
+}
+
+func mongoTime(lit QueryValue) time.Time {
+	// Time literals are validated when the query is parsed
+	t, _ := parseTime(lit)
+	return t
+}
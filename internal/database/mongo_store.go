@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,19 +15,23 @@ import (
 )
 
 type MongoStore struct {
-	client        *mongo.Client
-	database      *mongo.Database
-	rulesColl     *mongo.Collection
-	schemasColl   *mongo.Collection
-	templatesColl *mongo.Collection
+	client          *mongo.Client
+	database        *mongo.Database
+	rulesColl       *mongo.Collection
+	schemasColl     *mongo.Collection
+	templatesColl   *mongo.Collection
+	datasourcesColl *mongo.Collection
 }
 
 type mongoRule struct {
-	ID           string    `bson:"_id,omitempty"`
-	TemplateName string    `bson:"templateName"`
-	Parameters   bson.M    `bson:"parameters"`
-	CreatedAt    time.Time `bson:"createdAt"`
-	UpdatedAt    time.Time `bson:"updatedAt"`
+	ID           string        `bson:"_id,omitempty"`
+	Name         string        `bson:"name,omitempty"`
+	Tenant       string        `bson:"tenant,omitempty"`
+	TemplateName string        `bson:"templateName"`
+	Parameters   bson.M        `bson:"parameters"`
+	CreatedAt    time.Time     `bson:"createdAt"`
+	UpdatedAt    time.Time     `bson:"updatedAt"`
+	Metadata     *RuleMetadata `bson:"metadata,omitempty"`
 }
 
 func toMongoRule(r *Rule) (*mongoRule, error) {
@@ -37,10 +43,13 @@ func toMongoRule(r *Rule) (*mongoRule, error) {
 	}
 	return &mongoRule{
 		ID:           r.ID,
+		Name:         r.Name,
+		Tenant:       r.Tenant,
 		TemplateName: r.TemplateName,
 		Parameters:   params,
 		CreatedAt:    r.CreatedAt,
 		UpdatedAt:    r.UpdatedAt,
+		Metadata:     r.Metadata,
 	}, nil
 }
 
@@ -51,10 +60,13 @@ func fromMongoRule(mr *mongoRule) (*Rule, error) {
 	}
 	return &Rule{
 		ID:           mr.ID,
+		Name:         mr.Name,
+		Tenant:       mr.Tenant,
 		TemplateName: mr.TemplateName,
 		Parameters:   params,
 		CreatedAt:    mr.CreatedAt,
 		UpdatedAt:    mr.UpdatedAt,
+		Metadata:     mr.Metadata,
 	}, nil
 }
 
@@ -71,13 +83,38 @@ func NewMongoStore(ctx context.Context, connectionString, dbName string) (*Mongo
 	}
 
 	db := client.Database(dbName)
-	return &MongoStore{
-		client:        client,
-		database:      db,
-		rulesColl:     db.Collection("rules"),
-		schemasColl:   db.Collection("schemas"),
-		templatesColl: db.Collection("templates"),
-	}, nil
+	s := &MongoStore{
+		client:          client,
+		database:        db,
+		rulesColl:       db.Collection("rules"),
+		schemasColl:     db.Collection("schemas"),
+		templatesColl:   db.Collection("templates"),
+		datasourcesColl: db.Collection("datasources"),
+	}
+
+	if err := s.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ensureIndexes creates the indexes the store relies on, if missing.
+func (s *MongoStore) ensureIndexes(ctx context.Context) error {
+	// Rule names are unique per tenant and template; rules without a name are not indexed, and
+	// rules without a tenant share the null tenant
+	_, err := s.rulesColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "templateName", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"name": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		return err
+	}
+	// Drop the per-template index of earlier versions, which would keep names unique across tenants
+	var cmdErr mongo.CommandError
+	if _, err := s.rulesColl.Indexes().DropOne(ctx, "templateName_1_name_1"); err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == 27) {
+		return err
+	}
+	return nil
 }
 
 // Close closes the MongoDB connection.
@@ -103,7 +140,7 @@ func (s *MongoStore) CreateRule(ctx context.Context, rule *Rule) error {
 	}
 
 	_, err = s.rulesColl.InsertOne(ctx, mr)
-	return err
+	return ruleWriteError(err, rule)
 }
 
 // GetRule retrieves a rule by ID from MongoDB.
@@ -111,13 +148,38 @@ func (s *MongoStore) GetRule(ctx context.Context, id string) (*Rule, error) {
 	var mr mongoRule
 	if err := s.rulesColl.FindOne(ctx, bson.M{"_id": id}).Decode(&mr); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("rule not found")
+			return nil, ErrRuleNotFound
 		}
 		return nil, err
 	}
 	return fromMongoRule(&mr)
 }
 
+// GetRuleByName retrieves the rule of a tenant and template with a name from MongoDB.
+func (s *MongoStore) GetRuleByName(ctx context.Context, tenant, templateName, name string) (*Rule, error) {
+	// Rules without a tenant have no tenant field, which null matches
+	var tenantValue interface{}
+	if tenant != "" {
+		tenantValue = tenant
+	}
+	var mr mongoRule
+	if err := s.rulesColl.FindOne(ctx, bson.M{"tenant": tenantValue, "templateName": templateName, "name": name}).Decode(&mr); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, err
+	}
+	return fromMongoRule(&mr)
+}
+
+// ruleWriteError reports violations of the rule name index as ErrDuplicateRuleName.
+func ruleWriteError(err error, rule *Rule) error {
+	if mongo.IsDuplicateKeyError(err) && rule.Name != "" {
+		return fmt.Errorf("%w: '%s' is used by another rule of template '%s'%s", ErrDuplicateRuleName, rule.Name, rule.TemplateName, tenantSuffix(rule.Tenant))
+	}
+	return err
+}
+
 // ListRules retrieves a paginated list of rules from MongoDB.
 func (s *MongoStore) ListRules(ctx context.Context, offset, limit int) ([]*Rule, error) {
 	opts := options.Find().SetSkip(int64(offset)).SetLimit(int64(limit))
@@ -144,16 +206,11 @@ func (s *MongoStore) ListRules(ctx context.Context, offset, limit int) ([]*Rule,
 
 // SearchRules searches for rules matching the given filter.
 func (s *MongoStore) SearchRules(ctx context.Context, filter RuleFilter) ([]*Rule, error) {
-	query := bson.M{}
-
-	if filter.TemplateName != "" {
-		query["templateName"] = filter.TemplateName
-	}
-
-	for key, value := range filter.Parameters {
-		// Use the key exactly as provided - no automatic prefixing
-		query[key] = value
+	expr, err := filterExpr(filter)
+	if err != nil {
+		return nil, err
 	}
+	query := mongoQuery(&Query{Expr: expr})
 
 	cursor, err := s.rulesColl.Find(ctx, query)
 	if err != nil {
@@ -176,7 +233,133 @@ func (s *MongoStore) SearchRules(ctx context.Context, filter RuleFilter) ([]*Rul
 	return rules, nil
 }
 
-// UpdateRule updates an existing rule in MongoDB.
+// SearchRulesPage searches for rules matching the given filter and returns the requested page.
+// Sorting, cursors and limits are applied by MongoDB; the total is counted separately.
+func (s *MongoStore) SearchRulesPage(ctx context.Context, filter RuleFilter, page PageRequest) (*RulePage, error) {
+	expr, err := filterExpr(filter)
+	if err != nil {
+		return nil, err
+	}
+	query := mongoQuery(&Query{Expr: expr})
+
+	rs := page.Sort
+	if rs.Field == "" {
+		rs = DefaultRuleSort
+	}
+	opts := options.Find().SetSort(mongoSort(rs))
+	find := query
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor, rs)
+		if err != nil {
+			return nil, err
+		}
+		find = bson.M{"$and": bson.A{query, mongoAfter(rs, after)}}
+	} else if page.Offset > 0 {
+		opts.SetSkip(int64(page.Offset))
+	}
+	if page.Limit > 0 {
+		// One more rule tells whether there is a next page
+		opts.SetLimit(int64(page.Limit) + 1)
+	}
+	if page.Fields != nil {
+		opts.SetProjection(mongoProjection(page.Fields, rs))
+	}
+
+	total, err := s.rulesColl.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.rulesColl.Find(ctx, find, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := &RulePage{Rules: []*Rule{}, Total: total}
+	for cursor.Next(ctx) {
+		var mr mongoRule
+		if err := cursor.Decode(&mr); err != nil {
+			return nil, err
+		}
+		rule, err := fromMongoRule(&mr)
+		if err != nil {
+			return nil, err
+		}
+		result.Rules = append(result.Rules, rule)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	if page.Limit > 0 && len(result.Rules) > page.Limit {
+		result.Rules = result.Rules[:page.Limit]
+		result.NextCursor = encodeCursor(result.Rules[page.Limit-1], rs)
+	}
+	if page.Fields != nil {
+		// The sort field was fetched for the cursor; drop it unless it was requested
+		for i, rule := range result.Rules {
+			projected := trimRule(*rule, page.Fields)
+			if len(ProjectedParameterPaths(page.Fields)) > 0 && string(projected.Parameters) == "null" {
+				projected.Parameters = json.RawMessage("{}")
+			}
+			result.Rules[i] = &projected
+		}
+	}
+	return result, nil
+}
+
+// FacetRules counts field values over the rules matching the given filter with an aggregation.
+func (s *MongoStore) FacetRules(ctx context.Context, filter RuleFilter, fields []string, limit int) (*RuleFacets, error) {
+	expr, err := filterExpr(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := s.rulesColl.Aggregate(ctx, mongoFacetPipeline(mongoQuery(&Query{Expr: expr}), fields, limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// One document holding an array per sub-pipeline: "total" of {n}, "f<i>" of {_id, count}
+	var docs []map[string][]struct {
+		Value any   `bson:"_id"`
+		Count int64 `bson:"count"`
+		N     int64 `bson:"n"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	result := &RuleFacets{Facets: map[string][]FacetValue{}}
+	for i, field := range fields {
+		result.Facets[field] = []FacetValue{}
+		if len(docs) == 0 {
+			continue
+		}
+		for _, v := range docs[0]["f"+strconv.Itoa(i)] {
+			result.Facets[field] = append(result.Facets[field], FacetValue{Value: facetValue(v.Value), Count: v.Count})
+		}
+	}
+	if len(docs) > 0 && len(docs[0]["total"]) > 0 {
+		result.Total = docs[0]["total"][0].N
+	}
+	return result, nil
+}
+
+// facetValue converts decoded BSON numbers to float64, as JSON parameters decode in memory.
+func facetValue(v any) any {
+	switch n := v.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
+
+// UpdateRule updates an existing rule in MongoDB. Its tenant and creation time are kept.
 func (s *MongoStore) UpdateRule(ctx context.Context, id string, rule *Rule) error {
 	rule.UpdatedAt = time.Now()
 	mr, err := toMongoRule(rule)
@@ -184,19 +367,32 @@ func (s *MongoStore) UpdateRule(ctx context.Context, id string, rule *Rule) erro
 		return err
 	}
 
-	update := bson.M{
-		"$set": bson.M{
-			"templateName": mr.TemplateName,
-			"parameters":   mr.Parameters,
-			"updatedAt":    mr.UpdatedAt,
-		},
+	set := bson.M{
+		"templateName": mr.TemplateName,
+		"parameters":   mr.Parameters,
+		"updatedAt":    mr.UpdatedAt,
+	}
+	unset := bson.M{}
+	if mr.Metadata != nil {
+		set["metadata"] = mr.Metadata
+	} else {
+		unset["metadata"] = ""
+	}
+	if mr.Name != "" {
+		set["name"] = mr.Name
+	} else {
+		unset["name"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 	result, err := s.rulesColl.UpdateOne(ctx, bson.M{"_id": id}, update)
 	if err != nil {
-		return err
+		return ruleWriteError(err, rule)
 	}
 	if result.MatchedCount == 0 {
-		return errors.New("rule not found")
+		return ErrRuleNotFound
 	}
 	return nil
 }
@@ -208,7 +404,7 @@ func (s *MongoStore) DeleteRule(ctx context.Context, id string) error {
 		return err
 	}
 	if result.DeletedCount == 0 {
-		return errors.New("rule not found")
+		return ErrRuleNotFound
 	}
 	return nil
 }
@@ -313,3 +509,85 @@ func (s *MongoStore) DeleteTemplate(ctx context.Context, name string) error {
 	_, err := s.templatesColl.DeleteOne(ctx, bson.M{"name": name})
 	return err
 }
+
+// DatasourceStore Implementation
+
+// CreateDatasource saves a new datasource to MongoDB.
+func (s *MongoStore) CreateDatasource(ctx context.Context, ds *Datasource) error {
+	now := time.Now()
+	ds.CreatedAt, ds.UpdatedAt = now, now
+
+	// Insert only if no datasource with this name exists
+	result, err := s.datasourcesColl.UpdateOne(
+		ctx,
+		bson.M{"name": ds.Name},
+		bson.M{"$setOnInsert": ds},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return ErrDatasourceExists
+	}
+	return nil
+}
+
+// GetDatasource retrieves a datasource by name from MongoDB.
+func (s *MongoStore) GetDatasource(ctx context.Context, name string) (*Datasource, error) {
+	var ds Datasource
+	if err := s.datasourcesColl.FindOne(ctx, bson.M{"name": name}).Decode(&ds); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDatasourceNotFound
+		}
+		return nil, err
+	}
+	return &ds, nil
+}
+
+// ListDatasources retrieves all datasources from MongoDB, sorted by name.
+func (s *MongoStore) ListDatasources(ctx context.Context) ([]*Datasource, error) {
+	cursor, err := s.datasourcesColl.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	datasources := []*Datasource{}
+	if err := cursor.All(ctx, &datasources); err != nil {
+		return nil, err
+	}
+	return datasources, nil
+}
+
+// UpdateDatasource replaces an existing datasource in MongoDB.
+func (s *MongoStore) UpdateDatasource(ctx context.Context, name string, ds *Datasource) error {
+	existing, err := s.GetDatasource(ctx, name)
+	if err != nil {
+		return err
+	}
+	ds.Name = name
+	ds.CreatedAt = existing.CreatedAt
+	ds.UpdatedAt = time.Now()
+
+	result, err := s.datasourcesColl.ReplaceOne(ctx, bson.M{"name": name}, ds)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrDatasourceNotFound
+	}
+	return nil
+}
+
+// DeleteDatasource removes a datasource from MongoDB.
+func (s *MongoStore) DeleteDatasource(ctx context.Context, name string) error {
+	result, err := s.datasourcesColl.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrDatasourceNotFound
+	}
+	return nil
+}
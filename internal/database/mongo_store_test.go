@@ -28,6 +28,7 @@ func setupTestStore(t *testing.T) *MongoStore {
 	// Clean up database before test
 	err = store.database.Drop(ctx)
 	require.NoError(t, err)
+	require.NoError(t, store.ensureIndexes(ctx))
 
 	return store
 }
@@ -174,6 +175,46 @@ func TestMongoStore_Rules(t *testing.T) {
 	})
 }
 
+func TestMongoStore_SearchRulesQuery(t *testing.T) {
+	store := setupTestStore(t)
+	defer teardownTestStore(t, store)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRuleQueries(t, store)
+}
+
+func TestMongoStore_SearchRulesPage(t *testing.T) {
+	store := setupTestStore(t)
+	defer teardownTestStore(t, store)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRulePages(t, store)
+}
+
+func TestMongoStore_FacetRules(t *testing.T) {
+	store := setupTestStore(t)
+	defer teardownTestStore(t, store)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRuleFacets(t, store)
+}
+
+func TestMongoStore_RuleNames(t *testing.T) {
+	store := setupTestStore(t)
+	defer teardownTestStore(t, store)
+
+	testRuleNames(t, store)
+}
+
 func TestMongoStore_Templates(t *testing.T) {
 	store := setupTestStore(t)
 	defer teardownTestStore(t, store)
@@ -233,3 +274,10 @@ func TestMongoStore_Templates(t *testing.T) {
 		assert.Equal(t, "template not found", err.Error())
 	})
 }
+
+func TestMongoStore_Datasources(t *testing.T) {
+	store := setupTestStore(t)
+	defer teardownTestStore(t, store)
+
+	testDatasourceStore(t, store)
+}
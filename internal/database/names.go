@@ -0,0 +1,34 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrRuleNotFound is returned for rules that do not exist.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// ErrDuplicateRuleName is returned when a rule name is already used by another rule of the same
+// tenant and template.
+var ErrDuplicateRuleName = errors.New("duplicate rule name")
+
+// ruleName is the form of a rule name. Names appear in URL paths, so they cannot hold '/'; ':'
+// and '.' allow external IDs such as "jira:OPS-1234" or "payments.checkout-latency".
+var ruleName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,127}$`)
+
+// tenantSuffix describes the tenant of a rule in errors about its name.
+func tenantSuffix(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	return fmt.Sprintf(" in tenant '%s'", tenant)
+}
+
+// ValidateRuleName checks the form of a client-supplied rule name or external ID.
+func ValidateRuleName(name string) error {
+	if !ruleName.MatchString(name) {
+		return fmt.Errorf("invalid rule name '%s': must match %s", name, ruleName)
+	}
+	return nil
+}
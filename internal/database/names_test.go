@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRuleNames checks the rule name contract of a store: lookups by tenant, template and name,
+// and names unique per tenant and template.
+func testRuleNames(t *testing.T, store RuleStore) {
+	ctx := context.Background()
+	params := json.RawMessage(`{"target": {"namespace": "ns1"}}`)
+	require.NoError(t, store.CreateRule(ctx, &Rule{ID: "n1", Name: "checkout-latency", TemplateName: "k8s", Parameters: params}))
+	require.NoError(t, store.CreateRule(ctx, &Rule{ID: "n2", Name: "checkout-latency", TemplateName: "other", Parameters: params}))
+	require.NoError(t, store.CreateRule(ctx, &Rule{ID: "n3", TemplateName: "k8s", Parameters: params}))
+	require.NoError(t, store.CreateRule(ctx, &Rule{ID: "n4", TemplateName: "k8s", Parameters: params}))
+
+	t.Run("GetByName", func(t *testing.T) {
+		rule, err := store.GetRuleByName(ctx, "", "k8s", "checkout-latency")
+		require.NoError(t, err)
+		assert.Equal(t, "n1", rule.ID)
+		assert.Equal(t, "checkout-latency", rule.Name)
+
+		rule, err = store.GetRuleByName(ctx, "", "other", "checkout-latency")
+		require.NoError(t, err)
+		assert.Equal(t, "n2", rule.ID)
+
+		_, err = store.GetRuleByName(ctx, "", "k8s", "missing")
+		assert.ErrorIs(t, err, ErrRuleNotFound)
+	})
+
+	t.Run("DuplicateOnCreate", func(t *testing.T) {
+		err := store.CreateRule(ctx, &Rule{ID: "n5", Name: "checkout-latency", TemplateName: "k8s", Parameters: params})
+		assert.ErrorIs(t, err, ErrDuplicateRuleName)
+	})
+
+	t.Run("DuplicateOnUpdate", func(t *testing.T) {
+		err := store.UpdateRule(ctx, "n3", &Rule{Name: "checkout-latency", TemplateName: "k8s", Parameters: params})
+		assert.ErrorIs(t, err, ErrDuplicateRuleName)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		require.NoError(t, store.UpdateRule(ctx, "n1", &Rule{Name: "checkout-errors", TemplateName: "k8s", Parameters: params}))
+		rule, err := store.GetRuleByName(ctx, "", "k8s", "checkout-errors")
+		require.NoError(t, err)
+		assert.Equal(t, "n1", rule.ID)
+		_, err = store.GetRuleByName(ctx, "", "k8s", "checkout-latency")
+		assert.ErrorIs(t, err, ErrRuleNotFound)
+
+		// Unnamed rules do not collide, and updates keep the name when given
+		require.NoError(t, store.UpdateRule(ctx, "n3", &Rule{TemplateName: "k8s", Parameters: params}))
+		require.NoError(t, store.UpdateRule(ctx, "n1", &Rule{Name: "checkout-errors", TemplateName: "k8s", Parameters: params}))
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		for q, expected := range map[string][]string{
+			`name="checkout-errors"`: {"n1"},
+			`name=~"checkout-.*"`:    {"n1", "n2"},
+			`exists(name)`:           {"n1", "n2"},
+			`NOT exists(name)`:       {"n3", "n4"},
+		} {
+			query, err := ParseQuery(q)
+			require.NoError(t, err, q)
+			rules, err := store.SearchRules(ctx, RuleFilter{Query: query})
+			require.NoError(t, err, q)
+			var ids []string
+			for _, rule := range rules {
+				ids = append(ids, rule.ID)
+			}
+			assert.ElementsMatch(t, expected, ids, q)
+		}
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		fields, err := ParseRuleFields("name")
+		require.NoError(t, err)
+		page, err := store.SearchRulesPage(ctx, RuleFilter{TemplateName: "other"}, PageRequest{Limit: 10, Fields: fields})
+		require.NoError(t, err)
+		require.Len(t, page.Rules, 1)
+		assert.Equal(t, &Rule{ID: "n2", Name: "checkout-latency"}, page.Rules[0])
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		require.NoError(t, store.CreateRule(ctx, &Rule{ID: "t1", Tenant: "acme", Name: "checkout-errors", TemplateName: "k8s", Parameters: params}))
+		err := store.CreateRule(ctx, &Rule{ID: "t2", Tenant: "acme", Name: "checkout-errors", TemplateName: "k8s", Parameters: params})
+		assert.ErrorIs(t, err, ErrDuplicateRuleName)
+
+		rule, err := store.GetRuleByName(ctx, "acme", "k8s", "checkout-errors")
+		require.NoError(t, err)
+		assert.Equal(t, "t1", rule.ID)
+		assert.Equal(t, "acme", rule.Tenant)
+		rule, err = store.GetRuleByName(ctx, "", "k8s", "checkout-errors")
+		require.NoError(t, err)
+		assert.Equal(t, "n1", rule.ID, "rules without a tenant keep their own names")
+
+		// Updates keep the tenant
+		require.NoError(t, store.UpdateRule(ctx, "t1", &Rule{Name: "checkout-errors", TemplateName: "k8s", Parameters: params}))
+		rule, err = store.GetRule(ctx, "t1")
+		require.NoError(t, err)
+		assert.Equal(t, "acme", rule.Tenant)
+	})
+}
+
+func TestFileStore_RuleNames(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	testRuleNames(t, store)
+}
+
+func TestValidateRuleName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "checkout-latency", valid: true},
+		{name: "jira:OPS-1234", valid: true},
+		{name: "payments.checkout_p99", valid: true},
+		{name: "1st", valid: true},
+		{name: "", valid: false},
+		{name: "-leading-dash", valid: false},
+		{name: "team/rule", valid: false},
+		{name: "with space", valid: false},
+		{name: strings.Repeat("a", 129), valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRuleName(tt.name)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
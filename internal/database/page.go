@@ -0,0 +1,187 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPage is returned for unknown sort fields and cursors that do not belong to the request.
+var ErrInvalidPage = errors.New("invalid page request")
+
+// Rule fields listings can be sorted by.
+var sortFields = []string{queryFieldID, queryFieldTemplateName, queryFieldCreatedAt, queryFieldUpdatedAt}
+
+// RuleSort orders rules by a rule field. Ties are broken by id in the same direction, so the
+// order is total and cursors are stable.
+type RuleSort struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultRuleSort orders rules by id.
+var DefaultRuleSort = RuleSort{Field: queryFieldID}
+
+// ParseRuleSort parses a sort such as "updatedAt:desc". The direction defaults to asc and an
+// empty string returns DefaultRuleSort.
+func ParseRuleSort(s string) (RuleSort, error) {
+	if s == "" {
+		return DefaultRuleSort, nil
+	}
+	field, dir, _ := strings.Cut(s, ":")
+	rs := RuleSort{Field: field}
+	switch strings.ToLower(dir) {
+	case "", "asc":
+	case "desc":
+		rs.Desc = true
+	default:
+		return RuleSort{}, fmt.Errorf("%w: sort direction must be asc or desc, got '%s'", ErrInvalidPage, dir)
+	}
+	for _, f := range sortFields {
+		if f == field {
+			return rs, nil
+		}
+	}
+	return RuleSort{}, fmt.Errorf("%w: cannot sort by '%s' (use %s)", ErrInvalidPage, field, strings.Join(sortFields, ", "))
+}
+
+// String formats the sort like ParseRuleSort expects it.
+func (s RuleSort) String() string {
+	if s.Desc {
+		return s.Field + ":desc"
+	}
+	return s.Field + ":asc"
+}
+
+// PageRequest selects a page of rules. A Cursor (RulePage.NextCursor of the previous page)
+// continues after the last rule returned; without one, Offset rules are skipped. A Limit of 0
+// returns all rules. Fields (see ParseRuleFields) projects the returned rules; nil returns whole
+// rules.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   RuleSort
+	Fields []string
+}
+
+// RulePage is a page of rules. Total counts every rule matching the filter, and NextCursor is
+// empty on the last page.
+type RulePage struct {
+	Rules      []*Rule
+	Total      int64
+	NextCursor string
+}
+
+// pageCursor is the position after a rule in a sort order. It is encoded as base64 JSON, which
+// keeps it opaque to clients.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// sortValue returns the value of the sort field of a rule as stored in a cursor.
+func sortValue(rule *Rule, field string) string {
+	switch field {
+	case queryFieldTemplateName:
+		return rule.TemplateName
+	case queryFieldCreatedAt:
+		return rule.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case queryFieldUpdatedAt:
+		return rule.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return rule.ID
+}
+
+func encodeCursor(rule *Rule, s RuleSort) string {
+	data, _ := json.Marshal(pageCursor{Sort: s.String(), Value: sortValue(rule, s.Field), ID: rule.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor and checks that it was issued for the same sort.
+func decodeCursor(cursor string, s RuleSort) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	var c pageCursor
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	if c.Sort != s.String() {
+		return nil, fmt.Errorf("%w: cursor was issued for sort '%s', not '%s'", ErrInvalidPage, c.Sort, s.String())
+	}
+	if isTimeField(s.Field) {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+		}
+	}
+	return &c, nil
+}
+
+// compareSortKeys orders two (value, id) positions of the sort field, ascending.
+func compareSortKeys(field, aValue, aID, bValue, bID string) int {
+	c := 0
+	if isTimeField(field) {
+		at, _ := time.Parse(time.RFC3339Nano, aValue)
+		bt, _ := time.Parse(time.RFC3339Nano, bValue)
+		c = at.Compare(bt)
+	} else {
+		c = strings.Compare(aValue, bValue)
+	}
+	if c == 0 && field != queryFieldID {
+		c = strings.Compare(aID, bID)
+	}
+	return c
+}
+
+// pageRules sorts matching rules in memory and cuts the requested page, for stores without
+// native sorting.
+func pageRules(rules []*Rule, page PageRequest) (*RulePage, error) {
+	s := page.Sort
+	if s.Field == "" {
+		s = DefaultRuleSort
+	}
+	var after *pageCursor
+	if page.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(page.Cursor, s); err != nil {
+			return nil, err
+		}
+	}
+
+	direction := 1
+	if s.Desc {
+		direction = -1
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		return direction*compareSortKeys(s.Field, sortValue(a, s.Field), a.ID, sortValue(b, s.Field), b.ID) < 0
+	})
+
+	result := &RulePage{Total: int64(len(rules)), Rules: []*Rule{}}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(rules), func(i int) bool {
+			r := rules[i]
+			return direction*compareSortKeys(s.Field, sortValue(r, s.Field), r.ID, after.Value, after.ID) > 0
+		})
+	} else if page.Offset > 0 {
+		start = min(page.Offset, len(rules))
+	}
+
+	end := len(rules)
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+		result.NextCursor = encodeCursor(rules[end-1], s)
+	}
+	for _, rule := range rules[start:end] {
+		result.Rules = append(result.Rules, projectRule(rule, page.Fields))
+	}
+	return result, nil
+}
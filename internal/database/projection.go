@@ -0,0 +1,182 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// projectionParameters selects all parameters of a rule; projected parameter paths are below it.
+const projectionParameters = "parameters"
+
+// projectionMetadata selects the metadata of a rule.
+const projectionMetadata = "metadata"
+
+// ParseRuleFields parses a comma-separated projection such as "id,templateName,parameters.target".
+// Fields are the rule fields id, name, templateName, createdAt, updatedAt, parameters and metadata
+// (metadata paths select the whole metadata), or parameter paths (with or without the
+// "parameters." prefix). The id is always returned. Paths inside a
+// selected path are dropped, so the result has no overlaps. An empty projection returns nil,
+// which selects whole rules.
+func ParseRuleFields(s string) ([]string, error) {
+	seen := map[string]bool{queryFieldID: true}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if IsMetadataField(field) {
+			// Metadata is small and returned whole
+			field = projectionMetadata
+		} else if field != projectionParameters && field != projectionMetadata && !IsRuleField(field) {
+			path := ParameterPath(field)
+			if err := ValidateFieldPath(path); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidPage, err)
+			}
+			field = projectionParameters + "." + path
+		}
+		seen[field] = true
+	}
+	if len(seen) == 1 {
+		return nil, nil
+	}
+
+	fields := make([]string, 0, len(seen))
+	for field := range seen {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	// Sorted, a path follows the paths containing it
+	var result []string
+	for _, field := range fields {
+		if n := len(result); n > 0 && strings.HasPrefix(field, result[n-1]+".") {
+			continue
+		}
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+// ProjectedParameterPaths returns the parameter paths of a projection, without the prefix.
+func ProjectedParameterPaths(fields []string) []string {
+	var paths []string
+	for _, field := range fields {
+		if path, ok := strings.CutPrefix(field, projectionParameters+"."); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// projectRule returns a copy of a rule with only the projected fields; nil fields return the rule.
+// Parameter paths are applied like MongoDB projections: arrays on the way keep one object per
+// element holding the projected part, and non-object elements are dropped.
+func projectRule(rule *Rule, fields []string) *Rule {
+	if fields == nil {
+		return rule
+	}
+	projected := trimRule(*rule, fields)
+
+	if paths := ProjectedParameterPaths(fields); len(paths) > 0 {
+		// Rules without parameters project to an empty object, as in MongoDB
+		var params map[string]any
+		_ = json.Unmarshal(rule.Parameters, &params)
+		out := map[string]any{}
+		for _, path := range paths {
+			projectPath(params, out, strings.Split(path, "."))
+		}
+		projected.Parameters, _ = json.Marshal(out)
+	}
+	return &projected
+}
+
+// trimRule clears the rule fields a projection does not select. Parameters are kept when any
+// part of them is selected.
+func trimRule(rule Rule, fields []string) Rule {
+	selected := map[string]bool{}
+	for _, field := range fields {
+		top, _, _ := strings.Cut(field, ".")
+		selected[top] = true
+	}
+	if !selected[queryFieldName] {
+		rule.Name = ""
+	}
+	rule.Tenant = ""
+	if !selected[queryFieldTemplateName] {
+		rule.TemplateName = ""
+	}
+	if !selected[queryFieldCreatedAt] {
+		rule.CreatedAt = time.Time{}
+	}
+	if !selected[queryFieldUpdatedAt] {
+		rule.UpdatedAt = time.Time{}
+	}
+	if !selected[projectionParameters] {
+		rule.Parameters = nil
+	}
+	if !selected[projectionMetadata] {
+		rule.Metadata = nil
+	}
+	return rule
+}
+
+// projectPath copies the value at keys from src into dst.
+func projectPath(src, dst map[string]any, keys []string) {
+	value, ok := src[keys[0]]
+	if !ok {
+		return
+	}
+	if len(keys) == 1 {
+		dst[keys[0]] = value
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		child, _ := dst[keys[0]].(map[string]any)
+		if child == nil {
+			child = map[string]any{}
+			dst[keys[0]] = child
+		}
+		projectPath(v, child, keys[1:])
+	case []any:
+		existing, _ := dst[keys[0]].([]any)
+		items := []any{}
+		for _, elem := range v {
+			obj, ok := elem.(map[string]any)
+			if !ok {
+				continue
+			}
+			// Several paths into the same array fill the same element objects
+			var item map[string]any
+			if len(items) < len(existing) {
+				item, _ = existing[len(items)].(map[string]any)
+			}
+			if item == nil {
+				item = map[string]any{}
+			}
+			projectPath(obj, item, keys[1:])
+			items = append(items, item)
+		}
+		dst[keys[0]] = items
+	}
+}
+
+// mongoProjection returns the projection document of a field list. The sort field is included
+// so that cursors can be computed; it is cleared afterwards.
+func mongoProjection(fields []string, s RuleSort) bson.M {
+	projection := bson.M{"_id": 1}
+	for _, field := range fields {
+		if field != queryFieldID {
+			projection[field] = 1
+		}
+	}
+	if s.Field != queryFieldID {
+		projection[s.Field] = 1
+	}
+	return projection
+}
@@ -0,0 +1,616 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a parsed rule query, e.g.
+//
+//	target.namespace=~"team-.*" AND rules.threshold>0.8
+//
+// Queries are parsed once with ParseQuery; each store translates the expression tree into its
+// native form (a MongoDB filter, or an in-memory match in the FileStore).
+//
+// Fields are parameter paths (target.namespace, an optional "parameters." prefix is accepted) or
+// the rule fields id, name, templateName, createdAt and updatedAt. A path through an array
+// matches when any element matches (rules.threshold>0.8), and field[...] requires one element to
+// match the whole bracketed expression (rules[rule_type=cpu AND threshold>0.8]).
+//
+// Supported predicates: =, != (exact match), =~, !~ (regular expression matching the whole value),
+// >, >=, <, <= (numbers, or strings and RFC 3339 times when quoted), in (...), not in (...) and
+// exists(field). Predicates combine with AND, OR, NOT (or !) and parentheses; adjacent predicates
+// are joined with AND. Keywords are case-insensitive.
+type Query struct {
+	Expr QueryExpr
+	raw  string
+}
+
+// String returns the query as it was written.
+func (q *Query) String() string {
+	return q.raw
+}
+
+// QueryExpr is a node of a parsed query: *AndExpr, *OrExpr, *NotExpr, *CompareExpr, *ExistsExpr or *ElemMatchExpr.
+type QueryExpr interface {
+	queryExpr()
+}
+
+// AndExpr matches when all expressions match.
+type AndExpr struct {
+	Exprs []QueryExpr
+}
+
+// OrExpr matches when any expression matches.
+type OrExpr struct {
+	Exprs []QueryExpr
+}
+
+// NotExpr matches when its expression does not match.
+type NotExpr struct {
+	Expr QueryExpr
+}
+
+// Query operators.
+const (
+	OpEq       = "="
+	OpNe       = "!="
+	OpRegex    = "=~"
+	OpNotRegex = "!~"
+	OpGt       = ">"
+	OpGte      = ">="
+	OpLt       = "<"
+	OpLte      = "<="
+	OpIn       = "in"
+	OpNotIn    = "not in"
+)
+
+// CompareExpr compares a field with one value, or with a list of values for OpIn and OpNotIn.
+// Negated operators (!=, !~, not in) match when no value of the field matches, including when the
+// field is missing.
+type CompareExpr struct {
+	Field  string
+	Op     string
+	Values []QueryValue
+	re     *regexp.Regexp
+}
+
+// ExistsExpr matches when the field is present.
+type ExistsExpr struct {
+	Field string
+}
+
+// ElemMatchExpr matches when one element of the array field matches Expr, whose fields are
+// relative to the element.
+type ElemMatchExpr struct {
+	Field string
+	Expr  QueryExpr
+}
+
+func (*AndExpr) queryExpr()       {}
+func (*OrExpr) queryExpr()        {}
+func (*NotExpr) queryExpr()       {}
+func (*CompareExpr) queryExpr()   {}
+func (*ExistsExpr) queryExpr()    {}
+func (*ElemMatchExpr) queryExpr() {}
+
+// Query value kinds.
+const (
+	ValueString = "string"
+	ValueNumber = "number"
+	ValueBool   = "bool"
+)
+
+// QueryValue is a literal of a query. Quoted literals are strings; unquoted numbers and booleans
+// also match their text, so target.port=8080 matches both 8080 and "8080".
+type QueryValue struct {
+	Kind   string
+	Text   string
+	Number float64
+	Bool   bool
+}
+
+// Rule fields queries can refer to besides the parameters.
+const (
+	queryFieldID           = "id"
+	queryFieldName         = "name"
+	queryFieldTemplateName = "templateName"
+	queryFieldCreatedAt    = "createdAt"
+	queryFieldUpdatedAt    = "updatedAt"
+)
+
+// isTimeField reports whether a top-level query field holds a timestamp.
+func isTimeField(field string) bool {
+	return field == queryFieldCreatedAt || field == queryFieldUpdatedAt
+}
+
+// parameterPath returns the parameters path of a top-level query field, or false for rule fields
+// and metadata fields.
+func parameterPath(field string) (string, bool) {
+	switch field {
+	case queryFieldID, queryFieldName, queryFieldTemplateName, queryFieldCreatedAt, queryFieldUpdatedAt:
+		return "", false
+	}
+	if IsMetadataField(field) {
+		return "", false
+	}
+	return strings.TrimPrefix(field, "parameters."), true
+}
+
+// fieldSegment is the allowed form of a path segment. It keeps operators and system fields
+// (e.g. $where, _id through a prefix) out of the filters the stores build.
+var fieldSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateFieldPath checks that a field is a dot-separated path of names made of letters, digits,
+// '_' and '-'.
+func ValidateFieldPath(field string) error {
+	for _, segment := range strings.Split(field, ".") {
+		if !fieldSegment.MatchString(segment) {
+			return fmt.Errorf("invalid field '%s'", field)
+		}
+	}
+	return nil
+}
+
+// AddressableKey reports whether a parameter key can be a segment of the paths stores filter on:
+// it is not empty, holds no '.' (the path separator) and does not start with '$', which MongoDB
+// reserves for operators. Keys the API accepts in filters are stricter (see ValidateFieldPath).
+func AddressableKey(key string) bool {
+	return key != "" && !strings.Contains(key, ".") && !strings.HasPrefix(key, "$")
+}
+
+// IsRuleField reports whether a top-level query field is a rule field (including metadata fields)
+// rather than a parameter path.
+func IsRuleField(field string) bool {
+	_, isParameter := parameterPath(field)
+	return !isParameter
+}
+
+// ParameterPath returns a parameter field without its optional "parameters." prefix.
+func ParameterPath(field string) string {
+	return strings.TrimPrefix(field, "parameters.")
+}
+
+// filterExpr combines the criteria of a filter into one expression, so that both stores evaluate
+// parameter filters exactly like queries. Parameter values are compared like unquoted literals.
+// Parameter paths only need addressable segments: the service checks the paths of API filters
+// against the schemas, while its uniqueness lookups use the keys of the rule parameters.
+func filterExpr(filter RuleFilter) (QueryExpr, error) {
+	var exprs []QueryExpr
+	if filter.TemplateName != "" {
+		exprs = append(exprs, &CompareExpr{
+			Field:  queryFieldTemplateName,
+			Op:     OpEq,
+			Values: []QueryValue{{Kind: ValueString, Text: filter.TemplateName}},
+		})
+	}
+
+	keys := make([]string, 0, len(filter.Parameters))
+	for key := range filter.Parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := ParameterPath(key)
+		for _, segment := range strings.Split(path, ".") {
+			if !AddressableKey(segment) {
+				return nil, fmt.Errorf("%w: invalid field '%s'", ErrInvalidFilter, path)
+			}
+		}
+		exprs = append(exprs, &CompareExpr{
+			// The prefix keeps parameters named like rule fields (e.g. id) parameter paths
+			Field:  "parameters." + path,
+			Op:     OpEq,
+			Values: []QueryValue{bareValue(filter.Parameters[key])},
+		})
+	}
+
+	keys = keys[:0]
+	for key := range filter.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := metadataPrefix + strings.TrimPrefix(key, metadataPrefix)
+		if err := ValidateMetadataField(field); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		exprs = append(exprs, &CompareExpr{
+			Field:  field,
+			Op:     OpEq,
+			Values: []QueryValue{{Kind: ValueString, Text: filter.Metadata[key]}},
+		})
+	}
+
+	if filter.Query != nil && filter.Query.Expr != nil {
+		exprs = append(exprs, filter.Query.Expr)
+	}
+
+	switch len(exprs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exprs[0], nil
+	}
+	return &AndExpr{Exprs: exprs}, nil
+}
+
+// parseTime parses a query literal compared with a timestamp field.
+func parseTime(v QueryValue) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, v.Text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s': expected RFC 3339 or YYYY-MM-DD", v.Text)
+}
+
+// ParseQuery parses a rule query. An empty query returns nil.
+func ParseQuery(input string) (*Query, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseOr(true)
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return &Query{Expr: expr, raw: input}, nil
+}
+
+// --- Lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokBang
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:+", r)
+}
+
+func lexQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+					// Keep escapes other than quotes and backslashes, so regular expressions like "\d" survive
+					if runes[j] != r && runes[j] != '\\' {
+						sb.WriteRune('\\')
+					}
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, queryToken{kind: tokString, text: sb.String(), pos: i})
+			i = j + 1
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == '[':
+			tokens = append(tokens, queryToken{kind: tokLBracket, text: "[", pos: i})
+			i++
+		case r == ']':
+			tokens = append(tokens, queryToken{kind: tokRBracket, text: "]", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, queryToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && strings.ContainsRune("=~", runes[i+1]) && (r != '<' && r != '>' || runes[i+1] == '=') {
+				op += string(runes[i+1])
+			}
+			switch op {
+			case "!":
+				tokens = append(tokens, queryToken{kind: tokBang, text: op, pos: i})
+			case "=", "!=", "=~", "!~", ">", ">=", "<", "<=":
+				tokens = append(tokens, queryToken{kind: tokOp, text: op, pos: i})
+			case "==":
+				tokens = append(tokens, queryToken{kind: tokOp, text: OpEq, pos: i})
+			default:
+				return nil, fmt.Errorf("unknown operator '%s' at position %d", op, i)
+			}
+			i += len([]rune(op))
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokWord, text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", r, i)
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// --- Parser ---
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *queryParser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (p *queryParser) expect(kind tokenKind, what string) error {
+	if p.peek().kind != kind {
+		return p.errorf("expected %s, got %s", what, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+// parseOr parses a disjunction. topLevel is false inside element matches, where fields are relative.
+func (p *queryParser) parseOr(topLevel bool) (QueryExpr, error) {
+	var exprs []QueryExpr
+	for {
+		expr, err := p.parseAnd(topLevel)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.isKeyword("or") {
+			break
+		}
+		p.next()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &OrExpr{Exprs: exprs}, nil
+}
+
+func (p *queryParser) parseAnd(topLevel bool) (QueryExpr, error) {
+	var exprs []QueryExpr
+	for {
+		expr, err := p.parseUnary(topLevel)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if p.isKeyword("and") {
+			p.next()
+			continue
+		}
+		// Adjacent predicates are joined with AND
+		if t := p.peek(); (t.kind == tokWord && !p.isKeyword("or")) || t.kind == tokLParen || t.kind == tokBang {
+			continue
+		}
+		break
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &AndExpr{Exprs: exprs}, nil
+}
+
+func (p *queryParser) parseUnary(topLevel bool) (QueryExpr, error) {
+	if p.peek().kind == tokBang || (p.isKeyword("not") && p.tokens[p.pos+1].kind != tokOp) {
+		p.next()
+		expr, err := p.parseUnary(topLevel)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr}, nil
+	}
+	return p.parsePrimary(topLevel)
+}
+
+func (p *queryParser) parsePrimary(topLevel bool) (QueryExpr, error) {
+	t := p.peek()
+	if t.kind == tokLParen {
+		p.next()
+		expr, err := p.parseOr(topLevel)
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(tokRParen, "')'")
+	}
+	if t.kind != tokWord {
+		return nil, p.errorf("expected a field, got %s", t)
+	}
+
+	if strings.EqualFold(t.text, "exists") && p.tokens[p.pos+1].kind == tokLParen {
+		p.next()
+		p.next()
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		return &ExistsExpr{Field: field}, p.expect(tokRParen, "')'")
+	}
+
+	field, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		expr, err := p.parseOr(false)
+		if err != nil {
+			return nil, err
+		}
+		return &ElemMatchExpr{Field: field, Expr: expr}, p.expect(tokRBracket, "']'")
+	}
+
+	cmp := &CompareExpr{Field: field}
+	switch {
+	case p.peek().kind == tokOp:
+		cmp.Op = p.next().text
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []QueryValue{value}
+	case p.isKeyword("in"):
+		p.next()
+		cmp.Op = OpIn
+		if cmp.Values, err = p.parseList(); err != nil {
+			return nil, err
+		}
+	case p.isKeyword("not") && strings.EqualFold(p.tokens[p.pos+1].text, "in"):
+		p.next()
+		p.next()
+		cmp.Op = OpNotIn
+		if cmp.Values, err = p.parseList(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf("expected an operator after '%s', got %s", field, p.peek())
+	}
+	return cmp, validateCompare(cmp, topLevel)
+}
+
+func (p *queryParser) parseField() (string, error) {
+	t := p.peek()
+	if t.kind != tokWord {
+		return "", p.errorf("expected a field, got %s", t)
+	}
+	if err := ValidateFieldPath(t.text); err != nil {
+		return "", p.errorf("%v", err)
+	}
+	p.next()
+	return t.text, nil
+}
+
+func (p *queryParser) parseValue() (QueryValue, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokString:
+		p.next()
+		return QueryValue{Kind: ValueString, Text: t.text}, nil
+	case tokWord:
+		p.next()
+		return bareValue(t.text), nil
+	}
+	return QueryValue{}, p.errorf("expected a value, got %s", t)
+}
+
+// bareValue types an unquoted literal: numbers and booleans keep their text as well.
+func bareValue(text string) QueryValue {
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return QueryValue{Kind: ValueNumber, Text: text, Number: n}
+	}
+	if strings.EqualFold(text, "true") || strings.EqualFold(text, "false") {
+		return QueryValue{Kind: ValueBool, Text: text, Bool: strings.EqualFold(text, "true")}
+	}
+	return QueryValue{Kind: ValueString, Text: text}
+}
+
+func (p *queryParser) parseList() ([]QueryValue, error) {
+	if err := p.expect(tokLParen, "'('"); err != nil {
+		return nil, err
+	}
+	var values []QueryValue
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	return values, p.expect(tokRParen, "')'")
+}
+
+// validateCompare checks literals against the operator and compiles regular expressions.
+func validateCompare(cmp *CompareExpr, topLevel bool) error {
+	switch cmp.Op {
+	case OpRegex, OpNotRegex:
+		re, err := regexp.Compile("^(?:" + cmp.Values[0].Text + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regular expression for '%s': %w", cmp.Field, err)
+		}
+		cmp.re = re
+	case OpGt, OpGte, OpLt, OpLte:
+		if cmp.Values[0].Kind == ValueBool {
+			return fmt.Errorf("'%s %s' needs a number, string or time", cmp.Field, cmp.Op)
+		}
+	}
+	if topLevel && isTimeField(cmp.Field) {
+		for _, v := range cmp.Values {
+			if _, err := parseTime(v); err != nil {
+				return fmt.Errorf("'%s': %w", cmp.Field, err)
+			}
+		}
+	}
+	return nil
+}
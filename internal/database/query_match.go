@@ -0,0 +1,233 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matches evaluates the query against a rule in memory. It follows the MongoDB semantics the
+// MongoStore translation relies on, so both stores return the same rules.
+func (q *Query) Matches(rule *Rule) bool {
+	if q == nil || q.Expr == nil {
+		return true
+	}
+	var params map[string]any
+	if len(rule.Parameters) > 0 {
+		if err := json.Unmarshal(rule.Parameters, &params); err != nil {
+			return false
+		}
+	}
+	return matchExpr(q.Expr, func(field string) []any {
+		if path, ok := parameterPath(field); ok {
+			return lookupPath(params, strings.Split(path, "."))
+		}
+		if path, ok := metadataPath(field); ok {
+			return lookupPath(metadataValues(rule.Metadata), strings.Split(path, "."))
+		}
+		switch field {
+		case queryFieldID:
+			return []any{rule.ID}
+		case queryFieldName:
+			// Rules without a name have no name field, as in MongoDB
+			if rule.Name == "" {
+				return nil
+			}
+			return []any{rule.Name}
+		case queryFieldTemplateName:
+			return []any{rule.TemplateName}
+		case queryFieldCreatedAt:
+			return []any{rule.CreatedAt}
+		default:
+			return []any{rule.UpdatedAt}
+		}
+	})
+}
+
+// fieldLookup returns the values a field resolves to; arrays on the way fan out.
+type fieldLookup func(field string) []any
+
+func matchExpr(expr QueryExpr, lookup fieldLookup) bool {
+	switch e := expr.(type) {
+	case *AndExpr:
+		for _, sub := range e.Exprs {
+			if !matchExpr(sub, lookup) {
+				return false
+			}
+		}
+		return true
+	case *OrExpr:
+		for _, sub := range e.Exprs {
+			if matchExpr(sub, lookup) {
+				return true
+			}
+		}
+		return false
+	case *NotExpr:
+		return !matchExpr(e.Expr, lookup)
+	case *ExistsExpr:
+		return len(lookup(e.Field)) > 0
+	case *ElemMatchExpr:
+		for _, elem := range expandArrays(lookup(e.Field)) {
+			obj, ok := elem.(map[string]any)
+			if !ok {
+				continue
+			}
+			if matchExpr(e.Expr, func(field string) []any {
+				return lookupPath(obj, strings.Split(field, "."))
+			}) {
+				return true
+			}
+		}
+		return false
+	case *CompareExpr:
+		values := expandArrays(lookup(e.Field))
+		switch e.Op {
+		case OpNe:
+			return !anyValue(values, func(v any) bool { return valueEquals(v, e.Values[0]) })
+		case OpNotRegex:
+			return !anyValue(values, func(v any) bool { return regexMatches(e, v) })
+		case OpNotIn:
+			return !anyValue(values, func(v any) bool { return valueIn(v, e.Values) })
+		case OpEq:
+			return anyValue(values, func(v any) bool { return valueEquals(v, e.Values[0]) })
+		case OpRegex:
+			return anyValue(values, func(v any) bool { return regexMatches(e, v) })
+		case OpIn:
+			return anyValue(values, func(v any) bool { return valueIn(v, e.Values) })
+		default:
+			return anyValue(values, func(v any) bool {
+				c, ok := compareValue(v, e.Values[0])
+				if !ok {
+					return false
+				}
+				switch e.Op {
+				case OpGt:
+					return c > 0
+				case OpGte:
+					return c >= 0
+				case OpLt:
+					return c < 0
+				default:
+					return c <= 0
+				}
+			})
+		}
+	}
+	return false
+}
+
+// lookupPath resolves a dot-separated path. Numeric segments index arrays; other segments are
+// applied to every element of an array, like MongoDB does.
+func lookupPath(node any, keys []string) []any {
+	if len(keys) == 0 {
+		return []any{node}
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[keys[0]]
+		if !ok {
+			return nil
+		}
+		return lookupPath(child, keys[1:])
+	case []any:
+		if idx, err := strconv.Atoi(keys[0]); err == nil {
+			if idx < 0 || idx >= len(n) {
+				return nil
+			}
+			return lookupPath(n[idx], keys[1:])
+		}
+		var values []any
+		for _, elem := range n {
+			if _, ok := elem.(map[string]any); ok {
+				values = append(values, lookupPath(elem, keys)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// expandArrays replaces array values with their elements, so predicates match any element.
+func expandArrays(values []any) []any {
+	var expanded []any
+	for _, v := range values {
+		if arr, ok := v.([]any); ok {
+			expanded = append(expanded, arr...)
+			continue
+		}
+		expanded = append(expanded, v)
+	}
+	return expanded
+}
+
+func anyValue(values []any, pred func(any) bool) bool {
+	for _, v := range values {
+		if pred(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueEquals compares a stored value with a literal. Unquoted numbers and booleans match both
+// the typed value and their text.
+func valueEquals(v any, lit QueryValue) bool {
+	switch val := v.(type) {
+	case string:
+		return val == lit.Text
+	case float64:
+		return lit.Kind == ValueNumber && val == lit.Number
+	case bool:
+		return lit.Kind == ValueBool && val == lit.Bool
+	case time.Time:
+		t, err := parseTime(lit)
+		return err == nil && val.Equal(t)
+	}
+	return false
+}
+
+func valueIn(v any, lits []QueryValue) bool {
+	for _, lit := range lits {
+		if valueEquals(v, lit) {
+			return true
+		}
+	}
+	return false
+}
+
+func regexMatches(e *CompareExpr, v any) bool {
+	s, ok := v.(string)
+	return ok && e.re.MatchString(s)
+}
+
+// compareValue orders a stored value against a literal of the same type: numbers with numbers,
+// strings with strings and times with times. Mixed types never match.
+func compareValue(v any, lit QueryValue) (int, bool) {
+	switch val := v.(type) {
+	case float64:
+		if lit.Kind != ValueNumber {
+			return 0, false
+		}
+		switch {
+		case val < lit.Number:
+			return -1, true
+		case val > lit.Number:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if lit.Kind != ValueString {
+			return 0, false
+		}
+		return strings.Compare(val, lit.Text), true
+	case time.Time:
+		t, err := parseTime(lit)
+		if err != nil {
+			return 0, false
+		}
+		return val.Compare(t), true
+	}
+	return 0, false
+}
@@ -0,0 +1,442 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Run("Example", func(t *testing.T) {
+		q, err := ParseQuery(`target.namespace=~"team-.*" AND rules.threshold>0.8`)
+		require.NoError(t, err)
+
+		and, ok := q.Expr.(*AndExpr)
+		require.True(t, ok)
+		require.Len(t, and.Exprs, 2)
+
+		regex := and.Exprs[0].(*CompareExpr)
+		assert.Equal(t, "target.namespace", regex.Field)
+		assert.Equal(t, OpRegex, regex.Op)
+		assert.Equal(t, []QueryValue{{Kind: ValueString, Text: "team-.*"}}, regex.Values)
+
+		gt := and.Exprs[1].(*CompareExpr)
+		assert.Equal(t, "rules.threshold", gt.Field)
+		assert.Equal(t, OpGt, gt.Op)
+		assert.Equal(t, []QueryValue{{Kind: ValueNumber, Text: "0.8", Number: 0.8}}, gt.Values)
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		q, err := ParseQuery(`a=1 or b=2 and not c=3`)
+		require.NoError(t, err)
+
+		or := q.Expr.(*OrExpr)
+		require.Len(t, or.Exprs, 2)
+		and := or.Exprs[1].(*AndExpr)
+		require.Len(t, and.Exprs, 2)
+		assert.IsType(t, &NotExpr{}, and.Exprs[1])
+	})
+
+	t.Run("ImplicitAnd", func(t *testing.T) {
+		q, err := ParseQuery(`templateName=k8s !exists(target.env) (a=1 OR b=2)`)
+		require.NoError(t, err)
+
+		and := q.Expr.(*AndExpr)
+		require.Len(t, and.Exprs, 3)
+		assert.Equal(t, &NotExpr{Expr: &ExistsExpr{Field: "target.env"}}, and.Exprs[1])
+		assert.IsType(t, &OrExpr{}, and.Exprs[2])
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		q, err := ParseQuery(`severity NOT IN ("info", warning, 3)`)
+		require.NoError(t, err)
+
+		cmp := q.Expr.(*CompareExpr)
+		assert.Equal(t, OpNotIn, cmp.Op)
+		assert.Equal(t, []QueryValue{
+			{Kind: ValueString, Text: "info"},
+			{Kind: ValueString, Text: "warning"},
+			{Kind: ValueNumber, Text: "3", Number: 3},
+		}, cmp.Values)
+	})
+
+	t.Run("ElemMatch", func(t *testing.T) {
+		q, err := ParseQuery(`rules[rule_type=cpu AND threshold>=0.9]`)
+		require.NoError(t, err)
+
+		elem := q.Expr.(*ElemMatchExpr)
+		assert.Equal(t, "rules", elem.Field)
+		assert.Len(t, elem.Expr.(*AndExpr).Exprs, 2)
+	})
+
+	t.Run("Escapes", func(t *testing.T) {
+		q, err := ParseQuery(`a="say \"hi\"" b=~'\d+'`)
+		require.NoError(t, err)
+
+		and := q.Expr.(*AndExpr)
+		assert.Equal(t, `say "hi"`, and.Exprs[0].(*CompareExpr).Values[0].Text)
+		assert.Equal(t, `\d+`, and.Exprs[1].(*CompareExpr).Values[0].Text)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		q, err := ParseQuery("  ")
+		assert.NoError(t, err)
+		assert.Nil(t, q)
+	})
+
+	errorCases := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"MissingValue", `a=`, "expected a value"},
+		{"MissingOperator", `a b`, "expected an operator after 'a'"},
+		{"DanglingAnd", `a=1 AND`, "expected a field"},
+		{"UnbalancedParen", `(a=1`, "expected ')'"},
+		{"UnterminatedString", `a="x`, "unterminated string"},
+		{"UnknownOperator", `a=>1`, "expected a value, got '>'"},
+		{"InvalidRegex", `a=~"team-("`, "invalid regular expression for 'a'"},
+		{"BoolOrdering", `a>true`, "needs a number, string or time"},
+		{"InvalidTime", `createdAt>yesterday`, "invalid time 'yesterday'"},
+		{"InvalidField", `a..b=1`, "invalid field 'a..b'"},
+		{"OperatorField", `$where=1`, "unexpected character '$'"},
+		{"UnexpectedCharacter", `a=1 & b=2`, "unexpected character '&'"},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseQuery(tc.query)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+// queryTestRules are the rules the query cases run against, in every store.
+func queryTestRules() []*Rule {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []*Rule{
+		{
+			ID:           "q1",
+			TemplateName: "k8s",
+			Parameters: json.RawMessage(`{"target": {"namespace": "team-a", "port": 8080},
+				"rules": [{"rule_type": "cpu", "threshold": 0.9, "enabled": true}, {"rule_type": "memory", "threshold": 0.5}]}`),
+			CreatedAt: created,
+			Metadata: &RuleMetadata{
+				Owner:  "alice",
+				Team:   "payments",
+				Labels: map[string]string{"tier": "1"},
+				Links:  []RuleLink{{Name: "runbook", URL: "https://runbooks.example.com/cpu"}},
+			},
+		},
+		{
+			ID:           "q2",
+			TemplateName: "k8s",
+			Parameters: json.RawMessage(`{"target": {"namespace": "team-b", "port": "8080", "env": "prod"},
+				"rules": [{"rule_type": "cpu", "threshold": 0.7}, {"rule_type": "memory", "threshold": 0.95}]}`),
+			CreatedAt: created.AddDate(0, 1, 0),
+			Metadata:  &RuleMetadata{Team: "platform", Labels: map[string]string{"tier": "2"}},
+		},
+		{
+			ID:           "q3",
+			TemplateName: "openstack",
+			Parameters:   json.RawMessage(`{"target": {"namespace": "infra", "tags": ["gold", "eu"]}, "rules": []}`),
+			CreatedAt:    created.AddDate(0, 2, 0),
+		},
+	}
+}
+
+var queryTestCases = []struct {
+	name  string
+	query string
+	ids   []string
+}{
+	{"Example", `target.namespace=~"team-.*" AND rules.threshold>0.8`, []string{"q1", "q2"}},
+	{"Equal", `target.namespace=infra`, []string{"q3"}},
+	{"ParametersPrefix", `parameters.target.namespace="team-a"`, []string{"q1"}},
+	{"NotEqualMatchesMissing", `target.env!=prod`, []string{"q1", "q3"}},
+	{"RegexIsAnchored", `target.namespace=~"team"`, nil},
+	{"NotRegex", `target.namespace!~"team-.*"`, []string{"q3"}},
+	{"NumberMatchesText", `target.port=8080`, []string{"q1", "q2"}},
+	{"QuotedNumberMatchesText", `target.port="8080"`, []string{"q2"}},
+	{"Bool", `rules.enabled=true`, []string{"q1"}},
+	{"LessThan", `rules.threshold<0.6`, []string{"q1"}},
+	{"In", `target.namespace in ("team-b", infra)`, []string{"q2", "q3"}},
+	{"NotIn", `target.namespace not in ("team-b", infra)`, []string{"q1"}},
+	{"ScalarArray", `target.tags=eu`, []string{"q3"}},
+	{"ArrayIndex", `rules.1.rule_type=memory AND rules.1.threshold>0.9`, []string{"q2"}},
+	{"Exists", `exists(target.env)`, []string{"q2"}},
+	{"NotExists", `NOT exists(target.env) AND templateName=k8s`, []string{"q1"}},
+	{"ElemMatch", `rules[rule_type=cpu AND threshold>0.8]`, []string{"q1"}},
+	{"AnyElement", `rules.rule_type=cpu AND rules.threshold>0.8`, []string{"q1", "q2"}},
+	{"Or", `id=q1 OR templateName=openstack`, []string{"q1", "q3"}},
+	{"Grouping", `templateName=k8s AND (target.env=prod OR rules.enabled=true)`, []string{"q1", "q2"}},
+	{"CreatedAt", `createdAt>=2025-04-01`, []string{"q2", "q3"}},
+	{"CreatedAtRange", `createdAt>"2025-03-01T12:00:00Z" createdAt<2025-05-01`, []string{"q2"}},
+	{"MetadataTeam", `metadata.team=payments`, []string{"q1"}},
+	{"MetadataLabel", `metadata.labels.tier in ("1", "2") AND NOT exists(metadata.owner)`, []string{"q2"}},
+	{"MetadataLink", `metadata.links[name=runbook AND url=~"https://.*"]`, []string{"q1"}},
+	{"NoMetadata", `NOT exists(metadata.team)`, []string{"q3"}},
+}
+
+// testRuleQueries runs the query cases against a store holding queryTestRules.
+func testRuleQueries(t *testing.T, store RuleStore) {
+	ctx := context.Background()
+	for _, tc := range queryTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := ParseQuery(tc.query)
+			require.NoError(t, err)
+
+			rules, err := store.SearchRules(ctx, RuleFilter{Query: q})
+			require.NoError(t, err)
+
+			var ids []string
+			for _, r := range rules {
+				ids = append(ids, r.ID)
+			}
+			sort.Strings(ids)
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+
+	t.Run("MetadataFilter", func(t *testing.T) {
+		rules, err := store.SearchRules(ctx, RuleFilter{TemplateName: "k8s", Metadata: map[string]string{"metadata.labels.tier": "2"}})
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "q2", rules[0].ID)
+		assert.Equal(t, "platform", rules[0].Metadata.Team)
+
+		_, err = store.SearchRules(ctx, RuleFilter{Metadata: map[string]string{"ticket": "X-1"}})
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+	})
+}
+
+func TestFileStore_SearchRulesQuery(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "filestore_query_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	store, err := NewFileStore(tmpDir)
+	require.NoError(t, err)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRuleQueries(t, store)
+
+	t.Run("CombinedWithTemplateName", func(t *testing.T) {
+		q, err := ParseQuery(`target.namespace=~"team-.*"`)
+		require.NoError(t, err)
+
+		rules, err := store.SearchRules(context.Background(), RuleFilter{TemplateName: "openstack", Query: q})
+		require.NoError(t, err)
+		assert.Empty(t, rules)
+	})
+}
+
+func TestMongoQuery(t *testing.T) {
+	q, err := ParseQuery(`target.namespace=~"team-.*" AND NOT rules[threshold>=0.9 OR enabled=true] AND updatedAt<2025-01-01`)
+	require.NoError(t, err)
+
+	expected := bson.M{"$and": bson.A{
+		bson.M{"parameters.target.namespace": bson.M{"$regex": primitive.Regex{Pattern: "^(?:team-.*)$"}}},
+		bson.M{"$nor": bson.A{
+			bson.M{"parameters.rules": bson.M{"$elemMatch": bson.M{"$or": bson.A{
+				bson.M{"threshold": bson.M{"$gte": 0.9}},
+				bson.M{"enabled": bson.M{"$in": bson.A{true, "true"}}},
+			}}}},
+		}},
+		bson.M{"updatedAt": bson.M{"$lt": time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}}
+	assert.Equal(t, expected, mongoQuery(q))
+
+	q, err = ParseQuery(`id in (a, b) target.port!=8080`)
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"_id": bson.M{"$in": bson.A{"a", "b"}}},
+		bson.M{"parameters.target.port": bson.M{"$nin": bson.A{8080.0, "8080"}}},
+	}}, mongoQuery(q))
+}
+
+func TestMongoQuery_Filter(t *testing.T) {
+	expr, err := filterExpr(RuleFilter{
+		TemplateName: "k8s",
+		Parameters:   map[string]string{"target.port": "8080", "parameters._id": "x"},
+	})
+	require.NoError(t, err)
+
+	// Parameter keys never reach rule fields, even when they are named like one
+	assert.Equal(t, bson.M{"$and": bson.A{
+		bson.M{"templateName": bson.M{"$eq": "k8s"}},
+		bson.M{"parameters._id": bson.M{"$eq": "x"}},
+		bson.M{"parameters.target.port": bson.M{"$in": bson.A{8080.0, "8080"}}},
+	}}, mongoQuery(&Query{Expr: expr}))
+
+	for _, key := range []string{"$where", "target.$gt", "target..port", ""} {
+		_, err := filterExpr(RuleFilter{Parameters: map[string]string{key: "1"}})
+		assert.ErrorIs(t, err, ErrInvalidFilter, key)
+	}
+
+	// Other keys only need to be addressable; the service checks API filters against the schemas
+	_, err = filterExpr(RuleFilter{Parameters: map[string]string{"target.app/name": "checkout"}})
+	assert.NoError(t, err)
+}
+
+// testRulePages pages through queryTestRules (created in a known order) in a store.
+func testRulePages(t *testing.T, store RuleStore) {
+	ctx := context.Background()
+
+	ids := func(rules []*Rule) []string {
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	t.Run("CursorPages", func(t *testing.T) {
+		order := RuleSort{Field: "createdAt", Desc: true}
+		page, err := store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 2, Sort: order})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q3", "q2"}, ids(page.Rules))
+		assert.Equal(t, int64(3), page.Total)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 2, Sort: order, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q1"}, ids(page.Rules))
+		assert.Equal(t, int64(3), page.Total)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("TiesBrokenByID", func(t *testing.T) {
+		order := RuleSort{Field: "templateName"}
+		page, err := store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 1, Sort: order})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q1"}, ids(page.Rules))
+
+		page, err = store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 1, Sort: order, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q2"}, ids(page.Rules))
+	})
+
+	t.Run("FilterAndOffset", func(t *testing.T) {
+		page, err := store.SearchRulesPage(ctx, RuleFilter{TemplateName: "k8s"}, PageRequest{Limit: 5, Offset: 1, Sort: DefaultRuleSort})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q2"}, ids(page.Rules))
+		assert.Equal(t, int64(2), page.Total)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("NoLimit", func(t *testing.T) {
+		page, err := store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Sort: RuleSort{Field: "id", Desc: true}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"q3", "q2", "q1"}, ids(page.Rules))
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		fields, err := ParseRuleFields("templateName,target.namespace,parameters.rules.threshold")
+		require.NoError(t, err)
+		order := RuleSort{Field: "createdAt", Desc: true}
+		page, err := store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 2, Sort: order, Fields: fields})
+		require.NoError(t, err)
+		data, err := json.Marshal(page.Rules)
+		require.NoError(t, err)
+		// The sort field is not returned, but the cursor still continues the order
+		assert.JSONEq(t, `[
+			{"id": "q3", "templateName": "openstack", "parameters": {"target": {"namespace": "infra"}, "rules": []}},
+			{"id": "q2", "templateName": "k8s", "parameters": {"target": {"namespace": "team-b"}, "rules": [{"threshold": 0.7}, {"threshold": 0.95}]}}
+		]`, string(data))
+
+		page, err = store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 2, Sort: order, Fields: []string{"id"}, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		data, err = json.Marshal(page.Rules)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"id": "q1"}]`, string(data))
+	})
+
+	t.Run("CursorOfAnotherSort", func(t *testing.T) {
+		page, err := store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 1, Sort: DefaultRuleSort})
+		require.NoError(t, err)
+
+		_, err = store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 1, Sort: RuleSort{Field: "updatedAt"}, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, ErrInvalidPage)
+		_, err = store.SearchRulesPage(ctx, RuleFilter{}, PageRequest{Limit: 1, Sort: DefaultRuleSort, Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, ErrInvalidPage)
+	})
+}
+
+func TestFileStore_SearchRulesPage(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, rule := range queryTestRules() {
+		require.NoError(t, store.CreateRule(context.Background(), rule))
+	}
+
+	testRulePages(t, store)
+
+	t.Run("ListRules", func(t *testing.T) {
+		rules, err := store.ListRules(context.Background(), 0, 21)
+		require.NoError(t, err)
+		assert.Len(t, rules, 3)
+
+		rules, err = store.ListRules(context.Background(), 2, 10)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, "q3", rules[0].ID)
+	})
+}
+
+func TestParseRuleSort(t *testing.T) {
+	order, err := ParseRuleSort("updatedAt:desc")
+	require.NoError(t, err)
+	assert.Equal(t, RuleSort{Field: "updatedAt", Desc: true}, order)
+	assert.Equal(t, "updatedAt:desc", order.String())
+
+	order, err = ParseRuleSort("templateName")
+	require.NoError(t, err)
+	assert.Equal(t, RuleSort{Field: "templateName"}, order)
+
+	order, err = ParseRuleSort("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRuleSort, order)
+
+	_, err = ParseRuleSort("parameters.target:asc")
+	assert.ErrorIs(t, err, ErrInvalidPage)
+	_, err = ParseRuleSort("id:up")
+	assert.ErrorIs(t, err, ErrInvalidPage)
+}
+
+func TestParseRuleFields(t *testing.T) {
+	fields, err := ParseRuleFields("templateName, parameters.target.namespace,target,rules.threshold")
+	require.NoError(t, err)
+	// Paths inside a selected path are dropped and the id is always selected
+	assert.Equal(t, []string{"id", "parameters.rules.threshold", "parameters.target", "templateName"}, fields)
+
+	fields, err = ParseRuleFields("parameters,parameters.target,updatedAt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "parameters", "updatedAt"}, fields)
+	assert.Empty(t, ProjectedParameterPaths(fields))
+
+	fields, err = ParseRuleFields("metadata.team,metadata")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "metadata"}, fields)
+
+	fields, err = ParseRuleFields(" , ")
+	require.NoError(t, err)
+	assert.Nil(t, fields)
+
+	_, err = ParseRuleFields("target..namespace")
+	assert.ErrorIs(t, err, ErrInvalidPage)
+	_, err = ParseRuleFields("target.$where")
+	assert.ErrorIs(t, err, ErrInvalidPage)
+}
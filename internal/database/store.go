@@ -3,34 +3,56 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
 // Rule represents a user-defined alert rule instance.
 type Rule struct {
 	ID           string          `json:"id" bson:"_id,omitempty"`
-	TemplateName string          `json:"templateName" bson:"templateName"`
-	Parameters   json.RawMessage `json:"parameters" bson:"parameters"`
-	CreatedAt    time.Time       `json:"createdAt" bson:"createdAt"`
-	UpdatedAt    time.Time       `json:"updatedAt" bson:"updatedAt"`
+	Name         string          `json:"name,omitempty" bson:"name,omitempty"`                     // Optional client-supplied name or external ID, unique per template and tenant
+	Tenant       string          `json:"tenant,omitempty" bson:"tenant,omitempty" readOnly:"true"` // Tenant of the caller who created the rule; scopes its name
+	TemplateName string          `json:"templateName,omitempty" bson:"templateName"`
+	Parameters   json.RawMessage `json:"parameters,omitempty" bson:"parameters"`
+	CreatedAt    time.Time       `json:"createdAt,omitzero" bson:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt,omitzero" bson:"updatedAt"`
+	Metadata     *RuleMetadata   `json:"metadata,omitempty" bson:"metadata,omitempty"`
 }
 
 // RuleStore defines the interface for database operations on rules.
 type RuleStore interface {
 	CreateRule(ctx context.Context, rule *Rule) error
 	GetRule(ctx context.Context, id string) (*Rule, error)
+	// GetRuleByName returns the rule of a tenant and template with a name, or ErrRuleNotFound.
+	// The tenant is "" for rules created without one.
+	GetRuleByName(ctx context.Context, tenant, templateName, name string) (*Rule, error)
 	ListRules(ctx context.Context, offset, limit int) ([]*Rule, error)
 	UpdateRule(ctx context.Context, id string, rule *Rule) error
 	DeleteRule(ctx context.Context, id string) error
 	SearchRules(ctx context.Context, filter RuleFilter) ([]*Rule, error)
+	// SearchRulesPage returns one sorted page of the rules matching the filter, with their total.
+	SearchRulesPage(ctx context.Context, filter RuleFilter, page PageRequest) (*RulePage, error)
+	// FacetRules counts the values of fields (see ParseFacetFields) over the rules matching the
+	// filter, keeping the limit most frequent values per field (all for 0).
+	FacetRules(ctx context.Context, filter RuleFilter, fields []string, limit int) (*RuleFacets, error)
 }
 
+// ErrInvalidFilter is returned for search criteria that refer to invalid or unknown fields.
+var ErrInvalidFilter = errors.New("invalid filter")
+
 // RuleFilter defines the criteria for searching rules.
 type RuleFilter struct {
 	TemplateName string
 	// Parameters allows filtering by specific parameter fields.
-	// Keys should be dot-separated paths (e.g. "target.namespace").
+	// Keys are dot-separated parameter paths (e.g. "target.namespace"; a "parameters." prefix is
+	// accepted) and values match exactly. Numbers and booleans also match their text, and a path
+	// through an array matches when any element matches.
 	Parameters map[string]string
+	// Metadata filters by metadata fields (e.g. "team" or "labels.tier"; a "metadata." prefix is
+	// accepted), matching values exactly.
+	Metadata map[string]string
+	// Query is an optional parsed query (see ParseQuery), applied in addition to the other criteria.
+	Query *Query
 }
 
 // Schema represents a rule schema definition.
@@ -49,3 +71,59 @@ type TemplateProvider interface {
 	DeleteSchema(ctx context.Context, name string) error
 	DeleteTemplate(ctx context.Context, name string) error
 }
+
+// Datasource is a managed datasource definition. Template schemas reference it by name, so
+// changing an entry repoints every template that uses it.
+type Datasource struct {
+	Name               string `json:"name" bson:"name"`
+	DatasourceSettings `bson:",inline"`
+	// Environments overrides settings per environment (e.g. "staging"). Empty fields inherit the base settings.
+	Environments map[string]DatasourceSettings `json:"environments,omitempty" bson:"environments,omitempty"`
+	// ReadOnly marks datasources defined in the configuration file, which cannot be changed through the API.
+	ReadOnly  bool      `json:"read_only,omitempty" bson:"-"`
+	CreatedAt time.Time `json:"createdAt,omitempty" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty" bson:"updatedAt"`
+}
+
+// DatasourceSettings holds the connection settings of a datasource.
+type DatasourceSettings struct {
+	Type    string            `json:"type,omitempty" bson:"type,omitempty"` // prometheus, victoriametrics or thanos
+	URL     string            `json:"url,omitempty" bson:"url,omitempty"`
+	Tenant  string            `json:"tenant,omitempty" bson:"tenant,omitempty"` // VictoriaMetrics cluster tenant (accountID[:projectID])
+	Headers map[string]string `json:"headers,omitempty" bson:"headers,omitempty"`
+	Auth    *DatasourceAuth   `json:"auth,omitempty" bson:"auth,omitempty"`
+	TLS     *DatasourceTLS    `json:"tls,omitempty" bson:"tls,omitempty"`
+	Timeout string            `json:"timeout,omitempty" bson:"timeout,omitempty"` // Go duration, e.g. "5s"
+}
+
+// DatasourceAuth holds the credentials of a datasource: a bearer token or basic auth.
+type DatasourceAuth struct {
+	BearerToken     string `json:"bearer_token,omitempty" bson:"bearer_token,omitempty"`
+	BearerTokenFile string `json:"bearer_token_file,omitempty" bson:"bearer_token_file,omitempty"`
+	Username        string `json:"username,omitempty" bson:"username,omitempty"`
+	Password        string `json:"password,omitempty" bson:"password,omitempty"`
+	PasswordFile    string `json:"password_file,omitempty" bson:"password_file,omitempty"`
+}
+
+// DatasourceTLS holds the client TLS settings of a datasource. Paths refer to files on the server.
+type DatasourceTLS struct {
+	CAFile             string `json:"ca_file,omitempty" bson:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty" bson:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty" bson:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" bson:"insecure_skip_verify,omitempty"`
+}
+
+// Errors returned by DatasourceStore implementations.
+var (
+	ErrDatasourceNotFound = errors.New("datasource not found")
+	ErrDatasourceExists   = errors.New("datasource already exists")
+)
+
+// DatasourceStore defines the interface for database operations on managed datasources.
+type DatasourceStore interface {
+	CreateDatasource(ctx context.Context, ds *Datasource) error
+	GetDatasource(ctx context.Context, name string) (*Datasource, error)
+	ListDatasources(ctx context.Context) ([]*Datasource, error)
+	UpdateDatasource(ctx context.Context, name string, ds *Datasource) error
+	DeleteDatasource(ctx context.Context, name string) error
+}
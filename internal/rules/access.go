@@ -0,0 +1,137 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"rulemanager/internal/database"
+)
+
+// ErrForbidden is returned when the caller may not change a rule owned by another team.
+var ErrForbidden = errors.New("forbidden")
+
+// PlanActionForbidden is the plan action of a change that would modify a rule owned by a team the
+// caller is not a member of.
+const PlanActionForbidden = "forbidden"
+
+// Identity is the caller of a request, as established by an authenticating proxy.
+type Identity struct {
+	User  string   `json:"user,omitempty"`
+	Teams []string `json:"teams,omitempty"`
+	// Tenant scopes rule names, and callers may only change rules of their tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// Admin callers may change rules of any team and tenant.
+	Admin bool `json:"admin,omitempty"`
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the caller identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the caller identity, or nil when access control is disabled.
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// TenantFromContext returns the tenant of the caller, or "" when access control is disabled or the
+// caller has no tenant.
+func TenantFromContext(ctx context.Context) string {
+	if identity := IdentityFromContext(ctx); identity != nil {
+		return identity.Tenant
+	}
+	return ""
+}
+
+// MemberOf reports whether the identity belongs to a team.
+func (i *Identity) MemberOf(team string) bool {
+	return slices.Contains(i.Teams, team)
+}
+
+// ruleTeam returns the team owning a rule, or "" for rules without an owning team.
+func ruleTeam(rule *database.Rule) string {
+	if rule == nil || rule.Metadata == nil {
+		return ""
+	}
+	return rule.Metadata.Team
+}
+
+// canModify reports whether the caller may change rules owned by a team. Without an identity
+// (access control disabled) or an owning team, anyone may; otherwise members of the team and
+// admins may.
+func canModify(identity *Identity, team string) bool {
+	return identity == nil || team == "" || identity.Admin || identity.MemberOf(team)
+}
+
+// inTenant reports whether the caller may change rules of a tenant. Without an identity (access
+// control disabled) anyone may; otherwise admins and callers of the same tenant may.
+func inTenant(identity *Identity, tenant string) bool {
+	return identity == nil || identity.Admin || identity.Tenant == tenant
+}
+
+// AuthorizeRuleChange checks that the caller of ctx may change a rule from existing to updated.
+// Either may be nil, for creations and deletions. The caller must be allowed to modify rules of
+// both the current and the new owning team, so that a rule cannot be handed to, or taken from, a
+// team the caller is not a member of. Rules of another tenant cannot be changed. Errors wrap
+// ErrForbidden.
+func (s *Service) AuthorizeRuleChange(ctx context.Context, existing, updated *database.Rule) error {
+	identity := IdentityFromContext(ctx)
+	if existing != nil && !inTenant(identity, existing.Tenant) {
+		return fmt.Errorf("%w: rule %s belongs to another tenant", ErrForbidden, existing.ID)
+	}
+	if team := ruleTeam(existing); !canModify(identity, team) {
+		return fmt.Errorf("%w: rule %s is owned by team '%s'", ErrForbidden, existing.ID, team)
+	}
+	if team := ruleTeam(updated); !canModify(identity, team) {
+		return fmt.Errorf("%w: cannot assign a rule to team '%s' without being a member", ErrForbidden, team)
+	}
+	return nil
+}
+
+// RequireAdmin checks that the caller of ctx is an admin, for changes affecting every team such as
+// datasources. Anyone may make them when access control is disabled. Errors wrap ErrForbidden.
+func RequireAdmin(ctx context.Context) error {
+	if identity := IdentityFromContext(ctx); identity != nil && !identity.Admin {
+		return fmt.Errorf("%w: only admins may do this", ErrForbidden)
+	}
+	return nil
+}
+
+// checkOwnership marks a plan overwriting existing, a rule of another team or tenant, as forbidden,
+// unless the caller is an admin, whose plan reason notes the override.
+func checkOwnership(ctx context.Context, plan *RulePlan, existing *database.Rule) {
+	identity := IdentityFromContext(ctx)
+	team := ruleTeam(existing)
+	switch {
+	case !inTenant(identity, existing.Tenant):
+		plan.Action = PlanActionForbidden
+		plan.Reason = fmt.Sprintf("Would overwrite rule %s of another tenant", existing.ID)
+	case canModify(identity, team) && identity != nil && team != "" && !identity.MemberOf(team):
+		plan.Reason += fmt.Sprintf(" (overriding ownership of team '%s' as admin)", team)
+	case !canModify(identity, team):
+		plan.Action = PlanActionForbidden
+		plan.Reason = fmt.Sprintf("Would overwrite rule %s owned by another team ('%s')", existing.ID, team)
+	}
+}
+
+// AuthorizeRuleDeletion checks that the caller of ctx may delete a rule. The rule is only fetched
+// when access control is enabled; missing rules are left to the deletion to report, and other
+// errors fetching the rule are returned, so that the deletion is refused.
+func (s *Service) AuthorizeRuleDeletion(ctx context.Context, id string) error {
+	if IdentityFromContext(ctx) == nil {
+		return nil
+	}
+	existing, err := s.ruleStore.GetRule(ctx, id)
+	if errors.Is(err, database.ErrRuleNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get rule: %w", err)
+	}
+	return s.AuthorizeRuleChange(ctx, existing, nil)
+}
@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"rulemanager/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_AuthorizeRuleChange(t *testing.T) {
+	service := NewService(new(MockTemplateProvider), new(MockRuleStore), new(MockSchemaValidator))
+	owned := func(team string) *database.Rule {
+		return &database.Rule{ID: "r1", Metadata: &database.RuleMetadata{Team: team}}
+	}
+
+	tests := []struct {
+		name      string
+		identity  *Identity
+		existing  *database.Rule
+		updated   *database.Rule
+		forbidden bool
+	}{
+		{name: "Disabled", identity: nil, existing: owned("payments"), updated: owned("search")},
+		{name: "Unowned", identity: &Identity{Teams: []string{"platform"}}, existing: &database.Rule{ID: "r1"}, updated: &database.Rule{ID: "r1"}},
+		{name: "Member", identity: &Identity{Teams: []string{"platform", "payments"}}, existing: owned("payments"), updated: owned("payments")},
+		{name: "OtherTeam", identity: &Identity{Teams: []string{"platform"}}, existing: owned("payments"), updated: owned("payments"), forbidden: true},
+		{name: "HandOver", identity: &Identity{Teams: []string{"payments"}}, existing: owned("payments"), updated: owned("search"), forbidden: true},
+		{name: "Claim", identity: &Identity{Teams: []string{"platform"}}, existing: nil, updated: owned("payments"), forbidden: true},
+		{name: "Delete", identity: &Identity{Teams: []string{"platform"}}, existing: owned("payments"), updated: nil, forbidden: true},
+		{name: "Anonymous", identity: &Identity{}, existing: owned("payments"), updated: nil, forbidden: true},
+		{name: "Admin", identity: &Identity{Teams: []string{"sre"}, Admin: true}, existing: owned("payments"), updated: owned("search")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.identity != nil {
+				ctx = WithIdentity(ctx, tt.identity)
+			}
+			err := service.AuthorizeRuleChange(ctx, tt.existing, tt.updated)
+			if tt.forbidden {
+				assert.ErrorIs(t, err, ErrForbidden)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_AuthorizeRuleDeletion(t *testing.T) {
+	mockRS := new(MockRuleStore)
+	mockRS.On("GetRule", mock.Anything, "owned").Return(&database.Rule{ID: "owned", Metadata: &database.RuleMetadata{Team: "payments"}}, nil)
+	mockRS.On("GetRule", mock.Anything, "missing").Return((*database.Rule)(nil), database.ErrRuleNotFound)
+	mockRS.On("GetRule", mock.Anything, "unavailable").Return((*database.Rule)(nil), errors.New("connection reset"))
+	service := NewService(new(MockTemplateProvider), mockRS, new(MockSchemaValidator))
+	ctx := WithIdentity(context.Background(), &Identity{Teams: []string{"platform"}})
+
+	assert.ErrorIs(t, service.AuthorizeRuleDeletion(ctx, "owned"), ErrForbidden)
+	assert.NoError(t, service.AuthorizeRuleDeletion(ctx, "missing"), "missing rules are left to the deletion")
+
+	err := service.AuthorizeRuleDeletion(ctx, "unavailable")
+	assert.ErrorContains(t, err, "connection reset", "store errors refuse the deletion")
+	assert.NotErrorIs(t, err, ErrForbidden)
+
+	assert.NoError(t, service.AuthorizeRuleDeletion(context.Background(), "unavailable"), "the rule is not fetched without access control")
+}
+
+func TestService_PlanRuleCreation_Ownership(t *testing.T) {
+	templateName := "test_template"
+	params := json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}]}`)
+	schema := `{"type": "object"}`
+	existingRule := &database.Rule{ID: "123", Metadata: &database.RuleMetadata{Team: "payments"}}
+
+	plan := func(identity *Identity) *RulePlan {
+		mockTP := new(MockTemplateProvider)
+		mockVal := new(MockSchemaValidator)
+		mockRS := new(MockRuleStore)
+		mockTP.On("GetSchema", mock.Anything, templateName).Return(schema, nil)
+		mockVal.On("Validate", schema, []byte(params)).Return(nil)
+		mockRS.On("SearchRules", mock.Anything, mock.Anything).Return([]*database.Rule{existingRule}, nil)
+
+		plan, err := NewService(mockTP, mockRS, mockVal).PlanRuleCreation(WithIdentity(context.Background(), identity), templateName, params)
+		assert.NoError(t, err)
+		return plan
+	}
+
+	t.Run("Member", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"payments"}})
+		assert.Equal(t, "update", p.Action)
+		assert.NotContains(t, p.Reason, "admin")
+	})
+
+	t.Run("OtherTeam", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"platform"}})
+		assert.Equal(t, PlanActionForbidden, p.Action)
+		assert.Contains(t, p.Reason, "owned by another team ('payments')")
+		assert.Equal(t, existingRule, p.ExistingRule)
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"sre"}, Admin: true})
+		assert.Equal(t, "update", p.Action)
+		assert.Contains(t, p.Reason, "overriding ownership of team 'payments' as admin")
+	})
+}
+
+func TestService_PlanRuleUpdate_Ownership(t *testing.T) {
+	templateName := "test_template"
+	schema := `{"type": "object", "uniqueness_keys": ["target.namespace"]}`
+	existingRule := &database.Rule{
+		ID:           "r1",
+		TemplateName: templateName,
+		Parameters:   json.RawMessage(`{"target": {"namespace": "test"}}`),
+		Metadata:     &database.RuleMetadata{Team: "payments"},
+	}
+
+	plan := func(identity *Identity, matches []*database.Rule) *RulePlan {
+		mockTP := new(MockTemplateProvider)
+		mockVal := new(MockSchemaValidator)
+		mockRS := new(MockRuleStore)
+		mockRS.On("GetRule", mock.Anything, "r1").Return(existingRule, nil)
+		mockTP.On("GetSchema", mock.Anything, templateName).Return(schema, nil)
+		mockVal.On("Validate", schema, mock.Anything).Return(nil)
+		mockRS.On("SearchRules", mock.Anything, mock.Anything).Return(matches, nil)
+
+		plan, err := NewService(mockTP, mockRS, mockVal).PlanRuleUpdate(WithIdentity(context.Background(), identity), "r1", templateName, nil)
+		assert.NoError(t, err)
+		return plan
+	}
+
+	t.Run("OtherTeam", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"platform"}}, []*database.Rule{existingRule})
+		assert.Equal(t, PlanActionForbidden, p.Action)
+	})
+
+	t.Run("OtherTeamHidesConflict", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"platform"}}, []*database.Rule{{ID: "r2"}})
+		assert.Equal(t, PlanActionForbidden, p.Action)
+	})
+
+	t.Run("Member", func(t *testing.T) {
+		p := plan(&Identity{Teams: []string{"payments"}}, []*database.Rule{existingRule})
+		assert.Equal(t, "update", p.Action)
+	})
+}
@@ -0,0 +1,286 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Condition operators supported by PipelineCondition.
+const (
+	ConditionOpEq      = "eq"
+	ConditionOpNe      = "ne"
+	ConditionOpGt      = "gt"
+	ConditionOpGte     = "gte"
+	ConditionOpLt      = "lt"
+	ConditionOpLte     = "lte"
+	ConditionOpIn      = "in"
+	ConditionOpNotIn   = "not_in"
+	ConditionOpMatches = "matches"
+	ConditionOpExists  = "exists"
+)
+
+// evaluateCondition checks if a pipeline condition is met for the given rule parameters.
+// The error is set if the condition itself is malformed.
+func evaluateCondition(condition *PipelineCondition, ruleParams json.RawMessage) (bool, error) {
+	var params interface{}
+	if err := json.Unmarshal(ruleParams, &params); err != nil {
+		return false, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+	}
+	return condition.evaluate(params)
+}
+
+// evaluate checks the condition against decoded parameters. Every part set on the condition must hold.
+func (c *PipelineCondition) evaluate(params interface{}) (bool, error) {
+	hasLeaf := c.Path != "" || c.Property != ""
+	if !hasLeaf && len(c.All) == 0 && len(c.Any) == 0 && c.Not == nil {
+		return false, fmt.Errorf("condition must set path, all, any or not")
+	}
+
+	if hasLeaf {
+		met, err := c.evaluateLeaf(params)
+		if err != nil || !met {
+			return false, err
+		}
+	}
+
+	for _, sub := range c.All {
+		met, err := sub.evaluate(params)
+		if err != nil || !met {
+			return false, err
+		}
+	}
+
+	if len(c.Any) > 0 {
+		anyMet := false
+		for _, sub := range c.Any {
+			met, err := sub.evaluate(params)
+			if err != nil {
+				return false, err
+			}
+			if met {
+				anyMet = true
+				break
+			}
+		}
+		if !anyMet {
+			return false, nil
+		}
+	}
+
+	if c.Not != nil {
+		met, err := c.Not.evaluate(params)
+		if err != nil || met {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// evaluateLeaf applies the condition operator to the value found at the condition path, or at the
+// top-level key named by a legacy property. A missing path fails every comparison; use exists (or
+// not) to test for absence.
+func (c *PipelineCondition) evaluateLeaf(params interface{}) (bool, error) {
+	expected := c.Value
+	switch {
+	case c.StringValue != nil:
+		expected = *c.StringValue
+	case c.BoolValue != nil:
+		expected = *c.BoolValue
+	case c.NumberValue != nil:
+		expected = *c.NumberValue
+	}
+
+	op := c.Operator
+	if op == "" {
+		// A legacy property condition without a value has never matched; keep skipping its step.
+		if c.Path == "" && expected == nil {
+			return false, nil
+		}
+		op = ConditionOpEq
+	}
+
+	var actual interface{}
+	var found bool
+	if c.Path != "" {
+		var err error
+		if actual, found, err = lookupPath(params, c.Path); err != nil {
+			return false, err
+		}
+	} else if object, ok := params.(map[string]interface{}); ok {
+		// Properties name a top-level key as is: "a.b" or "x[0]" are keys, not paths
+		actual, found = object[c.Property]
+	}
+
+	switch op {
+	case ConditionOpExists:
+		want := true
+		if expected != nil {
+			b, ok := expected.(bool)
+			if !ok {
+				return false, fmt.Errorf("exists expects a boolean value")
+			}
+			want = b
+		}
+		return found == want, nil
+	case ConditionOpEq, ConditionOpNe, ConditionOpGt, ConditionOpGte, ConditionOpLt, ConditionOpLte:
+		if expected == nil {
+			return false, fmt.Errorf("operator %s requires a value", op)
+		}
+	case ConditionOpIn, ConditionOpNotIn:
+		if _, ok := expected.([]interface{}); !ok {
+			return false, fmt.Errorf("operator %s requires a list value", op)
+		}
+	case ConditionOpMatches:
+		if _, ok := expected.(string); !ok {
+			return false, fmt.Errorf("operator matches requires a string value")
+		}
+	default:
+		return false, fmt.Errorf("unknown condition operator: %s", op)
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	switch op {
+	case ConditionOpEq:
+		return valuesEqual(actual, expected), nil
+	case ConditionOpNe:
+		return !valuesEqual(actual, expected), nil
+	case ConditionOpIn, ConditionOpNotIn:
+		in := false
+		for _, candidate := range expected.([]interface{}) {
+			if valuesEqual(actual, candidate) {
+				in = true
+				break
+			}
+		}
+		return in == (op == ConditionOpIn), nil
+	case ConditionOpMatches:
+		re, err := regexp.Compile(expected.(string))
+		if err != nil {
+			return false, fmt.Errorf("invalid regex: %w", err)
+		}
+		str, ok := actual.(string)
+		return ok && re.MatchString(str), nil
+	default:
+		cmp, ok := compareValues(actual, expected)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case ConditionOpGt:
+			return cmp > 0, nil
+		case ConditionOpGte:
+			return cmp >= 0, nil
+		case ConditionOpLt:
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	}
+}
+
+// valuesEqual compares two decoded JSON values. Numbers compare by value regardless of their Go type.
+func valuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues orders two numbers or two strings. The bool is false if the values are not comparable.
+func compareValues(a, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if !okA || !okB {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// lookupPath resolves a path such as "target.namespace" or "rules[0].threshold" in decoded JSON.
+// The bool reports whether a non-null value exists at the path; the error is set for malformed paths.
+func lookupPath(data interface{}, path string) (interface{}, bool, error) {
+	current := data
+	for _, segment := range strings.Split(path, ".") {
+		name, indexes, err := parsePathSegment(segment)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid path '%s': %w", path, err)
+		}
+
+		if name != "" {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+			if current, ok = m[name]; !ok {
+				return nil, false, nil
+			}
+		}
+
+		for _, idx := range indexes {
+			arr, ok := current.([]interface{})
+			if !ok || idx >= len(arr) {
+				return nil, false, nil
+			}
+			current = arr[idx]
+		}
+	}
+	return current, current != nil, nil
+}
+
+// parsePathSegment splits "rules[0][1]" into its name and array indexes.
+func parsePathSegment(segment string) (string, []int, error) {
+	name := segment
+	var indexes []int
+	if i := strings.IndexByte(segment, '['); i >= 0 {
+		name = segment[:i]
+		rest := segment[i:]
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return "", nil, fmt.Errorf("malformed index in '%s'", segment)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return "", nil, fmt.Errorf("invalid index in '%s'", segment)
+			}
+			indexes = append(indexes, idx)
+			rest = rest[end+1:]
+		}
+	}
+	if name == "" && len(indexes) == 0 {
+		return "", nil, fmt.Errorf("empty segment")
+	}
+	return name, indexes, nil
+}
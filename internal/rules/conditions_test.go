@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	params := json.RawMessage(`{
+		"target": {"environment": "production", "namespace": "payments"},
+		"common": {"severity": "critical"},
+		"rule_type": "cpu",
+		"threshold": 0.9,
+		"enabled": true,
+		"rules": [{"rule_type": "cpu", "threshold": 0.8}, {"rule_type": "ram"}],
+		"a.b": "x",
+		"x[0]": "y"
+	}`)
+
+	tests := []struct {
+		name        string
+		condition   string
+		expected    bool
+		expectError string
+	}{
+		{name: "Legacy String Equality", condition: `{"property": "rule_type", "string_value": "cpu"}`, expected: true},
+		{name: "Legacy Bool Equality", condition: `{"property": "enabled", "bool_value": false}`, expected: false},
+		{name: "Legacy Number Equality", condition: `{"property": "threshold", "number_value": 0.9}`, expected: true},
+		{name: "Legacy Without Value Never Matches", condition: `{"property": "rule_type"}`, expected: false},
+		{name: "Legacy Dotted Key", condition: `{"property": "a.b", "string_value": "x"}`, expected: true},
+		{name: "Legacy Bracketed Key", condition: `{"property": "x[0]", "string_value": "y"}`, expected: true},
+		{name: "Legacy Property Is Not A Path", condition: `{"property": "target.namespace", "string_value": "payments"}`, expected: false},
+		{name: "Dotted Path Is Not A Key", condition: `{"path": "a.b", "value": "x"}`, expected: false},
+		{name: "Nested Path", condition: `{"path": "target.namespace", "value": "payments"}`, expected: true},
+		{name: "Array Path", condition: `{"path": "rules[0].threshold", "operator": "lt", "value": 0.85}`, expected: true},
+		{name: "Array Path Out Of Range", condition: `{"path": "rules[5].threshold", "operator": "exists"}`, expected: false},
+		{name: "Not Equal", condition: `{"path": "rule_type", "operator": "ne", "value": "ram"}`, expected: true},
+		{name: "Greater Or Equal", condition: `{"path": "threshold", "operator": "gte", "value": 0.9}`, expected: true},
+		{name: "Greater Than", condition: `{"path": "threshold", "operator": "gt", "value": 0.9}`, expected: false},
+		{name: "String Ordering", condition: `{"path": "target.namespace", "operator": "lte", "value": "q"}`, expected: true},
+		{name: "Mixed Types Not Comparable", condition: `{"path": "rule_type", "operator": "gt", "value": 1}`, expected: false},
+		{name: "In", condition: `{"path": "common.severity", "operator": "in", "value": ["warning", "critical"]}`, expected: true},
+		{name: "Not In", condition: `{"path": "common.severity", "operator": "not_in", "value": ["critical"]}`, expected: false},
+		{name: "Matches", condition: `{"path": "target.namespace", "operator": "matches", "value": "^pay"}`, expected: true},
+		{name: "Exists", condition: `{"path": "target.workload", "operator": "exists"}`, expected: false},
+		{name: "Exists False", condition: `{"path": "target.workload", "operator": "exists", "value": false}`, expected: true},
+		{name: "Missing Path Fails Comparison", condition: `{"path": "target.workload", "operator": "ne", "value": "x"}`, expected: false},
+		{
+			name: "All",
+			condition: `{"all": [
+				{"path": "target.environment", "value": "production"},
+				{"path": "common.severity", "value": "critical"},
+				{"path": "rule_type", "value": "cpu"}
+			]}`,
+			expected: true,
+		},
+		{
+			name:      "Any",
+			condition: `{"any": [{"path": "rule_type", "value": "ram"}, {"path": "rule_type", "value": "disk"}]}`,
+			expected:  false,
+		},
+		{name: "Not", condition: `{"not": {"path": "target.environment", "value": "staging"}}`, expected: true},
+		{
+			name:      "Leaf And Combinator",
+			condition: `{"path": "rule_type", "value": "cpu", "not": {"path": "enabled", "value": true}}`,
+			expected:  false,
+		},
+		{name: "Unknown Operator", condition: `{"path": "rule_type", "operator": "like", "value": "x"}`, expectError: "unknown condition operator"},
+		{name: "In Requires List", condition: `{"path": "rule_type", "operator": "in", "value": "cpu"}`, expectError: "requires a list value"},
+		{name: "Invalid Regex", condition: `{"path": "rule_type", "operator": "matches", "value": "("}`, expectError: "invalid regex"},
+		{name: "Malformed Path", condition: `{"path": "rules[x].threshold", "value": 1}`, expectError: "invalid path"},
+		{name: "Empty Condition", condition: `{}`, expectError: "condition must set"},
+		{name: "Path Without Value", condition: `{"path": "rule_type"}`, expectError: "operator eq requires a value"},
+		{name: "Error In Nested Condition", condition: `{"any": [{"path": "rule_type", "operator": "bogus"}]}`, expectError: "unknown condition operator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var condition PipelineCondition
+			assert.NoError(t, json.Unmarshal([]byte(tt.condition), &condition))
+
+			met, err := evaluateCondition(&condition, params)
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, met)
+		})
+	}
+}
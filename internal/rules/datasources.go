@@ -0,0 +1,748 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rulemanager/config"
+	"rulemanager/internal/database"
+)
+
+// DefaultDatasourceTimeout bounds a single datasource request.
+const DefaultDatasourceTimeout = 10 * time.Second
+
+// defaultDatasourceClient serves inline datasources, which have no client of their own, so their
+// connections are reused across requests.
+var defaultDatasourceClient = &http.Client{Timeout: DefaultDatasourceTimeout, CheckRedirect: sameHostRedirect}
+
+// RedactedSecret replaces credentials in datasources returned by the registry. Sending it back
+// in an update keeps the stored secret.
+const RedactedSecret = "<redacted>"
+
+// datasourceCacheTTL bounds how long a resolved managed datasource is reused, so changes made
+// through another instance are picked up.
+const datasourceCacheTTL = 30 * time.Second
+
+// ErrDatasourceReadOnly is returned when changing a datasource defined in the configuration file.
+var ErrDatasourceReadOnly = errors.New("datasource is defined in the configuration and cannot be changed through the API")
+
+// datasourceNames restricts managed datasource names to characters that are safe in URLs and file names.
+var datasourceNames = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// datasourceConn holds the connection settings of a named datasource. It is unexported so that
+// credentials are never serialized along with the DatasourceConfig.
+type datasourceConn struct {
+	client          *http.Client
+	pathPrefix      string
+	headers         map[string]string
+	bearerToken     string
+	bearerTokenFile string
+	basicAuth       *config.BasicAuthConfig
+}
+
+// DatasourceRegistry resolves the named datasources template schemas refer to. Datasources come
+// from the configuration file (read-only) and from the datasource store, managed through the API.
+type DatasourceRegistry struct {
+	static  map[string]*DatasourceConfig
+	configs map[string]config.DatasourceConfig
+	store   database.DatasourceStore
+
+	mu       sync.Mutex
+	resolved map[string]resolvedDatasource
+}
+
+type resolvedDatasource struct {
+	datasource *DatasourceConfig
+	expires    time.Time
+}
+
+// NewDatasourceRegistry validates the configured datasources and builds their HTTP clients.
+// store holds the datasources managed through the API; it may be nil.
+func NewDatasourceRegistry(cfgs []config.DatasourceConfig, store database.DatasourceStore) (*DatasourceRegistry, error) {
+	r := &DatasourceRegistry{
+		static:   make(map[string]*DatasourceConfig, len(cfgs)),
+		configs:  make(map[string]config.DatasourceConfig, len(cfgs)),
+		store:    store,
+		resolved: make(map[string]resolvedDatasource),
+	}
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("datasource name is required")
+		}
+		if _, ok := r.static[cfg.Name]; ok {
+			return nil, fmt.Errorf("datasource '%s' is defined more than once", cfg.Name)
+		}
+		ds, err := newDatasource(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("datasource '%s': %w", cfg.Name, err)
+		}
+		r.static[cfg.Name] = ds
+		r.configs[cfg.Name] = cfg
+	}
+	return r, nil
+}
+
+// newDatasource converts a configured datasource into its resolved form.
+func newDatasource(cfg config.DatasourceConfig) (*DatasourceConfig, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url '%s': expected an absolute http(s) url", cfg.URL)
+	}
+	if cfg.BearerToken != "" && cfg.BearerTokenFile != "" {
+		return nil, fmt.Errorf("bearer_token and bearer_token_file are mutually exclusive")
+	}
+	if (cfg.BearerToken != "" || cfg.BearerTokenFile != "") && cfg.BasicAuth != nil {
+		return nil, fmt.Errorf("bearer token and basic_auth are mutually exclusive")
+	}
+
+	tls := cfg.TLS
+	client, err := tlsClient(&WebhookTLSConfig{
+		CAFile:             tls.CAFile,
+		CertFile:           tls.CertFile,
+		KeyFile:            tls.KeyFile,
+		InsecureSkipVerify: tls.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = cfg.Timeout
+	if client.Timeout <= 0 {
+		client.Timeout = DefaultDatasourceTimeout
+	}
+
+	conn := &datasourceConn{
+		client:          client,
+		headers:         cfg.Headers,
+		bearerToken:     cfg.BearerToken,
+		bearerTokenFile: cfg.BearerTokenFile,
+		basicAuth:       cfg.BasicAuth,
+	}
+	if cfg.Tenant != "" {
+		conn.pathPrefix = "/select/" + url.PathEscape(cfg.Tenant) + "/prometheus"
+	}
+	return &DatasourceConfig{Name: cfg.Name, Type: cfg.Type, URL: cfg.URL, conn: conn}, nil
+}
+
+// Resolve returns the datasource a schema refers to. References by name are looked up in the
+// configuration, then in the store (applying the overrides of the referenced environment); inline
+// datasources (type and URL in the schema) are returned as is. A nil registry resolves only inline
+// datasources.
+func (r *DatasourceRegistry) Resolve(ctx context.Context, ref *DatasourceConfig) (*DatasourceConfig, error) {
+	if ref == nil || ref.Name == "" {
+		return ref, nil
+	}
+	if r != nil {
+		if ds, ok := r.static[ref.Name]; ok {
+			return ds, nil
+		}
+		if r.store != nil {
+			return r.resolveManaged(ctx, ref.Name, ref.Environment)
+		}
+	}
+	return nil, fmt.Errorf("unknown datasource: %s", ref.Name)
+}
+
+// resolveManaged builds a datasource from the store, reusing recently resolved entries.
+func (r *DatasourceRegistry) resolveManaged(ctx context.Context, name, environment string) (*DatasourceConfig, error) {
+	key := name + "/" + environment
+	r.mu.Lock()
+	cached, ok := r.resolved[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.datasource, nil
+	}
+
+	stored, err := r.store.GetDatasource(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrDatasourceNotFound) {
+			return nil, fmt.Errorf("unknown datasource: %s", name)
+		}
+		return nil, fmt.Errorf("failed to load datasource '%s': %w", name, err)
+	}
+	ds, err := managedDatasource(name, effectiveSettings(stored, environment))
+	if err != nil {
+		return nil, fmt.Errorf("datasource '%s': %w", name, err)
+	}
+	ds.Environment = environment
+
+	r.mu.Lock()
+	r.resolved[key] = resolvedDatasource{datasource: ds, expires: time.Now().Add(datasourceCacheTTL)}
+	r.mu.Unlock()
+	return ds, nil
+}
+
+// invalidate drops the resolved entries of a managed datasource after it changed.
+func (r *DatasourceRegistry) invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.resolved {
+		if strings.HasPrefix(key, name+"/") {
+			delete(r.resolved, key)
+		}
+	}
+}
+
+// List returns all datasources, from the configuration and the store, sorted by name. Credentials are redacted.
+func (r *DatasourceRegistry) List(ctx context.Context) ([]*database.Datasource, error) {
+	datasources := make([]*database.Datasource, 0, len(r.configs))
+	for _, cfg := range r.configs {
+		datasources = append(datasources, configDatasource(cfg))
+	}
+	if r.store != nil {
+		stored, err := r.store.ListDatasources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ds := range stored {
+			if _, shadowed := r.configs[ds.Name]; !shadowed {
+				datasources = append(datasources, ds)
+			}
+		}
+	}
+
+	sort.Slice(datasources, func(i, j int) bool { return datasources[i].Name < datasources[j].Name })
+	for i, ds := range datasources {
+		datasources[i] = redactDatasource(ds)
+	}
+	return datasources, nil
+}
+
+// Get returns a datasource by name with its credentials redacted.
+func (r *DatasourceRegistry) Get(ctx context.Context, name string) (*database.Datasource, error) {
+	if cfg, ok := r.configs[name]; ok {
+		return redactDatasource(configDatasource(cfg)), nil
+	}
+	if r.store == nil {
+		return nil, database.ErrDatasourceNotFound
+	}
+	ds, err := r.store.GetDatasource(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return redactDatasource(ds), nil
+}
+
+// Create validates and stores a new managed datasource.
+func (r *DatasourceRegistry) Create(ctx context.Context, ds *database.Datasource) error {
+	if _, ok := r.configs[ds.Name]; ok {
+		return database.ErrDatasourceExists
+	}
+	if err := r.requireStore(); err != nil {
+		return err
+	}
+	if err := validateDatasource(ds); err != nil {
+		return err
+	}
+	return r.store.CreateDatasource(ctx, ds)
+}
+
+// Update validates and replaces a managed datasource. Redacted credentials keep their stored value.
+func (r *DatasourceRegistry) Update(ctx context.Context, name string, ds *database.Datasource) error {
+	if _, ok := r.configs[name]; ok {
+		return ErrDatasourceReadOnly
+	}
+	if err := r.requireStore(); err != nil {
+		return err
+	}
+	existing, err := r.store.GetDatasource(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	ds.Name = name
+	restoreSecrets(&ds.DatasourceSettings, &existing.DatasourceSettings)
+	for env, settings := range ds.Environments {
+		previous := existing.Environments[env]
+		restoreSecrets(&settings, &previous)
+		ds.Environments[env] = settings
+	}
+	if err := validateDatasource(ds); err != nil {
+		return err
+	}
+
+	if err := r.store.UpdateDatasource(ctx, name, ds); err != nil {
+		return err
+	}
+	r.invalidate(name)
+	return nil
+}
+
+// Delete removes a managed datasource.
+func (r *DatasourceRegistry) Delete(ctx context.Context, name string) error {
+	if _, ok := r.configs[name]; ok {
+		return ErrDatasourceReadOnly
+	}
+	if err := r.requireStore(); err != nil {
+		return err
+	}
+	if err := r.store.DeleteDatasource(ctx, name); err != nil {
+		return err
+	}
+	r.invalidate(name)
+	return nil
+}
+
+func (r *DatasourceRegistry) requireStore() error {
+	if r.store == nil {
+		return fmt.Errorf("no datasource store is configured")
+	}
+	return nil
+}
+
+// DatasourceHealth is the result of probing a datasource.
+type DatasourceHealth struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+	URL         string `json:"url"`
+	// Status is "healthy" if every check passed, "degraded" if the labels API answers but build
+	// information is unavailable, and "unhealthy" otherwise.
+	Status  string            `json:"status"`
+	Version string            `json:"version,omitempty"`
+	Checks  []DatasourceCheck `json:"checks"`
+}
+
+// DatasourceCheck is the result of probing a single datasource endpoint.
+type DatasourceCheck struct {
+	Endpoint  string `json:"endpoint"`
+	Status    string `json:"status"` // "ok" or "error"
+	LatencyMs int64  `json:"latency_ms"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Datasource health statuses.
+const (
+	DatasourceHealthy   = "healthy"
+	DatasourceDegraded  = "degraded"
+	DatasourceUnhealthy = "unhealthy"
+)
+
+// Health probes the build information and labels endpoints of a datasource.
+func (r *DatasourceRegistry) Health(ctx context.Context, name, environment string) (*DatasourceHealth, error) {
+	if _, err := r.Get(ctx, name); err != nil {
+		return nil, err
+	}
+	ds, err := r.Resolve(ctx, &DatasourceConfig{Name: name, Environment: environment})
+	if err != nil {
+		return nil, err
+	}
+
+	health := &DatasourceHealth{Name: name, Environment: environment, URL: ds.URL}
+
+	var buildInfo struct {
+		Status string `json:"status"`
+		Data   struct {
+			Version string `json:"version"`
+		} `json:"data"`
+	}
+	buildInfoCheck := probeDatasource(ctx, ds, "/api/v1/status/buildinfo", &buildInfo)
+	health.Version = buildInfo.Data.Version
+	labelsCheck := probeDatasource(ctx, ds, "/api/v1/labels", nil)
+	health.Checks = []DatasourceCheck{buildInfoCheck, labelsCheck}
+
+	switch {
+	case labelsCheck.Status != "ok":
+		health.Status = DatasourceUnhealthy
+	case buildInfoCheck.Status != "ok":
+		health.Status = DatasourceDegraded
+	default:
+		health.Status = DatasourceHealthy
+	}
+	return health, nil
+}
+
+// probeDatasource calls a datasource endpoint and checks for a successful Prometheus API response.
+func probeDatasource(ctx context.Context, ds *DatasourceConfig, path string, out interface{}) DatasourceCheck {
+	check := DatasourceCheck{Endpoint: path, Status: "error"}
+	start := time.Now()
+	resp, err := ds.get(ctx, nil, path, nil)
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		check.Message = fmt.Sprintf("failed to read response: %v", err)
+		return check
+	}
+	if resp.StatusCode != http.StatusOK {
+		check.Message = fmt.Sprintf("datasource returned status %d", resp.StatusCode)
+		return check
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil || status.Status != "success" {
+		check.Message = "unexpected response, not a Prometheus-compatible API"
+		return check
+	}
+	if out != nil {
+		_ = json.Unmarshal(body, out)
+	}
+	check.Status = "ok"
+	return check
+}
+
+// validateDatasource checks the name and that the base settings and every environment build a valid datasource.
+func validateDatasource(ds *database.Datasource) error {
+	if !datasourceNames.MatchString(ds.Name) {
+		return fmt.Errorf("invalid datasource name '%s': use letters, digits, '.', '_' and '-'", ds.Name)
+	}
+	environments := []string{""}
+	for env := range ds.Environments {
+		if env == "" {
+			return fmt.Errorf("environment name must not be empty")
+		}
+		environments = append(environments, env)
+	}
+	sort.Strings(environments)
+
+	for _, env := range environments {
+		if _, err := managedDatasource(ds.Name, effectiveSettings(ds, env)); err != nil {
+			if env == "" {
+				return err
+			}
+			return fmt.Errorf("environment '%s': %w", env, err)
+		}
+	}
+	return nil
+}
+
+// rejectServerFiles checks that settings do not refer to files on the server. Datasources
+// managed through the API cannot use them: the server would send the contents of any file it can
+// read to a URL chosen by the caller. Token, password and TLS files are only supported for
+// datasources in the configuration file.
+func rejectServerFiles(s database.DatasourceSettings) error {
+	var fields []string
+	if a := s.Auth; a != nil {
+		if a.BearerTokenFile != "" {
+			fields = append(fields, "auth.bearer_token_file")
+		}
+		if a.PasswordFile != "" {
+			fields = append(fields, "auth.password_file")
+		}
+	}
+	if t := s.TLS; t != nil {
+		if t.CAFile != "" {
+			fields = append(fields, "tls.ca_file")
+		}
+		if t.CertFile != "" {
+			fields = append(fields, "tls.cert_file")
+		}
+		if t.KeyFile != "" {
+			fields = append(fields, "tls.key_file")
+		}
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("%s: files on the server can only be used by datasources in the configuration file", strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// effectiveSettings returns the settings of a datasource with the overrides of an environment applied.
+func effectiveSettings(ds *database.Datasource, environment string) database.DatasourceSettings {
+	settings := ds.DatasourceSettings
+	override, ok := ds.Environments[environment]
+	if environment == "" || !ok {
+		return settings
+	}
+
+	if override.Type != "" {
+		settings.Type = override.Type
+	}
+	if override.URL != "" {
+		settings.URL = override.URL
+	}
+	if override.Tenant != "" {
+		settings.Tenant = override.Tenant
+	}
+	if len(override.Headers) > 0 {
+		headers := make(map[string]string, len(settings.Headers)+len(override.Headers))
+		for k, v := range settings.Headers {
+			headers[k] = v
+		}
+		for k, v := range override.Headers {
+			headers[k] = v
+		}
+		settings.Headers = headers
+	}
+	if override.Auth != nil {
+		settings.Auth = override.Auth
+	}
+	if override.TLS != nil {
+		settings.TLS = override.TLS
+	}
+	if override.Timeout != "" {
+		settings.Timeout = override.Timeout
+	}
+	return settings
+}
+
+// managedDatasource builds a datasource from stored settings. Entries stored before file
+// references were rejected are refused here too, so they never read server files.
+func managedDatasource(name string, s database.DatasourceSettings) (*DatasourceConfig, error) {
+	if err := rejectServerFiles(s); err != nil {
+		return nil, err
+	}
+	cfg, err := datasourceConfig(name, s)
+	if err != nil {
+		return nil, err
+	}
+	return newDatasource(cfg)
+}
+
+// datasourceConfig converts stored settings into the configuration form understood by newDatasource.
+func datasourceConfig(name string, s database.DatasourceSettings) (config.DatasourceConfig, error) {
+	cfg := config.DatasourceConfig{Name: name, Type: s.Type, URL: s.URL, Tenant: s.Tenant, Headers: s.Headers}
+	if s.Timeout != "" {
+		timeout, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid timeout: %w", err)
+		}
+		cfg.Timeout = timeout
+	}
+	if a := s.Auth; a != nil {
+		cfg.BearerToken, cfg.BearerTokenFile = a.BearerToken, a.BearerTokenFile
+		if a.Username != "" || a.Password != "" || a.PasswordFile != "" {
+			cfg.BasicAuth = &config.BasicAuthConfig{Username: a.Username, Password: a.Password, PasswordFile: a.PasswordFile}
+		}
+	}
+	if t := s.TLS; t != nil {
+		cfg.TLS = config.TLSConfig{CAFile: t.CAFile, CertFile: t.CertFile, KeyFile: t.KeyFile, InsecureSkipVerify: t.InsecureSkipVerify}
+	}
+	return cfg, nil
+}
+
+// configDatasource converts a datasource from the configuration file into its stored form.
+func configDatasource(cfg config.DatasourceConfig) *database.Datasource {
+	ds := &database.Datasource{
+		Name: cfg.Name,
+		DatasourceSettings: database.DatasourceSettings{
+			Type:    cfg.Type,
+			URL:     cfg.URL,
+			Tenant:  cfg.Tenant,
+			Headers: cfg.Headers,
+		},
+		ReadOnly: true,
+	}
+	if cfg.Timeout > 0 {
+		ds.Timeout = cfg.Timeout.String()
+	}
+	if cfg.BearerToken != "" || cfg.BearerTokenFile != "" || cfg.BasicAuth != nil {
+		ds.Auth = &database.DatasourceAuth{BearerToken: cfg.BearerToken, BearerTokenFile: cfg.BearerTokenFile}
+		if b := cfg.BasicAuth; b != nil {
+			ds.Auth.Username, ds.Auth.Password, ds.Auth.PasswordFile = b.Username, b.Password, b.PasswordFile
+		}
+	}
+	if t := cfg.TLS; t != (config.TLSConfig{}) {
+		ds.TLS = &database.DatasourceTLS{CAFile: t.CAFile, CertFile: t.CertFile, KeyFile: t.KeyFile, InsecureSkipVerify: t.InsecureSkipVerify}
+	}
+	return ds
+}
+
+// redactDatasource returns a copy of a datasource with its inline credentials and header values
+// replaced by RedactedSecret. Headers often carry API keys or tenant IDs, so none are returned.
+func redactDatasource(ds *database.Datasource) *database.Datasource {
+	redacted := *ds
+	redacted.Auth = redactAuth(ds.Auth)
+	redacted.Headers = redactHeaders(ds.Headers)
+	if ds.Environments != nil {
+		redacted.Environments = make(map[string]database.DatasourceSettings, len(ds.Environments))
+		for env, settings := range ds.Environments {
+			settings.Auth = redactAuth(settings.Auth)
+			settings.Headers = redactHeaders(settings.Headers)
+			redacted.Environments[env] = settings
+		}
+	}
+	return &redacted
+}
+
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = RedactedSecret
+	}
+	return redacted
+}
+
+func redactAuth(auth *database.DatasourceAuth) *database.DatasourceAuth {
+	if auth == nil {
+		return nil
+	}
+	redacted := *auth
+	if redacted.BearerToken != "" {
+		redacted.BearerToken = RedactedSecret
+	}
+	if redacted.Password != "" {
+		redacted.Password = RedactedSecret
+	}
+	return &redacted
+}
+
+// restoreSecrets replaces redacted credentials and header values in updated settings with the
+// previously stored values.
+func restoreSecrets(updated, previous *database.DatasourceSettings) {
+	if updated.Headers != nil {
+		headers := make(map[string]string, len(updated.Headers))
+		for name, value := range updated.Headers {
+			if value == RedactedSecret {
+				value = previous.Headers[name]
+			}
+			headers[name] = value
+		}
+		updated.Headers = headers
+	}
+	if updated.Auth == nil {
+		return
+	}
+	var old database.DatasourceAuth
+	if previous.Auth != nil {
+		old = *previous.Auth
+	}
+	auth := *updated.Auth
+	if auth.BearerToken == RedactedSecret {
+		auth.BearerToken = old.BearerToken
+	}
+	if auth.Password == RedactedSecret {
+		auth.Password = old.Password
+	}
+	updated.Auth = &auth
+}
+
+// get sends an authenticated GET request to the datasource API. client overrides the datasource's
+// own client (e.g. in tests); inline datasources without one use a plain client.
+func (d *DatasourceConfig) get(ctx context.Context, client *http.Client, path string, params url.Values) (*http.Response, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid datasource URL: %w", err)
+	}
+	conn := d.conn
+	if conn == nil {
+		conn = &datasourceConn{}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + conn.pathPrefix + path
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := conn.authorize(req); err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = conn.client
+	}
+	if client == nil {
+		client = defaultDatasourceClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query datasource: %w", err)
+	}
+	return resp, nil
+}
+
+// cacheKey identifies a query against this datasource in the query cache.
+func (d *DatasourceConfig) cacheKey(path string, params url.Values) string {
+	return d.Name + "/" + d.Environment + "@" + d.URL + path + "?" + params.Encode()
+}
+
+// authorize adds the configured headers and credentials to a request.
+func (c *datasourceConn) authorize(req *http.Request) error {
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.bearerTokenFile != "":
+		token, err := os.ReadFile(c.bearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read bearer token file: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	case c.basicAuth != nil:
+		password := c.basicAuth.Password
+		if c.basicAuth.PasswordFile != "" {
+			raw, err := os.ReadFile(c.basicAuth.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("failed to read password file: %w", err)
+			}
+			password = strings.TrimSpace(string(raw))
+		}
+		req.SetBasicAuth(c.basicAuth.Username, password)
+	}
+	return nil
+}
+
+// resolveDatasource renders the environment of a schema's datasource reference with the rule
+// parameters and resolves it through the registry.
+func (s *Service) resolveDatasource(ctx context.Context, ref *DatasourceConfig, parameters json.RawMessage) (*DatasourceConfig, error) {
+	if ref == nil || ref.Name == "" {
+		return ref, nil
+	}
+	resolved := *ref
+	if strings.Contains(ref.Environment, "{{") {
+		var data interface{}
+		if err := json.Unmarshal(parameters, &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
+		}
+		environment, err := renderString(ref.Environment, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render datasource environment: %w", err)
+		}
+		resolved.Environment = environment
+	}
+	return s.datasources.Resolve(ctx, &resolved)
+}
+
+// DatasourceReferences returns the names of the schemas whose datasource refers to the named datasource.
+func (s *Service) DatasourceReferences(ctx context.Context, name string) ([]string, error) {
+	schemas, err := s.templateProvider.ListSchemas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	references := []string{}
+	for _, schema := range schemas {
+		var schemaObj struct {
+			Datasource *DatasourceConfig `json:"datasource"`
+		}
+		if err := json.Unmarshal(schema.Schema, &schemaObj); err != nil {
+			continue // Invalid schemas cannot refer to anything
+		}
+		if schemaObj.Datasource != nil && schemaObj.Datasource.Name == name {
+			references = append(references, schema.Name)
+		}
+	}
+	sort.Strings(references)
+	return references, nil
+}
@@ -0,0 +1,407 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rulemanager/config"
+	"rulemanager/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDatasourceRegistry_Auth(t *testing.T) {
+	var lastRequest *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	passwordFile := filepath.Join(dir, "password")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("file-secret\n"), 0o600))
+
+	registry, err := NewDatasourceRegistry([]config.DatasourceConfig{
+		{Name: "bearer", Type: "victoriametrics", URL: ts.URL, BearerToken: "s3cr3t"},
+		{Name: "bearer-file", Type: "victoriametrics", URL: ts.URL, BearerTokenFile: tokenFile},
+		{Name: "basic", Type: "prometheus", URL: ts.URL, BasicAuth: &config.BasicAuthConfig{Username: "vm", Password: "secret"}},
+		{Name: "basic-file", Type: "prometheus", URL: ts.URL, BasicAuth: &config.BasicAuthConfig{Username: "vm", PasswordFile: passwordFile}},
+		{Name: "tenant", Type: "victoriametrics", URL: ts.URL + "/vmselect/", Tenant: "42:1", Headers: map[string]string{"accountid": "42"}},
+	}, nil)
+	assert.NoError(t, err)
+
+	query := func(name string) {
+		t.Helper()
+		ds, err := registry.Resolve(context.Background(), &DatasourceConfig{Name: name})
+		assert.NoError(t, err)
+		_, err = queryPrometheus(context.Background(), nil, ds, "/api/v1/query", url.Values{"query": {"up"}})
+		assert.NoError(t, err)
+	}
+
+	t.Run("BearerToken", func(t *testing.T) {
+		query("bearer")
+		assert.Equal(t, "Bearer s3cr3t", lastRequest.Header.Get("Authorization"))
+
+		query("bearer-file")
+		assert.Equal(t, "Bearer file-token", lastRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("BasicAuth", func(t *testing.T) {
+		query("basic")
+		user, password, ok := lastRequest.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "vm", user)
+		assert.Equal(t, "secret", password)
+
+		query("basic-file")
+		_, password, _ = lastRequest.BasicAuth()
+		assert.Equal(t, "file-secret", password)
+	})
+
+	t.Run("TenantPathAndHeaders", func(t *testing.T) {
+		query("tenant")
+		assert.Equal(t, "/vmselect/select/42:1/prometheus/api/v1/query", lastRequest.URL.Path)
+		assert.Equal(t, "42", lastRequest.Header.Get("AccountID"))
+		assert.Empty(t, lastRequest.Header.Get("Authorization"))
+	})
+
+	t.Run("CredentialsNotSerialized", func(t *testing.T) {
+		ds, _ := registry.Resolve(context.Background(), &DatasourceConfig{Name: "bearer"})
+		raw, err := json.Marshal(ds)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "bearer", "type": "victoriametrics", "url": "`+ts.URL+`"}`, string(raw))
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		inline := &DatasourceConfig{Type: "prometheus", URL: "http://localhost:9090"}
+		ds, err := registry.Resolve(context.Background(), inline)
+		assert.NoError(t, err)
+		assert.Same(t, inline, ds)
+
+		_, err = registry.Resolve(context.Background(), &DatasourceConfig{Name: "missing"})
+		assert.EqualError(t, err, "unknown datasource: missing")
+
+		var empty *DatasourceRegistry
+		_, err = empty.Resolve(context.Background(), &DatasourceConfig{Name: "bearer"})
+		assert.EqualError(t, err, "unknown datasource: bearer")
+	})
+}
+
+func TestDatasourceRegistry_Redirects(t *testing.T) {
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-Api-Key")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved/api/v1/query" {
+			http.Redirect(w, r, "/api/v1/query?"+r.URL.RawQuery, http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/away/api/v1/query" {
+			http.Redirect(w, r, other.URL+"/api/v1/query?"+r.URL.RawQuery, http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+
+	registry, err := NewDatasourceRegistry([]config.DatasourceConfig{
+		{Name: "moved", Type: "prometheus", URL: ts.URL + "/moved", Headers: map[string]string{"X-Api-Key": "s3cr3t"}},
+		{Name: "away", Type: "prometheus", URL: ts.URL + "/away", Headers: map[string]string{"X-Api-Key": "s3cr3t"}},
+	}, nil)
+	assert.NoError(t, err)
+	query := func(ref *DatasourceConfig) error {
+		ds, err := registry.Resolve(context.Background(), ref)
+		assert.NoError(t, err)
+		_, err = queryPrometheus(context.Background(), nil, ds, "/api/v1/query", url.Values{"query": {"up"}})
+		return err
+	}
+
+	assert.NoError(t, query(&DatasourceConfig{Name: "moved"}), "redirects within the host are followed")
+
+	err = query(&DatasourceConfig{Name: "away"})
+	assert.ErrorContains(t, err, "redirect to another host refused")
+	assert.Empty(t, leaked, "configured headers are not sent to another host")
+
+	err = query(&DatasourceConfig{Type: "prometheus", URL: ts.URL + "/away"})
+	assert.ErrorContains(t, err, "redirect to another host refused", "inline datasources")
+}
+
+func TestNewDatasourceRegistry(t *testing.T) {
+	tests := []struct {
+		name        string
+		datasources []config.DatasourceConfig
+		wantErr     string
+	}{
+		{name: "Valid", datasources: []config.DatasourceConfig{{Name: "vm", Type: "victoriametrics", URL: "http://vm:8428"}}},
+		{name: "MissingName", datasources: []config.DatasourceConfig{{URL: "http://vm:8428"}}, wantErr: "datasource name is required"},
+		{name: "Duplicate", datasources: []config.DatasourceConfig{{Name: "vm", URL: "http://a"}, {Name: "vm", URL: "http://b"}}, wantErr: "datasource 'vm' is defined more than once"},
+		{name: "MissingURL", datasources: []config.DatasourceConfig{{Name: "vm"}}, wantErr: "datasource 'vm': url is required"},
+		{name: "ConflictingAuth", datasources: []config.DatasourceConfig{{Name: "vm", URL: "http://a", BearerToken: "t", BasicAuth: &config.BasicAuthConfig{Username: "u"}}}, wantErr: "datasource 'vm': bearer token and basic_auth are mutually exclusive"},
+		{name: "MissingCA", datasources: []config.DatasourceConfig{{Name: "vm", URL: "https://a", TLS: config.TLSConfig{CAFile: "/nonexistent.pem"}}}, wantErr: "datasource 'vm': failed to read CA file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDatasourceRegistry(tt.datasources, nil)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestService_GetOptions_NamedDatasource(t *testing.T) {
+	var authorization string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"status":"success","data":["payments","orders"]}`))
+	}))
+	defer ts.Close()
+
+	registry, err := NewDatasourceRegistry([]config.DatasourceConfig{{Name: "vm-prod", Type: "victoriametrics", URL: ts.URL, BearerToken: "s3cr3t"}}, nil)
+	assert.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{
+		"datasource": {"name": "vm-prod"},
+		"properties": {"namespace": {"type": "string", "x-dynamic-options": {"type": "prometheus_query", "label": "namespace", "match": "up"}}}
+	}`, nil)
+	service := NewService(mockTP, nil, nil, WithDatasources(registry))
+
+	options, err := service.GetOptions(context.Background(), OptionsQuery{TemplateName: "k8s", FieldPath: "namespace", Values: FieldValues{}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"payments", "orders"}, options)
+	assert.Equal(t, "Bearer s3cr3t", authorization)
+
+	t.Run("UnknownDatasource", func(t *testing.T) {
+		service := NewService(mockTP, nil, nil)
+
+		_, err := service.GetOptions(context.Background(), OptionsQuery{TemplateName: "k8s", FieldPath: "namespace", Values: FieldValues{}})
+
+		assert.EqualError(t, err, "unknown datasource: vm-prod")
+	})
+}
+
+func TestDatasourceRegistry_Managed(t *testing.T) {
+	var lastRequest *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	registry, err := NewDatasourceRegistry([]config.DatasourceConfig{{Name: "static", Type: "prometheus", URL: ts.URL}}, store)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	err = registry.Create(ctx, &database.Datasource{
+		Name: "vm",
+		DatasourceSettings: database.DatasourceSettings{
+			Type:    "victoriametrics",
+			URL:     ts.URL + "/prod",
+			Auth:    &database.DatasourceAuth{BearerToken: "prod-token"},
+			Headers: map[string]string{"X-Api-Key": "prod-key"},
+		},
+		Environments: map[string]database.DatasourceSettings{
+			"staging": {URL: ts.URL + "/staging", Auth: &database.DatasourceAuth{Username: "vm", Password: "staging-secret"},
+				Headers: map[string]string{"X-Scope-OrgID": "7"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	query := func(environment string) {
+		t.Helper()
+		ds, err := registry.Resolve(ctx, &DatasourceConfig{Name: "vm", Environment: environment})
+		assert.NoError(t, err)
+		_, err = queryPrometheus(ctx, nil, ds, "/api/v1/query", url.Values{"query": {"up"}})
+		assert.NoError(t, err)
+	}
+
+	t.Run("EnvironmentOverrides", func(t *testing.T) {
+		query("")
+		assert.Equal(t, "/prod/api/v1/query", lastRequest.URL.Path)
+		assert.Equal(t, "Bearer prod-token", lastRequest.Header.Get("Authorization"))
+
+		query("staging")
+		assert.Equal(t, "/staging/api/v1/query", lastRequest.URL.Path)
+		_, password, _ := lastRequest.BasicAuth()
+		assert.Equal(t, "staging-secret", password)
+
+		query("unknown-env")
+		assert.Equal(t, "/prod/api/v1/query", lastRequest.URL.Path, "environments without overrides use the base settings")
+	})
+
+	t.Run("Redacted", func(t *testing.T) {
+		ds, err := registry.Get(ctx, "vm")
+		assert.NoError(t, err)
+		assert.Equal(t, RedactedSecret, ds.Auth.BearerToken)
+		assert.Equal(t, RedactedSecret, ds.Environments["staging"].Auth.Password)
+		assert.Equal(t, map[string]string{"X-Api-Key": RedactedSecret}, ds.Headers)
+		assert.Equal(t, map[string]string{"X-Scope-OrgID": RedactedSecret}, ds.Environments["staging"].Headers)
+
+		list, err := registry.List(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, "static", list[0].Name)
+		assert.True(t, list[0].ReadOnly)
+		assert.Equal(t, RedactedSecret, list[1].Auth.BearerToken)
+		assert.Equal(t, RedactedSecret, list[1].Headers["X-Api-Key"])
+	})
+
+	t.Run("UpdateKeepsRedactedSecrets", func(t *testing.T) {
+		ds, _ := registry.Get(ctx, "vm")
+		ds.URL = ts.URL + "/prod-v2"
+		assert.NoError(t, registry.Update(ctx, "vm", ds))
+
+		query("")
+		assert.Equal(t, "/prod-v2/api/v1/query", lastRequest.URL.Path, "updates invalidate resolved datasources")
+		assert.Equal(t, "Bearer prod-token", lastRequest.Header.Get("Authorization"))
+		assert.Equal(t, "prod-key", lastRequest.Header.Get("X-Api-Key"))
+
+		query("staging")
+		_, password, _ := lastRequest.BasicAuth()
+		assert.Equal(t, "staging-secret", password)
+		assert.Equal(t, "7", lastRequest.Header.Get("X-Scope-OrgID"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		err := registry.Create(ctx, &database.Datasource{Name: "../etc", DatasourceSettings: database.DatasourceSettings{URL: ts.URL}})
+		assert.ErrorContains(t, err, "invalid datasource name")
+
+		err = registry.Create(ctx, &database.Datasource{Name: "broken", DatasourceSettings: database.DatasourceSettings{URL: ts.URL},
+			Environments: map[string]database.DatasourceSettings{"dev": {Timeout: "soon"}}})
+		assert.ErrorContains(t, err, "environment 'dev': invalid timeout")
+
+		err = registry.Create(ctx, &database.Datasource{Name: "files", DatasourceSettings: database.DatasourceSettings{URL: ts.URL,
+			Auth: &database.DatasourceAuth{BearerTokenFile: "/etc/passwd"}}})
+		assert.ErrorContains(t, err, "auth.bearer_token_file")
+		err = registry.Create(ctx, &database.Datasource{Name: "files", DatasourceSettings: database.DatasourceSettings{URL: ts.URL},
+			Environments: map[string]database.DatasourceSettings{"dev": {TLS: &database.DatasourceTLS{KeyFile: "/etc/shadow"}}}})
+		assert.ErrorContains(t, err, "environment 'dev': tls.key_file")
+
+		assert.ErrorIs(t, registry.Create(ctx, &database.Datasource{Name: "vm", DatasourceSettings: database.DatasourceSettings{URL: ts.URL}}), database.ErrDatasourceExists)
+		assert.ErrorIs(t, registry.Create(ctx, &database.Datasource{Name: "static", DatasourceSettings: database.DatasourceSettings{URL: ts.URL}}), database.ErrDatasourceExists)
+	})
+
+	t.Run("StoredFileReferences", func(t *testing.T) {
+		// Entries stored before file references were rejected are not resolved
+		assert.NoError(t, store.CreateDatasource(ctx, &database.Datasource{Name: "legacy", DatasourceSettings: database.DatasourceSettings{URL: ts.URL,
+			Auth: &database.DatasourceAuth{Username: "vm", PasswordFile: "/etc/passwd"}}}))
+		_, err := registry.Resolve(ctx, &DatasourceConfig{Name: "legacy"})
+		assert.ErrorContains(t, err, "auth.password_file")
+	})
+
+	t.Run("ConfigDatasourcesAreReadOnly", func(t *testing.T) {
+		assert.ErrorIs(t, registry.Update(ctx, "static", &database.Datasource{}), ErrDatasourceReadOnly)
+		assert.ErrorIs(t, registry.Delete(ctx, "static"), ErrDatasourceReadOnly)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, registry.Delete(ctx, "vm"))
+
+		_, err := registry.Resolve(ctx, &DatasourceConfig{Name: "vm"})
+		assert.EqualError(t, err, "unknown datasource: vm")
+		assert.ErrorIs(t, registry.Delete(ctx, "vm"), database.ErrDatasourceNotFound)
+	})
+}
+
+func TestDatasourceRegistry_Health(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthy/api/v1/status/buildinfo":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"version":"2.53.0"}}`))
+		case "/healthy/api/v1/labels", "/degraded/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__","job"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	registry, err := NewDatasourceRegistry([]config.DatasourceConfig{
+		{Name: "healthy", Type: "prometheus", URL: ts.URL + "/healthy"},
+		{Name: "degraded", Type: "victoriametrics", URL: ts.URL + "/degraded"},
+		{Name: "unhealthy", Type: "prometheus", URL: ts.URL + "/down"},
+	}, nil)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	health, err := registry.Health(ctx, "healthy", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DatasourceHealthy, health.Status)
+	assert.Equal(t, "2.53.0", health.Version)
+	assert.Len(t, health.Checks, 2)
+	assert.Equal(t, "/api/v1/status/buildinfo", health.Checks[0].Endpoint)
+
+	health, err = registry.Health(ctx, "degraded", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DatasourceDegraded, health.Status)
+	assert.Equal(t, "datasource returned status 404", health.Checks[0].Message)
+
+	health, err = registry.Health(ctx, "unhealthy", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DatasourceUnhealthy, health.Status)
+
+	_, err = registry.Health(ctx, "missing", "")
+	assert.ErrorIs(t, err, database.ErrDatasourceNotFound)
+}
+
+func TestService_RunPipelines_DatasourceEnvironment(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1,"1"]}]}}`))
+	}))
+	defer ts.Close()
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	registry, err := NewDatasourceRegistry(nil, store)
+	assert.NoError(t, err)
+	assert.NoError(t, registry.Create(context.Background(), &database.Datasource{
+		Name:               "vm",
+		DatasourceSettings: database.DatasourceSettings{Type: "victoriametrics", URL: ts.URL + "/prod"},
+		Environments:       map[string]database.DatasourceSettings{"staging": {URL: ts.URL + "/staging"}},
+	}))
+
+	schema := func(name string) string {
+		return `{
+			"datasource": {"name": "` + name + `", "environment": "{{ .target.environment }}"},
+			"pipelines": [{"name": "up_exists", "type": "validate_metric_exists", "parameters": {"metric_name": "up"}}]
+		}`
+	}
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "known").Return(schema("vm"), nil)
+	mockTP.On("GetSchema", mock.Anything, "unknown").Return(schema("missing"), nil)
+	mockValidator := new(MockSchemaValidator)
+	mockValidator.On("Validate", mock.Anything, mock.Anything).Return(nil)
+	service := NewService(mockTP, nil, mockValidator, WithDatasources(registry))
+
+	report, err := service.ValidateRule(context.Background(), "known", json.RawMessage(`{"target": {"environment": "staging"}}`))
+	assert.NoError(t, err)
+	assert.True(t, report.Passed())
+	assert.Equal(t, []string{"/staging/api/v1/query"}, paths)
+
+	report, err = service.ValidateRule(context.Background(), "unknown", json.RawMessage(`{"target": {"environment": "staging"}}`))
+	assert.Error(t, err)
+	assert.Equal(t, "unknown datasource: missing", report.Steps[0].Message, "unresolvable datasources fail the steps that query them")
+}
@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -21,16 +24,69 @@ type (
 )
 
 // DynamicOptionsConfig represents the x-dynamic-options configuration in a schema field.
-// Uses Prometheus API structure directly - no parsing needed.
+// Type selects the OptionsProvider; the remaining fields are provider specific. String settings
+// are Go templates rendered with the current form values, which is how options depend on other fields.
 type DynamicOptionsConfig struct {
-	Type         string   `json:"type"`
-	Label        string   `json:"label"` // The Prometheus label to query values for
-	Match        string   `json:"match"` // The match[] selector (can include filters and templates)
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"` // prometheus_query, prometheus_series: the label whose values are offered
+	Match string `json:"match,omitempty"` // prometheus_query: the match[] selector (can include filters and templates)
+	Query string `json:"query,omitempty"` // prometheus_series: instant query whose result series carry the label
+	URL   string `json:"url,omitempty"`   // static_http: JSON endpoint
+	Path  string `json:"path,omitempty"`  // static_http: JSONPath selecting the values, e.g. $.items[*].name
+	// Field is the dot-separated parameter path read from existing rules (rules_store).
+	Field string `json:"field,omitempty"`
+	// Filter restricts rules_store to rules whose parameters match, e.g. {"target.namespace": "{{.target.namespace}}"}.
+	Filter map[string]string `json:"filter,omitempty"`
+	// Template names another template to read from (rules_store, enum_from_schema). Defaults to the current one.
+	Template string `json:"template,omitempty"`
+	// Source locates the enum for enum_from_schema: a dot-separated field path or a JSON pointer such as #/$defs/tiers.
+	Source string `json:"source,omitempty"`
+	// Dependencies lists the fields the options depend on. Until all of them have a value no options are offered.
 	Dependencies []string `json:"dependencies,omitempty"`
+
+	// Lookback restricts prometheus_query to series seen within this Go duration (e.g. "1h") instead of the whole retention.
+	Lookback string `json:"lookback,omitempty"`
+	// Limit caps the number of options returned; 0 returns all of them.
+	Limit int `json:"limit,omitempty"`
+	// Sort orders the options: "asc", "desc" or "" to keep the provider's order.
+	Sort string `json:"sort,omitempty"`
+	// SearchMode selects how the search term of a request matches options: "substring" (default) or "prefix".
+	// Matching is case-insensitive.
+	SearchMode string `json:"search_mode,omitempty"`
+	// CacheTTL overrides how long the options are reused across requests (Go duration, "0s" disables caching).
+	CacheTTL string `json:"cache_ttl,omitempty"`
+}
+
+// Sort orders and search modes of dynamic options.
+const (
+	OptionsSortAsc         = "asc"
+	OptionsSortDesc        = "desc"
+	OptionsSearchSubstring = "substring"
+	OptionsSearchPrefix    = "prefix"
+)
+
+// OptionsQuery identifies a field whose dynamic options are requested, together with the form state
+// its settings are rendered with.
+type OptionsQuery struct {
+	TemplateName string
+	// FieldPath addresses the field, e.g. target.namespace, rules[0].service_name or
+	// rules[rule_type=service_up].service_name (see resolveField).
+	FieldPath string
+	// Values are the current values of the whole form.
+	Values FieldValues
+	// Item are the values of the array item the field belongs to. When nil, the item is taken from
+	// Values if the path addresses it by index.
+	Item FieldValues
+	// Search restricts the options to those matching the term, according to the field's search mode.
+	Search string
 }
 
-// GetOptions resolves dynamic options for a specific field in a template.
-func (s *Service) GetOptions(ctx context.Context, templateName string, fieldPath string, currentValues FieldValues) ([]string, error) {
+// GetOptions resolves dynamic options for a specific field in a template. Fields inside array items
+// render their settings with the item's values merged over the root values, so both {{.target.namespace}}
+// and an item field such as {{.rule_type}} are available.
+func (s *Service) GetOptions(ctx context.Context, query OptionsQuery) ([]string, error) {
+	templateName, fieldPath, search := query.TemplateName, query.FieldPath, query.Search
+
 	// 1. Get Schema
 	schemaStr, err := s.templateProvider.GetSchema(ctx, templateName)
 	if err != nil {
@@ -38,30 +94,118 @@ func (s *Service) GetOptions(ctx context.Context, templateName string, fieldPath
 	}
 
 	// 2. Parse schema and extract dynamic options for the field
-	dynamicOpts, err := extractDynamicOptions(schemaStr, fieldPath)
+	dynamicOpts, item, err := extractDynamicOptions(schemaStr, fieldPath, query.Values)
 	if err != nil {
 		return nil, err
 	}
+	if query.Item != nil {
+		item = query.Item
+	}
+	currentValues := query.Values
+	if item != nil {
+		currentValues = maps.Clone(query.Values)
+		if currentValues == nil {
+			currentValues = FieldValues{}
+		}
+		for k, v := range item {
+			currentValues[k] = v
+		}
+	}
 
-	if dynamicOpts.Type != "prometheus_query" {
+	provider, ok := s.optionsProviders[dynamicOpts.Type]
+	if !ok {
 		return nil, fmt.Errorf("unsupported dynamic options type: %s", dynamicOpts.Type)
 	}
-
-	if dynamicOpts.Label == "" {
-		return nil, fmt.Errorf("label is empty")
+	if err := validateOptionsConfig(dynamicOpts); err != nil {
+		return nil, err
+	}
+	cacheTTL := s.optionsCacheTTL
+	if dynamicOpts.CacheTTL != "" {
+		if cacheTTL, err = time.ParseDuration(dynamicOpts.CacheTTL); err != nil {
+			return nil, fmt.Errorf("invalid cache_ttl: %w", err)
+		}
 	}
 
-	if dynamicOpts.Match == "" {
-		return nil, fmt.Errorf("match is empty")
+	// 3. Options of dependent fields are empty until the fields they depend on are filled in
+	for _, dep := range dynamicOpts.Dependencies {
+		if !hasFieldValue(currentValues, dep) {
+			return []string{}, nil
+		}
 	}
 
-	// 3. Substitute variables in the match[] using Go templates
-	match, err := substituteVariables(dynamicOpts.Match, currentValues)
+	input := &OptionsInput{
+		TemplateName: templateName,
+		FieldPath:    fieldPath,
+		Config:       dynamicOpts,
+		Schema:       schemaStr,
+		Values:       currentValues,
+		Datasource: func() (*DatasourceConfig, error) {
+			return s.optionsDatasource(ctx, schemaStr, currentValues)
+		},
+		cache:    s.optionsCache,
+		cacheTTL: cacheTTL,
+	}
+	// Without a search term and with the provider's ascending order, the datasource can apply the limit itself
+	if search == "" && dynamicOpts.Sort != OptionsSortDesc {
+		input.Limit = dynamicOpts.Limit
+	}
+	options, err := provider.Options(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to substitute variables in match: %w", err)
+		return nil, err
+	}
+
+	// 4. Search, sort and limit. Provider results may be shared through the cache, so they are copied first.
+	return refineOptions(options, dynamicOpts, search), nil
+}
+
+// validateOptionsConfig checks the settings shared by all providers.
+func validateOptionsConfig(cfg *DynamicOptionsConfig) error {
+	switch cfg.Sort {
+	case "", OptionsSortAsc, OptionsSortDesc:
+	default:
+		return fmt.Errorf("invalid sort '%s': use asc or desc", cfg.Sort)
+	}
+	switch cfg.SearchMode {
+	case "", OptionsSearchSubstring, OptionsSearchPrefix:
+	default:
+		return fmt.Errorf("invalid search_mode '%s': use substring or prefix", cfg.SearchMode)
+	}
+	if cfg.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	return nil
+}
+
+// refineOptions applies the search term, sort order and limit of a field to resolved options.
+func refineOptions(options []string, cfg *DynamicOptionsConfig, search string) []string {
+	out := make([]string, 0, len(options))
+	search = strings.ToLower(search)
+	for _, option := range options {
+		value := strings.ToLower(option)
+		switch {
+		case search == "":
+		case cfg.SearchMode == OptionsSearchPrefix && !strings.HasPrefix(value, search):
+			continue
+		case cfg.SearchMode != OptionsSearchPrefix && !strings.Contains(value, search):
+			continue
+		}
+		out = append(out, option)
 	}
 
-	// 4. Get datasource configuration
+	switch cfg.Sort {
+	case OptionsSortAsc:
+		sort.Strings(out)
+	case OptionsSortDesc:
+		sort.Sort(sort.Reverse(sort.StringSlice(out)))
+	}
+	if cfg.Limit > 0 && len(out) > cfg.Limit {
+		out = out[:cfg.Limit]
+	}
+	return out
+}
+
+// optionsDatasource resolves the datasource of a template schema for an options query.
+func (s *Service) optionsDatasource(ctx context.Context, schemaStr string, currentValues FieldValues) (*DatasourceConfig, error) {
 	var schemaObj struct {
 		Datasource *DatasourceConfig `json:"datasource"`
 	}
@@ -72,66 +216,258 @@ func (s *Service) GetOptions(ctx context.Context, templateName string, fieldPath
 	if schemaObj.Datasource == nil {
 		return nil, fmt.Errorf("datasource not configured in template")
 	}
+	values, err := json.Marshal(currentValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process current values: %w", err)
+	}
+	return s.resolveDatasource(ctx, schemaObj.Datasource, values)
+}
 
-	// 5. Query Prometheus directly with label and match[] (no parsing needed!)
-	return s.queryLabelValues(ctx, schemaObj.Datasource, match, dynamicOpts.Label)
+// hasFieldValue reports whether the dot-separated path holds a non-empty value.
+func hasFieldValue(values FieldValues, path string) bool {
+	var cursor interface{} = map[string]interface{}(values)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cursor.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		cursor = m[part]
+	}
+	switch v := cursor.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	default:
+		return true
+	}
 }
 
 // extractDynamicOptions extracts the x-dynamic-options configuration for a specific field path.
-func extractDynamicOptions(schemaStr string, fieldPath string) (*DynamicOptionsConfig, error) {
+// It also returns the values of the array item the path addresses by index, if any.
+func extractDynamicOptions(schemaStr string, fieldPath string, values FieldValues) (*DynamicOptionsConfig, FieldValues, error) {
 	var schema SchemaNode
 	if err := json.Unmarshal([]byte(schemaStr), &schema); err != nil {
-		return nil, fmt.Errorf("failed to parse schema: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse schema: %w", err)
 	}
 
-	fieldDef, err := navigateToField(schema, fieldPath)
+	fieldDef, item, err := resolveField(schema, fieldPath, values)
 	if err != nil {
-		return nil, fmt.Errorf("field '%s' not found in schema: %w", fieldPath, err)
+		return nil, nil, fmt.Errorf("field '%s' not found in schema: %w", fieldPath, err)
 	}
 
 	// Extract x-dynamic-options from the field definition
 	dynOptsRaw, ok := fieldDef["x-dynamic-options"]
 	if !ok {
-		return nil, fmt.Errorf("field '%s' does not have dynamic options configured", fieldPath)
+		return nil, nil, fmt.Errorf("field '%s' does not have dynamic options configured", fieldPath)
 	}
 
 	// Marshal and unmarshal to convert to our struct
 	dynOptsBytes, err := json.Marshal(dynOptsRaw)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process dynamic options: %w", err)
+		return nil, nil, fmt.Errorf("failed to process dynamic options: %w", err)
 	}
 
 	var dynOpts DynamicOptionsConfig
 	if err := json.Unmarshal(dynOptsBytes, &dynOpts); err != nil {
-		return nil, fmt.Errorf("failed to parse dynamic options: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse dynamic options: %w", err)
 	}
 
-	return &dynOpts, nil
+	return &dynOpts, item, nil
 }
 
-// navigateToField traverses a JSON schema to find a field definition by dot-separated path.
+// navigateToField traverses a JSON schema to find a field definition by path (see resolveField).
 func navigateToField(schema SchemaNode, path string) (SchemaNode, error) {
+	field, _, err := resolveField(schema, path, nil)
+	return field, err
+}
+
+// fieldPathSegment is a property name, optionally followed by an array item selector.
+type fieldPathSegment struct {
+	name     string
+	selector string // "" (none), "[]" (any item), "[n]" (index) or "[key=value]" (oneOf branch)
+	index    int    // for index selectors
+	key      string // for branch selectors
+	value    string
+}
+
+// parseFieldPath splits a field path such as rules[rule_type=service_up].service_name into segments.
+func parseFieldPath(path string) ([]fieldPathSegment, error) {
 	if path == "" {
 		return nil, fmt.Errorf("empty field path")
 	}
+	var segments []fieldPathSegment
+	for _, part := range strings.Split(path, ".") {
+		seg := fieldPathSegment{name: part}
+		if open := strings.Index(part, "["); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("invalid selector in '%s'", part)
+			}
+			seg.name = part[:open]
+			seg.selector = part[open:]
+			inner := part[open+1 : len(part)-1]
+			if key, value, ok := strings.Cut(inner, "="); ok {
+				if key == "" {
+					return nil, fmt.Errorf("invalid selector in '%s'", part)
+				}
+				seg.key, seg.value = key, value
+			} else if inner != "" {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid selector in '%s'", part)
+				}
+				seg.index = index
+			}
+		}
+		if seg.name == "" {
+			return nil, fmt.Errorf("empty field name in path '%s'", path)
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil
+}
 
-	parts := strings.Split(path, ".")
-	cursor := schema
+// resolveField traverses a JSON schema to find a field definition. Path segments are property names
+// separated by dots. A segment naming an array may select its items:
+//   - rules[].severity addresses the item schema;
+//   - rules[2].service_name addresses item 2 of values, whose discriminator values choose the oneOf branch;
+//   - rules[rule_type=service_up].service_name addresses the oneOf branch whose rule_type is the const service_up.
+//
+// Without a selector, a segment after an array descends into its items, and a segment in an object
+// with oneOf/anyOf branches picks the branch matching values or else the first branch declaring the
+// property. The values of the item addressed by index are returned alongside the field.
+func resolveField(schema SchemaNode, path string, values FieldValues) (SchemaNode, FieldValues, error) {
+	segments, err := parseFieldPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for i, part := range parts {
-		props, ok := cursor["properties"].(map[string]interface{})
+	cursor := map[string]interface{}(schema)
+	var value interface{} = map[string]interface{}(values)
+	var item FieldValues
+	for i, seg := range segments {
+		current, _ := value.(map[string]interface{})
+		props, err := objectProperties(cursor, seg.name, current)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w at level %d (path: %s)", err, i, joinSegments(segments[:i+1]))
+		}
+		next, ok := props[seg.name].(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("no 'properties' field at level %d (path: %s)", i, strings.Join(parts[:i+1], "."))
+			return nil, nil, fmt.Errorf("field '%s' not found at level %d", seg.name, i)
+		}
+		cursor = next
+		value = current[seg.name]
+		if seg.selector == "" {
+			continue
 		}
 
-		next, ok := props[part].(map[string]interface{})
+		items, ok := cursor["items"].(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("field '%s' not found at level %d", part, i)
+			return nil, nil, fmt.Errorf("field '%s' is not an array", seg.name)
 		}
-		cursor = next
+		cursor = items
+		switch {
+		case seg.key != "":
+			value = nil
+			if cursor, err = selectBranch(cursor, seg.key, seg.value); err != nil {
+				return nil, nil, fmt.Errorf("field '%s': %w", seg.name, err)
+			}
+		case seg.selector == "[]":
+			value = nil
+		default:
+			arr, _ := value.([]interface{})
+			value = nil
+			if seg.index < len(arr) {
+				value = arr[seg.index]
+			}
+			current, _ := value.(map[string]interface{})
+			item = current
+		}
+	}
+	return cursor, item, nil
+}
+
+// objectProperties returns the properties of an object schema in which name is looked up,
+// descending into array items and choosing among oneOf/anyOf branches.
+func objectProperties(node map[string]interface{}, name string, values map[string]interface{}) (map[string]interface{}, error) {
+	if props, ok := node["properties"].(map[string]interface{}); ok {
+		return props, nil
+	}
+	if items, ok := node["items"].(map[string]interface{}); ok {
+		return objectProperties(items, name, nil)
+	}
+
+	branches := schemaBranches(node)
+	if branches == nil {
+		return nil, fmt.Errorf("no 'properties' field")
+	}
+	var fallback map[string]interface{}
+	for _, branch := range branches {
+		props, ok := branch["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if values != nil && branchMatches(props, values) {
+			return props, nil
+		}
+		if _, ok := props[name]; ok && fallback == nil {
+			fallback = props
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("no branch declares '%s'", name)
+	}
+	return fallback, nil
+}
+
+// selectBranch returns the oneOf/anyOf branch whose key property is the const value.
+func selectBranch(node map[string]interface{}, key, value string) (map[string]interface{}, error) {
+	for _, branch := range schemaBranches(node) {
+		props, _ := branch["properties"].(map[string]interface{})
+		if prop, ok := props[key].(map[string]interface{}); ok && prop["const"] != nil && fmt.Sprint(prop["const"]) == value {
+			return branch, nil
+		}
+	}
+	return nil, fmt.Errorf("no branch with %s=%s", key, value)
+}
+
+// branchMatches reports whether values satisfy every const property of a branch and the branch has at least one.
+func branchMatches(props map[string]interface{}, values map[string]interface{}) bool {
+	matched := false
+	for key, def := range props {
+		prop, ok := def.(map[string]interface{})
+		if !ok || prop["const"] == nil {
+			continue
+		}
+		if fmt.Sprint(values[key]) != fmt.Sprint(prop["const"]) {
+			return false
+		}
+		matched = true
 	}
+	return matched
+}
 
-	return cursor, nil
+// schemaBranches returns the oneOf, or else anyOf, branches of a schema node.
+func schemaBranches(node map[string]interface{}) []map[string]interface{} {
+	raw, ok := node["oneOf"].([]interface{})
+	if !ok {
+		raw, _ = node["anyOf"].([]interface{})
+	}
+	var branches []map[string]interface{}
+	for _, b := range raw {
+		if branch, ok := b.(map[string]interface{}); ok {
+			branches = append(branches, branch)
+		}
+	}
+	return branches
+}
+
+func joinSegments(segments []fieldPathSegment) string {
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = seg.name + seg.selector
+	}
+	return strings.Join(parts, ".")
 }
 
 // substituteVariables uses Go's text/template to replace template variables.
@@ -156,32 +492,18 @@ type PrometheusLabelValuesResponse struct {
 	Data   []string `json:"data"`
 }
 
-// queryLabelValues queries Prometheus for label values using the metadata API.
-func (s *Service) queryLabelValues(ctx context.Context, datasource *DatasourceConfig, match string, label string) ([]string, error) {
-	u, err := url.Parse(datasource.URL)
+// queryLabelValues queries Prometheus for label values using the metadata API. params carries match[]
+// and the optional start, end and limit.
+func queryLabelValues(ctx context.Context, datasource *DatasourceConfig, label string, params url.Values) ([]string, error) {
+	path := fmt.Sprintf("/api/v1/label/%s/values", url.PathEscape(label))
+	resp, err := datasource.get(ctx, nil, path, params)
 	if err != nil {
-		return nil, fmt.Errorf("invalid datasource URL: %w", err)
-	}
-
-	u.Path = fmt.Sprintf("/api/v1/label/%s/values", url.PathEscape(label))
-	q := u.Query()
-	q.Set("match[]", match)
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query datasource: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("datasource returned status %d for URL %s", resp.StatusCode, u.String())
+		return nil, fmt.Errorf("datasource returned status %d for URL %s", resp.StatusCode, resp.Request.URL.Redacted())
 	}
 
 	var result PrometheusLabelValuesResponse
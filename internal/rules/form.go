@@ -0,0 +1,412 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Form widgets suggested for fields of a FormModel.
+const (
+	WidgetText          = "text"
+	WidgetNumber        = "number"
+	WidgetCheckbox      = "checkbox"
+	WidgetSelect        = "select"
+	WidgetMultiSelect   = "multiselect"
+	WidgetDynamicSelect = "dynamic_select"
+	WidgetKeyValue      = "key_value" // object of free-form string pairs, e.g. labels
+	WidgetGroup         = "group"     // object with fixed fields
+	WidgetList          = "list"      // array of items, each rendered with the child fields
+	WidgetHidden        = "hidden"    // const value, set by the form itself
+)
+
+// FormModel is a UI-ready description of the form for a template, derived from its JSON schema.
+type FormModel struct {
+	Template    string       `json:"template"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Fields      []*FormField `json:"fields"`
+	// Dependencies lists, for every field with dynamic options depending on others, the fields whose
+	// changes require its options to be reloaded.
+	Dependencies []FormDependency `json:"dependencies"`
+}
+
+// FormField describes one input of a form. Fields appear in schema order.
+type FormField struct {
+	// Path addresses the field in the options endpoint (e.g. target.namespace, rules[].rule_type,
+	// rules[rule_type=service_up].service_name).
+	Path        string          `json:"path"`
+	Name        string          `json:"name"`
+	Label       string          `json:"label"`
+	Description string          `json:"description,omitempty"`
+	Type        string          `json:"type"`
+	Widget      string          `json:"widget"`
+	Required    bool            `json:"required"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Const       json.RawMessage `json:"const,omitempty"`
+	Choices     []FormChoice    `json:"choices,omitempty"`
+	Minimum     *float64        `json:"minimum,omitempty"`
+	Maximum     *float64        `json:"maximum,omitempty"`
+	Pattern     string          `json:"pattern,omitempty"`
+	// DynamicOptions is set for dynamic_select fields, whose choices come from the options endpoint.
+	DynamicOptions *DynamicOptionsConfig `json:"dynamic_options,omitempty"`
+	// DependsOn lists the paths of the fields the dynamic options depend on.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// VisibleWhen restricts the field to some values of a discriminator, e.g. the rule_type of a rule item.
+	VisibleWhen *FormCondition `json:"visible_when,omitempty"`
+	// Fields are the children of group fields and the item fields of list fields.
+	Fields []*FormField `json:"fields,omitempty"`
+}
+
+// FormChoice is an allowed value of a select field.
+type FormChoice struct {
+	Value interface{} `json:"value"`
+	Label string      `json:"label"`
+}
+
+// FormCondition makes a field visible only while the sibling Field has one of the values In.
+type FormCondition struct {
+	Field string   `json:"field"`
+	In    []string `json:"in"`
+}
+
+// FormDependency is an edge of the dependency graph between fields.
+type FormDependency struct {
+	Field     string   `json:"field"`
+	DependsOn []string `json:"depends_on"`
+}
+
+// BuildFormModel resolves a template schema into a form model: fields in schema order with widgets,
+// labels and constraints, rule types of oneOf items as a discriminator controlling field visibility,
+// and the dependency graph of dynamic options.
+func BuildFormModel(templateName, schemaStr string) (*FormModel, error) {
+	var root formSchema
+	if err := json.Unmarshal([]byte(schemaStr), &root); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	model := &FormModel{
+		Template:     templateName,
+		Title:        root.Title,
+		Description:  root.Description,
+		Dependencies: []FormDependency{},
+	}
+	model.Fields = objectFields(&root, "", nil)
+
+	var collect func(fields []*FormField)
+	collect = func(fields []*FormField) {
+		for _, f := range fields {
+			if len(f.DependsOn) > 0 {
+				model.Dependencies = append(model.Dependencies, FormDependency{Field: f.Path, DependsOn: f.DependsOn})
+			}
+			collect(f.Fields)
+		}
+	}
+	collect(model.Fields)
+	return model, nil
+}
+
+// objectFields builds the fields of an object schema. prefix is the path of the object ("" for the
+// root); siblings are the paths of fields in the same array item, which item-relative dependencies refer to.
+func objectFields(node *formSchema, prefix string, siblings map[string]string) []*FormField {
+	fields := make([]*FormField, 0, len(node.Properties.keys))
+	for _, name := range node.Properties.keys {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		fields = append(fields, buildField(name, path, node.Properties.values[name], slices.Contains(node.Required, name), siblings))
+	}
+	return fields
+}
+
+func buildField(name, path string, node *formSchema, required bool, siblings map[string]string) *FormField {
+	field := &FormField{
+		Path:        path,
+		Name:        name,
+		Label:       node.Title,
+		Description: node.Description,
+		Type:        node.typeName(),
+		Required:    required,
+		Default:     node.Default,
+		Const:       node.Const,
+		Minimum:     node.Minimum,
+		Maximum:     node.Maximum,
+		Pattern:     node.Pattern,
+	}
+	if field.Label == "" {
+		field.Label = humanize(name)
+	}
+
+	switch {
+	case len(node.Const) > 0:
+		field.Widget = WidgetHidden
+	case node.DynamicOptions != nil:
+		field.Widget = WidgetDynamicSelect
+		field.DynamicOptions = node.DynamicOptions
+		for _, dep := range node.DynamicOptions.Dependencies {
+			if sibling, ok := siblings[dep]; ok {
+				dep = sibling
+			}
+			field.DependsOn = append(field.DependsOn, dep)
+		}
+	case len(node.Enum) > 0:
+		field.Widget = WidgetSelect
+		field.Choices = enumChoices(node.Enum)
+	case field.Type == "boolean":
+		field.Widget = WidgetCheckbox
+	case field.Type == "number" || field.Type == "integer":
+		field.Widget = WidgetNumber
+	case field.Type == "object" && len(node.Properties.keys) > 0:
+		field.Widget = WidgetGroup
+		field.Fields = objectFields(node, path, siblings)
+	case field.Type == "object":
+		field.Widget = WidgetKeyValue
+	case field.Type == "array" && node.Items != nil:
+		field.Widget = WidgetList
+		field.Fields = itemFields(node.Items, path)
+		if len(field.Fields) == 1 && len(field.Fields[0].Choices) > 0 && len(node.Items.Properties.keys) == 0 {
+			field.Widget = WidgetMultiSelect
+			field.Choices = field.Fields[0].Choices
+			field.Fields = nil
+		}
+	default:
+		field.Widget = WidgetText
+	}
+	return field
+}
+
+// itemFields builds the fields of array items. Items with oneOf/anyOf branches get a select for the
+// discriminator (the property that is a const in every branch, e.g. rule_type), followed by the fields
+// of all branches, each visible only for the branches declaring it. Fields declared identically by
+// several branches appear once.
+func itemFields(items *formSchema, arrayPath string) []*FormField {
+	branches := items.OneOf
+	if len(branches) == 0 {
+		branches = items.AnyOf
+	}
+	if len(branches) == 0 {
+		if len(items.Properties.keys) == 0 {
+			// Array of scalars: a single field describes each item
+			return []*FormField{buildField("item", arrayPath+"[]", items, true, nil)}
+		}
+		return objectFields(items, arrayPath+"[]", itemSiblings(items, arrayPath+"[]"))
+	}
+
+	discriminator := branchDiscriminator(branches)
+	if discriminator == "" {
+		// Without a discriminator the branches cannot be told apart; offer the fields of the first one
+		return objectFields(branches[0], arrayPath+"[]", itemSiblings(branches[0], arrayPath+"[]"))
+	}
+
+	selector := &FormField{
+		Path:     arrayPath + "[]." + discriminator,
+		Name:     discriminator,
+		Label:    humanize(discriminator),
+		Type:     "string",
+		Widget:   WidgetSelect,
+		Required: true,
+	}
+	fields := []*FormField{selector}
+
+	type variant struct {
+		field  *FormField
+		node   *formSchema
+		values []string
+	}
+	var variants []*variant
+	byName := make(map[string][]*variant)
+	for _, branch := range branches {
+		value := branch.Properties.values[discriminator].constString()
+		label := branch.Title
+		if label == "" {
+			label = branch.Properties.values[discriminator].Description
+		}
+		if label == "" {
+			label = value
+		}
+		selector.Choices = append(selector.Choices, FormChoice{Value: value, Label: label})
+
+		branchPath := fmt.Sprintf("%s[%s=%s]", arrayPath, discriminator, value)
+		siblings := itemSiblings(branch, arrayPath+"[]")
+		for _, name := range branch.Properties.keys {
+			if name == discriminator {
+				continue
+			}
+			node := branch.Properties.values[name]
+			required := slices.Contains(branch.Required, name)
+
+			var shared *variant
+			for _, v := range byName[name] {
+				if reflect.DeepEqual(v.node, node) && v.field.Required == required {
+					shared = v
+					break
+				}
+			}
+			if shared != nil {
+				shared.values = append(shared.values, value)
+				continue
+			}
+			v := &variant{field: buildField(name, branchPath+"."+name, node, required, siblings), node: node, values: []string{value}}
+			variants = append(variants, v)
+			byName[name] = append(byName[name], v)
+		}
+	}
+
+	for _, v := range variants {
+		if len(v.values) > 1 {
+			// Shared by several branches: address it without a branch selector
+			repath(v.field, arrayPath+"[]."+v.field.Name)
+		}
+		v.field.VisibleWhen = &FormCondition{Field: selector.Path, In: v.values}
+		fields = append(fields, v.field)
+	}
+	return fields
+}
+
+// itemSiblings maps the property names of an item to their paths, so item-relative dependencies
+// such as "tier" can be expressed as paths.
+func itemSiblings(item *formSchema, itemPath string) map[string]string {
+	siblings := make(map[string]string, len(item.Properties.keys))
+	for _, name := range item.Properties.keys {
+		siblings[name] = itemPath + "." + name
+	}
+	return siblings
+}
+
+// repath moves a field and its children to a new path.
+func repath(field *FormField, path string) {
+	old := field.Path
+	field.Path = path
+	for _, child := range field.Fields {
+		repath(child, path+strings.TrimPrefix(child.Path, old))
+	}
+}
+
+// branchDiscriminator returns the property that is a const in every branch, preferring rule_type.
+func branchDiscriminator(branches []*formSchema) string {
+	isConst := func(name string) bool {
+		for _, b := range branches {
+			prop, ok := b.Properties.values[name]
+			if !ok || len(prop.Const) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if isConst("rule_type") {
+		return "rule_type"
+	}
+	for _, name := range branches[0].Properties.keys {
+		if isConst(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+func enumChoices(values []interface{}) []FormChoice {
+	choices := make([]FormChoice, 0, len(values))
+	for _, v := range values {
+		choices = append(choices, FormChoice{Value: v, Label: fmt.Sprint(v)})
+	}
+	return choices
+}
+
+// humanize turns a property name such as service_name into a label ("Service name").
+func humanize(name string) string {
+	label := strings.TrimSpace(strings.NewReplacer("_", " ", "-", " ").Replace(name))
+	if label == "" {
+		return name
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// formSchema is the subset of a JSON schema node the form model is built from.
+type formSchema struct {
+	Type           json.RawMessage       `json:"type"`
+	Title          string                `json:"title"`
+	Description    string                `json:"description"`
+	Default        json.RawMessage       `json:"default"`
+	Const          json.RawMessage       `json:"const"`
+	Enum           []interface{}         `json:"enum"`
+	Minimum        *float64              `json:"minimum"`
+	Maximum        *float64              `json:"maximum"`
+	Pattern        string                `json:"pattern"`
+	Properties     formProperties        `json:"properties"`
+	Required       []string              `json:"required"`
+	Items          *formSchema           `json:"items"`
+	OneOf          []*formSchema         `json:"oneOf"`
+	AnyOf          []*formSchema         `json:"anyOf"`
+	DynamicOptions *DynamicOptionsConfig `json:"x-dynamic-options"`
+}
+
+// typeName returns the JSON type of the node. For a list of types the first non-null one is used;
+// without a type, it is inferred from the other keywords.
+func (n *formSchema) typeName() string {
+	var name string
+	if err := json.Unmarshal(n.Type, &name); err != nil {
+		var names []string
+		_ = json.Unmarshal(n.Type, &names)
+		for _, t := range names {
+			if t != "null" {
+				name = t
+				break
+			}
+		}
+	}
+	switch {
+	case name != "":
+		return name
+	case len(n.Properties.keys) > 0:
+		return "object"
+	case n.Items != nil:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// constString returns the const value of the node as a string.
+func (n *formSchema) constString() string {
+	if n == nil {
+		return ""
+	}
+	var v interface{}
+	_ = json.Unmarshal(n.Const, &v)
+	return fmt.Sprint(v)
+}
+
+// formProperties keeps the properties of a schema node in document order, which is the field order of the form.
+type formProperties struct {
+	keys   []string
+	values map[string]*formSchema
+}
+
+func (p *formProperties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("properties must be an object")
+	}
+	p.values = make(map[string]*formSchema)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		var node formSchema
+		if err := dec.Decode(&node); err != nil {
+			return fmt.Errorf("property '%s': %w", key, err)
+		}
+		if _, ok := p.values[key]; !ok {
+			p.keys = append(p.keys, key)
+		}
+		p.values[key] = &node
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildFormModel_K8s(t *testing.T) {
+	raw, err := os.ReadFile("../../templates/_base/k8s.json")
+	assert.NoError(t, err)
+
+	model, err := BuildFormModel("k8s", string(raw))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "K8s Monitoring Rule", model.Title)
+	var names []string
+	for _, f := range model.Fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"target", "common", "rules"}, names, "fields keep the schema order")
+
+	target := model.Fields[0]
+	assert.Equal(t, WidgetGroup, target.Widget)
+	assert.True(t, target.Required)
+	workload := target.Fields[2]
+	assert.Equal(t, "target.workload", workload.Path)
+	assert.Equal(t, WidgetDynamicSelect, workload.Widget)
+	assert.Equal(t, []string{"target.namespace"}, workload.DependsOn)
+
+	common := model.Fields[1]
+	assert.JSONEq(t, `"5m"`, string(common.Fields[0].Default))
+	assert.Equal(t, WidgetSelect, common.Fields[1].Widget)
+	assert.Equal(t, WidgetKeyValue, common.Fields[2].Widget)
+
+	rules := model.Fields[2]
+	assert.Equal(t, WidgetList, rules.Widget)
+	ruleType := rules.Fields[0]
+	assert.Equal(t, "rules[].rule_type", ruleType.Path)
+	assert.Equal(t, []FormChoice{
+		{Value: "cpu", Label: "CPU usage monitoring"},
+		{Value: "ram", Label: "Memory usage monitoring"},
+		{Value: "service_up", Label: "Service availability monitoring"},
+	}, ruleType.Choices)
+
+	threshold := rules.Fields[2]
+	assert.Equal(t, "rules[].threshold", threshold.Path, "fields shared by branches appear once")
+	assert.Equal(t, &FormCondition{Field: "rules[].rule_type", In: []string{"cpu", "ram"}}, threshold.VisibleWhen)
+
+	serviceName := rules.Fields[3]
+	assert.Equal(t, "rules[rule_type=service_up].service_name", serviceName.Path)
+	assert.Equal(t, &FormCondition{Field: "rules[].rule_type", In: []string{"service_up"}}, serviceName.VisibleWhen)
+
+	assert.Equal(t, []FormDependency{
+		{Field: "target.workload", DependsOn: []string{"target.namespace"}},
+		{Field: "rules[rule_type=service_up].service_name", DependsOn: []string{"target.namespace"}},
+	}, model.Dependencies)
+}
+
+func TestBuildFormModel_Widgets(t *testing.T) {
+	model, err := BuildFormModel("demo", `{
+		"properties": {
+			"enabled": {"type": "boolean", "default": true},
+			"replicas": {"type": "integer", "minimum": 1, "maximum": 10},
+			"zones": {"type": "array", "items": {"type": "string", "enum": ["a", "b"]}},
+			"hosts": {"type": "array", "items": {"type": "string", "pattern": "^[a-z.]+$"}},
+			"kind": {"const": "demo"},
+			"checks": {"type": "array", "items": {
+				"required": ["tier"],
+				"properties": {
+					"tier": {"type": "string"},
+					"service": {"type": "string", "x-dynamic-options": {"type": "prometheus_query", "label": "job", "match": "up{tier=\"{{.tier}}\"}", "dependencies": ["tier"]}}
+				}
+			}},
+			"mixed": {"type": "array", "items": {"oneOf": [
+				{"title": "By name", "properties": {"name": {"type": "string"}}},
+				{"title": "By id", "properties": {"id": {"type": "number"}}}
+			]}}
+		}
+	}`)
+	assert.NoError(t, err)
+
+	fields := make(map[string]*FormField)
+	for _, f := range model.Fields {
+		fields[f.Name] = f
+	}
+	assert.Equal(t, WidgetCheckbox, fields["enabled"].Widget)
+	assert.Equal(t, WidgetNumber, fields["replicas"].Widget)
+	assert.Equal(t, 10.0, *fields["replicas"].Maximum)
+	assert.Equal(t, WidgetMultiSelect, fields["zones"].Widget)
+	assert.Len(t, fields["zones"].Choices, 2)
+	assert.Equal(t, WidgetList, fields["hosts"].Widget)
+	assert.Equal(t, "hosts[]", fields["hosts"].Fields[0].Path)
+	assert.Equal(t, "^[a-z.]+$", fields["hosts"].Fields[0].Pattern)
+	assert.Equal(t, WidgetHidden, fields["kind"].Widget)
+
+	checks := fields["checks"].Fields
+	assert.True(t, checks[0].Required)
+	assert.Equal(t, "checks[].service", checks[1].Path)
+	assert.Equal(t, []string{"checks[].tier"}, checks[1].DependsOn, "item-relative dependencies refer to the sibling field")
+
+	// Without a discriminator the first branch is offered
+	assert.Equal(t, "mixed[].name", fields["mixed"].Fields[0].Path)
+	assert.Nil(t, fields["mixed"].Fields[0].VisibleWhen)
+
+	_, err = BuildFormModel("broken", `{"properties": []}`)
+	assert.ErrorContains(t, err, "failed to parse schema")
+}
+
+func TestBuildFormModel_JSON(t *testing.T) {
+	model, err := BuildFormModel("demo", `{"title": "Demo", "properties": {"name": {"type": "string"}}}`)
+	assert.NoError(t, err)
+
+	raw, _ := json.Marshal(model)
+	assert.JSONEq(t, `{
+		"template": "demo",
+		"title": "Demo",
+		"fields": [{"path": "name", "name": "name", "label": "Name", "type": "string", "widget": "text", "required": false}],
+		"dependencies": []
+	}`, string(raw))
+}
@@ -0,0 +1,290 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPWebhookParams defines parameters for the http_webhook pipeline step.
+type HTTPWebhookParams struct {
+	// URL of the external validation service. Supports templating.
+	URL string `json:"url"`
+	// Headers sent with the request. Values support templating.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout of a single attempt (default 10s).
+	Timeout string `json:"timeout,omitempty"`
+	// Retries is the number of additional attempts on network errors, 429 and 5xx responses.
+	Retries int `json:"retries,omitempty"`
+	// RetryDelay is the pause between attempts (default 500ms).
+	RetryDelay string `json:"retry_delay,omitempty"`
+	// TLS configures the server CA and an optional client certificate for mTLS.
+	TLS *WebhookTLSConfig `json:"tls,omitempty"`
+}
+
+// WebhookTLSConfig defines TLS settings for the http_webhook step. Paths refer to files on the server.
+type WebhookTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// WebhookRequest is the JSON body POSTed to the webhook.
+type WebhookRequest struct {
+	Parameters json.RawMessage `json:"parameters"`
+	Rule       string          `json:"rule,omitempty"` // rendered rule YAML, if the template is available
+}
+
+// WebhookVerdict is the JSON response expected from the webhook.
+type WebhookVerdict struct {
+	Allowed  bool            `json:"allowed"`
+	Message  string          `json:"message,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Patch    json.RawMessage `json:"patch,omitempty"` // JSON patch (RFC 6902) to apply to the rule parameters
+}
+
+// HTTPWebhookRunner delegates validation to an external HTTP service.
+type HTTPWebhookRunner struct {
+	// Client overrides the HTTP client (e.g. in tests). When nil, a client is built from the step's TLS settings.
+	Client *http.Client
+}
+
+// errRetryable marks webhook failures that are worth another attempt.
+var errRetryable = errors.New("retryable")
+
+// errCrossHostRedirect is returned for redirects to another host, which would receive the
+// configured headers and credentials.
+var errCrossHostRedirect = errors.New("redirect to another host refused")
+
+// Run executes the webhook step.
+func (r *HTTPWebhookRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	var params HTTPWebhookParams
+	if err := json.Unmarshal(input.StepParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid step parameters: %w", err)
+	}
+	if params.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if params.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+
+	timeout, err := parseDurationOr(params.Timeout, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	retryDelay, err := parseDurationOr(params.RetryDelay, 500*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry_delay: %w", err)
+	}
+
+	var ruleData interface{}
+	if err := json.Unmarshal(input.RuleParams, &ruleData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+	}
+	target, err := renderString(params.URL, ruleData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render url: %w", err)
+	}
+	headers := make(map[string]string, len(params.Headers))
+	for name, value := range params.Headers {
+		if headers[name], err = renderString(value, ruleData); err != nil {
+			return nil, fmt.Errorf("failed to render header '%s': %w", name, err)
+		}
+	}
+
+	body := WebhookRequest{Parameters: input.RuleParams}
+	if input.RenderRule != nil {
+		if body.Rule, err = input.RenderRule(); err != nil {
+			return nil, fmt.Errorf("failed to render rule: %w", err)
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	client := r.Client
+	if client == nil {
+		if client, err = tlsClient(params.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	var verdict *WebhookVerdict
+	for attempt := 0; ; attempt++ {
+		verdict, err = postWebhook(ctx, client, target, headers, payload, timeout)
+		if err == nil || !errors.Is(err, errRetryable) || attempt >= params.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !verdict.Allowed {
+		if verdict.Message == "" {
+			return nil, fmt.Errorf("rejected by webhook")
+		}
+		return nil, fmt.Errorf("rejected by webhook: %s", verdict.Message)
+	}
+
+	return &StepOutput{Message: verdict.Message, Warnings: verdict.Warnings, Patch: verdict.Patch}, nil
+}
+
+// postWebhook performs a single webhook call and decodes the verdict.
+func postWebhook(ctx context.Context, client *http.Client, target string, headers map[string]string, payload []byte, timeout time.Duration) (*WebhookVerdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if errors.Is(err, errCrossHostRedirect) {
+		return nil, fmt.Errorf("webhook request failed: %w", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("webhook request failed: %w (%w)", err, errRetryable)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("webhook returned status %d (%w)", resp.StatusCode, errRetryable)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+
+	var verdict WebhookVerdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook verdict: %w", err)
+	}
+	return &verdict, nil
+}
+
+// tlsClient builds an HTTP client honoring the TLS settings of a webhook step or datasource. Clients
+// with the same settings share a transport, so that connections are reused across calls. Clients
+// only follow redirects to the same host (see sameHostRedirect).
+func tlsClient(cfg *WebhookTLSConfig) (*http.Client, error) {
+	if cfg == nil || *cfg == (WebhookTLSConfig{}) {
+		return &http.Client{CheckRedirect: sameHostRedirect}, nil
+	}
+	transport, err := tlsTransports.get(*cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, CheckRedirect: sameHostRedirect}, nil
+}
+
+// sameHostRedirect refuses redirects to another host or port. Requests carry configured headers
+// (API keys, tenant IDs) that net/http forwards on redirects, unlike the Authorization header.
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		return fmt.Errorf("%w: %s", errCrossHostRedirect, req.URL.Host)
+	}
+	return nil
+}
+
+// tlsTransports holds the transports built for TLS settings.
+var tlsTransports = &transportCache{entries: make(map[WebhookTLSConfig]*cachedTransport)}
+
+// transportCache caches transports by TLS settings (file paths and the insecure flag). A transport
+// is rebuilt when the modification time of one of its files changes, so that rotated certificates
+// are picked up.
+type transportCache struct {
+	mu      sync.Mutex
+	entries map[WebhookTLSConfig]*cachedTransport
+}
+
+type cachedTransport struct {
+	transport *http.Transport
+	modTimes  [3]time.Time
+}
+
+func (c *transportCache) get(cfg WebhookTLSConfig) (*http.Transport, error) {
+	var modTimes [3]time.Time
+	for i, path := range []string{cfg.CAFile, cfg.CertFile, cfg.KeyFile} {
+		if info, err := os.Stat(path); path != "" && err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.entries[cfg]
+	if ok && cached.modTimes == modTimes {
+		return cached.transport, nil
+	}
+	transport, err := newTLSTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cached.transport.CloseIdleConnections()
+	}
+	c.entries[cfg] = &cachedTransport{transport: transport, modTimes: modTimes}
+	return transport, nil
+}
+
+// newTLSTransport builds a transport with the CA and client certificate of TLS settings.
+func newTLSTransport(cfg WebhookTLSConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} //nolint:gosec // explicitly opted in by the schema
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// parseDurationOr parses a duration string, returning def if it is empty.
+func parseDurationOr(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
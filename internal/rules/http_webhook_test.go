@@ -0,0 +1,227 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPWebhookRunner_Run(t *testing.T) {
+	var calls atomic.Int32
+	var lastBody WebhookRequest
+	var lastHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		lastHeader = r.Header.Get("X-Namespace")
+		_ = json.NewDecoder(r.Body).Decode(&lastBody)
+
+		switch r.URL.Path {
+		case "/allow":
+			_, _ = w.Write([]byte(`{"allowed": true, "message": "topic exists", "warnings": ["topic has 1 partition"], "patch": [{"op": "add", "path": "/target/cluster", "value": "eu-1"}]}`))
+		case "/deny":
+			_, _ = w.Write([]byte(`{"allowed": false, "message": "topic 'orders' does not exist"}`))
+		case "/flaky":
+			if calls.Load() < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"allowed": true}`))
+		case "/bad-request":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`missing topic`))
+		default:
+			_, _ = w.Write([]byte(`not json`))
+		}
+	}))
+	defer ts.Close()
+
+	runner := &HTTPWebhookRunner{Client: ts.Client()}
+	ruleParams := json.RawMessage(`{"target": {"namespace": "payments"}}`)
+	render := func() (string, error) { return "- alert: Test\n  expr: up == 0\n", nil }
+
+	run := func(params string) (*StepOutput, error) {
+		calls.Store(0)
+		return runner.Run(context.Background(), &StepInput{
+			RuleParams: ruleParams,
+			StepParams: json.RawMessage(params),
+			RenderRule: render,
+		})
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		output, err := run(`{"url": "` + ts.URL + `/allow", "headers": {"X-Namespace": "{{ .target.namespace }}"}}`)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "topic exists", output.Message)
+		assert.Equal(t, []string{"topic has 1 partition"}, output.Warnings)
+		assert.JSONEq(t, `[{"op": "add", "path": "/target/cluster", "value": "eu-1"}]`, string(output.Patch))
+		assert.Equal(t, "payments", lastHeader)
+		assert.JSONEq(t, string(ruleParams), string(lastBody.Parameters))
+		assert.Contains(t, lastBody.Rule, "alert: Test")
+	})
+
+	t.Run("TemplatedURL", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/{{ if eq .target.namespace \"payments\" }}deny{{ end }}"}`)
+
+		assert.EqualError(t, err, "rejected by webhook: topic 'orders' does not exist")
+	})
+
+	t.Run("RetriesOnServerError", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/flaky", "retries": 2, "retry_delay": "1ms"}`)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/flaky", "retries": 1, "retry_delay": "1ms"}`)
+
+		assert.ErrorContains(t, err, "webhook returned status 503")
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("ClientErrorNotRetried", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/bad-request", "retries": 3, "retry_delay": "1ms"}`)
+
+		assert.EqualError(t, err, "webhook returned status 400: missing topic")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("InvalidVerdict", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/garbage"}`)
+
+		assert.ErrorContains(t, err, "failed to decode webhook verdict")
+	})
+
+	t.Run("MissingURL", func(t *testing.T) {
+		_, err := run(`{}`)
+
+		assert.EqualError(t, err, "url is required")
+	})
+
+	t.Run("InvalidTimeout", func(t *testing.T) {
+		_, err := run(`{"url": "` + ts.URL + `/allow", "timeout": "soon"}`)
+
+		assert.ErrorContains(t, err, "invalid timeout")
+	})
+}
+
+func TestHTTPWebhookRunner_Redirects(t *testing.T) {
+	var leaked atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "" {
+			leaked.Add(1)
+		}
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer other.Close()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/allow", http.StatusTemporaryRedirect)
+		case "/away":
+			http.Redirect(w, r, other.URL+"/allow", http.StatusTemporaryRedirect)
+		default:
+			_, _ = w.Write([]byte(`{"allowed": true}`))
+		}
+	}))
+	defer ts.Close()
+
+	runner := &HTTPWebhookRunner{}
+	run := func(path string) error {
+		calls.Store(0)
+		_, err := runner.Run(context.Background(), &StepInput{
+			RuleParams: json.RawMessage(`{}`),
+			StepParams: json.RawMessage(`{"url": "` + ts.URL + path + `", "headers": {"X-Api-Key": "s3cr3t"}, "retries": 2, "retry_delay": "1ms"}`),
+		})
+		return err
+	}
+
+	assert.NoError(t, run("/moved"), "redirects within the host are followed")
+
+	err := run("/away")
+	assert.ErrorContains(t, err, "webhook request failed")
+	assert.ErrorContains(t, err, "redirect to another host refused")
+	assert.Equal(t, int32(0), leaked.Load(), "configured headers are not sent to another host")
+	assert.Equal(t, int32(1), calls.Load(), "refused redirects are not retried")
+}
+
+func TestHTTPWebhookRunner_MutualTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	defer ts.Close()
+
+	runner := &HTTPWebhookRunner{}
+	input := func(params string) *StepInput {
+		return &StepInput{RuleParams: json.RawMessage(`{}`), StepParams: json.RawMessage(params)}
+	}
+
+	t.Run("UntrustedServer", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), input(`{"url": "`+ts.URL+`"}`))
+
+		assert.ErrorContains(t, err, "webhook request failed")
+	})
+
+	t.Run("InsecureSkipVerify", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), input(`{"url": "`+ts.URL+`", "tls": {"insecure_skip_verify": true}}`))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("MissingClientCertificate", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), input(`{"url": "`+ts.URL+`", "tls": {"cert_file": "/nonexistent.crt", "key_file": "/nonexistent.key"}}`))
+
+		assert.ErrorContains(t, err, "failed to load client certificate")
+	})
+}
+
+func TestHTTPWebhookRunner_ReusesConnections(t *testing.T) {
+	var connections atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"allowed": true}`))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600))
+
+	runner := &HTTPWebhookRunner{}
+	run := func() {
+		t.Helper()
+		_, err := runner.Run(context.Background(), &StepInput{
+			RuleParams: json.RawMessage(`{}`),
+			StepParams: json.RawMessage(`{"url": "` + ts.URL + `", "tls": {"ca_file": "` + caFile + `"}}`),
+		})
+		assert.NoError(t, err)
+	}
+
+	for range 3 {
+		run()
+	}
+	assert.Equal(t, int32(1), connections.Load(), "calls with the same TLS settings share a connection")
+
+	// A rotated CA file builds a new transport
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(caFile, later, later))
+	run()
+	assert.Equal(t, int32(2), connections.Load())
+}
@@ -88,7 +88,7 @@ func TestMongoIntegration_PerRulePipelines(t *testing.T) {
 			]
 		}`
 
-		err := service.ValidateRule(ctx, "k8s", json.RawMessage(params))
+		_, err := service.ValidateRule(ctx, "k8s", json.RawMessage(params))
 		assert.NoError(t, err)
 	})
 
@@ -111,7 +111,7 @@ func TestMongoIntegration_PerRulePipelines(t *testing.T) {
 			]
 		}`
 
-		err := service.ValidateRule(ctx, "k8s", json.RawMessage(params))
+		_, err := service.ValidateRule(ctx, "k8s", json.RawMessage(params))
 		assert.NoError(t, err)
 	})
 }
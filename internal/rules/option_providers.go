@@ -0,0 +1,611 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"rulemanager/internal/database"
+)
+
+// OptionsInput carries what an options provider needs to resolve the options of a field.
+type OptionsInput struct {
+	TemplateName string
+	FieldPath    string
+	Config       *DynamicOptionsConfig
+	// Schema is the JSON schema of the template.
+	Schema string
+	// Values are the current form values; provider settings are rendered with them.
+	Values FieldValues
+	// Datasource resolves the template datasource. It is evaluated lazily, so only providers
+	// querying the datasource require one.
+	Datasource func() (*DatasourceConfig, error)
+	// Limit, when positive, allows the provider to return only the first Limit options of its
+	// ascending order. Search, sort and the field's limit are applied to the result afterwards.
+	Limit int
+
+	// cache reuses resolved options across requests for cacheTTL; nil or a non-positive TTL disables it.
+	cache    *optionsCache
+	cacheTTL time.Duration
+}
+
+// Cached returns the options cached under key, or resolves them with fetch and caches them for the
+// field's TTL. key must identify the rendered lookup (datasource, query, ...), not the field.
+// The returned slice may be shared and must not be modified.
+func (in *OptionsInput) Cached(ctx context.Context, key string, fetch func(context.Context) ([]string, error)) ([]string, error) {
+	return in.cache.get(ctx, key, in.cacheTTL, fetch)
+}
+
+// OptionsProvider resolves the options of a field configured with x-dynamic-options.
+type OptionsProvider interface {
+	Options(ctx context.Context, input *OptionsInput) ([]string, error)
+}
+
+// builtinOptionsProviders returns the options providers available to every schema.
+func builtinOptionsProviders(s *Service) map[string]OptionsProvider {
+	return map[string]OptionsProvider{
+		"prometheus_query":  &PrometheusLabelValuesProvider{},
+		"prometheus_series": &PrometheusSeriesProvider{},
+		"static_http":       &StaticHTTPProvider{},
+		"rules_store":       &RulesStoreProvider{Store: s.ruleStore},
+		"enum_from_schema":  &SchemaEnumProvider{Templates: s.templateProvider},
+	}
+}
+
+// PrometheusLabelValuesProvider offers the values of a label from the label values API.
+type PrometheusLabelValuesProvider struct{}
+
+// Options queries /api/v1/label/<label>/values with the rendered match[] selector.
+func (p *PrometheusLabelValuesProvider) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	cfg := input.Config
+	if cfg.Label == "" {
+		return nil, fmt.Errorf("label is empty")
+	}
+	if cfg.Match == "" {
+		return nil, fmt.Errorf("match is empty")
+	}
+
+	match, err := substituteVariables(cfg.Match, input.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute variables in match: %w", err)
+	}
+	var lookback time.Duration
+	if cfg.Lookback != "" {
+		if lookback, err = time.ParseDuration(cfg.Lookback); err != nil || lookback <= 0 {
+			return nil, fmt.Errorf("invalid lookback '%s': expected a positive duration", cfg.Lookback)
+		}
+	}
+	datasource, err := input.Datasource()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"match[]": {match}}
+	if input.Limit > 0 {
+		params.Set("limit", strconv.Itoa(input.Limit))
+	}
+	// The time range changes with every request, so the key records the lookback instead
+	key := "label_values|" + cfg.Label + "|" + cfg.Lookback + "|" + datasource.cacheKey("", params)
+	return input.Cached(ctx, key, func(ctx context.Context) ([]string, error) {
+		if lookback > 0 {
+			end := time.Now()
+			params.Set("start", strconv.FormatInt(end.Add(-lookback).Unix(), 10))
+			params.Set("end", strconv.FormatInt(end.Unix(), 10))
+		}
+		return queryLabelValues(ctx, datasource, cfg.Label, params)
+	})
+}
+
+// PrometheusSeriesProvider offers the values of a label across the series returned by an instant query,
+// for options that need PromQL (aggregations, thresholds) rather than a series selector.
+type PrometheusSeriesProvider struct{}
+
+// Options runs the rendered query and collects the distinct, sorted values of the label.
+func (p *PrometheusSeriesProvider) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	cfg := input.Config
+	if cfg.Label == "" {
+		return nil, fmt.Errorf("label is empty")
+	}
+	if cfg.Query == "" {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	query, err := substituteVariables(cfg.Query, input.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute variables in query: %w", err)
+	}
+	datasource, err := input.Datasource()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"query": {query}}
+	key := "series|" + cfg.Label + "|" + datasource.cacheKey("/api/v1/query", params)
+	return input.Cached(ctx, key, func(ctx context.Context) ([]string, error) {
+		result, err := queryPrometheus(ctx, nil, datasource, "/api/v1/query", params)
+		if err != nil {
+			return nil, err
+		}
+		series, err := result.Series()
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]string, 0, len(series))
+		for _, s := range series {
+			if v := s.Metric[cfg.Label]; v != "" {
+				values = append(values, v)
+			}
+		}
+		return uniqueSorted(values), nil
+	})
+}
+
+// StaticHTTPProvider offers values selected with a JSONPath from a JSON endpoint.
+type StaticHTTPProvider struct {
+	// Client overrides the HTTP client (e.g. in tests).
+	Client *http.Client
+}
+
+// Options fetches the rendered URL and evaluates the JSONPath. Scalars are offered in document order;
+// objects, arrays and nulls are skipped.
+func (p *StaticHTTPProvider) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	cfg := input.Config
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is empty")
+	}
+	path := cfg.Path
+	if path == "" {
+		path = "$[*]"
+	}
+
+	target, err := substituteURL(cfg.URL, input.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute variables in url: %w", err)
+	}
+	return input.Cached(ctx, "http|"+target+"|"+path, func(ctx context.Context) ([]string, error) {
+		return p.fetch(ctx, target, path)
+	})
+}
+
+// substituteURL renders a URL template with the current form values, escaping every substituted
+// value for its position: path escaping before the query string and query escaping after it. Form
+// values therefore cannot add path segments, parameters or a fragment to the URL.
+func substituteURL(urlTemplate string, currentValues FieldValues) (string, error) {
+	tmpl, err := template.New("url").Funcs(template.FuncMap{
+		"_pathEscape":  escapeURLValue(url.PathEscape, true),
+		"_queryEscape": escapeURLValue(url.QueryEscape, false),
+	}).Parse(urlTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse url template: %w", err)
+	}
+	inQuery := false
+	escapeURLActions(tmpl.Tree.Root, &inQuery)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, currentValues); err != nil {
+		return "", fmt.Errorf("failed to execute url template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// escapeURLActions pipes the output of every action of a template into the escaping function for
+// its position. inQuery is set once literal text has started the query string.
+func escapeURLActions(node parse.Node, inQuery *bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeURLActions(child, inQuery)
+		}
+	case *parse.TextNode:
+		if bytes.ContainsAny(n.Text, "?#") {
+			*inQuery = true
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return // Assignments print nothing
+		}
+		escape := "_pathEscape"
+		if *inQuery {
+			escape = "_queryEscape"
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escape).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeURLActions(n.List, inQuery)
+		escapeURLActions(n.ElseList, inQuery)
+	case *parse.RangeNode:
+		escapeURLActions(n.List, inQuery)
+		escapeURLActions(n.ElseList, inQuery)
+	case *parse.WithNode:
+		escapeURLActions(n.List, inQuery)
+		escapeURLActions(n.ElseList, inQuery)
+	}
+}
+
+// escapeURLValue returns a template function printing a value and escaping it. In paths, "." and
+// ".." are refused, as they remain dot segments once escaped.
+func escapeURLValue(escape func(string) string, path bool) func(interface{}) (string, error) {
+	return func(value interface{}) (string, error) {
+		if value == nil {
+			return "", nil
+		}
+		s := fmt.Sprint(value)
+		if path && (s == "." || s == "..") {
+			return "", fmt.Errorf("value '%s' is not allowed in a url path", s)
+		}
+		return escape(s), nil
+	}
+}
+
+// fetch requests the endpoint and selects the option values.
+func (p *StaticHTTPProvider) fetch(ctx context.Context, target, path string) ([]string, error) {
+	client := p.Client
+	if client == nil {
+		client = defaultDatasourceClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("options endpoint returned status %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode options response: %w", err)
+	}
+
+	nodes, err := evalJSONPath(doc, path)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(nodes))
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if v, ok := scalarString(node); ok && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// RulesStoreProvider offers the values a parameter already has in existing rules, so that forms
+// can suggest e.g. the namespaces or teams in use.
+type RulesStoreProvider struct {
+	Store database.RuleStore
+}
+
+// Options counts the values of the field over the rules of the template matching the rendered
+// filter, in the store (see database.RuleStore.FacetRules), and returns them distinct and sorted.
+// Arrays along the path (e.g. rules.severity) are expanded.
+func (p *RulesStoreProvider) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	cfg := input.Config
+	if cfg.Field == "" {
+		return nil, fmt.Errorf("field is empty")
+	}
+	if p.Store == nil {
+		return nil, fmt.Errorf("rule store not available")
+	}
+	// The field always refers to parameters, even when named like a rule field (e.g. "name")
+	fields, err := database.ParseFacetFields("parameters." + cfg.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := database.RuleFilter{TemplateName: cfg.Template}
+	if filter.TemplateName == "" {
+		filter.TemplateName = input.TemplateName
+	}
+	key := "rules_store|" + filter.TemplateName + "|" + cfg.Field
+	if len(cfg.Filter) > 0 {
+		filter.Parameters = make(map[string]string, len(cfg.Filter))
+		for param, tmpl := range cfg.Filter {
+			value, err := substituteVariables(tmpl, input.Values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to substitute variables in filter '%s': %w", param, err)
+			}
+			filter.Parameters[param] = value
+		}
+		// Encoded sorted by key, so that equal filters share a cache entry
+		encoded, _ := json.Marshal(filter.Parameters)
+		key += "|" + string(encoded)
+	}
+
+	return input.Cached(ctx, key, func(ctx context.Context) ([]string, error) {
+		facets, err := p.Store.FacetRules(ctx, filter, fields, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search rules: %w", err)
+		}
+		values := make([]string, 0, len(facets.Facets[fields[0]]))
+		for _, facet := range facets.Facets[fields[0]] {
+			if v, ok := scalarString(facet.Value); ok && v != "" {
+				values = append(values, v)
+			}
+		}
+		return uniqueSorted(values), nil
+	})
+}
+
+// SchemaEnumProvider offers the values of an enum declared elsewhere in a schema, so that one list
+// (e.g. under $defs) can feed several fields. A templated source makes the enum depend on other fields.
+type SchemaEnumProvider struct {
+	Templates database.TemplateProvider
+}
+
+// Options locates the rendered source and returns its values: the enum of a field, the enum of its
+// items, the const values of its oneOf/anyOf branches, or a plain array of scalars.
+func (p *SchemaEnumProvider) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	cfg := input.Config
+	if cfg.Source == "" {
+		return nil, fmt.Errorf("source is empty")
+	}
+	source, err := substituteVariables(cfg.Source, input.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to substitute variables in source: %w", err)
+	}
+
+	schemaStr := input.Schema
+	if cfg.Template != "" && cfg.Template != input.TemplateName {
+		if p.Templates == nil {
+			return nil, fmt.Errorf("template provider not available")
+		}
+		if schemaStr, err = p.Templates.GetSchema(ctx, cfg.Template); err != nil {
+			return nil, fmt.Errorf("failed to get schema of template '%s': %w", cfg.Template, err)
+		}
+	}
+	var schema interface{}
+	if err := json.Unmarshal([]byte(schemaStr), &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	var node interface{}
+	if strings.HasPrefix(source, "#") {
+		node, err = resolvePointer(schema, strings.TrimPrefix(source, "#"))
+	} else {
+		root, _ := schema.(map[string]interface{})
+		node, err = navigateToField(root, source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("source '%s' not found in schema: %w", source, err)
+	}
+
+	return enumValues(node)
+}
+
+// enumValues extracts the allowed values of a schema node.
+func enumValues(node interface{}) ([]string, error) {
+	var items []interface{}
+	switch n := node.(type) {
+	case []interface{}:
+		items = n
+	case SchemaNode:
+		return enumValues(map[string]interface{}(n))
+	case map[string]interface{}:
+		switch {
+		case n["enum"] != nil:
+			items, _ = n["enum"].([]interface{})
+		case n["items"] != nil:
+			return enumValues(n["items"])
+		case n["oneOf"] != nil || n["anyOf"] != nil:
+			branches, _ := n["oneOf"].([]interface{})
+			if branches == nil {
+				branches, _ = n["anyOf"].([]interface{})
+			}
+			for _, branch := range branches {
+				if b, ok := branch.(map[string]interface{}); ok && b["const"] != nil {
+					items = append(items, b["const"])
+				}
+			}
+		default:
+			return nil, fmt.Errorf("source has no enum")
+		}
+	default:
+		return nil, fmt.Errorf("source has no enum")
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if v, ok := scalarString(item); ok {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// resolvePointer resolves a JSON pointer (RFC 6901) within a document.
+func resolvePointer(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+	cursor := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch c := cursor.(type) {
+		case map[string]interface{}:
+			next, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("'%s' not found", token)
+			}
+			cursor = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("index '%s' out of range", token)
+			}
+			cursor = c[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into '%s'", token)
+		}
+	}
+	return cursor, nil
+}
+
+// evalJSONPath evaluates a JSONPath expression. The supported subset covers member access
+// ($.a.b, $['a']), array indexes ($.items[0], negative indexes count from the end),
+// wildcards ($.items[*], $.*) and recursive descent ($..name).
+func evalJSONPath(doc interface{}, path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath '%s': must start with '$'", path)
+	}
+	nodes := []interface{}{doc}
+	rest := path[1:]
+	for rest != "" {
+		recursive := false
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			recursive = true
+			rest = rest[2:]
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] != '[':
+			return nil, fmt.Errorf("invalid JSONPath '%s': unexpected '%c'", path, rest[0])
+		}
+
+		var selector string
+		quoted := false
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s': unterminated '['", path)
+			}
+			selector = strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				selector = selector[1 : len(selector)-1]
+				quoted = true
+			}
+		} else {
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			selector = rest[:end]
+			rest = rest[end:]
+			quoted = selector != "*"
+		}
+		if selector == "" {
+			return nil, fmt.Errorf("invalid JSONPath '%s': empty selector", path)
+		}
+
+		if recursive {
+			var all []interface{}
+			for _, node := range nodes {
+				all = appendDescendants(all, node)
+			}
+			nodes = all
+		}
+
+		var next []interface{}
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if selector == "*" && !quoted {
+					keys := make([]string, 0, len(n))
+					for k := range n {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, n[k])
+					}
+				} else if v, ok := n[selector]; ok {
+					next = append(next, v)
+				}
+			case []interface{}:
+				if selector == "*" && !quoted {
+					next = append(next, n...)
+				} else if i, err := strconv.Atoi(selector); err == nil && !quoted {
+					if i < 0 {
+						i += len(n)
+					}
+					if i >= 0 && i < len(n) {
+						next = append(next, n[i])
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+// appendDescendants appends a node and all nodes nested in it, depth first.
+func appendDescendants(out []interface{}, node interface{}) []interface{} {
+	out = append(out, node)
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = appendDescendants(out, n[k])
+		}
+	case []interface{}:
+		for _, item := range n {
+			out = appendDescendants(out, item)
+		}
+	}
+	return out
+}
+
+// scalarString formats a JSON scalar as an option value.
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// uniqueSorted returns the distinct values in ascending order.
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	out := make([]string, 0, len(values))
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
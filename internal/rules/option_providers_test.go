@@ -0,0 +1,567 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rulemanager/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_GetOptions_Providers(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/query":
+			queries = append(queries, r.URL.Query().Get("query"))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service":"checkout"},"value":[1,"3"]},
+				{"metric":{"service":"api"},"value":[1,"2"]},
+				{"metric":{"service":"checkout"},"value":[1,"1"]},
+				{"metric":{},"value":[1,"1"]}
+			]}}`))
+		case "/teams/payments":
+			_, _ = w.Write([]byte(`{"members": [{"name": "alice", "id": 7}, {"name": "bob", "id": 8}, {"name": "alice"}, {"name": null}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	schema := `{
+		"datasource": {"type": "prometheus", "url": "` + ts.URL + `"},
+		"$defs": {
+			"severities": {"type": "string", "enum": ["critical", "warning", "info"]},
+			"tiers": {"production": ["gold", "silver"], "staging": ["bronze"]}
+		},
+		"properties": {
+			"target": {"properties": {
+				"environment": {"type": "string"},
+				"namespace": {"type": "string", "x-dynamic-options": {"type": "rules_store", "field": "target.namespace"}},
+				"team": {"type": "string"},
+				"service": {"type": "string", "x-dynamic-options": {
+					"type": "prometheus_series",
+					"label": "service",
+					"query": "sum by (service) (rate(http_requests_total{namespace=\"{{.target.namespace}}\"}[5m])) > 0",
+					"dependencies": ["target.namespace"]
+				}},
+				"owner": {"type": "string", "x-dynamic-options": {
+					"type": "static_http",
+					"url": "` + ts.URL + `/teams/{{.target.team}}",
+					"path": "$.members[*].name",
+					"dependencies": ["target.team"]
+				}},
+				"owner_id": {"type": "string", "x-dynamic-options": {"type": "static_http", "url": "` + ts.URL + `/teams/payments", "path": "$..id"}},
+				"tier": {"type": "string", "x-dynamic-options": {"type": "enum_from_schema", "source": "#/$defs/tiers/{{.target.environment}}", "dependencies": ["target.environment"]}},
+				"workload": {"type": "string", "x-dynamic-options": {
+					"type": "rules_store",
+					"field": "target.workload",
+					"filter": {"target.namespace": "{{.target.namespace}}"},
+					"dependencies": ["target.namespace"]
+				}}
+			}},
+			"common": {"properties": {
+				"severity": {"type": "string", "x-dynamic-options": {"type": "enum_from_schema", "source": "#/$defs/severities"}},
+				"priority": {"type": "string", "x-dynamic-options": {"type": "enum_from_schema", "template": "shared", "source": "priority"}},
+				"used_severity": {"type": "string", "x-dynamic-options": {"type": "rules_store", "field": "rules.severity"}},
+				"unknown": {"type": "string", "x-dynamic-options": {"type": "ldap"}}
+			}}
+		}
+	}`
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(schema, nil)
+	mockTP.On("GetSchema", mock.Anything, "shared").Return(`{"properties": {"priority": {"oneOf": [{"const": "P1"}, {"const": "P2"}]}}}`, nil)
+
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	ctx := context.Background()
+	for i, params := range []string{
+		`{"target": {"namespace": "payments", "workload": "api"}, "rules": [{"severity": "critical"}]}`,
+		`{"target": {"namespace": "orders", "workload": "worker"}, "rules": [{"severity": "warning"}, {"severity": "critical"}]}`,
+		`{"target": {"namespace": "payments", "workload": "checkout"}}`,
+	} {
+		assert.NoError(t, store.CreateRule(ctx, &database.Rule{ID: strconv.Itoa(i), TemplateName: "k8s", Parameters: json.RawMessage(params)}))
+	}
+	assert.NoError(t, store.CreateRule(ctx, &database.Rule{ID: "other", TemplateName: "other", Parameters: json.RawMessage(`{"target": {"namespace": "search"}}`)}))
+
+	service := NewService(mockTP, store, nil)
+	values := FieldValues{"target": map[string]interface{}{"namespace": "payments", "team": "payments", "environment": "production"}}
+
+	tests := []struct {
+		name    string
+		field   string
+		values  FieldValues
+		want    []string
+		wantErr string
+	}{
+		{name: "PrometheusSeries", field: "target.service", values: values, want: []string{"api", "checkout"}},
+		{name: "StaticHTTP", field: "target.owner", values: values, want: []string{"alice", "bob"}},
+		{name: "StaticHTTPRecursiveNumbers", field: "target.owner_id", values: values, want: []string{"7", "8"}},
+		{name: "RulesStore", field: "target.namespace", values: values, want: []string{"orders", "payments"}},
+		{name: "RulesStoreFiltered", field: "target.workload", values: values, want: []string{"api", "checkout"}},
+		{name: "EnumFromSchema", field: "common.severity", values: values, want: []string{"critical", "warning", "info"}},
+		{name: "EnumFromSchemaTemplatedSource", field: "target.tier", values: values, want: []string{"gold", "silver"}},
+		{name: "EnumFromOtherTemplate", field: "common.priority", values: values, want: []string{"P1", "P2"}},
+		{name: "RulesStoreItems", field: "common.used_severity", values: values, want: []string{"critical", "warning"}},
+		{name: "MissingDependency", field: "target.service", values: FieldValues{}, want: []string{}},
+		{name: "EmptyDependency", field: "target.owner", values: FieldValues{"target": map[string]interface{}{"team": ""}}, want: []string{}},
+		{name: "UnsupportedType", field: "common.unknown", values: values, wantErr: "unsupported dynamic options type: ldap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries = nil
+			options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: tt.field, Values: tt.values})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, options)
+		})
+	}
+
+	t.Run("QueryRendered", func(t *testing.T) {
+		queries = nil
+		service := NewService(mockTP, store, nil) // fresh options cache
+		_, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "target.service", Values: values})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`sum by (service) (rate(http_requests_total{namespace="payments"}[5m])) > 0`}, queries)
+	})
+
+	t.Run("CustomProvider", func(t *testing.T) {
+		service := NewService(mockTP, store, nil, WithOptionsProviders(map[string]OptionsProvider{"ldap": optionsFunc(func(ctx context.Context, input *OptionsInput) ([]string, error) {
+			return []string{input.TemplateName + ":" + input.FieldPath}, nil
+		})}))
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "common.unknown", Values: values})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"k8s:common.unknown"}, options)
+	})
+
+	t.Run("RulesStoreCached", func(t *testing.T) {
+		countingRS := new(MockRuleStore)
+		countingRS.On("FacetRules", mock.Anything, mock.Anything, []string{"parameters.target.workload"}, 0).Return(&database.RuleFacets{
+			Facets: map[string][]database.FacetValue{"parameters.target.workload": {{Value: "api", Count: 2}, {Value: "checkout", Count: 1}}},
+		}, nil)
+		service := NewService(mockTP, countingRS, nil)
+
+		for range 3 {
+			options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "target.workload", Values: values})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"api", "checkout"}, options)
+		}
+		countingRS.AssertNumberOfCalls(t, "FacetRules", 1)
+
+		other := FieldValues{"target": map[string]interface{}{"namespace": "orders"}}
+		_, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "target.workload", Values: other})
+		assert.NoError(t, err)
+		countingRS.AssertNumberOfCalls(t, "FacetRules", 2)
+		countingRS.AssertCalled(t, "FacetRules", mock.Anything, database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"target.namespace": "orders"}}, []string{"parameters.target.workload"}, 0)
+	})
+
+	t.Run("RulesStoreError", func(t *testing.T) {
+		failingRS := new(MockRuleStore)
+		failingRS.On("FacetRules", mock.Anything, mock.Anything, []string{"parameters.target.namespace"}, 0).Return((*database.RuleFacets)(nil), errors.New("database error"))
+		service := NewService(mockTP, failingRS, nil)
+
+		_, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "target.namespace", Values: values})
+
+		assert.EqualError(t, err, "failed to search rules: database error")
+	})
+}
+
+func TestStaticHTTPProvider_EscapesValues(t *testing.T) {
+	var requests []*url.URL
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL)
+		_, _ = w.Write([]byte(`["a"]`))
+	}))
+	defer ts.Close()
+
+	options := func(urlTemplate string, value string) error {
+		_, err := (&StaticHTTPProvider{}).Options(context.Background(), &OptionsInput{
+			Config: &DynamicOptionsConfig{URL: ts.URL + urlTemplate},
+			Values: FieldValues{"team": value},
+		})
+		return err
+	}
+
+	t.Run("Path", func(t *testing.T) {
+		requests = nil
+		assert.NoError(t, options("/teams/{{ .team }}/members", "x/../admin?y="))
+		if assert.Len(t, requests, 1) {
+			assert.Equal(t, "/teams/x%2F..%2Fadmin%3Fy=/members", requests[0].EscapedPath())
+			assert.Empty(t, requests[0].RawQuery)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		requests = nil
+		assert.NoError(t, options("/members?team={{ .team }}&active=true", "x&admin=true#"))
+		if assert.Len(t, requests, 1) {
+			assert.Equal(t, "/members", requests[0].Path)
+			assert.Equal(t, url.Values{"team": {"x&admin=true#"}, "active": {"true"}}, requests[0].Query())
+		}
+	})
+
+	t.Run("DotSegment", func(t *testing.T) {
+		requests = nil
+		assert.ErrorContains(t, options("/teams/{{ .team }}/members", ".."), "not allowed in a url path")
+		assert.Empty(t, requests)
+	})
+}
+
+func TestService_GetOptions_Lookups(t *testing.T) {
+	var requests []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query())
+		values := []string{"api", "billing", "checkout", "payments-api", "payments-worker"}
+		if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit > 0 && limit < len(values) {
+			values = values[:limit]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": values})
+	}))
+	defer ts.Close()
+
+	schema := `{
+		"datasource": {"type": "prometheus", "url": "` + ts.URL + `"},
+		"properties": {
+			"bounded": {"x-dynamic-options": {"type": "prometheus_query", "label": "service", "match": "up{namespace=\"{{.namespace}}\"}", "lookback": "1h", "limit": 2}},
+			"prefix": {"x-dynamic-options": {"type": "prometheus_query", "label": "service", "match": "up", "search_mode": "prefix", "sort": "desc", "cache_ttl": "0s"}},
+			"invalid_lookback": {"x-dynamic-options": {"type": "prometheus_query", "label": "service", "match": "up", "lookback": "soon"}},
+			"invalid_sort": {"x-dynamic-options": {"type": "prometheus_query", "label": "service", "match": "up", "sort": "random"}}
+		}
+	}`
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "svc").Return(schema, nil)
+	service := NewService(mockTP, nil, nil)
+	ctx := context.Background()
+
+	t.Run("LookbackAndLimit", func(t *testing.T) {
+		requests = nil
+		before := time.Now().Add(-time.Hour).Unix()
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "bounded", Values: FieldValues{"namespace": "prod"}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api", "billing"}, options)
+		assert.Len(t, requests, 1)
+		assert.Equal(t, `up{namespace="prod"}`, requests[0].Get("match[]"))
+		assert.Equal(t, "2", requests[0].Get("limit"))
+		start, _ := strconv.ParseInt(requests[0].Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(requests[0].Get("end"), 10, 64)
+		assert.GreaterOrEqual(t, start, before)
+		assert.Equal(t, int64(3600), end-start)
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		requests = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "bounded", Values: FieldValues{"namespace": "prod"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api", "billing"}, options)
+		assert.Empty(t, requests, "the same rendered match is served from the cache")
+
+		_, err = service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "bounded", Values: FieldValues{"namespace": "staging"}})
+		assert.NoError(t, err)
+		assert.Len(t, requests, 1, "a different rendered match is queried")
+	})
+
+	t.Run("SearchFetchesWithoutLimit", func(t *testing.T) {
+		requests = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "bounded", Values: FieldValues{"namespace": "prod"}, Search: "PAY"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"payments-api", "payments-worker"}, options)
+		assert.Len(t, requests, 1)
+		assert.Empty(t, requests[0].Get("limit"))
+	})
+
+	t.Run("PrefixSearchSortedDescending", func(t *testing.T) {
+		requests = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "prefix", Values: FieldValues{}, Search: "p"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"payments-worker", "payments-api"}, options)
+
+		options, err = service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "prefix", Values: FieldValues{}, Search: "api"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api"}, options, "prefix search does not match payments-api")
+		assert.Len(t, requests, 2, "cache_ttl 0s disables caching")
+	})
+
+	t.Run("InvalidSettings", func(t *testing.T) {
+		_, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "invalid_lookback", Values: FieldValues{}})
+		assert.EqualError(t, err, "invalid lookback 'soon': expected a positive duration")
+
+		_, err = service.GetOptions(ctx, OptionsQuery{TemplateName: "svc", FieldPath: "invalid_sort", Values: FieldValues{}})
+		assert.EqualError(t, err, "invalid sort 'random': use asc or desc")
+	})
+}
+
+func TestOptionsCache(t *testing.T) {
+	cache := newOptionsCache(time.Minute)
+	ctx := context.Background()
+	var calls atomic.Int32
+	fetch := func(context.Context) ([]string, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return []string{"a"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values, err := cache.get(ctx, "key", 50*time.Millisecond, fetch)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a"}, values)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load(), "concurrent lookups are de-duplicated")
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = cache.get(ctx, "key", 50*time.Millisecond, fetch)
+	assert.Equal(t, int32(2), calls.Load(), "entries expire after the TTL")
+
+	_, err := cache.get(ctx, "failing", time.Minute, func(context.Context) ([]string, error) { return nil, errors.New("boom") })
+	assert.EqualError(t, err, "boom")
+	_, err = cache.get(ctx, "failing", time.Minute, func(context.Context) ([]string, error) { return []string{"b"}, nil })
+	assert.NoError(t, err, "errors are not cached")
+
+	// A caller giving up does not cancel the lookup shared with other callers
+	release := make(chan struct{})
+	slow := func(ctx context.Context) ([]string, error) {
+		select {
+		case <-release:
+			return []string{"c"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.get(canceled, "slow", time.Minute, slow)
+		firstErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	second := make(chan []string, 1)
+	go func() {
+		values, err := cache.get(ctx, "slow", time.Minute, slow)
+		assert.NoError(t, err)
+		second <- values
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+	assert.Equal(t, []string{"c"}, <-second)
+}
+
+// optionsFunc adapts a function to the OptionsProvider interface.
+type optionsFunc func(ctx context.Context, input *OptionsInput) ([]string, error)
+
+func (f optionsFunc) Options(ctx context.Context, input *OptionsInput) ([]string, error) {
+	return f(ctx, input)
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "tags": []interface{}{"x", "y"}},
+			map[string]interface{}{"name": "b", "tags": []interface{}{"z"}},
+		},
+		"meta": map[string]interface{}{"odd key": "v", "name": "m"},
+	}
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		{path: "$.items[*].name", want: []interface{}{"a", "b"}},
+		{path: "$.items[1].name", want: []interface{}{"b"}},
+		{path: "$.items[-1].tags[0]", want: []interface{}{"z"}},
+		{path: "$['meta']['odd key']", want: []interface{}{"v"}},
+		{path: "$.items[*].tags[*]", want: []interface{}{"x", "y", "z"}},
+		{path: "$..name", want: []interface{}{"a", "b", "m"}},
+		{path: "$.meta.*", want: []interface{}{"m", "v"}},
+		{path: "$.missing", want: nil},
+		{path: "items", wantErr: "must start with '$'"},
+		{path: "$.items[0", wantErr: "unterminated '['"},
+		{path: "$.items.", wantErr: "empty selector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := evalJSONPath(doc, tt.path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveField(t *testing.T) {
+	var schema SchemaNode
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"target": {"properties": {"namespace": {"type": "string"}}},
+			"tags": {"type": "array", "items": {"properties": {"key": {"type": "string"}}}},
+			"rules": {"type": "array", "items": {"oneOf": [
+				{"properties": {"rule_type": {"const": "cpu"}, "threshold": {"type": "number", "title": "cpu threshold"}}},
+				{"properties": {"rule_type": {"const": "ram"}, "threshold": {"type": "number", "title": "ram threshold"}}},
+				{"properties": {"rule_type": {"const": "service_up"}, "service_name": {"type": "string"}}}
+			]}}
+		}
+	}`), &schema))
+	values := FieldValues{"rules": []interface{}{
+		map[string]interface{}{"rule_type": "cpu", "threshold": 80},
+		map[string]interface{}{"rule_type": "ram", "threshold": 90},
+	}}
+
+	tests := []struct {
+		path     string
+		wantType string
+		want     string // title of the field, if set
+		wantItem FieldValues
+		wantErr  string
+	}{
+		{path: "target.namespace", wantType: "string"},
+		{path: "tags[].key", wantType: "string"},
+		{path: "tags.key", wantType: "string"},
+		{path: "rules[rule_type=service_up].service_name", wantType: "string"},
+		{path: "rules[rule_type=ram].threshold", want: "ram threshold"},
+		{path: "rules[].service_name", wantType: "string"},
+		{path: "rules[].threshold", want: "cpu threshold"},
+		{path: "rules[1].threshold", want: "ram threshold", wantItem: FieldValues{"rule_type": "ram", "threshold": float64(90)}},
+		{path: "rules[rule_type=disk].threshold", wantErr: "field 'rules': no branch with rule_type=disk"},
+		{path: "target[0].namespace", wantErr: "field 'target' is not an array"},
+		{path: "rules[x].threshold", wantErr: "invalid selector in 'rules[x]'"},
+		{path: "rules[].missing", wantErr: "no branch declares 'missing' at level 1 (path: rules[].missing)"},
+		{path: "target.missing", wantErr: "field 'missing' not found at level 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			raw, _ := json.Marshal(values)
+			var decoded FieldValues
+			_ = json.Unmarshal(raw, &decoded)
+
+			field, item, err := resolveField(schema, tt.path, decoded)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantType != "" {
+				assert.Equal(t, tt.wantType, field["type"])
+			}
+			if tt.want != "" {
+				assert.Equal(t, tt.want, field["title"])
+			}
+			assert.Equal(t, tt.wantItem, item)
+		})
+	}
+}
+
+func TestService_GetOptions_ArrayItems(t *testing.T) {
+	var matches []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matches = append(matches, r.URL.Query().Get("match[]"))
+		_, _ = w.Write([]byte(`{"status":"success","data":["checkout","payments"]}`))
+	}))
+	defer ts.Close()
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{
+		"datasource": {"type": "prometheus", "url": "`+ts.URL+`"},
+		"properties": {
+			"target": {"properties": {"namespace": {"type": "string"}}},
+			"rules": {"type": "array", "items": {"oneOf": [
+				{"properties": {"rule_type": {"const": "cpu"}, "threshold": {"type": "number"}}},
+				{"properties": {
+					"rule_type": {"const": "service_up"},
+					"tier": {"type": "string"},
+					"service_name": {"type": "string", "x-dynamic-options": {
+						"type": "prometheus_query",
+						"label": "job",
+						"match": "up{namespace=\"{{.target.namespace}}\",tier=\"{{.tier}}\"}",
+						"dependencies": ["target.namespace", "tier"],
+						"cache_ttl": "0s"
+					}}
+				}}
+			]}}
+		}
+	}`, nil)
+	service := NewService(mockTP, nil, nil)
+	ctx := context.Background()
+	values := FieldValues{
+		"target": map[string]interface{}{"namespace": "prod"},
+		"rules": []interface{}{
+			map[string]interface{}{"rule_type": "cpu", "threshold": 80},
+			map[string]interface{}{"rule_type": "service_up", "tier": "frontend"},
+		},
+	}
+
+	t.Run("Index", func(t *testing.T) {
+		matches = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "rules[1].service_name", Values: values})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"checkout", "payments"}, options)
+		assert.Equal(t, []string{`up{namespace="prod",tier="frontend"}`}, matches)
+	})
+
+	t.Run("DiscriminatorWithItem", func(t *testing.T) {
+		matches = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{
+			TemplateName: "k8s",
+			FieldPath:    "rules[rule_type=service_up].service_name",
+			Values:       values,
+			Item:         FieldValues{"rule_type": "service_up", "tier": "backend"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"checkout", "payments"}, options)
+		assert.Equal(t, []string{`up{namespace="prod",tier="backend"}`}, matches)
+	})
+
+	t.Run("DiscriminatorWithoutItem", func(t *testing.T) {
+		matches = nil
+
+		options, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "rules[rule_type=service_up].service_name", Values: values})
+
+		assert.NoError(t, err)
+		assert.Empty(t, options, "the item dependency has no value")
+		assert.Empty(t, matches)
+	})
+
+	t.Run("IndexOfOtherBranch", func(t *testing.T) {
+		_, err := service.GetOptions(ctx, OptionsQuery{TemplateName: "k8s", FieldPath: "rules[0].service_name", Values: values})
+
+		assert.EqualError(t, err, "field 'rules[0].service_name' not found in schema: field 'service_name' not found at level 1")
+	})
+}
@@ -0,0 +1,69 @@
+package rules
+
+import "rulemanager/config"
+
+// ServiceOption configures optional Service behavior.
+type ServiceOption func(*Service)
+
+// WithPolicies enables the global policies of the set and makes its libraries available to cel steps.
+func WithPolicies(policies *PolicySet) ServiceOption {
+	return func(s *Service) {
+		s.policies = policies
+		s.pipelineProcessor.RegisterRunner("cel", &CELRunner{Libraries: policies.Libraries})
+	}
+}
+
+// WithPipelineConfig overrides the concurrency, deadline and query cache TTL of pipeline execution.
+func WithPipelineConfig(cfg config.PipelinesConfig) ServiceOption {
+	return func(s *Service) {
+		p := s.pipelineProcessor
+		if cfg.Concurrency > 0 {
+			p.concurrency = cfg.Concurrency
+		}
+		if cfg.Timeout > 0 {
+			p.timeout = cfg.Timeout
+		}
+		switch {
+		case cfg.QueryCacheTTL < 0:
+			p.cache = nil
+		case cfg.QueryCacheTTL > 0:
+			p.cache = newQueryCache(cfg.QueryCacheTTL, p.timeout, nil)
+		case p.cache != nil:
+			p.cache.group.timeout = p.timeout
+		}
+	}
+}
+
+// WithOptionsConfig overrides the cache TTL of dynamic options lookups.
+func WithOptionsConfig(cfg config.OptionsConfig) ServiceOption {
+	return func(s *Service) {
+		if cfg.CacheTTL != 0 {
+			s.optionsCacheTTL = cfg.CacheTTL
+		}
+	}
+}
+
+// WithStepRunners registers additional step runners, e.g. the external runners built by NewSubprocessRunners.
+func WithStepRunners(runners map[string]StepRunner) ServiceOption {
+	return func(s *Service) {
+		for name, runner := range runners {
+			s.pipelineProcessor.RegisterRunner(name, runner)
+		}
+	}
+}
+
+// WithDatasources makes the named datasources of the registry available to template schemas.
+func WithDatasources(datasources *DatasourceRegistry) ServiceOption {
+	return func(s *Service) {
+		s.datasources = datasources
+	}
+}
+
+// WithOptionsProviders registers additional x-dynamic-options providers, keyed by type.
+func WithOptionsProviders(providers map[string]OptionsProvider) ServiceOption {
+	return func(s *Service) {
+		for name, provider := range providers {
+			s.optionsProviders[name] = provider
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultOptionsCacheTTL is how long resolved dynamic options are reused across requests.
+const DefaultOptionsCacheTTL = time.Minute
+
+// optionsCache keeps resolved options for a per-lookup TTL and de-duplicates concurrent lookups of
+// the same key, so a form opened by many users queries the datasource once. Errors are never cached.
+// Cached slices are shared and must not be modified.
+type optionsCache struct {
+	group sharedFetches[[]string]
+
+	mu        sync.Mutex
+	entries   map[string]optionsCacheEntry
+	nextSweep time.Time
+}
+
+type optionsCacheEntry struct {
+	values  []string
+	expires time.Time
+}
+
+// newOptionsCache creates an options cache whose lookups shared by concurrent callers run for at
+// most fetchTimeout.
+func newOptionsCache(fetchTimeout time.Duration) *optionsCache {
+	return &optionsCache{group: sharedFetches[[]string]{timeout: fetchTimeout}, entries: make(map[string]optionsCacheEntry)}
+}
+
+// get returns the cached options for key, or calls fetch once for all concurrent callers of the same key.
+// Each caller stops waiting when its own ctx is done (see sharedFetches). A nil cache or a non-positive ttl always fetches.
+func (c *optionsCache) get(ctx context.Context, key string, ttl time.Duration, fetch func(context.Context) ([]string, error)) ([]string, error) {
+	if c == nil || ttl <= 0 {
+		return fetch(ctx)
+	}
+	if values, ok := c.lookup(key); ok {
+		return values, nil
+	}
+
+	return c.group.do(ctx, key, func(ctx context.Context) ([]string, error) {
+		values, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, values, ttl)
+		return values, nil
+	})
+}
+
+func (c *optionsCache) lookup(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.values, true
+}
+
+func (c *optionsCache) store(key string, values []string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	// Drop expired entries at most once per TTL so the cache does not grow unbounded
+	if now.After(c.nextSweep) {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(ttl)
+	}
+	c.entries[key] = optionsCacheEntry{values: values, expires: now.Add(ttl)}
+}
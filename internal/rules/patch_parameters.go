@@ -0,0 +1,73 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PatchParametersParams defines parameters for the patch_parameters pipeline step.
+type PatchParametersParams struct {
+	// Patch is a JSON patch (RFC 6902). String values support templating with the rule parameters.
+	Patch []interface{} `json:"patch"`
+}
+
+// PatchParametersRunner returns a static, templated JSON patch, e.g. to default labels from other parameters.
+type PatchParametersRunner struct{}
+
+// Run renders the configured patch.
+func (r *PatchParametersRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	var params PatchParametersParams
+	if err := json.Unmarshal(input.StepParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid step parameters: %w", err)
+	}
+	if len(params.Patch) == 0 {
+		return nil, fmt.Errorf("patch is required")
+	}
+
+	var ruleData interface{}
+	if err := json.Unmarshal(input.RuleParams, &ruleData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+	}
+
+	rendered, err := renderValues(params.Patch, ruleData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render patch: %w", err)
+	}
+	patch, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StepOutput{Patch: patch}, nil
+}
+
+// renderValues renders every string in a decoded JSON value as a template.
+func renderValues(value interface{}, data interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return renderString(v, data)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := renderValues(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered, err := renderValues(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,34 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchParametersRunner_Run(t *testing.T) {
+	runner := &PatchParametersRunner{}
+	ruleParams := json.RawMessage(`{"target": {"workload": "Payment-API"}}`)
+
+	output, err := runner.Run(context.Background(), &StepInput{
+		RuleParams: ruleParams,
+		StepParams: json.RawMessage(`{"patch": [
+			{"op": "replace", "path": "/target/workload", "value": "{{ .target.workload | printf \"%s\" }}-normalized"},
+			{"op": "add", "path": "/common/labels", "value": {"owner": "{{ .target.workload }}", "tier": 1}}
+		]}`),
+	})
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "/target/workload", "value": "Payment-API-normalized"},
+		{"op": "add", "path": "/common/labels", "value": {"owner": "Payment-API", "tier": 1}}
+	]`, string(output.Patch))
+
+	t.Run("MissingPatch", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), &StepInput{RuleParams: ruleParams, StepParams: json.RawMessage(`{}`)})
+
+		assert.EqualError(t, err, "patch is required")
+	})
+}
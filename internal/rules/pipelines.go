@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
@@ -16,47 +21,202 @@ type PipelineStep struct {
 	Name       string             `json:"name"`
 	Type       string             `json:"type"`
 	Condition  *PipelineCondition `json:"condition,omitempty"`
+	OnFailure  string             `json:"on_failure,omitempty"` // error (default), warn or ignore
 	Parameters json.RawMessage    `json:"parameters"`
 }
 
+// Severity levels for a failing pipeline step, set via PipelineStep.OnFailure.
+const (
+	OnFailureError  = "error"  // the step blocks persistence
+	OnFailureWarn   = "warn"   // the step is reported as a warning but does not block
+	OnFailureIgnore = "ignore" // the step outcome is recorded but otherwise ignored
+)
+
 // PipelineCondition defines a condition for executing a pipeline step.
-// Supports multiple value types for flexible comparisons.
+// A condition is either a comparison on a parameter path or a combination of nested conditions;
+// when several parts are set on the same object, all of them must hold. See conditions.go.
 type PipelineCondition struct {
-	Property    string   `json:"property"`
+	// Property is the legacy form of Path: a top-level key, taken literally, compared for equality
+	// with one of the typed values below.
+	Property    string   `json:"property,omitempty"`
 	StringValue *string  `json:"string_value,omitempty"`
 	BoolValue   *bool    `json:"bool_value,omitempty"`
 	NumberValue *float64 `json:"number_value,omitempty"`
+
+	// Path addresses a rule parameter using dots and array indexes, e.g. "target.namespace" or "rules[0].threshold".
+	Path string `json:"path,omitempty"`
+	// Operator is one of eq (default), ne, gt, gte, lt, lte, in, not_in, matches or exists.
+	Operator string `json:"operator,omitempty"`
+	// Value is the operand: a list for in/not_in, a regex for matches, an optional bool for exists.
+	Value interface{} `json:"value,omitempty"`
+
+	All []*PipelineCondition `json:"all,omitempty"`
+	Any []*PipelineCondition `json:"any,omitempty"`
+	Not *PipelineCondition   `json:"not,omitempty"`
 }
 
 // ValidateMetricExistsParams defines parameters for the validate_metric_exists pipeline step.
 type ValidateMetricExistsParams struct {
-	MetricName string            `json:"metric_name"`
-	Labels     map[string]string `json:"labels,omitempty"`
+	MetricName string `json:"metric_name"`
+	// Labels are label matchers the metric must have data for. Values support templating and may start
+	// with a matcher operator ("=~", "!=", "!~"); without one the value must match exactly.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
-// DatasourceConfig defines the connection details for a datasource.
+// DatasourceConfig defines the datasource of a template schema: either a reference to a named
+// datasource from the registry ({"name": "vm-prod"}) or an unauthenticated inline datasource
+// ({"type": "prometheus", "url": "..."}). Credentials of named datasources are never serialized.
 type DatasourceConfig struct {
-	Type string `json:"type"`
-	URL  string `json:"url"`
+	Name string `json:"name,omitempty"`
+	// Environment selects the per-environment overrides of a named datasource. Supports templating
+	// with the rule parameters, e.g. "{{ .target.environment }}".
+	Environment string `json:"environment,omitempty"`
+	Type        string `json:"type,omitempty"`
+	URL         string `json:"url,omitempty"`
+
+	conn *datasourceConn // set for datasources resolved from the registry
+	err  error           // set if the reference could not be resolved; reported by the steps that query it
+}
+
+// StepInput carries everything a StepRunner may need to execute a step.
+type StepInput struct {
+	Datasource *DatasourceConfig
+	// RuleParams are the rule parameters. For per-rule pipelines the current rule item is merged into the root.
+	RuleParams json.RawMessage
+	// StepParams are the step's own parameters from the schema. Set by the processor for each step.
+	StepParams json.RawMessage
+	// RenderRule renders the rule YAML from the template's Go template. It is evaluated lazily,
+	// so the template is only fetched when a runner needs it. Nil when no template is available.
+	RenderRule func() (string, error)
+
+	// queries de-duplicates datasource queries within a request; nil disables caching.
+	queries *queryCache
+	// limiter bounds the number of steps running at once within a request.
+	limiter chan struct{}
+}
+
+// queryDatasource runs a Prometheus query against the step's datasource through the request's query cache.
+func (in *StepInput) queryDatasource(ctx context.Context, client *http.Client, path string, params url.Values) (*promQueryResponse, error) {
+	return in.queries.get(ctx, in.Datasource.cacheKey(path, params), func(ctx context.Context) (*promQueryResponse, error) {
+		return queryPrometheus(ctx, client, in.Datasource, path, params)
+	})
+}
+
+// StepOutput carries optional information produced by a successful step.
+type StepOutput struct {
+	Message  string
+	Warnings []string
+	// Patch is a JSON patch (RFC 6902) the step proposes for the rule parameters.
+	Patch json.RawMessage
 }
 
 // StepRunner defines the interface for a pipeline step runner.
 type StepRunner interface {
-	Run(ctx context.Context, datasource *DatasourceConfig, ruleParams json.RawMessage, stepParams json.RawMessage) error
+	Run(ctx context.Context, input *StepInput) (*StepOutput, error)
+}
+
+// Step statuses reported in a StepResult.
+const (
+	StepStatusPassed  = "passed"
+	StepStatusFailed  = "failed"  // failed with on_failure=error
+	StepStatusWarning = "warning" // failed with on_failure=warn
+	StepStatusIgnored = "ignored" // failed with on_failure=ignore
+	StepStatusSkipped = "skipped"
+)
+
+// StepResult records the outcome of a single pipeline step.
+type StepResult struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Scope      string          `json:"scope,omitempty"` // "global" or the rule the step ran for (e.g. "rules[0] (cpu)")
+	Status     string          `json:"status"`
+	OnFailure  string          `json:"on_failure"`
+	DurationMs int64           `json:"duration_ms"`
+	Message    string          `json:"message,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"` // non-blocking findings reported by a passing step
+	Patch      json.RawMessage `json:"patch,omitempty"`
+}
+
+// PipelineReport collects the results of every pipeline step executed for a rule.
+type PipelineReport struct {
+	Steps []StepResult `json:"steps"`
+}
+
+// Passed reports whether no step in the report failed. A nil report is considered passed.
+func (r *PipelineReport) Passed() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the steps that failed and block persistence.
+func (r *PipelineReport) Failed() []StepResult {
+	return r.withStatus(StepStatusFailed)
+}
+
+// Warnings returns the steps that failed with on_failure=warn.
+// Warnings reported by passing steps are listed in their StepResult.Warnings.
+func (r *PipelineReport) Warnings() []StepResult {
+	return r.withStatus(StepStatusWarning)
+}
+
+func (r *PipelineReport) withStatus(status string) []StepResult {
+	if r == nil {
+		return nil
+	}
+	var steps []StepResult
+	for _, step := range r.Steps {
+		if step.Status == status {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Err summarizes the failed steps as a single error, or returns nil if the report passed.
+func (r *PipelineReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(failed))
+	for _, step := range failed {
+		name := step.Name
+		if step.Scope != "" {
+			name = fmt.Sprintf("%s/%s", step.Scope, step.Name)
+		}
+		msgs = append(msgs, fmt.Sprintf("pipeline step '%s' failed: %s", name, step.Message))
+	}
+	return errors.New(strings.Join(msgs, "; "))
 }
 
 // PipelineProcessor manages the execution of pipeline steps.
 type PipelineProcessor struct {
-	runners map[string]StepRunner
+	runners     map[string]StepRunner
+	concurrency int
+	timeout     time.Duration
+	cache       *queryCache
 }
 
+// Defaults for pipeline execution, see config.PipelinesConfig.
+const (
+	DefaultPipelineConcurrency   = 8
+	DefaultPipelineTimeout       = 30 * time.Second
+	DefaultPipelineQueryCacheTTL = 15 * time.Second
+)
+
 // NewPipelineProcessor creates a new PipelineProcessor with built-in runners.
 func NewPipelineProcessor() *PipelineProcessor {
 	p := &PipelineProcessor{
-		runners: make(map[string]StepRunner),
+		runners:     make(map[string]StepRunner),
+		concurrency: DefaultPipelineConcurrency,
+		timeout:     DefaultPipelineTimeout,
+		cache:       newQueryCache(DefaultPipelineQueryCacheTTL, DefaultPipelineTimeout, nil),
 	}
 	// Register built-in runners
 	p.RegisterRunner("validate_metric_exists", &ValidateMetricExistsRunner{})
+	p.RegisterRunner("validate_expression", &ValidateExpressionRunner{})
+	p.RegisterRunner("http_webhook", &HTTPWebhookRunner{})
+	p.RegisterRunner("patch_parameters", &PatchParametersRunner{})
+	p.RegisterRunner("cel", &CELRunner{})
 	p.RegisterRunner("dummy_always_pass", &DummyAlwaysPassRunner{})
 	return p
 }
@@ -66,67 +226,121 @@ func (p *PipelineProcessor) RegisterRunner(name string, runner StepRunner) {
 	p.runners[name] = runner
 }
 
-// Execute runs a sequence of pipeline steps.
-func (p *PipelineProcessor) Execute(ctx context.Context, schemaPipelines []PipelineStep, datasource *DatasourceConfig, ruleParams json.RawMessage) error {
-	for _, step := range schemaPipelines {
-		// Check condition
-		if step.Condition != nil {
-			if !p.evaluateCondition(step.Condition, ruleParams) {
-				continue
-			}
-		}
+// Execute runs every step of a pipeline and returns a result for each of them, in step order.
+// Steps are independent and run concurrently, bounded by the input's limiter (or the processor's
+// concurrency if the input has none). Failing steps do not stop execution; their status is derived
+// from the step's on_failure level. The returned error is non-nil if at least one step failed with on_failure=error.
+func (p *PipelineProcessor) Execute(ctx context.Context, schemaPipelines []PipelineStep, input StepInput) ([]StepResult, error) {
+	if input.limiter == nil {
+		input.limiter = make(chan struct{}, p.concurrency)
+	}
 
-		// Find runner
-		runner, ok := p.runners[step.Type]
-		if !ok {
-			return fmt.Errorf("unknown pipeline step type: %s", step.Type)
-		}
+	results := make([]StepResult, len(schemaPipelines))
+	var wg sync.WaitGroup
+	for i, step := range schemaPipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.runStep(ctx, step, input)
+		}()
+	}
+	wg.Wait()
 
-		// Run step
-		if err := runner.Run(ctx, datasource, ruleParams, step.Parameters); err != nil {
-			return fmt.Errorf("pipeline step '%s' failed: %w", step.Name, err)
+	var blocking []string
+	for i, result := range results {
+		if result.Status == StepStatusFailed {
+			blocking = append(blocking, fmt.Sprintf("pipeline step '%s' failed: %s", schemaPipelines[i].Name, result.Message))
 		}
 	}
-	return nil
+
+	if len(blocking) > 0 {
+		return results, errors.New(strings.Join(blocking, "; "))
+	}
+	return results, nil
 }
 
-// evaluateCondition checks if a pipeline condition is met.
-func (p *PipelineProcessor) evaluateCondition(condition *PipelineCondition, ruleParams json.RawMessage) bool {
-	var params map[string]interface{}
-	if err := json.Unmarshal(ruleParams, &params); err != nil {
-		return false
+// newRequest prepares the shared state of one request's pipelines: a deadline, a worker limit and a
+// request-scoped query cache backed by the processor-wide cache. The returned function releases the deadline.
+func (p *PipelineProcessor) newRequest(ctx context.Context) (context.Context, StepInput, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	return ctx, StepInput{
+		queries: newQueryCache(0, p.timeout, p.cache),
+		limiter: make(chan struct{}, p.concurrency),
+	}, cancel
+}
+
+// runStep evaluates the condition of a single step, runs it and classifies the outcome.
+func (p *PipelineProcessor) runStep(ctx context.Context, step PipelineStep, input StepInput) StepResult {
+	result := StepResult{Name: step.Name, Type: step.Type, OnFailure: step.OnFailure}
+	if result.OnFailure == "" {
+		result.OnFailure = OnFailureError
+	}
+
+	// Misconfigured steps always block, regardless of their on_failure level
+	switch result.OnFailure {
+	case OnFailureError, OnFailureWarn, OnFailureIgnore:
+	default:
+		result.Status = StepStatusFailed
+		result.Message = fmt.Sprintf("invalid on_failure value: %s (expected error, warn or ignore)", step.OnFailure)
+		return result
 	}
 
-	val, ok := params[condition.Property]
+	runner, ok := p.runners[step.Type]
 	if !ok {
-		return false
+		result.Status = StepStatusFailed
+		result.Message = fmt.Sprintf("unknown pipeline step type: %s", step.Type)
+		return result
 	}
 
-	// Check string value
-	if condition.StringValue != nil {
-		if strVal, ok := val.(string); ok {
-			return strVal == *condition.StringValue
+	// Check condition. An invalid condition is a misconfiguration and always blocks.
+	if step.Condition != nil {
+		met, err := evaluateCondition(step.Condition, input.RuleParams)
+		if err != nil {
+			result.Status = StepStatusFailed
+			result.Message = fmt.Sprintf("invalid condition: %v", err)
+			return result
+		}
+		if !met {
+			result.Status = StepStatusSkipped
+			result.Message = "condition not met"
+			return result
 		}
-		return false
 	}
 
-	// Check boolean value
-	if condition.BoolValue != nil {
-		if boolVal, ok := val.(bool); ok {
-			return boolVal == *condition.BoolValue
-		}
-		return false
+	// Wait for a worker slot
+	select {
+	case input.limiter <- struct{}{}:
+		defer func() { <-input.limiter }()
+	case <-ctx.Done():
+		result.Status = StepStatusFailed
+		result.Message = fmt.Sprintf("pipeline deadline exceeded: %v", ctx.Err())
+		return result
 	}
 
-	// Check number value
-	if condition.NumberValue != nil {
-		if numVal, ok := val.(float64); ok {
-			return numVal == *condition.NumberValue
+	input.StepParams = step.Parameters
+	start := time.Now()
+	output, err := runner.Run(ctx, &input)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err == nil {
+		result.Status = StepStatusPassed
+		if output != nil {
+			result.Message = output.Message
+			result.Warnings = output.Warnings
+			result.Patch = output.Patch
 		}
-		return false
+		return result
 	}
 
-	return false
+	result.Message = err.Error()
+	switch result.OnFailure {
+	case OnFailureWarn:
+		result.Status = StepStatusWarning
+	case OnFailureIgnore:
+		result.Status = StepStatusIgnored
+	default:
+		result.Status = StepStatusFailed
+	}
+	return result
 }
 
 // ValidateMetricExistsRunner checks if a metric exists in the datasource.
@@ -135,93 +349,188 @@ type ValidateMetricExistsRunner struct {
 }
 
 // Run executes the metric validation step.
-func (r *ValidateMetricExistsRunner) Run(ctx context.Context, datasource *DatasourceConfig, ruleParams json.RawMessage, stepParams json.RawMessage) error {
-	if datasource == nil {
-		return fmt.Errorf("datasource configuration is required for metric validation")
-	}
-	if datasource.Type != "prometheus" && datasource.Type != "victoriametrics" && datasource.Type != "thanos" {
-		// Assuming these all support PromQL
-		return fmt.Errorf("unsupported datasource type for metric validation: %s", datasource.Type)
+func (r *ValidateMetricExistsRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	if err := requirePromQLDatasource(input.Datasource); err != nil {
+		return nil, err
 	}
 
 	// Parse step parameters into typed struct
 	var params ValidateMetricExistsParams
-	if err := json.Unmarshal(stepParams, &params); err != nil {
-		return fmt.Errorf("invalid step parameters: %w", err)
+	if err := json.Unmarshal(input.StepParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid step parameters: %w", err)
 	}
 
 	if params.MetricName == "" {
-		return fmt.Errorf("metric_name is required")
+		return nil, fmt.Errorf("metric_name is required")
 	}
 
 	// Render template with rule parameters
 	var ruleData interface{}
-	if err := json.Unmarshal(ruleParams, &ruleData); err != nil {
-		return fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+	if err := json.Unmarshal(input.RuleParams, &ruleData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
 	}
 
 	metricName, err := renderString(params.MetricName, ruleData)
 	if err != nil {
-		return fmt.Errorf("failed to render metric_name: %w", err)
+		return nil, fmt.Errorf("failed to render metric_name: %w", err)
 	}
 
-	// Construct selector and query
-	selector := fmt.Sprintf("{__name__=%q}", metricName)
+	matchers, err := renderLabelMatchers(params.Labels, ruleData)
+	if err != nil {
+		return nil, err
+	}
 
-	query := fmt.Sprintf("count(%s)", selector)
+	found, err := r.hasSeries(ctx, input, metricName, matchers)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, nil
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("metric '%s' not found", metricName)
+	}
 
-	// 4. Execute Query
-	u, err := url.Parse(datasource.URL)
+	// Distinguish a missing metric from a label combination without data
+	exists, err := r.hasSeries(ctx, input, metricName, nil)
 	if err != nil {
-		return fmt.Errorf("invalid datasource URL: %w", err)
+		return nil, err
 	}
-	u.Path = "/api/v1/query" // Instant query is enough
-	q := u.Query()
-	q.Set("query", query)
-	u.RawQuery = q.Encode()
+	if !exists {
+		return nil, fmt.Errorf("metric '%s' not found", metricName)
+	}
+	return nil, fmt.Errorf("metric '%s' has no data for %s", metricName, strings.Join(matchers, ", "))
+}
+
+// hasSeries reports whether the datasource has any series for the metric and label matchers.
+func (r *ValidateMetricExistsRunner) hasSeries(ctx context.Context, input *StepInput, metricName string, matchers []string) (bool, error) {
+	selector := fmt.Sprintf("{%s}", strings.Join(append([]string{fmt.Sprintf("__name__=%q", metricName)}, matchers...), ","))
+	query := fmt.Sprintf("count(%s)", selector)
 
-	client := r.Client
-	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+	// Instant query is enough
+	result, err := input.queryDatasource(ctx, r.Client, "/api/v1/query", url.Values{"query": {query}})
+	if err != nil {
+		return false, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	series, err := result.Series()
 	if err != nil {
-		return err
+		return false, err
+	}
+	return len(series) > 0, nil
+}
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// renderLabelMatchers renders label matcher values with the rule parameters and returns
+// them as PromQL matchers (e.g. namespace="foo", pod=~"api-.*"), sorted by label name.
+func renderLabelMatchers(labels map[string]string, data interface{}) ([]string, error) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := make([]string, 0, len(names))
+	for _, name := range names {
+		if !labelNamePattern.MatchString(name) || name == "__name__" {
+			return nil, fmt.Errorf("invalid label name: %s", name)
+		}
+
+		value, err := renderString(labels[name], data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render label '%s': %w", name, err)
+		}
+
+		op := "="
+		for _, candidate := range []string{"=~", "!~", "!="} {
+			if strings.HasPrefix(value, candidate) {
+				op, value = candidate, strings.TrimPrefix(value, candidate)
+				break
+			}
+		}
+		if op == "=~" || op == "!~" {
+			if _, err := regexp.Compile(value); err != nil {
+				return nil, fmt.Errorf("invalid regex for label '%s': %w", name, err)
+			}
+		}
+
+		matchers = append(matchers, fmt.Sprintf("%s%s%q", name, op, value))
 	}
+	return matchers, nil
+}
 
-	resp, err := client.Do(req)
+// requirePromQLDatasource checks that a datasource is configured and speaks PromQL.
+func requirePromQLDatasource(datasource *DatasourceConfig) error {
+	if datasource == nil {
+		return fmt.Errorf("datasource configuration is required for metric validation")
+	}
+	if datasource.err != nil {
+		return datasource.err
+	}
+	if datasource.Type != "prometheus" && datasource.Type != "victoriametrics" && datasource.Type != "thanos" {
+		// Assuming these all support PromQL
+		return fmt.Errorf("unsupported datasource type for metric validation: %s", datasource.Type)
+	}
+	return nil
+}
+
+// promSeries is a single series returned by a Prometheus query API call.
+type promSeries struct {
+	Metric map[string]string `json:"metric"`
+}
+
+// promQueryResponse is the response of the Prometheus /api/v1/query and /api/v1/query_range endpoints.
+type promQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Series returns the series of a vector or matrix result. Scalar and string results yield a single unlabeled series.
+func (r *promQueryResponse) Series() ([]promSeries, error) {
+	switch r.Data.ResultType {
+	case "scalar", "string":
+		return []promSeries{{Metric: map[string]string{}}}, nil
+	}
+	var series []promSeries
+	if len(r.Data.Result) > 0 {
+		if err := json.Unmarshal(r.Data.Result, &series); err != nil {
+			return nil, fmt.Errorf("failed to decode query result: %w", err)
+		}
+	}
+	return series, nil
+}
+
+// queryPrometheus runs a GET request against a Prometheus-compatible query endpoint.
+func queryPrometheus(ctx context.Context, client *http.Client, datasource *DatasourceConfig, path string, params url.Values) (*promQueryResponse, error) {
+	resp, err := datasource.get(ctx, client, path, params)
 	if err != nil {
-		return fmt.Errorf("failed to query datasource: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("datasource returned status %d", resp.StatusCode)
-	}
-
-	var result struct {
-		Status string `json:"status"`
-		Data   struct {
-			ResultType string        `json:"resultType"`
-			Result     []interface{} `json:"result"`
-		} `json:"data"`
+		return nil, fmt.Errorf("datasource returned status %d", resp.StatusCode)
 	}
 
+	var result promQueryResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("failed to decode datasource response: %w", err)
+		return nil, fmt.Errorf("failed to decode datasource response: %w", err)
 	}
 
 	if result.Status != "success" {
-		return fmt.Errorf("datasource query failed")
-	}
-
-	// Metric exists if query returns results
-	if len(result.Data.Result) == 0 {
-		return fmt.Errorf("metric '%s' not found", metricName)
+		if result.Error != "" {
+			return nil, fmt.Errorf("datasource query failed: %s", result.Error)
+		}
+		return nil, fmt.Errorf("datasource query failed")
 	}
 
-	return nil
+	return &result, nil
 }
 
 func renderString(tmplStr string, data interface{}) (string, error) {
@@ -240,6 +549,6 @@ func renderString(tmplStr string, data interface{}) (string, error) {
 type DummyAlwaysPassRunner struct{}
 
 // Run always returns nil (success).
-func (r *DummyAlwaysPassRunner) Run(ctx context.Context, datasource *DatasourceConfig, ruleParams json.RawMessage, stepParams json.RawMessage) error {
-	return nil
+func (r *DummyAlwaysPassRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	return nil, nil
 }
@@ -3,9 +3,15 @@ package rules
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"rulemanager/config"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -131,7 +137,7 @@ func TestPipelineProcessor_Execute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := processor.Execute(context.Background(), tt.pipelines, datasource, tt.ruleParams)
+			_, err := processor.Execute(context.Background(), tt.pipelines, StepInput{Datasource: datasource, RuleParams: tt.ruleParams})
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -210,7 +216,7 @@ func TestPipelineProcessor_TargetValidation(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			paramsJSON, _ := json.Marshal(tt.params)
-			err := processor.Execute(context.Background(), tt.pipelines, datasource, paramsJSON)
+			_, err := processor.Execute(context.Background(), tt.pipelines, StepInput{Datasource: datasource, RuleParams: paramsJSON})
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -219,3 +225,309 @@ func TestPipelineProcessor_TargetValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineProcessor_ExecuteReport(t *testing.T) {
+	processor := NewPipelineProcessor()
+
+	pipelines := []PipelineStep{
+		{
+			Name:       "always_pass",
+			Type:       "dummy_always_pass",
+			Parameters: json.RawMessage(`{}`),
+		},
+		{
+			Name: "conditional",
+			Type: "dummy_always_pass",
+			Condition: &PipelineCondition{
+				Property:    "check",
+				StringValue: stringPtr("yes"),
+			},
+			Parameters: json.RawMessage(`{}`),
+		},
+		{
+			Name:       "no_datasource",
+			Type:       "validate_metric_exists",
+			Parameters: json.RawMessage(`{"metric_name": "up"}`),
+		},
+		{
+			Name:       "after_failure",
+			Type:       "dummy_always_pass",
+			Parameters: json.RawMessage(`{}`),
+		},
+	}
+
+	results, err := processor.Execute(context.Background(), pipelines, StepInput{RuleParams: json.RawMessage(`{"check": "no"}`)})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no_datasource")
+	assert.Len(t, results, 4)
+	assert.Equal(t, StepStatusPassed, results[0].Status)
+	assert.Equal(t, "dummy_always_pass", results[0].Type)
+	assert.Equal(t, StepStatusSkipped, results[1].Status)
+	assert.Equal(t, "condition not met", results[1].Message)
+	assert.Equal(t, StepStatusFailed, results[2].Status)
+	assert.Contains(t, results[2].Message, "datasource configuration is required")
+	assert.Equal(t, StepStatusPassed, results[3].Status, "steps after a failure still run")
+
+	t.Run("UnknownStepType", func(t *testing.T) {
+		results, err := processor.Execute(context.Background(), []PipelineStep{{Name: "bogus", Type: "does_not_exist"}}, StepInput{RuleParams: json.RawMessage(`{}`)})
+
+		assert.Error(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, StepStatusFailed, results[0].Status)
+		assert.Contains(t, results[0].Message, "unknown pipeline step type")
+	})
+
+	t.Run("InvalidCondition", func(t *testing.T) {
+		step := PipelineStep{
+			Name:      "bad_condition",
+			Type:      "dummy_always_pass",
+			OnFailure: OnFailureIgnore,
+			Condition: &PipelineCondition{Path: "rule_type", Operator: "like", Value: "cpu"},
+		}
+		results, err := processor.Execute(context.Background(), []PipelineStep{step}, StepInput{RuleParams: json.RawMessage(`{}`)})
+
+		assert.Error(t, err)
+		assert.Equal(t, StepStatusFailed, results[0].Status, "misconfigured conditions block regardless of on_failure")
+		assert.Contains(t, results[0].Message, "invalid condition: unknown condition operator")
+	})
+}
+
+func TestPipelineProcessor_OnFailure(t *testing.T) {
+	processor := NewPipelineProcessor()
+	failing := json.RawMessage(`{"metric_name": "up"}`) // fails: no datasource
+
+	pipelines := []PipelineStep{
+		{Name: "default", Type: "validate_metric_exists", Parameters: failing},
+		{Name: "warn", Type: "validate_metric_exists", OnFailure: OnFailureWarn, Parameters: failing},
+		{Name: "ignore", Type: "validate_metric_exists", OnFailure: OnFailureIgnore, Parameters: failing},
+		{Name: "bogus", Type: "dummy_always_pass", OnFailure: "sometimes", Parameters: json.RawMessage(`{}`)},
+	}
+
+	results, err := processor.Execute(context.Background(), pipelines, StepInput{RuleParams: json.RawMessage(`{}`)})
+
+	assert.Error(t, err)
+	assert.Len(t, results, 4)
+	assert.Equal(t, StepStatusFailed, results[0].Status)
+	assert.Equal(t, OnFailureError, results[0].OnFailure)
+	assert.Equal(t, StepStatusWarning, results[1].Status)
+	assert.Equal(t, StepStatusIgnored, results[2].Status)
+	assert.NotEmpty(t, results[2].Message)
+	assert.Equal(t, StepStatusFailed, results[3].Status)
+	assert.Contains(t, results[3].Message, "invalid on_failure value")
+
+	t.Run("WarningsDoNotBlock", func(t *testing.T) {
+		results, err := processor.Execute(context.Background(), pipelines[1:3], StepInput{RuleParams: json.RawMessage(`{}`)})
+
+		assert.NoError(t, err)
+		report := &PipelineReport{Steps: results}
+		assert.True(t, report.Passed())
+		assert.Len(t, report.Warnings(), 1)
+		assert.Equal(t, "warn", report.Warnings()[0].Name)
+	})
+}
+
+func TestPipelineReport(t *testing.T) {
+	var nilReport *PipelineReport
+	assert.True(t, nilReport.Passed())
+	assert.NoError(t, nilReport.Err())
+
+	report := &PipelineReport{Steps: []StepResult{
+		{Name: "a", Scope: "global", Status: StepStatusPassed},
+		{Name: "b", Scope: "rules[0] (cpu)", Status: StepStatusFailed, Message: "metric 'x' not found"},
+	}}
+	assert.False(t, report.Passed())
+	assert.Len(t, report.Failed(), 1)
+	assert.EqualError(t, report.Err(), "pipeline step 'rules[0] (cpu)/b' failed: metric 'x' not found")
+}
+
+func TestValidateMetricExistsRunner_Labels(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		queries = append(queries, query)
+		switch query {
+		case `count({__name__="kube_pod_info",namespace="demo",pod=~"api-.*"})`, `count({__name__="kube_pod_info"})`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[123,"1"]}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}
+	}))
+	defer ts.Close()
+
+	runner := &ValidateMetricExistsRunner{Client: ts.Client()}
+	datasource := &DatasourceConfig{Type: "prometheus", URL: ts.URL}
+	ruleParams := json.RawMessage(`{"target": {"namespace": "demo", "workload": "api"}, "missing_ns": "foo"}`)
+
+	tests := []struct {
+		name        string
+		params      string
+		expectError string
+	}{
+		{
+			name:   "Templated And Regex Matchers",
+			params: `{"metric_name": "kube_pod_info", "labels": {"namespace": "{{ .target.namespace }}", "pod": "=~{{ .target.workload }}-.*"}}`,
+		},
+		{
+			name:        "Label Combination Without Data",
+			params:      `{"metric_name": "kube_pod_info", "labels": {"namespace": "{{ .missing_ns }}"}}`,
+			expectError: `metric 'kube_pod_info' has no data for namespace="foo"`,
+		},
+		{
+			name:        "Metric Missing",
+			params:      `{"metric_name": "nope", "labels": {"namespace": "demo"}}`,
+			expectError: "metric 'nope' not found",
+		},
+		{
+			name:        "Invalid Regex",
+			params:      `{"metric_name": "kube_pod_info", "labels": {"pod": "=~api-("}}`,
+			expectError: "invalid regex for label 'pod'",
+		},
+		{
+			name:        "Invalid Label Name",
+			params:      `{"metric_name": "kube_pod_info", "labels": {"bad-name": "x"}}`,
+			expectError: "invalid label name: bad-name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runner.Run(context.Background(), &StepInput{
+				Datasource: datasource,
+				RuleParams: ruleParams,
+				StepParams: json.RawMessage(tt.params),
+			})
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("NegativeMatchers", func(t *testing.T) {
+		queries = nil
+		_, _ = runner.Run(context.Background(), &StepInput{
+			Datasource: datasource,
+			RuleParams: ruleParams,
+			StepParams: json.RawMessage(`{"metric_name": "kube_pod_info", "labels": {"a": "!=x", "b": "!~y.*"}}`),
+		})
+		assert.Equal(t, `count({__name__="kube_pod_info",a!="x",b!~"y.*"})`, queries[0])
+	})
+}
+
+// blockingRunner records how many runs overlap and blocks until released or cancelled.
+type blockingRunner struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	release chan struct{}
+}
+
+func (r *blockingRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	r.mu.Lock()
+	r.running++
+	r.peak = max(r.peak, r.running)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running--
+		r.mu.Unlock()
+	}()
+
+	select {
+	case <-r.release:
+		return &StepOutput{Message: string(input.StepParams)}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestPipelineProcessor_Concurrency(t *testing.T) {
+	steps := make([]PipelineStep, 6)
+	for i := range steps {
+		steps[i] = PipelineStep{Name: fmt.Sprintf("step-%d", i), Type: "blocking", Parameters: json.RawMessage(strconv.Itoa(i))}
+	}
+
+	t.Run("BoundedAndOrdered", func(t *testing.T) {
+		processor := NewPipelineProcessor()
+		processor.concurrency = 2
+		runner := &blockingRunner{release: make(chan struct{})}
+		processor.RegisterRunner("blocking", runner)
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			close(runner.release)
+		}()
+		results, err := processor.Execute(context.Background(), steps, StepInput{RuleParams: json.RawMessage(`{}`)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, runner.peak)
+		for i, result := range results {
+			assert.Equal(t, fmt.Sprintf("step-%d", i), result.Name)
+			assert.Equal(t, strconv.Itoa(i), result.Message)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		processor := NewPipelineProcessor()
+		processor.timeout = 20 * time.Millisecond
+		processor.concurrency = 1
+		processor.RegisterRunner("blocking", &blockingRunner{release: make(chan struct{})})
+
+		ctx, input, cancel := processor.newRequest(context.Background())
+		defer cancel()
+		input.RuleParams = json.RawMessage(`{}`)
+		results, err := processor.Execute(ctx, steps[:2], input)
+
+		assert.Error(t, err)
+		for _, result := range results {
+			assert.Equal(t, StepStatusFailed, result.Status)
+		}
+		// One step times out while running, the other while waiting for a worker
+		messages := []string{results[0].Message, results[1].Message}
+		assert.Contains(t, messages, context.DeadlineExceeded.Error())
+		assert.Contains(t, messages, "pipeline deadline exceeded: "+context.DeadlineExceeded.Error())
+	})
+}
+
+func TestPipelineProcessor_QueryCache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[123,"1"]}]}}`))
+	}))
+	defer ts.Close()
+
+	processor := NewPipelineProcessor()
+	processor.runners["validate_metric_exists"].(*ValidateMetricExistsRunner).Client = ts.Client()
+	step := PipelineStep{Name: "check", Type: "validate_metric_exists", Parameters: json.RawMessage(`{"metric_name": "up"}`)}
+
+	run := func() {
+		ctx, input, cancel := processor.newRequest(context.Background())
+		defer cancel()
+		input.Datasource = &DatasourceConfig{Type: "prometheus", URL: ts.URL}
+		input.RuleParams = json.RawMessage(`{}`)
+		_, err := processor.Execute(ctx, []PipelineStep{step, step, step}, input)
+		assert.NoError(t, err)
+	}
+
+	run()
+	assert.Equal(t, int32(1), requests.Load(), "identical queries within a request are de-duplicated")
+
+	run()
+	assert.Equal(t, int32(1), requests.Load(), "identical queries across requests are cached briefly")
+
+	processor.cache = nil
+	run()
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestWithPipelineConfig_FetchTimeout(t *testing.T) {
+	service := NewService(nil, nil, nil, WithPipelineConfig(config.PipelinesConfig{Timeout: 5 * time.Second}))
+	assert.Equal(t, 5*time.Second, service.pipelineProcessor.cache.group.timeout, "shared queries are bounded by the pipeline timeout")
+	assert.Equal(t, 5*time.Second, service.optionsCache.group.timeout, "options lookups are bounded by the pipeline timeout")
+
+	service = NewService(nil, nil, nil, WithPipelineConfig(config.PipelinesConfig{Timeout: 5 * time.Second, QueryCacheTTL: time.Minute}))
+	assert.Equal(t, 5*time.Second, service.pipelineProcessor.cache.group.timeout)
+}
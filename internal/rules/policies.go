@@ -0,0 +1,252 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"rulemanager/config"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Policy is a named CEL expression that must evaluate to true for a rule to be accepted.
+// Expressions see the rule parameters as `params` and the rendered rules as `rendered`
+// (a list of maps with name, alert, record, expr, for, labels and annotations).
+type Policy struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Message    string `json:"message,omitempty"`
+	// OnFailure applies to global policies, which run as their own pipeline step.
+	OnFailure string `json:"on_failure,omitempty"`
+}
+
+// PolicySet holds the global policies and the shared policy libraries loaded from config.
+type PolicySet struct {
+	Global    []Policy
+	Libraries map[string][]Policy
+}
+
+// NewPolicySet converts the policy configuration, checks on_failure levels and compiles every
+// expression, so invalid policies are reported at startup rather than on the first rule.
+func NewPolicySet(cfg config.PoliciesConfig) (*PolicySet, error) {
+	set := &PolicySet{Libraries: make(map[string][]Policy)}
+	convert := func(policies []config.PolicyConfig, where string) ([]Policy, error) {
+		out := make([]Policy, 0, len(policies))
+		for _, p := range policies {
+			policy := Policy{Name: p.Name, Expression: p.Expression, Message: p.Message, OnFailure: p.OnFailure}
+			if policy.Name == "" {
+				return nil, fmt.Errorf("%s: policy name is required", where)
+			}
+			switch policy.OnFailure {
+			case "", OnFailureError, OnFailureWarn, OnFailureIgnore:
+			default:
+				return nil, fmt.Errorf("%s: policy '%s': invalid on_failure value: %s (expected error, warn or ignore)", where, policy.Name, policy.OnFailure)
+			}
+			if _, err := compilePolicy(policy.Expression); err != nil {
+				return nil, fmt.Errorf("%s: policy '%s': %w", where, policy.Name, err)
+			}
+			out = append(out, policy)
+		}
+		return out, nil
+	}
+
+	var err error
+	if set.Global, err = convert(cfg.Global, "global policies"); err != nil {
+		return nil, err
+	}
+	for name, policies := range cfg.Libraries {
+		if set.Libraries[name], err = convert(policies, fmt.Sprintf("policy library '%s'", name)); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// CELParams defines parameters for the cel pipeline step.
+type CELParams struct {
+	// Policy, Expression and Message define a single inline policy.
+	Policy     string `json:"policy,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// Policies lists additional inline policies.
+	Policies []Policy `json:"policies,omitempty"`
+	// Libraries references shared policy libraries from the configuration by name.
+	Libraries []string `json:"libraries,omitempty"`
+}
+
+// CELRunner evaluates CEL policies against the rule parameters and the rendered rule.
+type CELRunner struct {
+	Libraries map[string][]Policy
+}
+
+// Run evaluates every policy of the step and reports all violations.
+func (r *CELRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	var params CELParams
+	if err := json.Unmarshal(input.StepParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid step parameters: %w", err)
+	}
+
+	var policies []Policy
+	if params.Expression != "" {
+		name := params.Policy
+		if name == "" {
+			name = "inline"
+		}
+		policies = append(policies, Policy{Name: name, Expression: params.Expression, Message: params.Message})
+	}
+	policies = append(policies, params.Policies...)
+	for _, library := range params.Libraries {
+		libraryPolicies, ok := r.Libraries[library]
+		if !ok {
+			return nil, fmt.Errorf("unknown policy library: %s", library)
+		}
+		policies = append(policies, libraryPolicies...)
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("expression, policies or libraries is required")
+	}
+
+	activation, err := policyActivation(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []string
+	for _, policy := range policies {
+		allowed, err := evaluatePolicy(ctx, policy, activation)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("policy '%s' could not be evaluated: %v", policy.Name, err))
+			continue
+		}
+		if !allowed {
+			message := policy.Message
+			if message == "" {
+				message = "expression evaluated to false"
+			}
+			violations = append(violations, fmt.Sprintf("policy '%s' violated: %s", policy.Name, message))
+		}
+	}
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(violations, "; "))
+	}
+
+	return &StepOutput{Message: fmt.Sprintf("%d policies passed", len(policies))}, nil
+}
+
+// celEnv is the shared CEL environment for policies.
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("params", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("rendered", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+	)
+})
+
+// celCostLimit bounds the evaluation cost of a policy, so that rule parameters cannot make a
+// comprehension run away. celInterruptCheckFrequency is how many comprehension iterations run
+// between checks of the request context.
+const (
+	celCostLimit               = 1_000_000
+	celInterruptCheckFrequency = 100
+)
+
+// celPrograms caches compiled programs by expression.
+var celPrograms sync.Map
+
+// compilePolicy compiles a policy expression, which must evaluate to a bool.
+func compilePolicy(expression string) (cel.Program, error) {
+	if cached, ok := celPrograms.Load(expression); ok {
+		return cached.(cel.Program), nil
+	}
+	if expression == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to a bool, got %s", ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(celCostLimit), cel.InterruptCheckFrequency(celInterruptCheckFrequency))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	celPrograms.Store(expression, program)
+	return program, nil
+}
+
+// evaluatePolicy runs a policy against the activation and reports whether it holds. Evaluation
+// stops when ctx is done or the policy exceeds celCostLimit.
+func evaluatePolicy(ctx context.Context, policy Policy, activation map[string]any) (bool, error) {
+	program, err := compilePolicy(policy.Expression)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.ContextEval(ctx, activation)
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to a bool, got %s", out.Type())
+	}
+	return bool(allowed), nil
+}
+
+// policyActivation exposes the rule parameters and, lazily, the rendered rules to CEL.
+func policyActivation(input *StepInput) (map[string]any, error) {
+	params := map[string]any{}
+	if len(input.RuleParams) > 0 {
+		if err := json.Unmarshal(input.RuleParams, &params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+		}
+	}
+
+	rendered := func() ref.Val {
+		if input.RenderRule == nil {
+			return types.DefaultTypeAdapter.NativeToValue([]any{})
+		}
+		out, err := input.RenderRule()
+		if err != nil {
+			return types.NewErr("failed to render rule: %v", err)
+		}
+		parsed, err := parseRenderedRules(out)
+		if err != nil {
+			return types.NewErr("%v", err)
+		}
+		list := make([]any, 0, len(parsed))
+		for _, rule := range parsed {
+			list = append(list, map[string]any{
+				"name":        rule.Name(),
+				"alert":       rule.Alert,
+				"record":      rule.Record,
+				"expr":        rule.Expr,
+				"for":         rule.For.Duration().String(),
+				"labels":      stringMap(rule.Labels),
+				"annotations": stringMap(rule.Annotations),
+			})
+		}
+		return types.DefaultTypeAdapter.NativeToValue(list)
+	}
+
+	return map[string]any{"params": params, "rendered": rendered}, nil
+}
+
+func stringMap(m map[string]string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"rulemanager/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCELRunner_Run(t *testing.T) {
+	runner := &CELRunner{Libraries: map[string][]Policy{
+		"guardrails": {
+			{Name: "cpu_threshold_below_one", Expression: `params.rules.all(r, r.rule_type != "cpu" || r.threshold < 1.0)`, Message: "threshold for cpu must be < 1.0"},
+		},
+	}}
+	runbook := `rendered.all(r, !("severity" in r.labels) || r.labels.severity != "critical" || "runbook_url" in r.annotations)`
+	renderWith := func(annotations string) func() (string, error) {
+		return func() (string, error) {
+			return "- alert: HighCPU\n  expr: up > 0\n  labels:\n    severity: critical\n  annotations:\n" + annotations, nil
+		}
+	}
+
+	tests := []struct {
+		name        string
+		params      string
+		ruleParams  string
+		render      func() (string, error)
+		expectError string
+	}{
+		{
+			name:       "Inline Policy On Rendered Rule Passes",
+			params:     `{"policy": "critical_requires_runbook", "expression": ` + jsonString(runbook) + `}`,
+			ruleParams: `{}`,
+			render:     renderWith("    runbook_url: https://runbooks/cpu\n"),
+		},
+		{
+			name:        "Inline Policy Violated",
+			params:      `{"policy": "critical_requires_runbook", "expression": ` + jsonString(runbook) + `, "message": "critical alerts need a runbook_url"}`,
+			ruleParams:  `{}`,
+			render:      renderWith("    summary: cpu\n"),
+			expectError: "policy 'critical_requires_runbook' violated: critical alerts need a runbook_url",
+		},
+		{
+			name:       "Library Passes",
+			params:     `{"libraries": ["guardrails"]}`,
+			ruleParams: `{"rules": [{"rule_type": "cpu", "threshold": 0.9}]}`,
+		},
+		{
+			name:        "Library Violated",
+			params:      `{"libraries": ["guardrails"]}`,
+			ruleParams:  `{"rules": [{"rule_type": "cpu", "threshold": 1.5}]}`,
+			expectError: "policy 'cpu_threshold_below_one' violated: threshold for cpu must be < 1.0",
+		},
+		{
+			name:        "All Violations Reported",
+			params:      `{"policies": [{"name": "a", "expression": "false"}, {"name": "b", "expression": "false"}]}`,
+			ruleParams:  `{}`,
+			expectError: "policy 'a' violated: expression evaluated to false; policy 'b' violated",
+		},
+		{
+			name:        "Unknown Library",
+			params:      `{"libraries": ["missing"]}`,
+			ruleParams:  `{}`,
+			expectError: "unknown policy library: missing",
+		},
+		{
+			name:        "Compile Error",
+			params:      `{"expression": "params.("}`,
+			ruleParams:  `{}`,
+			expectError: "policy 'inline' could not be evaluated: invalid expression",
+		},
+		{
+			name:        "Evaluation Error",
+			params:      `{"expression": "params.missing == 1"}`,
+			ruleParams:  `{}`,
+			expectError: "policy 'inline' could not be evaluated: no such key: missing",
+		},
+		{
+			name:        "Render Error",
+			params:      `{"expression": "size(rendered) > 0"}`,
+			ruleParams:  `{}`,
+			render:      func() (string, error) { return "", errors.New("boom") },
+			expectError: "failed to render rule: boom",
+		},
+		{
+			name:        "No Policies",
+			params:      `{}`,
+			ruleParams:  `{}`,
+			expectError: "expression, policies or libraries is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runner.Run(context.Background(), &StepInput{
+				RuleParams: json.RawMessage(tt.ruleParams),
+				StepParams: json.RawMessage(tt.params),
+				RenderRule: tt.render,
+			})
+			if tt.expectError != "" {
+				assert.ErrorContains(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCELRunner_Limits(t *testing.T) {
+	runner := &CELRunner{}
+	items := make([]int, 1000)
+	ruleParams, _ := json.Marshal(map[string]any{"items": items})
+	costly := `{"expression": "params.items.all(a, params.items.all(b, params.items.all(c, true)))"}`
+
+	t.Run("CostLimit", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), &StepInput{RuleParams: ruleParams, StepParams: json.RawMessage(costly)})
+		assert.ErrorContains(t, err, "policy 'inline' could not be evaluated: operation cancelled: actual cost limit exceeded")
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := runner.Run(ctx, &StepInput{RuleParams: ruleParams, StepParams: json.RawMessage(`{"expression": "params.items.all(a, true)"}`)})
+		assert.ErrorContains(t, err, "policy 'inline' could not be evaluated: operation interrupted")
+	})
+}
+
+func TestNewPolicySet(t *testing.T) {
+	set, err := NewPolicySet(config.PoliciesConfig{
+		Global:    []config.PolicyConfig{{Name: "has_target", Expression: `"target" in params`, OnFailure: "warn"}},
+		Libraries: map[string][]config.PolicyConfig{"k8s": {{Name: "ns", Expression: `params.target.namespace != "default"`}}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "warn", set.Global[0].OnFailure)
+	assert.Len(t, set.Libraries["k8s"], 1)
+
+	t.Run("InvalidExpression", func(t *testing.T) {
+		_, err := NewPolicySet(config.PoliciesConfig{Libraries: map[string][]config.PolicyConfig{"k8s": {{Name: "bad", Expression: "1 +"}}}})
+
+		assert.ErrorContains(t, err, "policy library 'k8s': policy 'bad': invalid expression")
+	})
+
+	t.Run("NonBoolExpression", func(t *testing.T) {
+		_, err := NewPolicySet(config.PoliciesConfig{Global: []config.PolicyConfig{{Name: "num", Expression: "1 + 1"}}})
+
+		assert.ErrorContains(t, err, "must evaluate to a bool")
+	})
+
+	t.Run("MissingName", func(t *testing.T) {
+		_, err := NewPolicySet(config.PoliciesConfig{Global: []config.PolicyConfig{{Expression: "true"}}})
+
+		assert.ErrorContains(t, err, "policy name is required")
+	})
+
+	t.Run("InvalidOnFailure", func(t *testing.T) {
+		_, err := NewPolicySet(config.PoliciesConfig{Global: []config.PolicyConfig{{Name: "has_target", Expression: "true", OnFailure: "warning"}}})
+
+		assert.ErrorContains(t, err, "global policies: policy 'has_target': invalid on_failure value: warning")
+	})
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
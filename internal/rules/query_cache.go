@@ -0,0 +1,83 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queryCache de-duplicates identical datasource queries and caches their responses.
+// A request-scoped cache (ttl 0, entries never expire) sits in front of the processor-wide
+// cache, which keeps responses for a short TTL so repeated requests share datasource round-trips.
+// Errors are never cached.
+type queryCache struct {
+	ttl    time.Duration
+	parent *queryCache
+	group  sharedFetches[*promQueryResponse]
+
+	mu        sync.Mutex
+	entries   map[string]queryCacheEntry
+	nextSweep time.Time
+}
+
+type queryCacheEntry struct {
+	response *promQueryResponse
+	expires  time.Time // zero for request-scoped entries
+}
+
+// newQueryCache creates a cache whose entries expire after ttl (0 = never) and which falls back to parent on a miss.
+// A query shared by concurrent callers runs for at most fetchTimeout, the pipeline deadline.
+func newQueryCache(ttl, fetchTimeout time.Duration, parent *queryCache) *queryCache {
+	return &queryCache{ttl: ttl, parent: parent, group: sharedFetches[*promQueryResponse]{timeout: fetchTimeout}, entries: make(map[string]queryCacheEntry)}
+}
+
+// get returns the cached response for key, or calls fetch once for all concurrent callers of the same key.
+// Each caller stops waiting when its own ctx is done (see sharedFetches). A nil cache always fetches.
+func (c *queryCache) get(ctx context.Context, key string, fetch func(context.Context) (*promQueryResponse, error)) (*promQueryResponse, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+	if response, ok := c.lookup(key); ok {
+		return response, nil
+	}
+
+	return c.group.do(ctx, key, func(ctx context.Context) (*promQueryResponse, error) {
+		response, err := c.parent.get(ctx, key, fetch)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, response)
+		return response, nil
+	})
+}
+
+func (c *queryCache) lookup(key string) (*promQueryResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (c *queryCache) store(key string, response *promQueryResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := queryCacheEntry{response: response}
+	if c.ttl > 0 {
+		now := time.Now()
+		entry.expires = now.Add(c.ttl)
+		// Drop expired entries at most once per TTL so the shared cache does not grow unbounded
+		if now.After(c.nextSweep) {
+			for k, e := range c.entries {
+				if now.After(e.expires) {
+					delete(c.entries, k)
+				}
+			}
+			c.nextSweep = now.Add(c.ttl)
+		}
+	}
+	c.entries[key] = entry
+}
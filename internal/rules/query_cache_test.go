@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCache(t *testing.T) {
+	response := &promQueryResponse{Status: "success"}
+
+	t.Run("DeduplicatesConcurrentQueries", func(t *testing.T) {
+		var fetches atomic.Int32
+		release := make(chan struct{})
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			fetches.Add(1)
+			<-release
+			return response, nil
+		}
+
+		cache := newQueryCache(0, time.Minute, nil)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := cache.get(context.Background(), "q", fetch)
+				assert.NoError(t, err)
+				assert.Same(t, response, got)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+
+	t.Run("SharedCacheExpires", func(t *testing.T) {
+		var fetches atomic.Int32
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			fetches.Add(1)
+			return response, nil
+		}
+
+		shared := newQueryCache(50*time.Millisecond, time.Minute, nil)
+		_, _ = newQueryCache(0, time.Minute, shared).get(context.Background(), "q", fetch)
+		_, _ = newQueryCache(0, time.Minute, shared).get(context.Background(), "q", fetch)
+		assert.Equal(t, int32(1), fetches.Load(), "a second request hits the shared cache")
+
+		time.Sleep(60 * time.Millisecond)
+		_, _ = newQueryCache(0, time.Minute, shared).get(context.Background(), "q", fetch)
+		assert.Equal(t, int32(2), fetches.Load(), "expired entries are fetched again")
+	})
+
+	t.Run("ErrorsNotCached", func(t *testing.T) {
+		var fetches atomic.Int32
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			if fetches.Add(1) == 1 {
+				return nil, errors.New("datasource unavailable")
+			}
+			return response, nil
+		}
+
+		cache := newQueryCache(time.Minute, time.Minute, nil)
+		_, err := cache.get(context.Background(), "q", fetch)
+		assert.EqualError(t, err, "datasource unavailable")
+
+		got, err := cache.get(context.Background(), "q", fetch)
+		assert.NoError(t, err)
+		assert.Same(t, response, got)
+		assert.Equal(t, int32(2), fetches.Load())
+	})
+
+	t.Run("CanceledCallerDoesNotFailOthers", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			close(started)
+			select {
+			case <-release:
+				return response, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		cache := newQueryCache(0, time.Minute, nil)
+		first, cancel := context.WithCancel(context.Background())
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := cache.get(first, "q", fetch)
+			firstErr <- err
+		}()
+		<-started
+
+		second := make(chan *promQueryResponse, 1)
+		go func() {
+			got, err := cache.get(context.Background(), "q", fetch)
+			assert.NoError(t, err)
+			second <- got
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-firstErr, context.Canceled, "the canceled caller stops waiting")
+		close(release)
+		assert.Same(t, response, <-second, "the shared fetch outlives the first caller")
+	})
+
+	t.Run("FetchBoundedByTimeout", func(t *testing.T) {
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		start := time.Now()
+		_, err := newQueryCache(0, 20*time.Millisecond, nil).get(context.Background(), "q", fetch)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second, "the shared fetch stops at the configured timeout")
+	})
+
+	t.Run("FetchCanceledWhenAllCallersGiveUp", func(t *testing.T) {
+		var fetches atomic.Int32
+		stopped := make(chan error, 1)
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			if fetches.Add(1) > 1 {
+				return response, nil
+			}
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return nil, ctx.Err()
+		}
+
+		cache := newQueryCache(0, time.Minute, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := cache.get(ctx, "q", fetch)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, <-stopped, context.Canceled, "the fetch no caller waits for is canceled")
+
+		got, err := cache.get(context.Background(), "q", fetch)
+		assert.NoError(t, err, "a later caller starts a new fetch")
+		assert.Same(t, response, got)
+	})
+
+	t.Run("NilCacheAlwaysFetches", func(t *testing.T) {
+		var cache *queryCache
+		var fetches atomic.Int32
+		fetch := func(ctx context.Context) (*promQueryResponse, error) {
+			fetches.Add(1)
+			return response, nil
+		}
+
+		_, _ = cache.get(context.Background(), "q", fetch)
+		_, _ = cache.get(context.Background(), "q", fetch)
+		assert.Equal(t, int32(2), fetches.Load())
+	})
+}
@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rulemanager/internal/database"
+)
+
+// SearchRules validates the filter and the projected fields against the template schemas and
+// returns a page of the matching rules from the rule store.
+func (s *Service) SearchRules(ctx context.Context, filter database.RuleFilter, page database.PageRequest) (*database.RulePage, error) {
+	if err := s.ValidateRuleFilter(ctx, filter); err != nil {
+		return nil, err
+	}
+	if err := s.validateRuleFields(ctx, filter.TemplateName, page.Fields); err != nil {
+		return nil, err
+	}
+	return s.ruleStore.SearchRulesPage(ctx, filter, page)
+}
+
+// validateRuleFields checks that projected parameter paths are declared by the schema of the
+// filtered template, or by any schema. Errors wrap database.ErrInvalidPage.
+func (s *Service) validateRuleFields(ctx context.Context, templateName string, fields []string) error {
+	paths := database.ProjectedParameterPaths(fields)
+	if len(paths) == 0 {
+		return nil
+	}
+	schemas, err := s.filterSchemas(ctx, templateName)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := filterFieldType(schemas, path); err != nil {
+			return fmt.Errorf("%w: unknown field '%s'", database.ErrInvalidPage, path)
+		}
+	}
+	return nil
+}
+
+// FacetRules validates the filter and the faceted fields against the template schemas and counts
+// the field values over the matching rules. Faceted parameter paths must be declared with a
+// scalar type, or as arrays of scalars; errors wrap database.ErrInvalidFacet.
+func (s *Service) FacetRules(ctx context.Context, filter database.RuleFilter, fields []string, limit int) (*database.RuleFacets, error) {
+	if err := s.ValidateRuleFilter(ctx, filter); err != nil {
+		return nil, err
+	}
+
+	var schemas []SchemaNode
+	for _, field := range fields {
+		if database.IsRuleField(field) {
+			continue
+		}
+		if schemas == nil {
+			var err error
+			if schemas, err = s.filterSchemas(ctx, filter.TemplateName); err != nil {
+				return nil, err
+			}
+		}
+		path := database.ParameterPath(field)
+		fieldType, err := filterFieldType(schemas, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown field '%s'", database.ErrInvalidFacet, path)
+		}
+		if fieldType == "object" || fieldType == "array" {
+			return nil, fmt.Errorf("%w: field '%s' is an %s; facets need values", database.ErrInvalidFacet, path, fieldType)
+		}
+	}
+	return s.ruleStore.FacetRules(ctx, filter, fields, limit)
+}
+
+// ValidateRuleFilter checks that the parameter filters and query fields are paths declared by the
+// schema of the filtered template, or by any schema when no template is given, and that values
+// fit the declared types (a number field cannot be compared with "abc"). Errors wrap
+// database.ErrInvalidFilter.
+func (s *Service) ValidateRuleFilter(ctx context.Context, filter database.RuleFilter) error {
+	for key := range filter.Metadata {
+		if err := checkMetadataField("metadata." + strings.TrimPrefix(key, "metadata.")); err != nil {
+			return err
+		}
+	}
+	if len(filter.Parameters) == 0 && (filter.Query == nil || filter.Query.Expr == nil) {
+		return nil
+	}
+
+	schemas, err := s.filterSchemas(ctx, filter.TemplateName)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(filter.Parameters))
+	for key := range filter.Parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := database.ParameterPath(key)
+		fieldType, err := filterFieldType(schemas, path)
+		if err != nil {
+			return err
+		}
+		if err := checkFilterText(path, fieldType, filter.Parameters[key]); err != nil {
+			return err
+		}
+	}
+
+	if filter.Query != nil && filter.Query.Expr != nil {
+		return checkQueryExpr(schemas, filter.Query.Expr, "")
+	}
+	return nil
+}
+
+// filterSchemas returns the parsed schema of the template, or of every template.
+func (s *Service) filterSchemas(ctx context.Context, templateName string) ([]SchemaNode, error) {
+	var contents []string
+	if templateName != "" {
+		schemaStr, err := s.templateProvider.GetSchema(ctx, templateName)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown template '%s'", database.ErrInvalidFilter, templateName)
+		}
+		contents = append(contents, schemaStr)
+	} else {
+		schemas, err := s.templateProvider.ListSchemas(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list schemas: %w", err)
+		}
+		for _, schema := range schemas {
+			contents = append(contents, string(schema.Schema))
+		}
+	}
+
+	var nodes []SchemaNode
+	for _, content := range contents {
+		var node SchemaNode
+		if err := json.Unmarshal([]byte(content), &node); err != nil {
+			if templateName != "" {
+				return nil, fmt.Errorf("failed to parse schema: %w", err)
+			}
+			// A broken schema has no fields to match; the others still apply
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// filterFieldType looks up a parameter path in the schemas and returns its declared type
+// ("" when the schema does not say). Array fields report the type of their items.
+func filterFieldType(schemas []SchemaNode, path string) (string, error) {
+	if err := database.ValidateFieldPath(path); err != nil {
+		return "", fmt.Errorf("%w: %v", database.ErrInvalidFilter, err)
+	}
+	// Numeric segments index arrays: rules.0.threshold is rules[0].threshold
+	var parts []string
+	for _, segment := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(segment); err == nil && len(parts) > 0 {
+			parts[len(parts)-1] += "[" + segment + "]"
+			continue
+		}
+		parts = append(parts, segment)
+	}
+	selectorPath := strings.Join(parts, ".")
+
+	for _, schema := range schemas {
+		node, err := navigateToField(schema, selectorPath)
+		if err != nil {
+			continue
+		}
+		fieldType := schemaType(node)
+		if fieldType == "array" {
+			items, _ := node["items"].(map[string]interface{})
+			fieldType = schemaType(items)
+		}
+		return fieldType, nil
+	}
+	return "", fmt.Errorf("%w: unknown field '%s'", database.ErrInvalidFilter, path)
+}
+
+// schemaType returns the declared type of a schema node, ignoring "null" in type lists.
+func schemaType(node map[string]interface{}) string {
+	switch t := node["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// checkFilterText checks a parameter filter value against the field type.
+func checkFilterText(field, fieldType, value string) error {
+	switch fieldType {
+	case "number", "integer":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%w: field '%s' is a number, got '%s'", database.ErrInvalidFilter, field, value)
+		}
+	case "boolean":
+		if !strings.EqualFold(value, "true") && !strings.EqualFold(value, "false") {
+			return fmt.Errorf("%w: field '%s' is a bool, got '%s'", database.ErrInvalidFilter, field, value)
+		}
+	}
+	return nil
+}
+
+// checkQueryExpr checks the fields and literals of a query. Fields inside element matches are
+// relative to the array field given as prefix. Metadata fields are checked against the fixed
+// metadata fields rather than the schemas.
+func checkQueryExpr(schemas []SchemaNode, expr database.QueryExpr, prefix string) error {
+	var exprs []database.QueryExpr
+	switch e := expr.(type) {
+	case *database.AndExpr:
+		exprs = e.Exprs
+	case *database.OrExpr:
+		exprs = e.Exprs
+	case *database.NotExpr:
+		exprs = []database.QueryExpr{e.Expr}
+	case *database.ExistsExpr:
+		if database.IsMetadataField(prefix + e.Field) {
+			return checkMetadataField(prefix + e.Field)
+		}
+		if prefix == "" && database.IsRuleField(e.Field) {
+			return nil
+		}
+		_, err := filterFieldType(schemas, queryPath(prefix, e.Field))
+		return err
+	case *database.ElemMatchExpr:
+		if database.IsMetadataField(prefix + e.Field) {
+			if err := checkMetadataField(prefix + e.Field); err != nil {
+				return err
+			}
+			return checkQueryExpr(schemas, e.Expr, prefix+e.Field+".")
+		}
+		path := queryPath(prefix, e.Field)
+		if _, err := filterFieldType(schemas, path); err != nil {
+			return err
+		}
+		return checkQueryExpr(schemas, e.Expr, path+".")
+	case *database.CompareExpr:
+		if database.IsMetadataField(prefix + e.Field) {
+			return checkMetadataField(prefix + e.Field)
+		}
+		if prefix == "" && database.IsRuleField(e.Field) {
+			return nil
+		}
+		path := queryPath(prefix, e.Field)
+		fieldType, err := filterFieldType(schemas, path)
+		if err != nil {
+			return err
+		}
+		return checkQueryValues(path, fieldType, e)
+	}
+	for _, sub := range exprs {
+		if err := checkQueryExpr(schemas, sub, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMetadataField checks that a query field names a metadata field.
+func checkMetadataField(field string) error {
+	if err := database.ValidateMetadataField(field); err != nil {
+		return fmt.Errorf("%w: %v", database.ErrInvalidFilter, err)
+	}
+	return nil
+}
+
+// queryPath returns the parameter path of a query field; the "parameters." prefix is only
+// meaningful outside element matches.
+func queryPath(prefix, field string) string {
+	if prefix == "" {
+		return database.ParameterPath(field)
+	}
+	return prefix + field
+}
+
+// checkQueryValues checks the operator and literals of a comparison against the field type.
+func checkQueryValues(field, fieldType string, e *database.CompareExpr) error {
+	var want string
+	switch fieldType {
+	case "number", "integer":
+		want = database.ValueNumber
+	case "boolean":
+		want = database.ValueBool
+	default:
+		return nil
+	}
+
+	switch e.Op {
+	case database.OpRegex, database.OpNotRegex:
+		return fmt.Errorf("%w: field '%s' is a %s; regular expressions need a string field", database.ErrInvalidFilter, field, want)
+	case database.OpGt, database.OpGte, database.OpLt, database.OpLte:
+		if want == database.ValueBool {
+			return fmt.Errorf("%w: field '%s' is a bool and cannot be ordered", database.ErrInvalidFilter, field)
+		}
+	}
+	for _, v := range e.Values {
+		if v.Kind != want {
+			return fmt.Errorf("%w: field '%s' is a %s, got '%s'", database.ErrInvalidFilter, field, want, v.Text)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"rulemanager/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ValidateRuleFilter(t *testing.T) {
+	raw, err := os.ReadFile("../../templates/_base/k8s.json")
+	require.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(string(raw), nil)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{
+		{Name: "k8s", Schema: json.RawMessage(raw)},
+		{Name: "broken", Schema: json.RawMessage(`{`)},
+	}, nil)
+	service := NewService(mockTP, nil, nil)
+
+	tests := []struct {
+		name   string
+		filter database.RuleFilter
+		err    string
+	}{
+		{"NoCriteria", database.RuleFilter{TemplateName: "unknown"}, ""},
+		{"ParameterPath", database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"target.namespace": "payments"}}, ""},
+		{"PrefixedPathAcrossSchemas", database.RuleFilter{Parameters: map[string]string{"parameters.common.severity": "critical"}}, ""},
+		{"BranchField", database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"rules.service_name": "api"}}, ""},
+		{"IndexedItem", database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"rules.1.threshold": "2"}}, ""},
+		{"UnknownPath", database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"target.cluster": "a"}}, "unknown field 'target.cluster'"},
+		{"NumberValue", database.RuleFilter{TemplateName: "k8s", Parameters: map[string]string{"rules.threshold": "many"}}, "is a number, got 'many'"},
+		{"Query", database.RuleFilter{TemplateName: "k8s", Query: mustParseQuery(t, `target.namespace=~"team-.*" AND rules.threshold>0.8 AND NOT exists(common.for) AND createdAt>2025-01-01`)}, ""},
+		{"ElemMatchQuery", database.RuleFilter{Query: mustParseQuery(t, `rules[service_name=api AND threshold in (1, 2)]`)}, ""},
+		{"ElemMatchUnknownField", database.RuleFilter{Query: mustParseQuery(t, `rules[namespace=a]`)}, "unknown field 'rules.namespace'"},
+		{"QueryNumberValue", database.RuleFilter{Query: mustParseQuery(t, `rules.threshold!=high`)}, "is a number, got 'high'"},
+		{"Metadata", database.RuleFilter{Metadata: map[string]string{"team": "payments", "metadata.labels.tier": "1"}}, ""},
+		{"UnknownMetadata", database.RuleFilter{Metadata: map[string]string{"ticket": "OPS-1"}}, "unknown metadata field 'metadata.ticket'"},
+		{"MetadataQuery", database.RuleFilter{TemplateName: "k8s", Query: mustParseQuery(t, `metadata.owner=alice AND metadata.links[name=runbook] AND target.namespace=a`)}, ""},
+		{"UnknownMetadataQuery", database.RuleFilter{Query: mustParseQuery(t, `metadata.links[title=runbook]`)}, "unknown metadata field 'metadata.links.title'"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := service.ValidateRuleFilter(context.Background(), tc.filter)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, database.ErrInvalidFilter)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestService_SearchRulesFields(t *testing.T) {
+	raw, err := os.ReadFile("../../templates/_base/k8s.json")
+	require.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(string(raw), nil)
+	mockStore := new(MockRuleStore)
+	service := NewService(mockTP, mockStore, nil)
+	filter := database.RuleFilter{TemplateName: "k8s"}
+
+	page := database.PageRequest{Fields: []string{"id", "parameters.rules.threshold", "parameters.target"}}
+	mockStore.On("SearchRulesPage", mock.Anything, filter, page).Return(&database.RulePage{Rules: []*database.Rule{}}, nil)
+	_, err = service.SearchRules(context.Background(), filter, page)
+	assert.NoError(t, err)
+
+	_, err = service.SearchRules(context.Background(), filter, database.PageRequest{Fields: []string{"id", "parameters.target.cluster"}})
+	assert.ErrorIs(t, err, database.ErrInvalidPage)
+	assert.ErrorContains(t, err, "unknown field 'target.cluster'")
+	mockStore.AssertNumberOfCalls(t, "SearchRulesPage", 1)
+}
+
+func TestService_FacetRules(t *testing.T) {
+	raw, err := os.ReadFile("../../templates/_base/k8s.json")
+	require.NoError(t, err)
+
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("ListSchemas", mock.Anything).Return([]*database.Schema{{Name: "k8s", Schema: json.RawMessage(raw)}}, nil)
+	mockStore := new(MockRuleStore)
+	service := NewService(mockTP, mockStore, nil)
+
+	fields := []string{"templateName", "parameters.target.namespace", "parameters.rules.threshold"}
+	mockStore.On("FacetRules", mock.Anything, database.RuleFilter{}, fields, 10).Return(&database.RuleFacets{}, nil)
+	_, err = service.FacetRules(context.Background(), database.RuleFilter{}, fields, 10)
+	assert.NoError(t, err)
+
+	for field, msg := range map[string]string{
+		"parameters.target.cluster": "unknown field 'target.cluster'",
+		"parameters.target":         "field 'target' is an object",
+	} {
+		_, err = service.FacetRules(context.Background(), database.RuleFilter{}, []string{field}, 0)
+		assert.ErrorIs(t, err, database.ErrInvalidFacet)
+		assert.ErrorContains(t, err, msg)
+	}
+	mockStore.AssertNumberOfCalls(t, "FacetRules", 1)
+}
+
+func mustParseQuery(t *testing.T, s string) *database.Query {
+	t.Helper()
+	q, err := database.ParseQuery(s)
+	require.NoError(t, err)
+	return q
+}
@@ -4,19 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"reflect"
 	"rulemanager/internal/database"
 	"rulemanager/internal/validation"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"dario.cat/mergo"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
 	"github.com/VictoriaMetrics/metricsql"
-	"github.com/stretchr/testify/assert/yaml"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
 )
 
 // Service provides methods for managing rules and templates.
@@ -25,20 +31,54 @@ type Service struct {
 	ruleStore         database.RuleStore
 	validator         validation.SchemaValidator
 	pipelineProcessor *PipelineProcessor
+	policies          *PolicySet
+	datasources       *DatasourceRegistry
+	optionsProviders  map[string]OptionsProvider
+	optionsCache      *optionsCache
+	optionsCacheTTL   time.Duration
 }
 
 // NewService creates a new Service with the given dependencies.
-func NewService(tp database.TemplateProvider, rs database.RuleStore, v validation.SchemaValidator) *Service {
-	return &Service{
+func NewService(tp database.TemplateProvider, rs database.RuleStore, v validation.SchemaValidator, opts ...ServiceOption) *Service {
+	s := &Service{
 		templateProvider:  tp,
 		ruleStore:         rs,
 		validator:         v,
 		pipelineProcessor: NewPipelineProcessor(),
 	}
+	s.optionsProviders = builtinOptionsProviders(s)
+	s.optionsCacheTTL = DefaultOptionsCacheTTL
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Options lookups get the pipeline deadline, the longest any request waits for a datasource
+	s.optionsCache = newOptionsCache(s.pipelineProcessor.timeout)
+	return s
 }
 
 // GenerateRule generates a rule configuration from a template and parameters.
 func (s *Service) GenerateRule(ctx context.Context, templateName string, parameters json.RawMessage) (string, error) {
+	return s.generateRule(ctx, templateName, parameters, nil)
+}
+
+// CheckRule renders a rule with its metadata, as the vmalert export does, to report template errors
+// before the rule is saved. The output must be well-formed YAML: metadata labels colliding with
+// labels set by the template would otherwise produce duplicate keys, which vmalert refuses to load.
+func (s *Service) CheckRule(ctx context.Context, templateName string, parameters json.RawMessage, metadata *database.RuleMetadata) error {
+	rendered, err := s.generateRule(ctx, templateName, parameters, metadata)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(rendered), &doc); err != nil {
+		return fmt.Errorf("generated rule is not valid YAML (do metadata labels collide with labels of the template?): %w", err)
+	}
+	return nil
+}
+
+// generateRule generates a rule configuration, with the rule metadata available to the template
+// through ruleLabels.
+func (s *Service) generateRule(ctx context.Context, templateName string, parameters json.RawMessage, metadata *database.RuleMetadata) (string, error) {
 	schemaStr, err := s.templateProvider.GetSchema(ctx, templateName)
 	if err != nil {
 		return "", err
@@ -53,12 +93,16 @@ func (s *Service) GenerateRule(ctx context.Context, templateName string, paramet
 		return "", err
 	}
 
-	return s.renderTemplate(templateName, tmplStr, parameters)
+	return s.renderTemplate(templateName, tmplStr, parameters, metadata)
 }
 
-func (s *Service) renderTemplate(name, tmplStr string, parameters json.RawMessage) (string, error) {
+// renderTemplate renders a rule template with the parameters as data. Templates can add the
+// labels of the rule metadata to alerts with ruleLabels (see RuleMetadata.AlertLabels), which is
+// empty for rules without metadata.
+func (s *Service) renderTemplate(name, tmplStr string, parameters json.RawMessage, metadata *database.RuleMetadata) (string, error) {
 	funcMap := template.FuncMap{
-		"title": cases.Title(language.English).String,
+		"title":      cases.Title(language.English).String,
+		"ruleLabels": metadata.AlertLabels,
 	}
 	tmpl, err := template.New(name).Funcs(funcMap).Parse(tmplStr)
 	if err != nil {
@@ -79,17 +123,47 @@ func (s *Service) renderTemplate(name, tmplStr string, parameters json.RawMessag
 }
 
 // ValidateRule validates parameters against the schema and executes any defined pipelines.
-func (s *Service) ValidateRule(ctx context.Context, templateName string, parameters json.RawMessage) error {
+// The returned report lists every executed step; the error is non-nil if validation or any step failed.
+func (s *Service) ValidateRule(ctx context.Context, templateName string, parameters json.RawMessage) (*PipelineReport, error) {
 	schemaStr, err := s.templateProvider.GetSchema(ctx, templateName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := s.validator.Validate(schemaStr, parameters); err != nil {
-		return err
+		return nil, err
+	}
+
+	run, err := s.runPipelines(ctx, templateName, schemaStr, parameters)
+	if err != nil {
+		return nil, err
 	}
 
-	// 1. Execute Global Pipelines
+	return run.Report, run.Report.Err()
+}
+
+// AppliedPatch records a JSON patch returned by a mutating pipeline step and applied to the rule parameters.
+type AppliedPatch struct {
+	Step  string          `json:"step"`
+	Scope string          `json:"scope"`
+	Patch json.RawMessage `json:"patch"`
+}
+
+// pipelineRun is the outcome of running the schema pipelines for a set of parameters.
+type pipelineRun struct {
+	Report *PipelineReport
+	// Parameters are the input parameters with every step patch applied. Unchanged if no step returned a patch.
+	Parameters json.RawMessage
+	Patches    []AppliedPatch
+}
+
+// runPipelines executes the global and per-rule pipelines declared in the schema and applies the patches
+// returned by mutating steps, in order. Global patches address the full parameters; per-rule patches
+// address the parameters the step saw, the root parameters merged with its rule item (see rebaseRulePatch).
+// Per-rule steps see the parameters as patched by the global steps.
+// Step failures are recorded in the report; the error is only set if the pipelines could not be run at all
+// or the patched parameters no longer validate against the schema.
+func (s *Service) runPipelines(ctx context.Context, templateName, schemaStr string, parameters json.RawMessage) (*pipelineRun, error) {
 	var schemaObj struct {
 		Datasource *DatasourceConfig `json:"datasource"`
 		Pipelines  []PipelineStep    `json:"pipelines"`
@@ -109,22 +183,47 @@ func (s *Service) ValidateRule(ctx context.Context, templateName string, paramet
 		} `json:"properties"`
 	}
 	if err := json.Unmarshal([]byte(schemaStr), &schemaObj); err != nil {
-		return fmt.Errorf("failed to parse schema for pipelines: %w", err)
+		return nil, fmt.Errorf("failed to parse schema for pipelines: %w", err)
+	}
+	// An unresolvable datasource only fails the steps that query it
+	datasource, err := s.resolveDatasource(ctx, schemaObj.Datasource, parameters)
+	if err != nil {
+		datasource = &DatasourceConfig{Name: schemaObj.Datasource.Name, err: err}
+	}
+
+	// All pipelines of this request share a deadline, a worker limit and a query cache
+	ctx, base, cancel := s.pipelineProcessor.newRequest(ctx)
+	defer cancel()
+	input := func(datasource *DatasourceConfig, ruleParams, renderParams json.RawMessage) StepInput {
+		in := base
+		in.Datasource = datasource
+		in.RuleParams = ruleParams
+		in.RenderRule = s.lazyRender(ctx, templateName, renderParams)
+		return in
 	}
 
-	// Execute global pipelines
+	run := &pipelineRun{Report: &PipelineReport{Steps: []StepResult{}}, Parameters: parameters}
+
+	// 1. Execute global pipelines
 	if len(schemaObj.Pipelines) > 0 {
-		if err := s.pipelineProcessor.Execute(ctx, schemaObj.Pipelines, schemaObj.Datasource, parameters); err != nil {
-			return err
+		results, _ := s.pipelineProcessor.Execute(ctx, schemaObj.Pipelines, input(datasource, parameters, parameters))
+		for i := range results {
+			results[i].Scope = "global"
 		}
+		run.Parameters = run.applyPatches(results, run.Parameters)
+		run.Report.Steps = append(run.Report.Steps, results...)
 	}
 
-	// 2. Execute Per-Rule Pipelines
+	// 2. Execute per-rule pipelines
+	var root map[string]interface{}
+	if err := json.Unmarshal(run.Parameters, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters for rules: %w", err)
+	}
 	var paramsObj struct {
 		Rules []map[string]interface{} `json:"rules"`
 	}
-	if err := json.Unmarshal(parameters, &paramsObj); err != nil {
-		return fmt.Errorf("failed to parse parameters for rules: %w", err)
+	if err := json.Unmarshal(run.Parameters, &paramsObj); err != nil {
+		return nil, fmt.Errorf("failed to parse parameters for rules: %w", err)
 	}
 
 	// Map rule types to their schema definitions (containing pipelines)
@@ -135,41 +234,163 @@ func (s *Service) ValidateRule(ctx context.Context, templateName string, paramet
 		}
 	}
 
-	// Iterate over user rules and execute corresponding pipelines
+	// Rules are independent of each other, so their pipelines run concurrently.
+	// Results and patches are still collected in rule order.
+	ruleResults := make([][]StepResult, len(paramsObj.Rules))
+	var wg sync.WaitGroup
 	for i, rule := range paramsObj.Rules {
 		ruleType, ok := rule["rule_type"].(string)
 		if !ok {
 			continue // Should be caught by schema validation, but safe to skip
 		}
 
-		if pipelines, exists := rulePipelines[ruleType]; exists {
-			// Create a merged context for the pipeline: Root Params + Rule Params
-			// We re-marshal the root parameters to a map to merge
-			var rootParams map[string]interface{}
-			if err := json.Unmarshal(parameters, &rootParams); err != nil {
-				return err
+		pipelines, exists := rulePipelines[ruleType]
+		if !exists {
+			continue
+		}
+
+		// Create a merged context for the pipeline: Root Params + Rule Params.
+		// Rule properties are merged into the root map so {{ .threshold }} works if the pipeline expects it.
+		merged := maps.Clone(root)
+		for k, v := range rule {
+			merged[k] = v
+		}
+		mergedParams, err := json.Marshal(merged)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal merged parameters for rule %d: %w", i, err)
+		}
+
+		// The rendered rule only contains this rule item, so expression steps check its own query.
+		single := maps.Clone(root)
+		single["rules"] = []interface{}{rule}
+		singleRuleParams, err := json.Marshal(single)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal parameters for rule %d: %w", i, err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, _ := s.pipelineProcessor.Execute(ctx, pipelines, input(datasource, mergedParams, singleRuleParams))
+			for j := range results {
+				results[j].Scope = fmt.Sprintf("rules[%d] (%s)", i, ruleType)
 			}
+			ruleResults[i] = results
+		}()
+	}
+	wg.Wait()
 
-			// Merge rule properties into root params (overwriting if collision, though structure usually differs)
-			// Actually, better to keep them separate or just rely on the fact that templates access what they need.
-			// If we merge `rule` into root, `threshold` becomes top-level.
-			// Let's merge rule properties into the root map so {{ .threshold }} works if the pipeline expects it.
-			for k, v := range rule {
-				rootParams[k] = v
+	// Per-rule steps saw the root parameters merged with their rule item, so their patches are
+	// rebased onto the full parameters accordingly
+	for i, results := range ruleResults {
+		if results == nil {
+			continue
+		}
+		for j := range results {
+			if len(results[j].Patch) > 0 {
+				results[j].Patch = rebaseRulePatch(results[j].Patch, root, paramsObj.Rules[i], i)
 			}
+		}
+		run.Parameters = run.applyPatches(results, run.Parameters)
+		run.Report.Steps = append(run.Report.Steps, results...)
+	}
+
+	// 3. Patched parameters must still satisfy the schema
+	if len(run.Patches) > 0 {
+		if err := s.validator.Validate(schemaStr, run.Parameters); err != nil {
+			return nil, fmt.Errorf("parameters are invalid after applying pipeline patches: %w", err)
+		}
+	}
 
-			mergedParams, err := json.Marshal(rootParams)
+	// 4. Evaluate global policies against the final parameters, one step per policy
+	if s.policies != nil && len(s.policies.Global) > 0 {
+		steps := make([]PipelineStep, 0, len(s.policies.Global))
+		for _, policy := range s.policies.Global {
+			stepParams, err := json.Marshal(CELParams{Policies: []Policy{policy}})
 			if err != nil {
-				return fmt.Errorf("failed to marshal merged parameters for rule %d: %w", i, err)
+				return nil, err
 			}
+			steps = append(steps, PipelineStep{Name: policy.Name, Type: "cel", OnFailure: policy.OnFailure, Parameters: stepParams})
+		}
+		results, _ := s.pipelineProcessor.Execute(ctx, steps, input(nil, run.Parameters, run.Parameters))
+		for i := range results {
+			results[i].Scope = "policy"
+		}
+		run.Report.Steps = append(run.Report.Steps, results...)
+	}
 
-			if err := s.pipelineProcessor.Execute(ctx, pipelines, schemaObj.Datasource, mergedParams); err != nil {
-				return fmt.Errorf("pipeline failed for rule %d (%s): %w", i, ruleType, err)
+	return run, nil
+}
+
+// rebaseRulePatch rewrites a patch against the merged parameters of a per-rule step into a patch
+// against the full parameters. Paths into a field of the rule item, or into a field that the root
+// parameters do not have, are moved under /rules/<index>; paths into other root fields (e.g.
+// /target/namespace) are kept. Patches that cannot be decoded are returned as is, so that applying
+// them fails the step.
+func rebaseRulePatch(patch json.RawMessage, root, rule map[string]interface{}, index int) json.RawMessage {
+	var ops []map[string]interface{}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return patch
+	}
+	prefix := fmt.Sprintf("/rules/%d", index)
+	rebase := func(path string) string {
+		key, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+		key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+		_, inRule := rule[key]
+		_, inRoot := root[key]
+		if path == "" || inRule || !inRoot {
+			return prefix + path
+		}
+		return path
+	}
+	for _, op := range ops {
+		for _, field := range []string{"path", "from"} {
+			if path, ok := op[field].(string); ok {
+				op[field] = rebase(path)
 			}
 		}
 	}
+	rebased, err := json.Marshal(ops)
+	if err != nil {
+		return patch
+	}
+	return rebased
+}
 
-	return nil
+// applyPatches applies the patches of passed steps to doc in order and records them.
+// A patch that cannot be applied fails its step.
+func (r *pipelineRun) applyPatches(results []StepResult, doc json.RawMessage) json.RawMessage {
+	for i := range results {
+		result := &results[i]
+		if result.Status != StepStatusPassed || len(result.Patch) == 0 {
+			continue
+		}
+
+		patch, err := jsonpatch.DecodePatch(result.Patch)
+		if err == nil {
+			var patched []byte
+			if patched, err = patch.Apply(doc); err == nil {
+				doc = patched
+				r.Patches = append(r.Patches, AppliedPatch{Step: result.Name, Scope: result.Scope, Patch: result.Patch})
+				continue
+			}
+		}
+		result.Status = StepStatusFailed
+		result.Message = fmt.Sprintf("failed to apply patch: %v", err)
+	}
+	return doc
+}
+
+// lazyRender returns a memoized function rendering the template with the given parameters.
+// The template is only fetched if a pipeline step needs the rendered rule.
+func (s *Service) lazyRender(ctx context.Context, templateName string, parameters json.RawMessage) func() (string, error) {
+	return sync.OnceValues(func() (string, error) {
+		tmplStr, err := s.templateProvider.GetTemplate(ctx, templateName)
+		if err != nil {
+			return "", err
+		}
+		return s.renderTemplate(templateName, tmplStr, parameters, nil)
+	})
 }
 
 // GenerateVMAlertConfig generates a vmalert configuration for a list of rules.
@@ -178,7 +399,7 @@ func (s *Service) GenerateVMAlertConfig(ctx context.Context, rules []*database.R
 
 	for _, rule := range rules {
 		// Group rules by template name for organizational clarity
-		ruleContent, err := s.GenerateRule(ctx, rule.TemplateName, rule.Parameters)
+		ruleContent, err := s.generateRule(ctx, rule.TemplateName, rule.Parameters, rule.Metadata)
 		if err != nil {
 			// Skip rules that fail to generate and continue processing others
 			slog.Warn("Failed to generate rule", "id", rule.ID, "error", err)
@@ -208,7 +429,7 @@ func (s *Service) GenerateVMAlertConfig(ctx context.Context, rules []*database.R
 
 // ValidateTemplate renders a template with parameters and validates the generated query.
 func (s *Service) ValidateTemplate(ctx context.Context, templateContent string, parameters json.RawMessage) (string, error) {
-	rendered, err := s.renderTemplate("validate", templateContent, parameters)
+	rendered, err := s.renderTemplate("validate", templateContent, parameters, nil)
 	if err != nil {
 		return "", err
 	}
@@ -220,13 +441,27 @@ func (s *Service) ValidateTemplate(ctx context.Context, templateContent string,
 	return rendered, nil
 }
 
-// ValidateRuleContent parses the generated rule to ensure it is a valid vmalert rule.
+// ValidateRuleContent parses the generated rule to ensure it is a valid vmalert rule. Templates
+// rendering several rules (a list of rules or a groups document) have every rule validated.
 func (s *Service) ValidateRuleContent(ruleYaml string) error {
-	var rule config.Rule
-	if err := yaml.Unmarshal([]byte(ruleYaml), &rule); err != nil {
-		return fmt.Errorf("failed to parse rule: %w", err)
+	rules, err := parseRenderedRules(ruleYaml)
+	if err != nil || len(rules) == 0 {
+		var rule config.Rule
+		if err := yaml.Unmarshal([]byte(ruleYaml), &rule); err != nil {
+			return fmt.Errorf("failed to parse rule: %w", err)
+		}
+		return validateRule(rule)
 	}
 
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, rule.Name(), err)
+		}
+	}
+	return nil
+}
+
+func validateRule(rule config.Rule) error {
 	// First, validate rule structure using vmalert
 	if err := rule.Validate(); err != nil {
 		return fmt.Errorf("rule validation failed: %w", err)
@@ -244,10 +479,14 @@ func (s *Service) ValidateRuleContent(ruleYaml string) error {
 
 // RulePlan represents the result of a rule planning operation.
 type RulePlan struct {
-	Action       string         `json:"action"` // "create", "update", "no_change"
+	Action       string         `json:"action"` // "create", "update", "no_change", "conflict" or "forbidden" (see PlanActionForbidden)
 	Reason       string         `json:"reason"`
 	ExistingRule *database.Rule `json:"existing_rule,omitempty"`
 	NewRule      *database.Rule `json:"new_rule"`
+	// Pipeline holds the per-step results of the schema pipelines run against the new parameters.
+	Pipeline *PipelineReport `json:"pipeline,omitempty"`
+	// Patches lists the patches mutating steps applied to the parameters, in order. NewRule holds the result.
+	Patches []AppliedPatch `json:"patches,omitempty"`
 }
 
 // PlanRuleCreation simulates rule creation and checks for conflicts.
@@ -262,6 +501,13 @@ func (s *Service) PlanRuleCreation(ctx context.Context, templateName string, par
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	run, err := s.runPipelines(ctx, templateName, schemaStr, parameters)
+	if err != nil {
+		return nil, err
+	}
+	// Continue with the parameters as enriched by mutating steps
+	parameters = run.Parameters
+
 	// 2. Parse parameters
 	var paramsMap map[string]interface{}
 	if err := json.Unmarshal(parameters, &paramsMap); err != nil {
@@ -282,58 +528,39 @@ func (s *Service) PlanRuleCreation(ctx context.Context, templateName string, par
 		uniquenessKeys = []string{"target", "rules.rule_type"}
 	}
 
-	// 4. Construct Search Filter
-	filter := database.RuleFilter{
-		TemplateName: templateName,
-		Parameters:   make(map[string]string),
-	}
-
-	for _, key := range uniquenessKeys {
-		if key == "target" {
-			// Special handling for target: expand all leaf fields
-			if target, ok := paramsMap["target"].(map[string]interface{}); ok {
-				for k, v := range target {
-					if strVal, ok := v.(string); ok {
-						filter.Parameters["target."+k] = strVal
-					}
-				}
-			}
-			continue
-		}
-
-		// Handle dot notation (e.g., "rules.rule_type", "common.severity")
-		val, found := getValueByPath(paramsMap, key)
-		if found && val != "" {
-			filter.Parameters[key] = val
-		}
-	}
-
-	// 5. Search for existing rules
-	existingRules, err := s.ruleStore.SearchRules(ctx, filter)
+	// 4. Search for existing rules
+	existingRules, err := s.findByUniqueness(ctx, templateName, uniquenessKeys, paramsMap)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search for existing rules: %w", err)
+		return nil, err
 	}
 
-	// 6. Determine Action
+	// 5. Determine Action
 	newRule := &database.Rule{
 		TemplateName: templateName,
+		Tenant:       TenantFromContext(ctx),
 		Parameters:   parameters,
 	}
 
 	if len(existingRules) > 0 {
 		existing := existingRules[0]
-		return &RulePlan{
+		plan := &RulePlan{
 			Action:       "update",
 			Reason:       fmt.Sprintf("Rule with same uniqueness constraints (%v) already exists", uniquenessKeys),
 			ExistingRule: existing,
 			NewRule:      newRule,
-		}, nil
+			Pipeline:     run.Report,
+			Patches:      run.Patches,
+		}
+		checkOwnership(ctx, plan, existing)
+		return plan, nil
 	}
 
 	return &RulePlan{
-		Action:  "create",
-		Reason:  "No existing rule found with these constraints",
-		NewRule: newRule,
+		Action:   "create",
+		Reason:   "No existing rule found with these constraints",
+		NewRule:  newRule,
+		Pipeline: run.Report,
+		Patches:  run.Patches,
 	}, nil
 }
 
@@ -371,6 +598,13 @@ func (s *Service) PlanRuleUpdate(ctx context.Context, id string, templateName st
 		finalParamsJSON = existingRule.Parameters
 	}
 
+	return s.planRuleChange(ctx, existingRule, templateName, finalParamsJSON)
+}
+
+// planRuleChange plans replacing the parameters of an existing rule with finalParamsJSON.
+func (s *Service) planRuleChange(ctx context.Context, existingRule *database.Rule, templateName string, finalParamsJSON json.RawMessage) (*RulePlan, error) {
+	id := existingRule.ID
+
 	// 3. Validate merged parameters against schema
 	schemaStr, err := s.templateProvider.GetSchema(ctx, templateName)
 	if err != nil {
@@ -381,6 +615,12 @@ func (s *Service) PlanRuleUpdate(ctx context.Context, id string, templateName st
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	run, err := s.runPipelines(ctx, templateName, schemaStr, finalParamsJSON)
+	if err != nil {
+		return nil, err
+	}
+	finalParamsJSON = run.Parameters
+
 	// 4. Determine Uniqueness Keys
 	var schemaObj struct {
 		UniquenessKeys []string `json:"uniqueness_keys"`
@@ -394,12 +634,66 @@ func (s *Service) PlanRuleUpdate(ctx context.Context, id string, templateName st
 		uniquenessKeys = []string{"target", "rules.rule_type"}
 	}
 
-	// 5. Construct Search Filter
+	// 5. Parse final parameters
 	var paramsMap map[string]interface{}
 	if err := json.Unmarshal(finalParamsJSON, &paramsMap); err != nil {
 		return nil, fmt.Errorf("failed to parse final parameters: %w", err)
 	}
 
+	// 6. Search for existing rules
+	existingRules, err := s.findByUniqueness(ctx, templateName, uniquenessKeys, paramsMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// 7. Check for conflicts (exclude current ID)
+	for _, rule := range existingRules {
+		if rule.ID != id {
+			plan := &RulePlan{
+				Action:       "conflict",
+				Reason:       fmt.Sprintf("Rule with same uniqueness constraints (%v) already exists (ID: %s)", uniquenessKeys, rule.ID),
+				ExistingRule: rule,
+				NewRule: &database.Rule{
+					ID:           id,
+					Name:         existingRule.Name,
+					Tenant:       existingRule.Tenant,
+					TemplateName: templateName,
+					Parameters:   finalParamsJSON,
+					Metadata:     existingRule.Metadata,
+				},
+				Pipeline: run.Report,
+				Patches:  run.Patches,
+			}
+			// Callers who may not change the rule learn nothing about conflicts
+			checkOwnership(ctx, plan, existingRule)
+			return plan, nil
+		}
+	}
+
+	// No conflict -> Update
+	plan := &RulePlan{
+		Action: "update",
+		Reason: "No conflict found",
+		NewRule: &database.Rule{
+			ID:           id,
+			Name:         existingRule.Name,
+			Tenant:       existingRule.Tenant,
+			TemplateName: templateName,
+			Parameters:   finalParamsJSON,
+			Metadata:     existingRule.Metadata,
+		},
+		Pipeline: run.Report,
+		Patches:  run.Patches,
+	}
+	checkOwnership(ctx, plan, existingRule)
+	return plan, nil
+}
+
+// findByUniqueness returns the rules of a template whose uniqueness keys have the values of params.
+// The "target" key stands for every string field of the target. Target fields the stores cannot
+// address (keys holding '.' or starting with '$', see database.AddressableKey) cannot be matched,
+// so rules with such targets match no other rule.
+func (s *Service) findByUniqueness(ctx context.Context, templateName string, uniquenessKeys []string, params map[string]interface{}) ([]*database.Rule, error) {
 	filter := database.RuleFilter{
 		TemplateName: templateName,
 		Parameters:   make(map[string]string),
@@ -407,9 +701,13 @@ func (s *Service) PlanRuleUpdate(ctx context.Context, id string, templateName st
 
 	for _, key := range uniquenessKeys {
 		if key == "target" {
-			if target, ok := paramsMap["target"].(map[string]interface{}); ok {
+			// Special handling for target: expand all leaf fields
+			if target, ok := params["target"].(map[string]interface{}); ok {
 				for k, v := range target {
 					if strVal, ok := v.(string); ok {
+						if !database.AddressableKey(k) {
+							return nil, nil
+						}
 						filter.Parameters["target."+k] = strVal
 					}
 				}
@@ -417,44 +715,66 @@ func (s *Service) PlanRuleUpdate(ctx context.Context, id string, templateName st
 			continue
 		}
 
-		val, found := getValueByPath(paramsMap, key)
+		// Handle dot notation (e.g., "rules.rule_type", "common.severity")
+		val, found := getValueByPath(params, key)
 		if found && val != "" {
 			filter.Parameters[key] = val
 		}
 	}
 
-	// 6. Search for existing rules
-	existingRules, err := s.ruleStore.SearchRules(ctx, filter)
+	existing, err := s.ruleStore.SearchRules(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for existing rules: %w", err)
 	}
+	return existing, nil
+}
 
-	// 7. Check for conflicts (exclude current ID)
-	for _, rule := range existingRules {
-		if rule.ID != id {
-			return &RulePlan{
-				Action:       "conflict",
-				Reason:       fmt.Sprintf("Rule with same uniqueness constraints (%v) already exists (ID: %s)", uniquenessKeys, rule.ID),
-				ExistingRule: rule,
-				NewRule: &database.Rule{
-					ID:           id,
-					TemplateName: templateName,
-					Parameters:   finalParamsJSON,
-				},
-			}, nil
+// PlanRuleUpsert simulates the idempotent upsert of the rule of a template with a client-supplied
+// name, in the tenant of the caller. A rule with the name has its parameters replaced (not merged), and reports "no_change"
+// when they are already equal. Without one, the plan is a creation; when the parameters match an
+// unnamed rule by uniqueness keys, that rule is updated and takes the name, and a rule with
+// another name is a conflict.
+func (s *Service) PlanRuleUpsert(ctx context.Context, templateName, name string, parameters json.RawMessage) (*RulePlan, error) {
+	existingRule, err := s.ruleStore.GetRuleByName(ctx, TenantFromContext(ctx), templateName, name)
+	if err != nil && !errors.Is(err, database.ErrRuleNotFound) {
+		return nil, fmt.Errorf("failed to get rule by name: %w", err)
+	}
+
+	if existingRule == nil {
+		plan, err := s.PlanRuleCreation(ctx, templateName, parameters)
+		if err != nil {
+			return nil, err
 		}
+		plan.NewRule.Name = name
+		if plan.Action == "update" && plan.ExistingRule.Name != "" {
+			plan.Action = "conflict"
+			plan.Reason = fmt.Sprintf("Rule with same uniqueness constraints already exists with name '%s' (ID: %s)", plan.ExistingRule.Name, plan.ExistingRule.ID)
+		}
+		return plan, nil
 	}
 
-	// No conflict -> Update
-	return &RulePlan{
-		Action: "update",
-		Reason: "No conflict found",
-		NewRule: &database.Rule{
-			ID:           id,
-			TemplateName: templateName,
-			Parameters:   finalParamsJSON,
-		},
-	}, nil
+	plan, err := s.planRuleChange(ctx, existingRule, templateName, parameters)
+	if err != nil {
+		return nil, err
+	}
+	if plan.ExistingRule == nil {
+		// Conflicts keep the conflicting rule
+		plan.ExistingRule = existingRule
+	}
+	if plan.Action == "update" && sameJSON(existingRule.Parameters, plan.NewRule.Parameters) {
+		plan.Action = "no_change"
+		plan.Reason = "Rule already has these parameters"
+	}
+	return plan, nil
+}
+
+// sameJSON reports whether two JSON documents hold the same values, whatever their formatting.
+func sameJSON(a, b json.RawMessage) bool {
+	var av, bv any
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
 }
 
 // getValueByPath extracts a string value from a map using dot notation.
@@ -4,7 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"rulemanager/internal/database"
+	"rulemanager/internal/validation"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -79,6 +83,14 @@ func (m *MockRuleStore) GetRule(ctx context.Context, id string) (*database.Rule,
 	return args.Get(0).(*database.Rule), args.Error(1)
 }
 
+func (m *MockRuleStore) GetRuleByName(ctx context.Context, tenant, templateName, name string) (*database.Rule, error) {
+	args := m.Called(ctx, tenant, templateName, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*database.Rule), args.Error(1)
+}
+
 func (m *MockRuleStore) ListRules(ctx context.Context, offset, limit int) ([]*database.Rule, error) {
 	args := m.Called(ctx, offset, limit)
 	return args.Get(0).([]*database.Rule), args.Error(1)
@@ -99,6 +111,16 @@ func (m *MockRuleStore) SearchRules(ctx context.Context, filter database.RuleFil
 	return args.Get(0).([]*database.Rule), args.Error(1)
 }
 
+func (m *MockRuleStore) SearchRulesPage(ctx context.Context, filter database.RuleFilter, page database.PageRequest) (*database.RulePage, error) {
+	args := m.Called(ctx, filter, page)
+	return args.Get(0).(*database.RulePage), args.Error(1)
+}
+
+func (m *MockRuleStore) FacetRules(ctx context.Context, filter database.RuleFilter, fields []string, limit int) (*database.RuleFacets, error) {
+	args := m.Called(ctx, filter, fields, limit)
+	return args.Get(0).(*database.RuleFacets), args.Error(1)
+}
+
 func TestService_GenerateRule(t *testing.T) {
 	// Setup
 	mockTP := new(MockTemplateProvider)
@@ -222,7 +244,7 @@ func TestService_ValidateRule(t *testing.T) {
 		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
 		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
 
-		err := service.ValidateRule(ctx, templateName, params)
+		_, err := service.ValidateRule(ctx, templateName, params)
 
 		assert.NoError(t, err)
 		mockTP.AssertExpectations(t)
@@ -238,7 +260,7 @@ func TestService_ValidateRule(t *testing.T) {
 		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
 		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
 
-		err := service.ValidateRule(ctx, templateName, params)
+		_, err := service.ValidateRule(ctx, templateName, params)
 
 		assert.NoError(t, err)
 		mockTP.AssertExpectations(t)
@@ -248,7 +270,7 @@ func TestService_ValidateRule(t *testing.T) {
 	t.Run("SchemaError", func(t *testing.T) {
 		mockTP.On("GetSchema", ctx, templateName).Return("", errors.New("schema error")).Once()
 
-		err := service.ValidateRule(ctx, templateName, params)
+		_, err := service.ValidateRule(ctx, templateName, params)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "schema error")
@@ -260,7 +282,7 @@ func TestService_ValidateRule(t *testing.T) {
 		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
 		mockVal.On("Validate", schema, []byte(params)).Return(errors.New("validation failed")).Once()
 
-		err := service.ValidateRule(ctx, templateName, params)
+		_, err := service.ValidateRule(ctx, templateName, params)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "validation failed")
@@ -273,7 +295,7 @@ func TestService_ValidateRule(t *testing.T) {
 		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
 		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
 
-		err := service.ValidateRule(ctx, templateName, params)
+		_, err := service.ValidateRule(ctx, templateName, params)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to parse schema")
@@ -374,6 +396,46 @@ func TestService_PlanRuleCreation(t *testing.T) {
 		mockTP.AssertExpectations(t)
 		mockRS.AssertExpectations(t)
 	})
+	t.Run("PipelineReport", func(t *testing.T) {
+		schema := `{
+			"type": "object",
+			"uniqueness_keys": ["target.namespace"],
+			"pipelines": [
+				{"name": "global_check", "type": "dummy_always_pass", "parameters": {}}
+			],
+			"properties": {
+				"rules": {
+					"items": {
+						"oneOf": [
+							{
+								"properties": {"rule_type": {"const": "cpu"}},
+								"pipelines": [
+									{"name": "cpu_metric", "type": "validate_metric_exists", "parameters": {"metric_name": "cpu"}}
+								]
+							}
+						]
+					}
+				}
+			}
+		}`
+		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
+		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
+		mockRS.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+
+		plan, err := service.PlanRuleCreation(ctx, templateName, params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "create", plan.Action)
+		assert.NotNil(t, plan.Pipeline)
+		assert.Len(t, plan.Pipeline.Steps, 2)
+		assert.Equal(t, "global", plan.Pipeline.Steps[0].Scope)
+		assert.Equal(t, StepStatusPassed, plan.Pipeline.Steps[0].Status)
+		assert.Equal(t, "rules[0] (cpu)", plan.Pipeline.Steps[1].Scope)
+		assert.Equal(t, StepStatusFailed, plan.Pipeline.Steps[1].Status)
+		assert.False(t, plan.Pipeline.Passed())
+		mockTP.AssertExpectations(t)
+		mockRS.AssertExpectations(t)
+	})
 }
 
 func TestService_PlanRuleUpdate(t *testing.T) {
@@ -394,6 +456,7 @@ func TestService_PlanRuleUpdate(t *testing.T) {
 		ID:           ruleID,
 		TemplateName: templateName,
 		Parameters:   existingParams,
+		Metadata:     &database.RuleMetadata{Team: "payments"},
 	}
 
 	t.Run("Update_NoConflict", func(t *testing.T) {
@@ -423,6 +486,7 @@ func TestService_PlanRuleUpdate(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, "update", plan.Action)
+		assert.Equal(t, existingRule.Metadata, plan.NewRule.Metadata, "metadata is kept")
 		mockTP.AssertExpectations(t)
 		mockRS.AssertExpectations(t)
 	})
@@ -458,3 +522,315 @@ func TestService_PlanRuleUpdate(t *testing.T) {
 		mockRS.AssertExpectations(t)
 	})
 }
+
+func TestService_ValidateRule_RenderedExpression(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == `up{job="cpu"}` {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"cpu"},"value":[1,"1"]}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer ts.Close()
+
+	mockTP := new(MockTemplateProvider)
+	mockVal := new(MockSchemaValidator)
+	mockRS := new(MockRuleStore)
+	service := NewService(mockTP, mockRS, mockVal)
+	ctx := context.Background()
+
+	templateName := "test_template"
+	schema := `{
+		"type": "object",
+		"datasource": {"type": "prometheus", "url": "` + ts.URL + `"},
+		"properties": {
+			"rules": {
+				"items": {
+					"oneOf": [
+						{"properties": {"rule_type": {"const": "cpu"}}, "pipelines": [{"name": "expr", "type": "validate_expression", "parameters": {}}]},
+						{"properties": {"rule_type": {"const": "ram"}}, "pipelines": [{"name": "expr", "type": "validate_expression", "parameters": {}}]}
+					]
+				}
+			}
+		}
+	}`
+	tmpl := "{{ range .rules }}- alert: {{ .rule_type }}\n  expr: up{job=\"{{ .rule_type }}\"} > 0\n{{ end }}"
+	params := json.RawMessage(`{"rules": [{"rule_type": "cpu"}, {"rule_type": "ram"}]}`)
+
+	mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
+	mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
+	mockTP.On("GetTemplate", mock.Anything, templateName).Return(tmpl, nil).Twice() // rendered under the pipeline deadline
+
+	report, err := service.ValidateRule(ctx, templateName, params)
+
+	assert.Error(t, err)
+	assert.Len(t, report.Steps, 2)
+	// Each per-rule step renders and queries only its own rule item
+	assert.Equal(t, StepStatusPassed, report.Steps[0].Status)
+	assert.Equal(t, "cpu: 1 series", report.Steps[0].Message)
+	assert.Equal(t, StepStatusFailed, report.Steps[1].Status)
+	assert.Contains(t, report.Steps[1].Message, `rule 'ram': query returned no data: up{job="ram"}`)
+	mockTP.AssertExpectations(t)
+}
+
+func TestService_PlanRuleUpsert(t *testing.T) {
+	templateName := "test_template"
+	schema := `{"type": "object", "uniqueness_keys": ["target.namespace"]}`
+	params := json.RawMessage(`{"target": {"namespace": "test"}, "rules": [{"rule_type": "cpu"}]}`)
+
+	setup := func(byName *database.Rule, matches []*database.Rule) *Service {
+		mockTP := new(MockTemplateProvider)
+		mockVal := new(MockSchemaValidator)
+		mockRS := new(MockRuleStore)
+		if byName != nil {
+			mockRS.On("GetRuleByName", mock.Anything, "", templateName, "cpu-high").Return(byName, nil)
+		} else {
+			mockRS.On("GetRuleByName", mock.Anything, "", templateName, "cpu-high").Return(nil, database.ErrRuleNotFound)
+		}
+		mockTP.On("GetSchema", mock.Anything, templateName).Return(schema, nil)
+		mockVal.On("Validate", schema, mock.Anything).Return(nil)
+		mockRS.On("SearchRules", mock.Anything, mock.Anything).Return(matches, nil)
+		return NewService(mockTP, mockRS, mockVal)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		plan, err := setup(nil, nil).PlanRuleUpsert(context.Background(), templateName, "cpu-high", params)
+		assert.NoError(t, err)
+		assert.Equal(t, "create", plan.Action)
+		assert.Equal(t, "cpu-high", plan.NewRule.Name)
+	})
+
+	t.Run("AdoptUnnamed", func(t *testing.T) {
+		unnamed := &database.Rule{ID: "r1", TemplateName: templateName, Parameters: params}
+		plan, err := setup(nil, []*database.Rule{unnamed}).PlanRuleUpsert(context.Background(), templateName, "cpu-high", params)
+		assert.NoError(t, err)
+		assert.Equal(t, "update", plan.Action)
+		assert.Equal(t, unnamed, plan.ExistingRule)
+	})
+
+	t.Run("OtherName", func(t *testing.T) {
+		named := &database.Rule{ID: "r1", Name: "cpu-warning", TemplateName: templateName, Parameters: params}
+		plan, err := setup(nil, []*database.Rule{named}).PlanRuleUpsert(context.Background(), templateName, "cpu-high", params)
+		assert.NoError(t, err)
+		assert.Equal(t, "conflict", plan.Action)
+		assert.Contains(t, plan.Reason, "cpu-warning")
+	})
+
+	t.Run("NoChange", func(t *testing.T) {
+		existing := &database.Rule{ID: "r1", Name: "cpu-high", TemplateName: templateName, Parameters: json.RawMessage(`{"rules": [{"rule_type": "cpu"}], "target": {"namespace": "test"}}`)}
+		plan, err := setup(existing, []*database.Rule{existing}).PlanRuleUpsert(context.Background(), templateName, "cpu-high", params)
+		assert.NoError(t, err)
+		assert.Equal(t, "no_change", plan.Action)
+		assert.Equal(t, existing, plan.ExistingRule)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		existing := &database.Rule{
+			ID:           "r1",
+			Name:         "cpu-high",
+			TemplateName: templateName,
+			Parameters:   json.RawMessage(`{"target": {"namespace": "test"}, "common": {"severity": "critical"}, "rules": [{"rule_type": "cpu"}]}`),
+		}
+		plan, err := setup(existing, []*database.Rule{existing}).PlanRuleUpsert(context.Background(), templateName, "cpu-high", params)
+		assert.NoError(t, err)
+		assert.Equal(t, "update", plan.Action)
+		assert.Equal(t, "cpu-high", plan.NewRule.Name)
+		assert.JSONEq(t, string(params), string(plan.NewRule.Parameters), "parameters are replaced, not merged")
+	})
+}
+
+func TestService_PlanRuleCreation_TargetKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+	mockTP := new(MockTemplateProvider)
+	mockTP.On("GetSchema", mock.Anything, "k8s").Return(`{"type": "object"}`, nil)
+	service := NewService(mockTP, store, validation.NewJSONSchemaValidator())
+
+	// Target keys are chosen by clients and need not be valid search paths
+	params := json.RawMessage(`{"target": {"app/name": "checkout", "namespace": "payments"}, "rules": [{"rule_type": "cpu"}]}`)
+	plan, err := service.PlanRuleCreation(ctx, "k8s", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "create", plan.Action)
+
+	assert.NoError(t, store.CreateRule(ctx, &database.Rule{ID: "r1", TemplateName: "k8s", Parameters: params}))
+	plan, err = service.PlanRuleCreation(ctx, "k8s", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "update", plan.Action, "keys the stores can address take part in uniqueness")
+
+	other := json.RawMessage(`{"target": {"app/name": "search", "namespace": "payments"}, "rules": [{"rule_type": "cpu"}]}`)
+	plan, err = service.PlanRuleCreation(ctx, "k8s", other)
+	assert.NoError(t, err)
+	assert.Equal(t, "create", plan.Action)
+
+	// Keys holding the path separator cannot be matched, so such rules never collide
+	dotted := json.RawMessage(`{"target": {"k8s.io/app": "checkout"}, "rules": [{"rule_type": "cpu"}]}`)
+	assert.NoError(t, store.CreateRule(ctx, &database.Rule{ID: "r2", TemplateName: "k8s", Parameters: dotted}))
+	plan, err = service.PlanRuleCreation(ctx, "k8s", dotted)
+	assert.NoError(t, err)
+	assert.Equal(t, "create", plan.Action)
+
+	plan, err = service.PlanRuleUpdate(ctx, "r1", "k8s", json.RawMessage(`{"common": {"severity": "critical"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "update", plan.Action)
+}
+
+func TestService_PlanRuleCreation_Patches(t *testing.T) {
+	mockTP := new(MockTemplateProvider)
+	mockVal := new(MockSchemaValidator)
+	mockRS := new(MockRuleStore)
+	service := NewService(mockTP, mockRS, mockVal)
+	ctx := context.Background()
+
+	templateName := "test_template"
+	schema := `{
+		"type": "object",
+		"uniqueness_keys": ["target.namespace", "common.labels.team"],
+		"pipelines": [
+			{"name": "team_from_namespace", "type": "patch_parameters", "parameters": {"patch": [
+				{"op": "add", "path": "/common", "value": {"labels": {"team": "team-{{ .target.namespace }}"}}}
+			]}}
+		],
+		"properties": {
+			"rules": {
+				"items": {
+					"oneOf": [
+						{
+							"properties": {"rule_type": {"const": "cpu"}},
+							"pipelines": [
+								{"name": "default_threshold", "type": "patch_parameters", "parameters": {"patch": [{"op": "add", "path": "/threshold", "value": 0.8}]}}
+							]
+						}
+					]
+				}
+			}
+		}
+	}`
+	params := json.RawMessage(`{"target": {"namespace": "payments"}, "rules": [{"rule_type": "cpu"}]}`)
+	enriched := `{"target": {"namespace": "payments"}, "common": {"labels": {"team": "team-payments"}}, "rules": [{"rule_type": "cpu", "threshold": 0.8}]}`
+
+	t.Run("AppliesPatchesInOrder", func(t *testing.T) {
+		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
+		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
+		mockVal.On("Validate", schema, mock.MatchedBy(func(b []byte) bool { return jsonEqual(enriched, string(b)) })).Return(nil).Once()
+		expectedFilter := database.RuleFilter{
+			TemplateName: templateName,
+			Parameters:   map[string]string{"target.namespace": "payments", "common.labels.team": "team-payments"},
+		}
+		mockRS.On("SearchRules", ctx, expectedFilter).Return([]*database.Rule{}, nil).Once()
+
+		plan, err := service.PlanRuleCreation(ctx, templateName, params)
+
+		assert.NoError(t, err)
+		assert.True(t, plan.Pipeline.Passed())
+		assert.JSONEq(t, enriched, string(plan.NewRule.Parameters))
+		assert.Len(t, plan.Patches, 2)
+		assert.Equal(t, "team_from_namespace", plan.Patches[0].Step)
+		assert.Equal(t, "global", plan.Patches[0].Scope)
+		assert.Equal(t, "rules[0] (cpu)", plan.Patches[1].Scope)
+		mockVal.AssertExpectations(t)
+		mockRS.AssertExpectations(t)
+	})
+
+	t.Run("PerRulePatchOfRootField", func(t *testing.T) {
+		ruleSchema := `{
+			"type": "object",
+			"properties": {"rules": {"items": {"oneOf": [{
+				"properties": {"rule_type": {"const": "cpu"}},
+				"pipelines": [
+					{"name": "owner_and_severity", "type": "patch_parameters", "parameters": {"patch": [
+						{"op": "add", "path": "/target/owner", "value": "team-{{ .target.namespace }}"},
+						{"op": "add", "path": "/severity", "value": "critical"}
+					]}}
+				]
+			}]}}}
+		}`
+		params := json.RawMessage(`{"target": {"namespace": "payments"}, "rules": [{"rule_type": "memory"}, {"rule_type": "cpu", "threshold": 0.9}]}`)
+		enriched := `{"target": {"namespace": "payments", "owner": "team-payments"}, "rules": [{"rule_type": "memory"}, {"rule_type": "cpu", "threshold": 0.9, "severity": "critical"}]}`
+		mockTP.On("GetSchema", ctx, templateName).Return(ruleSchema, nil).Once()
+		mockVal.On("Validate", ruleSchema, []byte(params)).Return(nil).Once()
+		mockVal.On("Validate", ruleSchema, mock.MatchedBy(func(b []byte) bool { return jsonEqual(enriched, string(b)) })).Return(nil).Once()
+		mockRS.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+
+		plan, err := service.PlanRuleCreation(ctx, templateName, params)
+
+		assert.NoError(t, err)
+		assert.True(t, plan.Pipeline.Passed(), plan.Pipeline.Err())
+		assert.JSONEq(t, enriched, string(plan.NewRule.Parameters), "root paths patch the root, rule paths the rule item")
+		if assert.Len(t, plan.Patches, 1) {
+			assert.Equal(t, "rules[1] (cpu)", plan.Patches[0].Scope)
+			assert.JSONEq(t, `[{"op": "add", "path": "/target/owner", "value": "team-payments"}, {"op": "add", "path": "/rules/1/severity", "value": "critical"}]`, string(plan.Patches[0].Patch))
+		}
+		mockVal.AssertExpectations(t)
+	})
+
+	t.Run("InvalidAfterPatch", func(t *testing.T) {
+		mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
+		mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
+		mockVal.On("Validate", schema, mock.Anything).Return(errors.New("threshold must be <= 0.5")).Once()
+
+		_, err := service.PlanRuleCreation(ctx, templateName, params)
+
+		assert.ErrorContains(t, err, "parameters are invalid after applying pipeline patches: threshold must be <= 0.5")
+	})
+
+	t.Run("UnappliablePatchFailsStep", func(t *testing.T) {
+		badSchema := `{
+			"type": "object",
+			"pipelines": [
+				{"name": "bad_patch", "type": "patch_parameters", "parameters": {"patch": [{"op": "remove", "path": "/does/not/exist"}]}}
+			]
+		}`
+		mockTP.On("GetSchema", ctx, templateName).Return(badSchema, nil).Once()
+		mockVal.On("Validate", badSchema, []byte(params)).Return(nil).Once()
+		mockRS.On("SearchRules", ctx, mock.AnythingOfType("database.RuleFilter")).Return([]*database.Rule{}, nil).Once()
+
+		plan, err := service.PlanRuleCreation(ctx, templateName, params)
+
+		assert.NoError(t, err)
+		assert.False(t, plan.Pipeline.Passed())
+		assert.Contains(t, plan.Pipeline.Steps[0].Message, "failed to apply patch")
+		assert.Empty(t, plan.Patches)
+		assert.Equal(t, params, plan.NewRule.Parameters)
+	})
+}
+
+// jsonEqual reports whether two JSON documents are semantically equal.
+func jsonEqual(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestService_GlobalPolicies(t *testing.T) {
+	mockTP := new(MockTemplateProvider)
+	mockVal := new(MockSchemaValidator)
+	mockRS := new(MockRuleStore)
+	policies := &PolicySet{Global: []Policy{
+		{Name: "not_default_namespace", Expression: `params.target.namespace != "default"`, Message: "rules must not target the default namespace"},
+		{Name: "severity_set", Expression: `has(params.common) && has(params.common.severity)`, OnFailure: OnFailureWarn},
+	}}
+	service := NewService(mockTP, mockRS, mockVal, WithPolicies(policies))
+	ctx := context.Background()
+
+	templateName := "test_template"
+	schema := `{"type": "object"}`
+	params := json.RawMessage(`{"target": {"namespace": "default"}, "rules": [{"rule_type": "cpu"}]}`)
+
+	mockTP.On("GetSchema", ctx, templateName).Return(schema, nil).Once()
+	mockVal.On("Validate", schema, []byte(params)).Return(nil).Once()
+
+	report, err := service.ValidateRule(ctx, templateName, params)
+
+	assert.ErrorContains(t, err, "rules must not target the default namespace")
+	assert.Len(t, report.Steps, 2)
+	assert.Equal(t, "not_default_namespace", report.Steps[0].Name)
+	assert.Equal(t, "policy", report.Steps[0].Scope)
+	assert.Equal(t, StepStatusFailed, report.Steps[0].Status)
+	assert.Equal(t, "severity_set", report.Steps[1].Name)
+	assert.Equal(t, StepStatusWarning, report.Steps[1].Status)
+	// The template is only rendered if a policy uses `rendered`
+	mockTP.AssertNotCalled(t, "GetTemplate", mock.Anything, templateName)
+}
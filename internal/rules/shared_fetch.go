@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sharedFetches runs one fetch per key for all concurrent callers of the key. The fetch runs
+// detached from the callers' contexts, so that one caller giving up does not fail the others; it is
+// bounded by timeout and canceled as soon as every caller waiting for it has given up.
+type sharedFetches[T any] struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*sharedCall[T]
+}
+
+type sharedCall[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do returns the result of fetch for key, joining the fetch in flight for the key if there is one.
+// It returns early with the error of ctx when ctx is done.
+func (g *sharedFetches[T]) do(ctx context.Context, key string, fetch func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		if g.calls == nil {
+			g.calls = make(map[string]*sharedCall[T])
+		}
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), g.timeout)
+		call = &sharedCall[T]{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			call.value, call.err = fetch(fetchCtx)
+			cancel()
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Later callers start a new fetch rather than joining the canceled one
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"rulemanager/config"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// DefaultSubprocessTimeout bounds a single run of an external step runner.
+const DefaultSubprocessTimeout = 10 * time.Second
+
+// SubprocessRequest is the JSON document written to the stdin of an external step runner.
+type SubprocessRequest struct {
+	Type           string            `json:"type"`                 // step type the runner is registered as
+	Parameters     json.RawMessage   `json:"parameters"`           // step parameters, validated against the declared schema
+	RuleParameters json.RawMessage   `json:"rule_parameters"`      // rule parameters (the current rule item merged with root for per-rule steps)
+	Rule           string            `json:"rule,omitempty"`       // rendered rule YAML, if the template is available
+	Datasource     *DatasourceConfig `json:"datasource,omitempty"` // datasource of the template, if any
+}
+
+// SubprocessRunner runs a pipeline step by executing an external program. The program reads a
+// SubprocessRequest from stdin and writes a verdict (the same document as the http_webhook step's
+// WebhookVerdict) to stdout. A non-zero exit status fails the step with the program's stderr.
+type SubprocessRunner struct {
+	Type    string
+	Command string
+	Args    []string
+	Env     []string
+	Timeout time.Duration
+
+	schema *gojsonschema.Schema
+}
+
+// NewSubprocessRunners builds the external step runners registered in the configuration, keyed by
+// step type. Names must be unique and must not shadow built-in step types; every parameter schema
+// is compiled up front so misconfigurations are reported at startup.
+func NewSubprocessRunners(cfgs []config.RunnerConfig) (map[string]StepRunner, error) {
+	builtin := NewPipelineProcessor().runners
+	runners := make(map[string]StepRunner, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("runner name is required")
+		}
+		if _, ok := builtin[cfg.Name]; ok {
+			return nil, fmt.Errorf("runner '%s': name conflicts with a built-in step type", cfg.Name)
+		}
+		if _, ok := runners[cfg.Name]; ok {
+			return nil, fmt.Errorf("runner '%s' is registered more than once", cfg.Name)
+		}
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("runner '%s': command is required", cfg.Name)
+		}
+		if cfg.ParametersSchema == "" {
+			return nil, fmt.Errorf("runner '%s': parameters_schema is required", cfg.Name)
+		}
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(cfg.ParametersSchema))
+		if err != nil {
+			return nil, fmt.Errorf("runner '%s': invalid parameters_schema: %w", cfg.Name, err)
+		}
+
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultSubprocessTimeout
+		}
+		runners[cfg.Name] = &SubprocessRunner{
+			Type:    cfg.Name,
+			Command: cfg.Command,
+			Args:    cfg.Args,
+			Env:     cfg.Env,
+			Timeout: timeout,
+			schema:  schema,
+		}
+	}
+	return runners, nil
+}
+
+// Run validates the step parameters, executes the program and converts its verdict.
+func (r *SubprocessRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	params := input.StepParams
+	if len(params) == 0 {
+		params = json.RawMessage(`{}`)
+	}
+	if err := r.validateParameters(params); err != nil {
+		return nil, err
+	}
+
+	request := SubprocessRequest{Type: r.Type, Parameters: params, RuleParameters: input.RuleParams, Datasource: input.Datasource}
+	if input.RenderRule != nil {
+		var err error
+		if request.Rule, err = input.RenderRule(); err != nil {
+			return nil, fmt.Errorf("failed to render rule: %w", err)
+		}
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.Command, r.Args...)
+	cmd.Env = append(os.Environ(), r.Env...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait for stray children holding the output pipes open after the program is killed
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("runner timed out after %s", r.Timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("runner exited with status %d: %s", exitErr.ExitCode(), truncate(strings.TrimSpace(stderr.String()), 512))
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", r.Command, err)
+	}
+
+	var verdict WebhookVerdict
+	if err := json.Unmarshal(stdout.Bytes(), &verdict); err != nil {
+		return nil, fmt.Errorf("failed to decode runner verdict: %w", err)
+	}
+	if !verdict.Allowed {
+		if verdict.Message == "" {
+			return nil, fmt.Errorf("rejected by runner")
+		}
+		return nil, fmt.Errorf("rejected by runner: %s", verdict.Message)
+	}
+
+	return &StepOutput{Message: verdict.Message, Warnings: verdict.Warnings, Patch: verdict.Patch}, nil
+}
+
+// validateParameters checks the step parameters against the schema declared for the runner.
+func (r *SubprocessRunner) validateParameters(params json.RawMessage) error {
+	if r.schema == nil {
+		return nil
+	}
+	result, err := r.schema.Validate(gojsonschema.NewBytesLoader(params))
+	if err != nil {
+		return fmt.Errorf("invalid step parameters: %w", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+	msgs := make([]string, 0, len(result.Errors()))
+	for _, desc := range result.Errors() {
+		msgs = append(msgs, desc.String())
+	}
+	return fmt.Errorf("invalid step parameters: %s", strings.Join(msgs, "; "))
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rulemanager/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeScript creates an executable shell script in a temporary directory.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "runner.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSubprocessRunner_Run(t *testing.T) {
+	schema := `{"type": "object", "properties": {"topic": {"type": "string"}}, "required": ["topic"]}`
+	newRunner := func(t *testing.T, script string, timeout time.Duration) StepRunner {
+		runners, err := NewSubprocessRunners([]config.RunnerConfig{{
+			Name:             "kafka_topic_exists",
+			Command:          writeScript(t, script),
+			Env:              []string{"RUNNER_MODE=test"},
+			Timeout:          timeout,
+			ParametersSchema: schema,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runners["kafka_topic_exists"]
+	}
+	input := func(params string) *StepInput {
+		return &StepInput{
+			RuleParams: json.RawMessage(`{"target": {"namespace": "payments"}}`),
+			StepParams: json.RawMessage(params),
+			RenderRule: func() (string, error) { return "- alert: Test\n  expr: up == 0\n", nil },
+		}
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "request.json")
+		runner := newRunner(t, `cat > `+out+`
+echo '{"allowed": true, "message": "'"$RUNNER_MODE"'", "warnings": ["topic has 1 partition"], "patch": [{"op": "add", "path": "/target/cluster", "value": "eu-1"}]}'
+`, 0)
+
+		output, err := runner.Run(context.Background(), input(`{"topic": "orders"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "test", output.Message)
+		assert.Equal(t, []string{"topic has 1 partition"}, output.Warnings)
+		assert.JSONEq(t, `[{"op": "add", "path": "/target/cluster", "value": "eu-1"}]`, string(output.Patch))
+
+		raw, err := os.ReadFile(out)
+		assert.NoError(t, err)
+		var request SubprocessRequest
+		assert.NoError(t, json.Unmarshal(raw, &request))
+		assert.Equal(t, "kafka_topic_exists", request.Type)
+		assert.JSONEq(t, `{"topic": "orders"}`, string(request.Parameters))
+		assert.JSONEq(t, `{"target": {"namespace": "payments"}}`, string(request.RuleParameters))
+		assert.Contains(t, request.Rule, "alert: Test")
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		runner := newRunner(t, `echo '{"allowed": false, "message": "topic does not exist"}'`, 0)
+
+		_, err := runner.Run(context.Background(), input(`{"topic": "orders"}`))
+
+		assert.EqualError(t, err, "rejected by runner: topic does not exist")
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		runner := newRunner(t, `echo '{"allowed": true}'`, 0)
+
+		_, err := runner.Run(context.Background(), input(`{"topic": 1}`))
+
+		assert.ErrorContains(t, err, "invalid step parameters: topic: Invalid type")
+	})
+
+	t.Run("NonZeroExit", func(t *testing.T) {
+		runner := newRunner(t, "echo 'broker unreachable' >&2\nexit 3\n", 0)
+
+		_, err := runner.Run(context.Background(), input(`{"topic": "orders"}`))
+
+		assert.EqualError(t, err, "runner exited with status 3: broker unreachable")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		runner := newRunner(t, "sleep 5\n", 50*time.Millisecond)
+
+		start := time.Now()
+		_, err := runner.Run(context.Background(), input(`{"topic": "orders"}`))
+
+		assert.EqualError(t, err, "runner timed out after 50ms")
+		assert.Less(t, time.Since(start), 3*time.Second)
+	})
+
+	t.Run("InvalidVerdict", func(t *testing.T) {
+		runner := newRunner(t, "echo 'ok'\n", 0)
+
+		_, err := runner.Run(context.Background(), input(`{"topic": "orders"}`))
+
+		assert.ErrorContains(t, err, "failed to decode runner verdict")
+	})
+}
+
+func TestNewSubprocessRunners(t *testing.T) {
+	valid := config.RunnerConfig{Name: "custom", Command: "/bin/true", ParametersSchema: `{"type": "object"}`}
+
+	tests := []struct {
+		name    string
+		runners []config.RunnerConfig
+		wantErr string
+	}{
+		{name: "Valid", runners: []config.RunnerConfig{valid}},
+		{name: "MissingName", runners: []config.RunnerConfig{{Command: "/bin/true", ParametersSchema: `{}`}}, wantErr: "runner name is required"},
+		{name: "BuiltinConflict", runners: []config.RunnerConfig{{Name: "cel", Command: "/bin/true", ParametersSchema: `{}`}}, wantErr: "runner 'cel': name conflicts with a built-in step type"},
+		{name: "Duplicate", runners: []config.RunnerConfig{valid, valid}, wantErr: "runner 'custom' is registered more than once"},
+		{name: "MissingCommand", runners: []config.RunnerConfig{{Name: "custom", ParametersSchema: `{}`}}, wantErr: "runner 'custom': command is required"},
+		{name: "MissingSchema", runners: []config.RunnerConfig{{Name: "custom", Command: "/bin/true"}}, wantErr: "runner 'custom': parameters_schema is required"},
+		{name: "InvalidSchema", runners: []config.RunnerConfig{{Name: "custom", Command: "/bin/true", ParametersSchema: `{"type": 1}`}}, wantErr: "runner 'custom': invalid parameters_schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runners, err := NewSubprocessRunners(tt.runners)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, DefaultSubprocessTimeout, runners["custom"].(*SubprocessRunner).Timeout)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmalert/config"
+	"github.com/VictoriaMetrics/metricsql"
+	"gopkg.in/yaml.v3"
+)
+
+// Query types supported by the validate_expression pipeline step.
+const (
+	QueryTypeInstant = "instant"
+	QueryTypeRange   = "range"
+)
+
+// ValidateExpressionParams defines parameters for the validate_expression pipeline step.
+type ValidateExpressionParams struct {
+	// Alert restricts the check to rendered rules with this alert (or record) name. Supports templating.
+	// When empty, every rule rendered by the template is checked.
+	Alert string `json:"alert,omitempty"`
+	// QueryType is "instant" (default) or "range".
+	QueryType string `json:"query_type,omitempty"`
+	// Range is the lookback window of range queries (default 1h).
+	Range string `json:"range,omitempty"`
+	// Step is the resolution of range queries (default 1m).
+	Step string `json:"step,omitempty"`
+	// StripComparison queries the expression without its top-level threshold comparison
+	// (e.g. "sum(x) by (pod) > 0.8" is queried as "sum(x) by (pod)"), so the check does not
+	// depend on the alert currently firing. Defaults to true.
+	StripComparison *bool `json:"strip_comparison,omitempty"`
+	// MinSeries is the minimum number of series the query must return (default 1).
+	MinSeries *int `json:"min_series,omitempty"`
+	// MaxSeries is the maximum number of series the query may return (cardinality guard).
+	MaxSeries *int `json:"max_series,omitempty"`
+	// RequireAnnotationLabels asserts that the result labels include every label referenced
+	// in the rule annotations (e.g. {{ $labels.pod }}).
+	RequireAnnotationLabels bool `json:"require_annotation_labels,omitempty"`
+	// RequiredLabels lists additional label keys every result series must carry.
+	RequiredLabels []string `json:"required_labels,omitempty"`
+}
+
+// ValidateExpressionRunner runs the rendered rule expression against the datasource and asserts on the result.
+type ValidateExpressionRunner struct {
+	Client *http.Client
+}
+
+// annotationLabelRefs matches label references in alert annotations: {{ $labels.foo }} and {{ .Labels.foo }}.
+var annotationLabelRefs = regexp.MustCompile(`(?:\$labels|\.Labels)\.([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// Run executes the expression validation step.
+func (r *ValidateExpressionRunner) Run(ctx context.Context, input *StepInput) (*StepOutput, error) {
+	if err := requirePromQLDatasource(input.Datasource); err != nil {
+		return nil, err
+	}
+
+	var params ValidateExpressionParams
+	if len(input.StepParams) > 0 {
+		if err := json.Unmarshal(input.StepParams, &params); err != nil {
+			return nil, fmt.Errorf("invalid step parameters: %w", err)
+		}
+	}
+	if params.QueryType == "" {
+		params.QueryType = QueryTypeInstant
+	}
+	if params.QueryType != QueryTypeInstant && params.QueryType != QueryTypeRange {
+		return nil, fmt.Errorf("invalid query_type: %s (expected instant or range)", params.QueryType)
+	}
+
+	if input.RenderRule == nil {
+		return nil, fmt.Errorf("rendered rule is not available for expression validation")
+	}
+	rendered, err := input.RenderRule()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render rule: %w", err)
+	}
+
+	rules, err := parseRenderedRules(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	alertName := params.Alert
+	if alertName != "" {
+		var ruleData interface{}
+		if err := json.Unmarshal(input.RuleParams, &ruleData); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rule parameters: %w", err)
+		}
+		if alertName, err = renderString(alertName, ruleData); err != nil {
+			return nil, fmt.Errorf("failed to render alert: %w", err)
+		}
+	}
+
+	var summaries []string
+	for _, rule := range rules {
+		if alertName != "" && rule.Name() != alertName {
+			continue
+		}
+		summary, err := r.checkRule(ctx, input, &rule, &params)
+		if err != nil {
+			return nil, fmt.Errorf("rule '%s': %w", rule.Name(), err)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	if len(summaries) == 0 {
+		if alertName != "" {
+			return nil, fmt.Errorf("no rendered rule named '%s'", alertName)
+		}
+		return nil, fmt.Errorf("template rendered no rules")
+	}
+
+	return &StepOutput{Message: strings.Join(summaries, "; ")}, nil
+}
+
+// checkRule queries a single rendered rule and checks the result against the step parameters.
+func (r *ValidateExpressionRunner) checkRule(ctx context.Context, input *StepInput, rule *config.Rule, params *ValidateExpressionParams) (string, error) {
+	query := rule.Expr
+	if params.StripComparison == nil || *params.StripComparison {
+		stripped, err := stripComparison(query)
+		if err != nil {
+			return "", err
+		}
+		query = stripped
+	}
+
+	values := url.Values{"query": {query}}
+	path := "/api/v1/query"
+	if params.QueryType == QueryTypeRange {
+		lookback, step, err := rangeWindow(params)
+		if err != nil {
+			return "", err
+		}
+		end := time.Now()
+		values.Set("start", strconv.FormatInt(end.Add(-lookback).Unix(), 10))
+		values.Set("end", strconv.FormatInt(end.Unix(), 10))
+		values.Set("step", strconv.FormatInt(int64(step.Seconds()), 10))
+		path = "/api/v1/query_range"
+	}
+
+	result, err := input.queryDatasource(ctx, r.Client, path, values)
+	if err != nil {
+		return "", err
+	}
+	series, err := result.Series()
+	if err != nil {
+		return "", err
+	}
+
+	minSeries := 1
+	if params.MinSeries != nil {
+		minSeries = *params.MinSeries
+	}
+	if len(series) < minSeries {
+		if len(series) == 0 {
+			return "", fmt.Errorf("query returned no data: %s", query)
+		}
+		return "", fmt.Errorf("query returned %d series, expected at least %d: %s", len(series), minSeries, query)
+	}
+	if params.MaxSeries != nil && len(series) > *params.MaxSeries {
+		return "", fmt.Errorf("query returned %d series, exceeding the limit of %d: %s", len(series), *params.MaxSeries, query)
+	}
+
+	required := append([]string{}, params.RequiredLabels...)
+	if params.RequireAnnotationLabels {
+		required = append(required, annotationLabels(rule.Annotations)...)
+	}
+	if missing := missingLabels(series, required); len(missing) > 0 {
+		return "", fmt.Errorf("result series are missing label(s) %s: %s", strings.Join(missing, ", "), query)
+	}
+
+	return fmt.Sprintf("%s: %d series", rule.Name(), len(series)), nil
+}
+
+// parseRenderedRules parses rendered template output, either a list of rules or a vmalert groups document.
+func parseRenderedRules(rendered string) ([]config.Rule, error) {
+	var rules []config.Rule
+	if err := yaml.Unmarshal([]byte(rendered), &rules); err == nil && len(rules) > 0 {
+		return rules, nil
+	}
+
+	var groups struct {
+		Groups []struct {
+			Rules []config.Rule `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse rendered rule: %w", err)
+	}
+	for _, g := range groups.Groups {
+		rules = append(rules, g.Rules...)
+	}
+	return rules, nil
+}
+
+// stripComparison removes a top-level comparison against a constant threshold from a query.
+func stripComparison(expr string) (string, error) {
+	parsed, err := metricsql.Parse(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid MetricsQL expression: %w", err)
+	}
+	be, ok := parsed.(*metricsql.BinaryOpExpr)
+	if !ok || be.Bool || !metricsql.IsBinaryOpCmp(be.Op) {
+		return expr, nil
+	}
+	if _, isNumber := be.Right.(*metricsql.NumberExpr); !isNumber {
+		return expr, nil
+	}
+	return string(be.Left.AppendString(nil)), nil
+}
+
+// rangeWindow returns the lookback and resolution of a range query.
+func rangeWindow(params *ValidateExpressionParams) (time.Duration, time.Duration, error) {
+	lookback, step := time.Hour, time.Minute
+	var err error
+	if params.Range != "" {
+		if lookback, err = time.ParseDuration(params.Range); err != nil {
+			return 0, 0, fmt.Errorf("invalid range: %w", err)
+		}
+	}
+	if params.Step != "" {
+		if step, err = time.ParseDuration(params.Step); err != nil {
+			return 0, 0, fmt.Errorf("invalid step: %w", err)
+		}
+	}
+	if step < time.Second {
+		return 0, 0, fmt.Errorf("step must be at least 1s")
+	}
+	return lookback, step, nil
+}
+
+// annotationLabels returns the sorted, de-duplicated label keys referenced in annotations.
+func annotationLabels(annotations map[string]string) []string {
+	seen := make(map[string]bool)
+	var labels []string
+	for _, value := range annotations {
+		for _, match := range annotationLabelRefs.FindAllStringSubmatch(value, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				labels = append(labels, match[1])
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// missingLabels returns the required label keys absent from at least one series.
+func missingLabels(series []promSeries, required []string) []string {
+	var missing []string
+	for _, label := range required {
+		for _, s := range series {
+			if _, ok := s.Metric[label]; !ok {
+				missing = append(missing, label)
+				break
+			}
+		}
+	}
+	return missing
+}
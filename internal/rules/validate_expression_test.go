@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExpressionRunner_Run(t *testing.T) {
+	var lastPath, lastQuery string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastPath = r.URL.Path
+		lastQuery = r.URL.Query().Get("query")
+		switch lastQuery {
+		case `sum(rate(http_requests_total[5m])) by(pod)`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{"pod":"a"},"value":[1,"1"]},{"metric":{"pod":"b"},"value":[1,"2"]}]}}`))
+		case `up{job="api"}`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[1,"1"]]}]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}
+	}))
+	defer ts.Close()
+
+	runner := &ValidateExpressionRunner{Client: ts.Client()}
+	datasource := &DatasourceConfig{Type: "prometheus", URL: ts.URL}
+
+	rendered := `
+- alert: HighRequestRate
+  expr: sum(rate(http_requests_total[5m])) by (pod) > 100
+  annotations:
+    summary: "High request rate on {{ $labels.pod }}"
+- alert: MissingData
+  expr: absent_metric > 1
+  annotations:
+    summary: "{{ $labels.namespace }}"
+`
+	render := func() (string, error) { return rendered, nil }
+
+	tests := []struct {
+		name        string
+		params      string
+		expectError string
+		expectPath  string
+		expectQuery string
+	}{
+		{
+			name:        "Strips Comparison",
+			params:      `{"alert": "HighRequestRate"}`,
+			expectPath:  "/api/v1/query",
+			expectQuery: `sum(rate(http_requests_total[5m])) by(pod)`,
+		},
+		{
+			name:        "Templated Alert Name",
+			params:      `{"alert": "{{ .name }}", "require_annotation_labels": true}`,
+			expectQuery: `sum(rate(http_requests_total[5m])) by(pod)`,
+		},
+		{
+			name:        "No Data",
+			params:      `{"alert": "MissingData"}`,
+			expectError: "query returned no data",
+		},
+		{
+			name:        "Keep Comparison",
+			params:      `{"alert": "HighRequestRate", "strip_comparison": false}`,
+			expectError: "query returned no data",
+			expectQuery: `sum(rate(http_requests_total[5m])) by (pod) > 100`,
+		},
+		{
+			name:        "Max Series Exceeded",
+			params:      `{"alert": "HighRequestRate", "max_series": 1}`,
+			expectError: "exceeding the limit of 1",
+		},
+		{
+			name:        "Min Series Not Reached",
+			params:      `{"alert": "HighRequestRate", "min_series": 3}`,
+			expectError: "expected at least 3",
+		},
+		{
+			name:        "Required Label Missing",
+			params:      `{"alert": "HighRequestRate", "required_labels": ["namespace"]}`,
+			expectError: "missing label(s) namespace",
+		},
+		{
+			name:        "All Rules Checked",
+			params:      `{}`,
+			expectError: "rule 'MissingData'",
+		},
+		{
+			name:        "Unknown Alert",
+			params:      `{"alert": "Nope"}`,
+			expectError: "no rendered rule named 'Nope'",
+		},
+		{
+			name:        "Invalid Query Type",
+			params:      `{"query_type": "sometimes"}`,
+			expectError: "invalid query_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := runner.Run(context.Background(), &StepInput{
+				Datasource: datasource,
+				RuleParams: json.RawMessage(`{"name": "HighRequestRate"}`),
+				StepParams: json.RawMessage(tt.params),
+				RenderRule: render,
+			})
+			if tt.expectError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "HighRequestRate: 2 series", output.Message)
+			}
+			if tt.expectPath != "" {
+				assert.Equal(t, tt.expectPath, lastPath)
+			}
+			if tt.expectQuery != "" {
+				assert.Equal(t, tt.expectQuery, lastQuery)
+			}
+		})
+	}
+
+	t.Run("RangeQueryOnGroups", func(t *testing.T) {
+		groups := func() (string, error) {
+			return "groups:\n- name: g\n  rules:\n  - record: job:up\n    expr: up{job=\"api\"}\n", nil
+		}
+		output, err := runner.Run(context.Background(), &StepInput{
+			Datasource: datasource,
+			StepParams: json.RawMessage(`{"query_type": "range", "range": "30m", "step": "30s"}`),
+			RenderRule: groups,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "/api/v1/query_range", lastPath)
+		assert.Equal(t, "job:up: 1 series", output.Message)
+	})
+
+	t.Run("NoRenderedRule", func(t *testing.T) {
+		_, err := runner.Run(context.Background(), &StepInput{Datasource: datasource})
+		assert.ErrorContains(t, err, "rendered rule is not available")
+	})
+}
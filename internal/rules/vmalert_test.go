@@ -75,6 +75,46 @@ func TestService_GenerateVMAlertConfig(t *testing.T) {
 	})
 }
 
+func TestService_GenerateVMAlertConfig_RuleLabels(t *testing.T) {
+	mockTP := new(MockTemplateProvider)
+	mockVal := new(MockSchemaValidator)
+	service := NewService(mockTP, new(MockRuleStore), mockVal)
+	ctx := context.Background()
+
+	params := json.RawMessage(`{"name": "test"}`)
+	tmplContent := `- alert: {{ .name }}
+  labels:
+    severity: critical
+    {{- range $key, $value := ruleLabels }}
+    {{ $key }}: {{ $value | printf "%q" }}
+    {{- end }}`
+	mockTP.On("GetSchema", ctx, "labelled").Return(`{"type": "object"}`, nil)
+	mockVal.On("Validate", `{"type": "object"}`, []byte(params)).Return(nil)
+	mockTP.On("GetTemplate", ctx, "labelled").Return(tmplContent, nil)
+
+	config, err := service.GenerateVMAlertConfig(ctx, []*database.Rule{
+		{ID: "1", TemplateName: "labelled", Parameters: params, Metadata: &database.RuleMetadata{
+			Team:   "payments",
+			Labels: map[string]string{"tier": "1"},
+		}},
+		{ID: "2", TemplateName: "labelled", Parameters: params},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `groups:
+  - name: labelled
+    rules:
+      - alert: test
+        labels:
+          severity: critical
+          team: "payments"
+          tier: "1"
+      - alert: test
+        labels:
+          severity: critical
+`, config)
+}
+
 func TestService_ValidateTemplate(t *testing.T) {
 	mockTP := new(MockTemplateProvider)
 	mockVal := new(MockSchemaValidator)
@@ -182,4 +222,42 @@ labels:
 
 		assert.Error(t, err) // Alert rules must have an expr
 	})
+
+	t.Run("RuleList", func(t *testing.T) {
+		ruleYaml := `
+- alert: HighCPU
+  expr: sum(rate(cpu_usage[5m])) > 0.9
+- alert: HighMemory
+  expr: sum(memory_usage) > 0.8`
+
+		err := service.ValidateRuleContent(ruleYaml)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("InvalidRuleInList", func(t *testing.T) {
+		ruleYaml := `
+- alert: HighCPU
+  expr: sum(rate(cpu_usage[5m])) > 0.9
+- alert: HighMemory
+  expr: sum(memory_usage`
+
+		err := service.ValidateRuleContent(ruleYaml)
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "rule 1 (HighMemory): invalid MetricsQL expression")
+		}
+	})
+
+	t.Run("GroupsDocument", func(t *testing.T) {
+		ruleYaml := `groups:
+  - name: demo
+    rules:
+      - alert: Test
+        for: 5m`
+
+		err := service.ValidateRuleContent(ruleYaml)
+
+		assert.Error(t, err)
+	})
 }